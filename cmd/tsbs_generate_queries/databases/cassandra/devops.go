@@ -60,7 +60,8 @@ func (d *Devops) getHostWhere(nHosts int) []string {
 // AND time >= '$HOUR_START' AND time < '$HOUR_END'
 // GROUP BY minute ORDER BY minute ASC
 func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange time.Duration) {
-	interval := d.Interval.MustRandWindow(timeRange)
+	interval := d.MustQueryInterval(timeRange)
+	bucket := d.GetSingleGroupByBucket()
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
 	tagSet := d.getHostWhere(nHosts)
@@ -68,11 +69,11 @@ func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange t
 	tagSets := [][]string{}
 	tagSets = append(tagSets, tagSet)
 
-	humanLabel := fmt.Sprintf("Cassandra %d cpu metric(s), random %4d hosts, random %s by 1m", numMetrics, nHosts, timeRange)
+	humanLabel := fmt.Sprintf("Cassandra %d cpu metric(s), random %4d hosts, %s by %s", numMetrics, nHosts, d.WindowLabel(timeRange), devops.ShortDuration(bucket))
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, "max", metrics, interval, tagSets)
 	q := qi.(*query.Cassandra)
-	q.GroupByDuration = time.Minute
+	q.GroupByDuration = bucket
 }
 
 // GroupByOrderByLimit populates a query.Query that has a time WHERE clause, that groups by a truncated date, orders by that date, and takes a limit:
@@ -81,7 +82,7 @@ func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange t
 // GROUP BY t ORDER BY t DESC
 // LIMIT $LIMIT
 func (d *Devops) GroupByOrderByLimit(qi query.Query) {
-	interval := d.Interval.MustRandWindow(time.Hour)
+	interval := d.MustQueryInterval(time.Hour)
 
 	interval, err := utils.NewTimeInterval(d.Interval.Start(), interval.End())
 	if err != nil {
@@ -105,11 +106,11 @@ func (d *Devops) GroupByOrderByLimit(qi query.Query) {
 // WHERE time >= '$HOUR_START' AND time < '$HOUR_END'
 // GROUP BY hour, hostname ORDER BY hour
 func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
-	interval := d.Interval.MustRandWindow(devops.DoubleGroupByDuration)
+	interval := d.MustQueryInterval(devops.DoubleGroupByDuration)
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
 
-	humanLabel := devops.GetDoubleGroupByLabel("Cassandra", numMetrics)
+	humanLabel := d.GetDoubleGroupByLabel("Cassandra", numMetrics)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, "avg", metrics, interval, nil)
 	q := qi.(*query.Cassandra)
@@ -124,14 +125,14 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 // AND time >= '$HOUR_START' AND time < '$HOUR_END'
 // GROUP BY hour ORDER BY hour
 func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
-	interval := d.Interval.MustRandWindow(devops.MaxAllDuration)
+	interval := d.MustQueryInterval(devops.MaxAllDuration)
 
 	tagSet := d.getHostWhere(nHosts)
 
 	tagSets := [][]string{}
 	tagSets = append(tagSets, tagSet)
 
-	humanLabel := devops.GetMaxAllLabel("Cassandra", nHosts)
+	humanLabel := d.GetMaxAllLabel("Cassandra", nHosts)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, "max", devops.GetAllCPUMetrics(), interval, tagSets)
 	q := qi.(*query.Cassandra)
@@ -157,7 +158,7 @@ func (d *Devops) LastPointPerHost(qi query.Query) {
 // AND time >= '$TIME_START' AND time < '$TIME_END'
 // AND (hostname = '$HOST' OR hostname = '$HOST2'...)
 func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
-	interval := d.Interval.MustRandWindow(devops.HighCPUDuration)
+	interval := d.MustQueryInterval(devops.HighCPUDuration)
 
 	tagSet := d.getHostWhere(nHosts)
 
@@ -175,6 +176,25 @@ func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
 	q.WhereClause = []byte("usage_user,>,90.0")
 }
 
+// DownsampleAll selects the AVG of numMetrics metrics under 'cpu' per device
+// per hour over the whole time range of the dataset,
+// e.g. in pseudo-SQL:
+//
+// SELECT AVG(metric1), ..., AVG(metricN)
+// FROM cpu
+// WHERE time >= '$DATASET_START' AND time < '$DATASET_END'
+// GROUP BY hour, hostname ORDER BY hour
+func (d *Devops) DownsampleAll(qi query.Query, numMetrics int) {
+	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
+	panicIfErr(err)
+
+	humanLabel := devops.GetDownsampleLabel("Cassandra", numMetrics)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, d.Interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, "avg", metrics, d.Interval, nil)
+	q := qi.(*query.Cassandra)
+	q.GroupByDuration = time.Hour
+}
+
 func (d *Devops) fillInQuery(qi query.Query, humanLabel, humanDesc, aggType string, fields []string, interval *utils.TimeInterval, tagSets [][]string) {
 	q := qi.(*query.Cassandra)
 	q.HumanLabel = []byte(humanLabel)
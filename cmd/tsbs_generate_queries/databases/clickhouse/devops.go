@@ -2,6 +2,8 @@ package clickhouse
 
 import (
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,13 +22,18 @@ func panicIfErr(err error) {
 type Devops struct {
 	*devops.Core
 	UseTags bool
+
+	// UsePrepared makes the queries statement templates with placeholders
+	// for the times and tags they select, whose values are bound as params
+	UsePrepared bool
+	params      []interface{}
 }
 
 // NewDevops makes an Devops object ready to generate Queries.
 func NewDevops(start, end time.Time, scale int) *Devops {
 	core, err := devops.NewCore(start, end, scale)
 	panicIfErr(err)
-	return &Devops{core, false}
+	return &Devops{Core: core}
 }
 
 // GenerateEmptyQuery returns an empty query.ClickHouse
@@ -34,6 +41,52 @@ func (d *Devops) GenerateEmptyQuery() query.Query {
 	return query.NewClickHouse()
 }
 
+// getTimeBucket returns the expression truncating created_at to time buckets
+// of the given width
+func getTimeBucket(bucket time.Duration) string {
+	if bucket == time.Minute {
+		return "toStartOfMinute(created_at)"
+	}
+	return fmt.Sprintf("toStartOfInterval(created_at, INTERVAL %d second)", int64(bucket.Seconds()))
+}
+
+// bind returns the SQL for a value of a query: literal, or a numbered
+// placeholder for v when generating prepared statements, in which case v is
+// bound as a param. fillInQuery turns numbered placeholders into the
+// positional ones ClickHouse understands.
+func (d *Devops) bind(v interface{}, literal string) string {
+	if !d.UsePrepared {
+		return literal
+	}
+	d.params = append(d.params, v)
+	return fmt.Sprintf("$%d", len(d.params))
+}
+
+// bindString returns the SQL for a string value of a query
+func (d *Devops) bindString(s string) string {
+	return d.bind(s, "'"+s+"'")
+}
+
+// bindTime returns the SQL for a time value of a query
+func (d *Devops) bindTime(t time.Time) string {
+	return d.bindString(t.Format(clickhouseTimeStringFormat))
+}
+
+var numberedPlaceholder = regexp.MustCompile(`\$(\d+)`)
+
+// positionalParams replaces the numbered placeholders of sql with positional
+// ones, returning the params in the order of the placeholders. A param whose
+// placeholder occurs more than once is repeated accordingly.
+func positionalParams(sql string, params []interface{}) (string, []interface{}) {
+	var positional []interface{}
+	sql = numberedPlaceholder.ReplaceAllStringFunc(sql, func(p string) string {
+		n, _ := strconv.Atoi(p[1:])
+		positional = append(positional, params[n-1])
+		return "?"
+	})
+	return sql, positional
+}
+
 // getHostWhereWithHostnames creates WHERE SQL statement for multiple hostnames.
 // NOTE: 'WHERE' itself is not included, just hostname filter clauses, ready to concatenate to 'WHERE' string
 func (d *Devops) getHostWhereWithHostnames(hostnames []string) string {
@@ -44,7 +97,7 @@ func (d *Devops) getHostWhereWithHostnames(hostnames []string) string {
 		// Need to prepare WHERE with `tags` table
 		// WHERE tags_id IN (SELECT those tag.id FROM separated tags table WHERE )
 		for _, s := range hostnames {
-			hostnameSelectionClauses = append(hostnameSelectionClauses, fmt.Sprintf("'%s'", s))
+			hostnameSelectionClauses = append(hostnameSelectionClauses, d.bindString(s))
 		}
 		return fmt.Sprintf("tags_id IN (SELECT id FROM tags WHERE hostname IN (%s))", strings.Join(hostnameSelectionClauses, ","))
 	}
@@ -56,7 +109,7 @@ func (d *Devops) getHostWhereWithHostnames(hostnames []string) string {
 	// All tags are included into one table
 	// Need to prepare WHERE (hostname = 'host1' OR hostname = 'host2') clause
 	for _, s := range hostnames {
-		hostnameSelectionClauses = append(hostnameSelectionClauses, fmt.Sprintf("hostname = '%s'", s))
+		hostnameSelectionClauses = append(hostnameSelectionClauses, "hostname = "+d.bindString(s))
 	}
 	// (host=h1 OR host=h2)
 	return "(" + strings.Join(hostnameSelectionClauses, " OR ") + ")"
@@ -69,6 +122,16 @@ func (d *Devops) getHostWhereString(nhosts int) string {
 	return d.getHostWhereWithHostnames(hostnames)
 }
 
+// getHostGroupClauses returns the column to select in a subquery to group
+// rows of a measurement by host, the key to group on and the JOIN clause the
+// outer query needs to select the hostname
+func (d *Devops) getHostGroupClauses() (groupColumn, groupKey, joinClause string) {
+	if d.UseTags {
+		return "tags_id AS id", "id", "ANY INNER JOIN tags USING (id)"
+	}
+	return "hostname", "hostname", ""
+}
+
 // getSelectClausesAggMetrics gets specified aggregate function clause for multiple memtrics
 // Ex.: max(cpu_time) AS max_cpu_time
 func (d *Devops) getSelectClausesAggMetrics(aggregateFunction string, metrics []string) []string {
@@ -98,7 +161,7 @@ const clickhouseTimeStringFormat = "2006-01-02 15:04:05"
 // cpu-max-all-1
 // cpu-max-all-8
 func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
-	interval := d.Interval.MustRandWindow(devops.MaxAllDuration)
+	interval := d.MustQueryInterval(devops.MaxAllDuration)
 	metrics := devops.GetAllCPUMetrics()
 	selectClauses := d.getSelectClausesAggMetrics("max", metrics)
 
@@ -107,16 +170,16 @@ func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
             toStartOfHour(created_at) AS hour,
             %s
         FROM cpu
-        WHERE %s AND (created_at >= '%s') AND (created_at < '%s')
+        WHERE %s AND (created_at >= %s) AND (created_at < %s)
         GROUP BY hour
         ORDER BY hour
         `,
 		strings.Join(selectClauses, ", "),
 		d.getHostWhereString(nHosts),
-		interval.Start().Format(clickhouseTimeStringFormat),
-		interval.End().Format(clickhouseTimeStringFormat))
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
 
-	humanLabel := devops.GetMaxAllLabel("ClickHouse", nHosts)
+	humanLabel := d.GetMaxAllLabel("ClickHouse", nHosts)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
@@ -137,7 +200,7 @@ func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
 func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
-	interval := d.Interval.MustRandWindow(devops.DoubleGroupByDuration)
+	interval := d.MustQueryInterval(devops.DoubleGroupByDuration)
 
 	selectClauses := make([]string, numMetrics)
 	meanClauses := make([]string, numMetrics)
@@ -164,7 +227,7 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
                 tags_id AS id,
                 %s
             FROM cpu
-            WHERE (created_at >= '%s') AND (created_at < '%s')
+            WHERE (created_at >= %s) AND (created_at < %s)
             GROUP BY
                 hour,
                 id
@@ -177,12 +240,12 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 		hostnameField,                                       // main SELECT %s,
 		strings.Join(meanClauses, ", "),                     // main SELECT %s
 		strings.Join(selectClauses, ", "),                   // cpu_avg SELECT %s
-		interval.Start().Format(clickhouseTimeStringFormat), // cpu_avg time >= '%s'
-		interval.End().Format(clickhouseTimeStringFormat),   // cpu_avg time < '%s'
+		d.bindTime(interval.Start()),                        // cpu_avg time >= %s
+		d.bindTime(interval.End()),                          // cpu_avg time < %s
 		joinClause,    // JOIN clause
 		hostnameField) // ORDER BY %s
 
-	humanLabel := devops.GetDoubleGroupByLabel("ClickHouse", numMetrics)
+	humanLabel := d.GetDoubleGroupByLabel("ClickHouse", numMetrics)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
@@ -198,19 +261,19 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 // Resultsets:
 // groupby-orderby-limit
 func (d *Devops) GroupByOrderByLimit(qi query.Query) {
-	interval := d.Interval.MustRandWindow(time.Hour)
+	interval := d.MustQueryInterval(time.Hour)
 
 	sql := fmt.Sprintf(`
         SELECT
             toStartOfMinute(created_at) AS minute,
             max(usage_user)
         FROM cpu
-        WHERE created_at < '%s'
+        WHERE created_at < %s
         GROUP BY minute
         ORDER BY minute DESC
         LIMIT 5
         `,
-		interval.End().Format(clickhouseTimeStringFormat))
+		d.bindTime(interval.End()))
 
 	humanLabel := "ClickHouse max cpu over last 5 min-intervals (random end)"
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.EndString())
@@ -236,15 +299,15 @@ func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
 	} else {
 		hostWhereClause = fmt.Sprintf("AND (%s)", d.getHostWhereString(nHosts))
 	}
-	interval := d.Interval.MustRandWindow(devops.HighCPUDuration)
+	interval := d.MustQueryInterval(devops.HighCPUDuration)
 
 	sql := fmt.Sprintf(`
         SELECT *
         FROM cpu
-        PREWHERE (usage_user > 90.0) AND (created_at >= '%s') AND (created_at <  '%s') %s
+        PREWHERE (usage_user > 90.0) AND (created_at >= %s) AND (created_at <  %s) %s
         `,
-		interval.Start().Format(clickhouseTimeStringFormat),
-		interval.End().Format(clickhouseTimeStringFormat),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
 		hostWhereClause)
 
 	humanLabel, err := devops.GetHighCPULabel("ClickHouse", nHosts)
@@ -316,26 +379,565 @@ func (d *Devops) LastPointPerHost(qi query.Query) {
 // single-groupby-5-1-1
 // single-groupby-5-8-1
 func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange time.Duration) {
-	interval := d.Interval.MustRandWindow(timeRange)
+	interval := d.MustQueryInterval(timeRange)
+	bucket := d.GetSingleGroupByBucket()
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
 	selectClauses := d.getSelectClausesAggMetrics("max", metrics)
 
 	sql := fmt.Sprintf(`
         SELECT
-            toStartOfMinute(created_at) AS minute,
+            %s AS minute,
             %s
         FROM cpu
-        WHERE %s AND (created_at >= '%s') AND (created_at < '%s')
+        WHERE %s AND (created_at >= %s) AND (created_at < %s)
         GROUP BY minute
         ORDER BY minute ASC
         `,
+		getTimeBucket(bucket),
+		strings.Join(selectClauses, ", "),
+		d.getHostWhereString(nHosts),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
+
+	humanLabel := fmt.Sprintf("ClickHouse %d cpu metric(s), random %4d hosts, %s by %s", numMetrics, nHosts, d.WindowLabel(timeRange), devops.ShortDuration(bucket))
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// CounterRate selects the per-second rate of a counter of a measurement per
+// minute for nHosts hosts, from the maximum of the counter in consecutive
+// minutes. Rows are sorted by host, so the difference to the previous row is
+// only a rate if that row belongs to the same host, e.g. in pseudo-SQL:
+//
+// SELECT minute, hostname, runningDifference(max_counter) / 60
+// FROM
+// (
+//     SELECT minute, hostname, max(counter) AS max_counter
+//     FROM measurement
+//     WHERE
+//         (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+//         AND time >= '$HOUR_START'
+//         AND time < '$HOUR_END'
+//     GROUP BY minute, hostname
+//     ORDER BY hostname, minute
+// )
+//
+// Resultsets:
+// rate-net-1
+// rate-net-8
+// rate-diskio-1
+func (d *Devops) CounterRate(qi query.Query, measurement, counter string, nHosts int) {
+	interval := d.MustQueryInterval(devops.RateDuration)
+	groupColumn, groupKey, joinClause := d.getHostGroupClauses()
+
+	sql := fmt.Sprintf(`
+        SELECT
+            minute,
+            hostname,
+            rate_%[3]s
+        FROM
+        (
+            SELECT
+                minute,
+                %[2]s,
+                if((rowNumberInAllBlocks() > 0) AND (runningDifference(cityHash64(%[2]s)) = 0), runningDifference(max_%[3]s) / 60, NULL) AS rate_%[3]s
+            FROM
+            (
+                SELECT
+                    toStartOfMinute(created_at) AS minute,
+                    %[1]s,
+                    max(%[3]s) AS max_%[3]s
+                FROM %[4]s
+                WHERE %[5]s AND (created_at >= %[6]s) AND (created_at < %[7]s)
+                GROUP BY
+                    minute,
+                    %[2]s
+                ORDER BY
+                    %[2]s ASC,
+                    minute ASC
+            )
+        ) AS rates
+        %[8]s
+        ORDER BY
+            hostname ASC,
+            minute ASC
+        `,
+		groupColumn,
+		groupKey,
+		counter,
+		measurement,
+		d.getHostWhereString(nHosts),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
+		joinClause)
+
+	humanLabel := d.GetRateLabel("ClickHouse", measurement, counter, nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	qi.(*query.ClickHouse).Table = []byte(measurement)
+}
+
+// CPUPercentiles selects percentiles of usage_user per hour for nHosts hosts,
+// e.g. in pseudo-SQL:
+//
+// SELECT hour, quantile(0.5)(usage_user), ...
+// FROM cpu
+// WHERE
+//     (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+//     AND time >= '$HOUR_START'
+//     AND time < '$HOUR_END'
+// GROUP BY hour
+// ORDER BY hour
+//
+// Resultsets:
+// cpu-percentiles-1
+// cpu-percentiles-8
+func (d *Devops) CPUPercentiles(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.PercentilesDuration)
+
+	percentiles := devops.GetCPUPercentiles()
+	selectClauses := make([]string, len(percentiles))
+	for i, p := range percentiles {
+		selectClauses[i] = fmt.Sprintf("quantile(%0.2f)(usage_user) AS p%d_usage_user", float64(p)/100, p)
+	}
+
+	sql := fmt.Sprintf(`
+        SELECT
+            toStartOfHour(created_at) AS hour,
+            %s
+        FROM cpu
+        WHERE %s AND (created_at >= %s) AND (created_at < %s)
+        GROUP BY hour
+        ORDER BY hour
+        `,
+		strings.Join(selectClauses, ", "),
+		d.getHostWhereString(nHosts),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
+
+	humanLabel := d.GetPercentilesLabel("ClickHouse", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// TopKHosts selects the k hosts with the highest mean usage_user over a random
+// hour, e.g. in pseudo-SQL:
+//
+// SELECT hostname, avg(usage_user) AS mean_usage_user
+// FROM cpu
+// WHERE time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hostname
+// ORDER BY mean_usage_user DESC
+// LIMIT $K
+//
+// Resultsets:
+// top-k-5
+// top-k-20
+func (d *Devops) TopKHosts(qi query.Query, k int) {
+	interval := d.MustQueryInterval(devops.TopKDuration)
+	groupColumn, groupKey, joinClause := d.getHostGroupClauses()
+
+	sql := fmt.Sprintf(`
+        SELECT
+            hostname,
+            mean_usage_user
+        FROM
+        (
+            SELECT
+                %s,
+                avg(usage_user) AS mean_usage_user
+            FROM cpu
+            WHERE (created_at >= %s) AND (created_at < %s)
+            GROUP BY %s
+            ORDER BY mean_usage_user DESC
+            LIMIT %d
+        ) AS top_k
+        %s
+        ORDER BY mean_usage_user DESC
+        `,
+		groupColumn,
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
+		groupKey,
+		k,
+		joinClause)
+
+	humanLabel := d.GetTopKLabel("ClickHouse", k)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// CPUMemJoin selects the mean usage_user of cpu and the mean used_percent of
+// mem per minute and host for nHosts hosts, joined on minute and host,
+// e.g. in pseudo-SQL:
+//
+// SELECT minute, hostname, mean_usage_user, mean_used_percent
+// FROM
+// (
+//     SELECT minute, hostname, avg(usage_user) AS mean_usage_user
+//     FROM cpu WHERE ... GROUP BY minute, hostname
+// )
+// ALL INNER JOIN
+// (
+//     SELECT minute, hostname, avg(used_percent) AS mean_used_percent
+//     FROM mem WHERE ... GROUP BY minute, hostname
+// ) USING (minute, hostname)
+// ORDER BY minute, hostname
+//
+// Resultsets:
+// cpu-mem-join-1
+// cpu-mem-join-8
+func (d *Devops) CPUMemJoin(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.CPUMemJoinDuration)
+	hostnames, err := d.GetRandomHosts(nHosts)
+	panicIfErr(err)
+	groupColumn, groupKey, joinClause := d.getHostGroupClauses()
+
+	sql := fmt.Sprintf(`
+        SELECT
+            minute,
+            hostname,
+            mean_usage_user,
+            mean_used_percent
+        FROM
+        (
+            SELECT
+                minute,
+                %[2]s,
+                mean_usage_user,
+                mean_used_percent
+            FROM
+            (
+                SELECT
+                    toStartOfMinute(created_at) AS minute,
+                    %[1]s,
+                    avg(usage_user) AS mean_usage_user
+                FROM cpu
+                WHERE %[3]s AND (created_at >= %[4]s) AND (created_at < %[5]s)
+                GROUP BY
+                    minute,
+                    %[2]s
+            ) AS cpu_avg
+            ALL INNER JOIN
+            (
+                SELECT
+                    toStartOfMinute(created_at) AS minute,
+                    %[1]s,
+                    avg(used_percent) AS mean_used_percent
+                FROM mem
+                WHERE %[3]s AND (created_at >= %[4]s) AND (created_at < %[5]s)
+                GROUP BY
+                    minute,
+                    %[2]s
+            ) AS mem_avg USING (minute, %[2]s)
+        ) AS cpu_mem
+        %[6]s
+        ORDER BY
+            minute ASC,
+            hostname ASC
+        `,
+		groupColumn,
+		groupKey,
+		d.getHostWhereWithHostnames(hostnames),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
+		joinClause)
+
+	humanLabel := d.GetCPUMemJoinLabel("ClickHouse", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// DownsampleAll selects the AVG of numMetrics metrics under 'cpu' per host per
+// hour over the whole time range of the dataset, e.g. in pseudo-SQL:
+//
+// SELECT hour, hostname, AVG(metric1), ..., AVG(metricN)
+// FROM cpu
+// WHERE time >= '$DATASET_START' AND time < '$DATASET_END'
+// GROUP BY hour, hostname
+// ORDER BY hour, hostname
+//
+// Resultsets:
+// downsample-1
+// downsample-all
+func (d *Devops) DownsampleAll(qi query.Query, numMetrics int) {
+	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
+	panicIfErr(err)
+	groupColumn, groupKey, joinClause := d.getHostGroupClauses()
+
+	selectClauses := make([]string, numMetrics)
+	meanClauses := make([]string, numMetrics)
+	for i, m := range metrics {
+		meanClauses[i] = "mean_" + m
+		selectClauses[i] = fmt.Sprintf("avg(%s) AS %s", m, meanClauses[i])
+	}
+
+	sql := fmt.Sprintf(`
+        SELECT
+            hour,
+            hostname,
+            %s
+        FROM
+        (
+            SELECT
+                toStartOfHour(created_at) AS hour,
+                %s,
+                %s
+            FROM cpu
+            WHERE (created_at >= %s) AND (created_at < %s)
+            GROUP BY
+                hour,
+                %s
+        ) AS cpu_avg
+        %s
+        ORDER BY
+            hour ASC,
+            hostname ASC
+        `,
+		strings.Join(meanClauses, ", "),
+		groupColumn,
 		strings.Join(selectClauses, ", "),
+		d.bindTime(d.Interval.Start()),
+		d.bindTime(d.Interval.End()),
+		groupKey,
+		joinClause)
+
+	humanLabel := devops.GetDownsampleLabel("ClickHouse", numMetrics)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, d.Interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// DiskFull selects the disks of nHosts hosts (or all hosts if nHosts is 0)
+// whose used_percent exceeded devops.DiskFullThreshold in a random hour,
+// e.g. in pseudo-SQL:
+//
+// SELECT hostname, path, max(used_percent) AS max_used_percent
+// FROM disk
+// WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hostname, path
+// HAVING max_used_percent > $THRESHOLD
+// ORDER BY max_used_percent DESC
+//
+// Resultsets:
+// disk-full-all
+// disk-full-1
+func (d *Devops) DiskFull(qi query.Query, nHosts int) {
+	var hostWhereClause string
+	if nHosts > 0 {
+		hostWhereClause = fmt.Sprintf("AND (%s)", d.getHostWhereString(nHosts))
+	}
+	interval := d.MustQueryInterval(devops.DiskFullDuration)
+	groupColumn, groupKey, joinClause := d.getHostGroupClauses()
+
+	sql := fmt.Sprintf(`
+        SELECT
+            hostname,
+            path,
+            max_used_percent
+        FROM
+        (
+            SELECT
+                %s,
+                visitParamExtractString(additional_tags, 'path') AS path,
+                max(used_percent) AS max_used_percent
+            FROM disk
+            WHERE (created_at >= %s) AND (created_at < %s) %s
+            GROUP BY
+                %s,
+                path
+            HAVING max_used_percent > %0.1f
+        ) AS disk_max
+        %s
+        ORDER BY
+            max_used_percent DESC,
+            hostname ASC
+        `,
+		groupColumn,
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
+		hostWhereClause,
+		groupKey,
+		devops.DiskFullThreshold,
+		joinClause)
+
+	humanLabel, err := d.GetDiskFullLabel("ClickHouse", nHosts)
+	panicIfErr(err)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	qi.(*query.ClickHouse).Table = []byte("disk")
+}
+
+// RedisHitRatio selects the share of redis keyspace lookups that were hits
+// per hour and host for nHosts hosts, from the increase of the hit and miss
+// counters within the hour, e.g. in pseudo-SQL:
+//
+// SELECT hour, hostname, hits / (hits + misses) AS hit_ratio
+// FROM
+// (
+//     SELECT hour, hostname,
+//     max(keyspace_hits) - min(keyspace_hits) AS hits,
+//     max(keyspace_misses) - min(keyspace_misses) AS misses
+//     FROM redis WHERE ... GROUP BY hour, hostname
+// )
+// ORDER BY hour, hostname
+//
+// Resultsets:
+// redis-hit-ratio-1
+// redis-hit-ratio-8
+func (d *Devops) RedisHitRatio(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.RedisHitRatioDuration)
+	groupColumn, groupKey, joinClause := d.getHostGroupClauses()
+
+	sql := fmt.Sprintf(`
+        SELECT
+            hour,
+            hostname,
+            if(hits + misses = 0, NULL, hits / (hits + misses)) AS hit_ratio
+        FROM
+        (
+            SELECT
+                toStartOfHour(created_at) AS hour,
+                %s,
+                max(keyspace_hits) - min(keyspace_hits) AS hits,
+                max(keyspace_misses) - min(keyspace_misses) AS misses
+            FROM redis
+            WHERE %s AND (created_at >= %s) AND (created_at < %s)
+            GROUP BY
+                hour,
+                %s
+        ) AS redis_lookups
+        %s
+        ORDER BY
+            hour ASC,
+            hostname ASC
+        `,
+		groupColumn,
 		d.getHostWhereString(nHosts),
-		interval.Start().Format(clickhouseTimeStringFormat),
-		interval.End().Format(clickhouseTimeStringFormat))
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
+		groupKey,
+		joinClause)
+
+	humanLabel := d.GetRedisHitRatioLabel("ClickHouse", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	qi.(*query.ClickHouse).Table = []byte("redis")
+}
+
+// NginxRequestRate selects the nginx requests per second of each service per
+// minute over a random hour, from the increase of the requests counter of
+// every host within the minute, e.g. in pseudo-SQL:
+//
+// SELECT minute, service, sum(requests) / 60 AS requests_per_sec
+// FROM
+// (
+//     SELECT minute, hostname, max(requests) - min(requests) AS requests
+//     FROM nginx WHERE ... GROUP BY minute, hostname
+// )
+// GROUP BY minute, service
+// ORDER BY minute, service
+//
+// Resultsets:
+// nginx-rate-per-service
+func (d *Devops) NginxRequestRate(qi query.Query) {
+	interval := d.MustQueryInterval(devops.NginxRateDuration)
+
+	// The service of a host is only stored in the tags table, so the
+	// requests are always grouped by tags_id and joined with it
+	sql := fmt.Sprintf(`
+        SELECT
+            minute,
+            service,
+            sum(requests) / 60 AS requests_per_sec
+        FROM
+        (
+            SELECT
+                toStartOfMinute(created_at) AS minute,
+                tags_id AS id,
+                max(requests) - min(requests) AS requests
+            FROM nginx
+            WHERE (created_at >= %s) AND (created_at < %s)
+            GROUP BY
+                minute,
+                id
+        ) AS host_requests
+        ANY INNER JOIN tags USING (id)
+        GROUP BY
+            minute,
+            service
+        ORDER BY
+            minute ASC,
+            service ASC
+        `,
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
+
+	humanLabel := d.GetNginxRateLabel("ClickHouse")
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	qi.(*query.ClickHouse).Table = []byte("nginx")
+}
 
-	humanLabel := fmt.Sprintf("ClickHouse %d cpu metric(s), random %4d hosts, random %s by 1m", numMetrics, nHosts, timeRange)
+// GroupByTag selects the mean usage_user per hour and value of groupTag of the
+// hosts with random values of filterTags, e.g. in pseudo-SQL:
+//
+// SELECT hour, datacenter, avg(usage_user) AS mean_usage_user
+// FROM cpu
+// WHERE service = '$SERVICE' AND service_environment = '$ENVIRONMENT'
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hour, datacenter
+// ORDER BY hour, datacenter
+//
+// Resultsets:
+// tag-groupby-datacenter-service
+// tag-groupby-team-region
+func (d *Devops) GroupByTag(qi query.Query, groupTag string, filterTags []string) {
+	interval := d.MustQueryInterval(devops.TagGroupByDuration)
+	filters, err := d.GetRandomTagFilters(filterTags)
+	panicIfErr(err)
+
+	filterClauses := make([]string, len(filters))
+	for i, f := range filters {
+		filterClauses[i] = fmt.Sprintf("%s = %s", f.Key, d.bindString(f.Value))
+	}
+
+	// Host tags other than hostname are only stored in the tags table, so
+	// sums and counts are taken per tags_id and combined per groupTag after
+	// the join
+	sql := fmt.Sprintf(`
+        SELECT
+            hour,
+            %[1]s,
+            sum(sum_usage_user) / sum(count_usage_user) AS mean_usage_user
+        FROM
+        (
+            SELECT
+                toStartOfHour(created_at) AS hour,
+                tags_id AS id,
+                sum(usage_user) AS sum_usage_user,
+                count(usage_user) AS count_usage_user
+            FROM cpu
+            WHERE tags_id IN (SELECT id FROM tags WHERE %[2]s) AND (created_at >= %[3]s) AND (created_at < %[4]s)
+            GROUP BY
+                hour,
+                id
+        ) AS cpu_sum
+        ANY INNER JOIN tags USING (id)
+        GROUP BY
+            hour,
+            %[1]s
+        ORDER BY
+            hour ASC,
+            %[1]s ASC
+        `,
+		groupTag,
+		strings.Join(filterClauses, " AND "),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
+
+	humanLabel := d.GetTagGroupByLabel("ClickHouse", groupTag, filterTags)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
@@ -346,5 +948,9 @@ func (d *Devops) fillInQuery(qi query.Query, humanLabel, humanDesc, sql string)
 	q.HumanLabel = []byte(humanLabel)
 	q.HumanDescription = []byte(humanDesc)
 	q.Table = []byte("cpu")
+	if len(d.params) > 0 {
+		sql, q.Params = positionalParams(sql, d.params)
+		d.params = nil
+	}
 	q.SqlQuery = []byte(sql)
 }
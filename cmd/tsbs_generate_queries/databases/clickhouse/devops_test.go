@@ -2,11 +2,13 @@ package clickhouse
 
 import (
 	"math/rand"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	internalutils "github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/query"
 )
 
@@ -365,6 +367,41 @@ func TestHighCPUForHosts(t *testing.T) {
 	runTestCases(t, testFunc, start, end, cases)
 }
 
+func TestHighCPUForHostsPrepared(t *testing.T) {
+	expectedQuery := `
+        SELECT *
+        FROM cpu
+        PREWHERE (usage_user > 90.0) AND (created_at >= ?) AND (created_at <  ?) AND ((hostname = ? OR hostname = ? OR hostname = ? OR hostname = ? OR hostname = ?))
+        `
+	// the hosts are bound before the times, but come after them in the query
+	expectedParams := []interface{}{"1970-01-01 00:12:17", "1970-01-01 12:12:17", "host_5", "host_9", "host_3", "host_1", "host_7"}
+
+	rand.Seed(123)
+	start := time.Unix(0, 0)
+	end := start.Add(devops.HighCPUDuration).Add(time.Hour)
+	d := NewDevops(start, end, 10)
+	d.UsePrepared = true
+
+	q := d.GenerateEmptyQuery().(*query.ClickHouse)
+	d.HighCPUForHosts(q, 5)
+	if got := string(q.SqlQuery); got != expectedQuery {
+		t.Errorf("incorrect query:\ngot\n%s\nwant\n%s", got, expectedQuery)
+	}
+	if !reflect.DeepEqual(q.Params, expectedParams) {
+		t.Errorf("incorrect params: got %v want %v", q.Params, expectedParams)
+	}
+}
+
+func TestPositionalParams(t *testing.T) {
+	sql, params := positionalParams("a = $2 AND b = $1 OR a = $2", []interface{}{"x", int64(1)})
+	if want := "a = ? AND b = ? OR a = ?"; sql != want {
+		t.Errorf("incorrect sql: got %s want %s", sql, want)
+	}
+	if want := []interface{}{int64(1), "x", int64(1)}; !reflect.DeepEqual(params, want) {
+		t.Errorf("incorrect params: got %v want %v", params, want)
+	}
+}
+
 func TestLastPointPerHost(t *testing.T) {
 	cases := []testCase{
 		{
@@ -449,6 +486,610 @@ func TestGroupByTime(t *testing.T) {
 	runTestCases(t, testFunc, start, end, cases)
 }
 
+func TestGroupByTimeConfiguredWindows(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "recent window with 5m buckets",
+			input:              1,
+			expectedHumanLabel: "ClickHouse 1 cpu metric(s), random    1 hosts, recent 10m0s by 5m",
+			expectedHumanDesc:  "ClickHouse 1 cpu metric(s), random    1 hosts, recent 10m0s by 5m: 1970-01-01T01:50:00Z",
+			expectedQuery: `
+        SELECT
+            toStartOfInterval(created_at, INTERVAL 300 second) AS minute,
+            max(usage_user) AS max_usage_user
+        FROM cpu
+        WHERE (hostname = 'host_5') AND (created_at >= '1970-01-01 01:50:00') AND (created_at < '1970-01-01 02:00:00')
+        GROUP BY minute
+        ORDER BY minute ASC
+        `,
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		if err := d.ConfigureWindows(10*time.Minute, 5*time.Minute, internalutils.WindowRecent); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		q := d.GenerateEmptyQuery()
+		d.GroupByTime(q, c.input, 1, time.Second)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestCounterRate(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "no tags",
+			expectedHumanLabel: "ClickHouse rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "ClickHouse rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m: 1970-01-01T00:16:22Z",
+			expectedQuery: `
+        SELECT
+            minute,
+            hostname,
+            rate_bytes_recv
+        FROM
+        (
+            SELECT
+                minute,
+                hostname,
+                if((rowNumberInAllBlocks() > 0) AND (runningDifference(cityHash64(hostname)) = 0), runningDifference(max_bytes_recv) / 60, NULL) AS rate_bytes_recv
+            FROM
+            (
+                SELECT
+                    toStartOfMinute(created_at) AS minute,
+                    hostname,
+                    max(bytes_recv) AS max_bytes_recv
+                FROM net
+                WHERE (hostname = 'host_9' OR hostname = 'host_3') AND (created_at >= '1970-01-01 00:16:22') AND (created_at < '1970-01-01 01:16:22')
+                GROUP BY
+                    minute,
+                    hostname
+                ORDER BY
+                    hostname ASC,
+                    minute ASC
+            )
+        ) AS rates
+        
+        ORDER BY
+            hostname ASC,
+            minute ASC
+        `,
+		},
+		{
+			desc:               "use tags",
+			devopsUseTags:      true,
+			expectedHumanLabel: "ClickHouse rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "ClickHouse rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m: 1970-01-01T00:37:12Z",
+			expectedQuery: `
+        SELECT
+            minute,
+            hostname,
+            rate_bytes_recv
+        FROM
+        (
+            SELECT
+                minute,
+                id,
+                if((rowNumberInAllBlocks() > 0) AND (runningDifference(cityHash64(id)) = 0), runningDifference(max_bytes_recv) / 60, NULL) AS rate_bytes_recv
+            FROM
+            (
+                SELECT
+                    toStartOfMinute(created_at) AS minute,
+                    tags_id AS id,
+                    max(bytes_recv) AS max_bytes_recv
+                FROM net
+                WHERE tags_id IN (SELECT id FROM tags WHERE hostname IN ('host_9','host_5')) AND (created_at >= '1970-01-01 00:37:12') AND (created_at < '1970-01-01 01:37:12')
+                GROUP BY
+                    minute,
+                    id
+                ORDER BY
+                    id ASC,
+                    minute ASC
+            )
+        ) AS rates
+        ANY INNER JOIN tags USING (id)
+        ORDER BY
+            hostname ASC,
+            minute ASC
+        `,
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.CounterRate(q, "net", "bytes_recv", 2)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestCPUPercentiles(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "ClickHouse percentiles of usage_user, random    1 hosts, random 12h0m0s by 1h",
+			expectedHumanDesc:  "ClickHouse percentiles of usage_user, random    1 hosts, random 12h0m0s by 1h: 1970-01-01T00:16:22Z",
+			expectedQuery: `
+        SELECT
+            toStartOfHour(created_at) AS hour,
+            quantile(0.50)(usage_user) AS p50_usage_user, quantile(0.90)(usage_user) AS p90_usage_user, quantile(0.99)(usage_user) AS p99_usage_user
+        FROM cpu
+        WHERE (hostname = 'host_9') AND (created_at >= '1970-01-01 00:16:22') AND (created_at < '1970-01-01 12:16:22')
+        GROUP BY hour
+        ORDER BY hour
+        `,
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.CPUPercentiles(q, 1)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(devops.PercentilesDuration).Add(time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestTopKHosts(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "ClickHouse top 5 hosts by mean usage_user, random 1h0m0s",
+			expectedHumanDesc:  "ClickHouse top 5 hosts by mean usage_user, random 1h0m0s: 1970-01-01T00:16:22Z",
+			expectedQuery: `
+        SELECT
+            hostname,
+            mean_usage_user
+        FROM
+        (
+            SELECT
+                hostname,
+                avg(usage_user) AS mean_usage_user
+            FROM cpu
+            WHERE (created_at >= '1970-01-01 00:16:22') AND (created_at < '1970-01-01 01:16:22')
+            GROUP BY hostname
+            ORDER BY mean_usage_user DESC
+            LIMIT 5
+        ) AS top_k
+        
+        ORDER BY mean_usage_user DESC
+        `,
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.TopKHosts(q, 5)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestCPUMemJoin(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "no tags",
+			expectedHumanLabel: "ClickHouse cpu joined with mem, random    2 hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "ClickHouse cpu joined with mem, random    2 hosts, random 1h0m0s by 1m: 1970-01-01T00:16:22Z",
+			expectedQuery: `
+        SELECT
+            minute,
+            hostname,
+            mean_usage_user,
+            mean_used_percent
+        FROM
+        (
+            SELECT
+                minute,
+                hostname,
+                mean_usage_user,
+                mean_used_percent
+            FROM
+            (
+                SELECT
+                    toStartOfMinute(created_at) AS minute,
+                    hostname,
+                    avg(usage_user) AS mean_usage_user
+                FROM cpu
+                WHERE (hostname = 'host_9' OR hostname = 'host_3') AND (created_at >= '1970-01-01 00:16:22') AND (created_at < '1970-01-01 01:16:22')
+                GROUP BY
+                    minute,
+                    hostname
+            ) AS cpu_avg
+            ALL INNER JOIN
+            (
+                SELECT
+                    toStartOfMinute(created_at) AS minute,
+                    hostname,
+                    avg(used_percent) AS mean_used_percent
+                FROM mem
+                WHERE (hostname = 'host_9' OR hostname = 'host_3') AND (created_at >= '1970-01-01 00:16:22') AND (created_at < '1970-01-01 01:16:22')
+                GROUP BY
+                    minute,
+                    hostname
+            ) AS mem_avg USING (minute, hostname)
+        ) AS cpu_mem
+        
+        ORDER BY
+            minute ASC,
+            hostname ASC
+        `,
+		},
+		{
+			desc:               "use tags",
+			devopsUseTags:      true,
+			expectedHumanLabel: "ClickHouse cpu joined with mem, random    2 hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "ClickHouse cpu joined with mem, random    2 hosts, random 1h0m0s by 1m: 1970-01-01T00:37:12Z",
+			expectedQuery: `
+        SELECT
+            minute,
+            hostname,
+            mean_usage_user,
+            mean_used_percent
+        FROM
+        (
+            SELECT
+                minute,
+                id,
+                mean_usage_user,
+                mean_used_percent
+            FROM
+            (
+                SELECT
+                    toStartOfMinute(created_at) AS minute,
+                    tags_id AS id,
+                    avg(usage_user) AS mean_usage_user
+                FROM cpu
+                WHERE tags_id IN (SELECT id FROM tags WHERE hostname IN ('host_9','host_5')) AND (created_at >= '1970-01-01 00:37:12') AND (created_at < '1970-01-01 01:37:12')
+                GROUP BY
+                    minute,
+                    id
+            ) AS cpu_avg
+            ALL INNER JOIN
+            (
+                SELECT
+                    toStartOfMinute(created_at) AS minute,
+                    tags_id AS id,
+                    avg(used_percent) AS mean_used_percent
+                FROM mem
+                WHERE tags_id IN (SELECT id FROM tags WHERE hostname IN ('host_9','host_5')) AND (created_at >= '1970-01-01 00:37:12') AND (created_at < '1970-01-01 01:37:12')
+                GROUP BY
+                    minute,
+                    id
+            ) AS mem_avg USING (minute, id)
+        ) AS cpu_mem
+        ANY INNER JOIN tags USING (id)
+        ORDER BY
+            minute ASC,
+            hostname ASC
+        `,
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.CPUMemJoin(q, 2)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestDownsampleAll(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "ClickHouse mean of 2 metrics, all hosts, all data by 1h",
+			expectedHumanDesc:  "ClickHouse mean of 2 metrics, all hosts, all data by 1h: 1970-01-01T00:00:00Z",
+			expectedQuery: `
+        SELECT
+            hour,
+            hostname,
+            mean_usage_user, mean_usage_system
+        FROM
+        (
+            SELECT
+                toStartOfHour(created_at) AS hour,
+                hostname,
+                avg(usage_user) AS mean_usage_user, avg(usage_system) AS mean_usage_system
+            FROM cpu
+            WHERE (created_at >= '1970-01-01 00:00:00') AND (created_at < '1970-01-01 02:00:00')
+            GROUP BY
+                hour,
+                hostname
+        ) AS cpu_avg
+        
+        ORDER BY
+            hour ASC,
+            hostname ASC
+        `,
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.DownsampleAll(q, 2)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestDiskFull(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "all hosts",
+			expectedHumanLabel: "ClickHouse disk over threshold, all hosts, random 1h0m0s",
+			expectedHumanDesc:  "ClickHouse disk over threshold, all hosts, random 1h0m0s: 1970-01-01T00:16:22Z",
+			expectedQuery: `
+        SELECT
+            hostname,
+            path,
+            max_used_percent
+        FROM
+        (
+            SELECT
+                hostname,
+                visitParamExtractString(additional_tags, 'path') AS path,
+                max(used_percent) AS max_used_percent
+            FROM disk
+            WHERE (created_at >= '1970-01-01 00:16:22') AND (created_at < '1970-01-01 01:16:22') 
+            GROUP BY
+                hostname,
+                path
+            HAVING max_used_percent > 90.0
+        ) AS disk_max
+        
+        ORDER BY
+            max_used_percent DESC,
+            hostname ASC
+        `,
+		},
+		{
+			desc:               "all hosts - use tags",
+			devopsUseTags:      true,
+			expectedHumanLabel: "ClickHouse disk over threshold, all hosts, random 1h0m0s",
+			expectedHumanDesc:  "ClickHouse disk over threshold, all hosts, random 1h0m0s: 1970-01-01T00:54:10Z",
+			expectedQuery: `
+        SELECT
+            hostname,
+            path,
+            max_used_percent
+        FROM
+        (
+            SELECT
+                tags_id AS id,
+                visitParamExtractString(additional_tags, 'path') AS path,
+                max(used_percent) AS max_used_percent
+            FROM disk
+            WHERE (created_at >= '1970-01-01 00:54:10') AND (created_at < '1970-01-01 01:54:10') 
+            GROUP BY
+                id,
+                path
+            HAVING max_used_percent > 90.0
+        ) AS disk_max
+        ANY INNER JOIN tags USING (id)
+        ORDER BY
+            max_used_percent DESC,
+            hostname ASC
+        `,
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.DiskFull(q, 0)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestDiskFullForHosts(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "ClickHouse disk over threshold, 1 host(s), random 1h0m0s",
+			expectedHumanDesc:  "ClickHouse disk over threshold, 1 host(s), random 1h0m0s: 1970-01-01T00:54:10Z",
+			expectedQuery: `
+        SELECT
+            hostname,
+            path,
+            max_used_percent
+        FROM
+        (
+            SELECT
+                hostname,
+                visitParamExtractString(additional_tags, 'path') AS path,
+                max(used_percent) AS max_used_percent
+            FROM disk
+            WHERE (created_at >= '1970-01-01 00:54:10') AND (created_at < '1970-01-01 01:54:10') AND ((hostname = 'host_5'))
+            GROUP BY
+                hostname,
+                path
+            HAVING max_used_percent > 90.0
+        ) AS disk_max
+        
+        ORDER BY
+            max_used_percent DESC,
+            hostname ASC
+        `,
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.DiskFull(q, 1)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestRedisHitRatio(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "ClickHouse redis keyspace hit ratio, random    2 hosts, random 12h0m0s by 1h",
+			expectedHumanDesc:  "ClickHouse redis keyspace hit ratio, random    2 hosts, random 12h0m0s by 1h: 1970-01-01T00:16:22Z",
+			expectedQuery: `
+        SELECT
+            hour,
+            hostname,
+            if(hits + misses = 0, NULL, hits / (hits + misses)) AS hit_ratio
+        FROM
+        (
+            SELECT
+                toStartOfHour(created_at) AS hour,
+                hostname,
+                max(keyspace_hits) - min(keyspace_hits) AS hits,
+                max(keyspace_misses) - min(keyspace_misses) AS misses
+            FROM redis
+            WHERE (hostname = 'host_9' OR hostname = 'host_3') AND (created_at >= '1970-01-01 00:16:22') AND (created_at < '1970-01-01 12:16:22')
+            GROUP BY
+                hour,
+                hostname
+        ) AS redis_lookups
+        
+        ORDER BY
+            hour ASC,
+            hostname ASC
+        `,
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.RedisHitRatio(q, 2)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(devops.RedisHitRatioDuration).Add(time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestNginxRequestRate(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "ClickHouse nginx requests per second per service, all hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "ClickHouse nginx requests per second per service, all hosts, random 1h0m0s by 1m: 1970-01-01T00:16:22Z",
+			expectedQuery: `
+        SELECT
+            minute,
+            service,
+            sum(requests) / 60 AS requests_per_sec
+        FROM
+        (
+            SELECT
+                toStartOfMinute(created_at) AS minute,
+                tags_id AS id,
+                max(requests) - min(requests) AS requests
+            FROM nginx
+            WHERE (created_at >= '1970-01-01 00:16:22') AND (created_at < '1970-01-01 01:16:22')
+            GROUP BY
+                minute,
+                id
+        ) AS host_requests
+        ANY INNER JOIN tags USING (id)
+        GROUP BY
+            minute,
+            service
+        ORDER BY
+            minute ASC,
+            service ASC
+        `,
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.NginxRequestRate(q)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestGroupByTag(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "ClickHouse mean usage_user per datacenter, random service and service_environment, random 12h0m0s by 1h",
+			expectedHumanDesc:  "ClickHouse mean usage_user per datacenter, random service and service_environment, random 12h0m0s by 1h: 1970-01-01T00:16:22Z",
+			expectedQuery: `
+        SELECT
+            hour,
+            datacenter,
+            sum(sum_usage_user) / sum(count_usage_user) AS mean_usage_user
+        FROM
+        (
+            SELECT
+                toStartOfHour(created_at) AS hour,
+                tags_id AS id,
+                sum(usage_user) AS sum_usage_user,
+                count(usage_user) AS count_usage_user
+            FROM cpu
+            WHERE tags_id IN (SELECT id FROM tags WHERE service = '9' AND service_environment = 'staging') AND (created_at >= '1970-01-01 00:16:22') AND (created_at < '1970-01-01 12:16:22')
+            GROUP BY
+                hour,
+                id
+        ) AS cpu_sum
+        ANY INNER JOIN tags USING (id)
+        GROUP BY
+            hour,
+            datacenter
+        ORDER BY
+            hour ASC,
+            datacenter ASC
+        `,
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.GroupByTag(q, "datacenter", []string{"service", "service_environment"})
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(devops.TagGroupByDuration).Add(time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
 type testCase struct {
 	desc               string
 	input              int
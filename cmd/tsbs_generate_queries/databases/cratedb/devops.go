@@ -2,6 +2,7 @@ package cratedb
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,13 +20,18 @@ func panicIfErr(err error) {
 // Devops produces CrateDB-specific queries for all the devops query types.
 type Devops struct {
 	*devops.Core
+
+	// UsePrepared makes the queries statement templates with placeholders
+	// for the times and tags they select, whose values are bound as params
+	UsePrepared bool
+	params      []interface{}
 }
 
 // NewDevops makes an Devops object ready to generate Queries.
 func NewDevops(start, end time.Time, scale int) *Devops {
 	core, err := devops.NewCore(start, end, scale)
 	panicIfErr(err)
-	return &Devops{core}
+	return &Devops{Core: core}
 }
 
 const hostnameField = "tags['hostname']"
@@ -35,6 +41,46 @@ func (d *Devops) GenerateEmptyQuery() query.Query {
 	return query.NewCrateDB()
 }
 
+// bind returns the SQL for a value of a query: literal, or a placeholder for
+// v when generating prepared statements, in which case v is bound as a param
+func (d *Devops) bind(v interface{}, literal string) string {
+	if !d.UsePrepared {
+		return literal
+	}
+	d.params = append(d.params, v)
+	return fmt.Sprintf("$%d", len(d.params))
+}
+
+// bindString returns the SQL for a string value of a query
+func (d *Devops) bindString(s string) string {
+	return d.bind(s, "'"+s+"'")
+}
+
+// bindTime returns the SQL for a time value of a query, which CrateDB
+// compares to timestamps as milliseconds since the epoch
+func (d *Devops) bindTime(t time.Time) string {
+	millis := t.UnixNano() / int64(time.Millisecond)
+	return d.bind(millis, strconv.FormatInt(millis, 10))
+}
+
+// bindHosts returns the SQL for a list of hostnames of a query
+func (d *Devops) bindHosts(hosts []string) string {
+	values := make([]string, len(hosts))
+	for i, h := range hosts {
+		values[i] = d.bindString(h)
+	}
+	return strings.Join(values, ", ")
+}
+
+// getTimeBucket returns the expression truncating ts to time buckets of the
+// given width
+func getTimeBucket(bucket time.Duration) string {
+	if bucket == time.Minute {
+		return "date_trunc('minute', ts)"
+	}
+	return fmt.Sprintf("date_bin('%d seconds'::interval, ts, 0)", int64(bucket.Seconds()))
+}
+
 // getSelectAggClauses builds specified aggregate function clauses for
 // a set of column idents.
 //
@@ -56,7 +102,7 @@ func (d *Devops) getSelectAggClauses(aggFunc string, idents []string) []string {
 // cpu-max-all-1
 // cpu-max-all-8
 func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
-	interval := d.Interval.MustRandWindow(devops.MaxAllDuration)
+	interval := d.MustQueryInterval(devops.MaxAllDuration)
 	selectClauses := d.getSelectAggClauses("max", devops.GetAllCPUMetrics())
 	hosts, err := d.GetRandomHosts(nHosts)
 	panicIfErr(err)
@@ -66,18 +112,18 @@ func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
 			date_trunc('hour', ts) AS hour,
 			%s
 		FROM cpu
-		WHERE %s IN ('%s')
-		  AND ts >= %d
-		  AND ts < %d
+		WHERE %s IN (%s)
+		  AND ts >= %s
+		  AND ts < %s
 		GROUP BY hour
 		ORDER BY hour`,
 		strings.Join(selectClauses, ", "),
 		hostnameField,
-		strings.Join(hosts, "', '"),
-		interval.StartUnixMillis(),
-		interval.EndUnixMillis())
+		d.bindHosts(hosts),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
 
-	humanLabel := devops.GetMaxAllLabel("CrateDB", nHosts)
+	humanLabel := d.GetMaxAllLabel("CrateDB", nHosts)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
@@ -92,7 +138,7 @@ func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
 func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
-	interval := d.Interval.MustRandWindow(devops.DoubleGroupByDuration)
+	interval := d.MustQueryInterval(devops.DoubleGroupByDuration)
 	selectClauses := d.getSelectAggClauses("mean", metrics)
 
 	sql := fmt.Sprintf(`
@@ -100,16 +146,16 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 			date_trunc('hour', ts) AS hour,
 			%s
 		FROM cpu
-		WHERE ts >= %d
-		  AND ts < %d
+		WHERE ts >= %s
+		  AND ts < %s
 		GROUP BY hour, %s
 		ORDER BY hour`,
 		strings.Join(selectClauses, ", "),
-		interval.StartUnixMillis(),
-		interval.EndUnixMillis(),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
 		hostnameField)
 
-	humanLabel := devops.GetDoubleGroupByLabel("CrateDB", numMetrics)
+	humanLabel := d.GetDoubleGroupByLabel("CrateDB", numMetrics)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
@@ -120,17 +166,17 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 // Queries:
 // groupby-orderby-limit
 func (d *Devops) GroupByOrderByLimit(qi query.Query) {
-	interval := d.Interval.MustRandWindow(time.Hour)
+	interval := d.MustQueryInterval(time.Hour)
 	sql := fmt.Sprintf(`
 		SELECT
 			date_trunc('minute', ts) as minute,
 			max(usage_user)
 		FROM cpu
-		WHERE ts < %d
+		WHERE ts < %s
 		GROUP BY minute
 		ORDER BY minute DESC
 		LIMIT 5`,
-		interval.EndUnixMillis())
+		d.bindTime(interval.End()))
 
 	humanLabel := "CrateDB max cpu over last 5 min-intervals (random end)"
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.EndString())
@@ -163,7 +209,7 @@ func (d *Devops) LastPointPerHost(qi query.Query) {
 // high-cpu-1
 // high-cpu-all
 func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
-	interval := d.Interval.MustRandWindow(devops.HighCPUDuration)
+	interval := d.MustQueryInterval(devops.HighCPUDuration)
 	hosts, err := d.GetRandomHosts(nHosts)
 	panicIfErr(err)
 
@@ -171,13 +217,13 @@ func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
 		SELECT *
 		FROM cpu
 		WHERE usage_user > 90.0
-		  AND ts >= %d
-		  AND ts < %d
-		  AND %s IN ('%s')`,
-		interval.StartUnixMillis(),
-		interval.EndUnixMillis(),
+		  AND ts >= %s
+		  AND ts < %s
+		  AND %s IN (%s)`,
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
 		hostnameField,
-		strings.Join(hosts, "', '"))
+		d.bindHosts(hosts))
 
 	humanLabel, err := devops.GetHighCPULabel("CrateDB", nHosts)
 	panicIfErr(err)
@@ -196,7 +242,8 @@ func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
 // single-groupby-5-1-1
 // single-groupby-5-8-1
 func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange time.Duration) {
-	interval := d.Interval.MustRandWindow(timeRange)
+	interval := d.MustQueryInterval(timeRange)
+	bucket := d.GetSingleGroupByBucket()
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
 	selectClauses := d.getSelectAggClauses("max", metrics)
@@ -205,23 +252,377 @@ func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange t
 
 	sql := fmt.Sprintf(`
 		SELECT
-			date_trunc('minute', ts) as minute,
+			%s as minute,
 			%s
 		FROM cpu
-		WHERE %s IN ('%s')
-		  AND ts >= %d
-		  AND ts < %d
+		WHERE %s IN (%s)
+		  AND ts >= %s
+		  AND ts < %s
 		GROUP BY minute
 		ORDER BY minute ASC`,
+		getTimeBucket(bucket),
 		strings.Join(selectClauses, ", "),
 		hostnameField,
-		strings.Join(hosts, "', '"),
-		interval.StartUnixMillis(),
-		interval.EndUnixMillis())
+		d.bindHosts(hosts),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
 
 	humanLabel := fmt.Sprintf(
-		"CrateDB %d cpu metric(s), random %4d hosts, random %s by 1m",
-		numMetrics, nHosts, timeRange)
+		"CrateDB %d cpu metric(s), random %4d hosts, %s by %s",
+		numMetrics, nHosts, d.WindowLabel(timeRange), devops.ShortDuration(bucket))
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// CounterRate selects the per-second rate of a counter of a measurement per
+// minute for N random hosts, from the maximum of the counter in consecutive
+// minutes. It uses the lag window function, so it needs a CrateDB version
+// with window function support.
+//
+// Queries:
+// rate-net-1
+// rate-net-8
+// rate-diskio-1
+func (d *Devops) CounterRate(qi query.Query, measurement, counter string, nHosts int) {
+	interval := d.MustQueryInterval(devops.RateDuration)
+	hosts, err := d.GetRandomHosts(nHosts)
+	panicIfErr(err)
+
+	sql := fmt.Sprintf(`
+		SELECT
+			minute,
+			host,
+			(max_%[1]s - lag(max_%[1]s) OVER (PARTITION BY host ORDER BY minute)) / 60 AS rate_%[1]s
+		FROM
+		  (
+			SELECT
+				date_trunc('minute', ts) AS minute,
+				%[2]s AS host,
+				max(%[1]s) AS max_%[1]s
+			FROM %[3]s
+			WHERE %[2]s IN (%[4]s)
+			  AND ts >= %[5]s
+			  AND ts < %[6]s
+			GROUP BY minute, host
+		  ) c
+		ORDER BY host, minute`,
+		counter,
+		hostnameField,
+		measurement,
+		d.bindHosts(hosts),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
+
+	humanLabel := d.GetRateLabel("CrateDB", measurement, counter, nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	qi.(*query.CrateDB).Table = []byte(measurement)
+}
+
+// CPUPercentiles selects percentiles of usage_user per hour for N random hosts
+//
+// Queries:
+// cpu-percentiles-1
+// cpu-percentiles-8
+func (d *Devops) CPUPercentiles(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.PercentilesDuration)
+	hosts, err := d.GetRandomHosts(nHosts)
+	panicIfErr(err)
+
+	percentiles := devops.GetCPUPercentiles()
+	selectClauses := make([]string, len(percentiles))
+	for i, p := range percentiles {
+		selectClauses[i] = fmt.Sprintf("percentile(usage_user, %0.2f) AS p%d_usage_user", float64(p)/100, p)
+	}
+
+	sql := fmt.Sprintf(`
+		SELECT
+			date_trunc('hour', ts) AS hour,
+			%s
+		FROM cpu
+		WHERE %s IN (%s)
+		  AND ts >= %s
+		  AND ts < %s
+		GROUP BY hour
+		ORDER BY hour`,
+		strings.Join(selectClauses, ", "),
+		hostnameField,
+		d.bindHosts(hosts),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
+
+	humanLabel := d.GetPercentilesLabel("CrateDB", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// TopKHosts selects the k hosts with the highest mean usage_user over a random
+// hour
+//
+// Queries:
+// top-k-5
+// top-k-20
+func (d *Devops) TopKHosts(qi query.Query, k int) {
+	interval := d.MustQueryInterval(devops.TopKDuration)
+
+	sql := fmt.Sprintf(`
+		SELECT
+			%s AS host,
+			avg(usage_user) AS mean_usage_user
+		FROM cpu
+		WHERE ts >= %s
+		  AND ts < %s
+		GROUP BY host
+		ORDER BY mean_usage_user DESC
+		LIMIT %d`,
+		hostnameField,
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
+		k)
+
+	humanLabel := d.GetTopKLabel("CrateDB", k)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// CPUMemJoin selects the mean usage_user of cpu and the mean used_percent of
+// mem per minute and host for N random hosts, joined on minute and host
+//
+// Queries:
+// cpu-mem-join-1
+// cpu-mem-join-8
+func (d *Devops) CPUMemJoin(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.CPUMemJoinDuration)
+	hosts, err := d.GetRandomHosts(nHosts)
+	panicIfErr(err)
+
+	sql := fmt.Sprintf(`
+		SELECT
+			c.minute,
+			c.host,
+			c.mean_usage_user,
+			m.mean_used_percent
+		FROM
+		  (
+			SELECT
+				date_trunc('minute', ts) AS minute,
+				%[1]s AS host,
+				avg(usage_user) AS mean_usage_user
+			FROM cpu
+			WHERE %[1]s IN (%[2]s)
+			  AND ts >= %[3]s
+			  AND ts < %[4]s
+			GROUP BY minute, host
+		  ) c
+		JOIN
+		  (
+			SELECT
+				date_trunc('minute', ts) AS minute,
+				%[1]s AS host,
+				avg(used_percent) AS mean_used_percent
+			FROM mem
+			WHERE %[1]s IN (%[2]s)
+			  AND ts >= %[3]s
+			  AND ts < %[4]s
+			GROUP BY minute, host
+		  ) m
+		ON c.minute = m.minute
+		  AND c.host = m.host
+		ORDER BY c.minute, c.host`,
+		hostnameField,
+		d.bindHosts(hosts),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
+
+	humanLabel := d.GetCPUMemJoinLabel("CrateDB", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// DownsampleAll selects the AVG of metrics in the group `cpu` per host per
+// hour over the whole time range of the dataset
+//
+// Queries:
+// downsample-1
+// downsample-all
+func (d *Devops) DownsampleAll(qi query.Query, numMetrics int) {
+	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
+	panicIfErr(err)
+	selectClauses := d.getSelectAggClauses("avg", metrics)
+
+	sql := fmt.Sprintf(`
+		SELECT
+			date_trunc('hour', ts) AS hour,
+			%[1]s AS host,
+			%[2]s
+		FROM cpu
+		WHERE ts >= %[3]s
+		  AND ts < %[4]s
+		GROUP BY hour, host
+		ORDER BY hour, host`,
+		hostnameField,
+		strings.Join(selectClauses, ", "),
+		d.bindTime(d.Interval.Start()),
+		d.bindTime(d.Interval.End()))
+
+	humanLabel := devops.GetDownsampleLabel("CrateDB", numMetrics)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, d.Interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// DiskFull selects the disks of N random hosts (or all hosts if N is 0) whose
+// used_percent exceeded devops.DiskFullThreshold in a random hour
+//
+// Queries:
+// disk-full-all
+// disk-full-1
+func (d *Devops) DiskFull(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.DiskFullDuration)
+	var hostWhereClause string
+	if nHosts > 0 {
+		hosts, err := d.GetRandomHosts(nHosts)
+		panicIfErr(err)
+		hostWhereClause = fmt.Sprintf("\n\t\t  AND %s IN (%s)", hostnameField, d.bindHosts(hosts))
+	}
+
+	sql := fmt.Sprintf(`
+		SELECT
+			%[1]s AS host,
+			tags['path'] AS path,
+			max(used_percent) AS max_used_percent
+		FROM disk
+		WHERE ts >= %[2]s
+		  AND ts < %[3]s%[4]s
+		GROUP BY host, path
+		HAVING max(used_percent) > %.1[5]f
+		ORDER BY max_used_percent DESC, host`,
+		hostnameField,
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
+		hostWhereClause,
+		devops.DiskFullThreshold)
+
+	humanLabel, err := d.GetDiskFullLabel("CrateDB", nHosts)
+	panicIfErr(err)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	qi.(*query.CrateDB).Table = []byte("disk")
+}
+
+// RedisHitRatio selects the share of redis keyspace lookups that were hits
+// per hour and host for N random hosts, from the increase of the hit and miss
+// counters within the hour
+//
+// Queries:
+// redis-hit-ratio-1
+// redis-hit-ratio-8
+func (d *Devops) RedisHitRatio(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.RedisHitRatioDuration)
+	hosts, err := d.GetRandomHosts(nHosts)
+	panicIfErr(err)
+
+	sql := fmt.Sprintf(`
+		SELECT
+			hour,
+			host,
+			CASE WHEN hits + misses = 0 THEN NULL ELSE hits / (hits + misses) END AS hit_ratio
+		FROM
+		  (
+			SELECT
+				date_trunc('hour', ts) AS hour,
+				%s AS host,
+				max(keyspace_hits) - min(keyspace_hits) AS hits,
+				max(keyspace_misses) - min(keyspace_misses) AS misses
+			FROM redis
+			WHERE %s IN (%s)
+			  AND ts >= %s
+			  AND ts < %s
+			GROUP BY hour, host
+		  ) r
+		ORDER BY hour, host`,
+		hostnameField,
+		hostnameField,
+		d.bindHosts(hosts),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
+
+	humanLabel := d.GetRedisHitRatioLabel("CrateDB", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	qi.(*query.CrateDB).Table = []byte("redis")
+}
+
+// NginxRequestRate selects the nginx requests per second of each service per
+// minute over a random hour, from the increase of the requests counter of
+// every host within the minute
+//
+// Queries:
+// nginx-rate-per-service
+func (d *Devops) NginxRequestRate(qi query.Query) {
+	interval := d.MustQueryInterval(devops.NginxRateDuration)
+
+	sql := fmt.Sprintf(`
+		SELECT
+			minute,
+			service,
+			sum(requests) / 60 AS requests_per_sec
+		FROM
+		  (
+			SELECT
+				date_trunc('minute', ts) AS minute,
+				%s AS host,
+				tags['service'] AS service,
+				max(requests) - min(requests) AS requests
+			FROM nginx
+			WHERE ts >= %s
+			  AND ts < %s
+			GROUP BY minute, host, service
+		  ) r
+		GROUP BY minute, service
+		ORDER BY minute, service`,
+		hostnameField,
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
+
+	humanLabel := d.GetNginxRateLabel("CrateDB")
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	qi.(*query.CrateDB).Table = []byte("nginx")
+}
+
+// GroupByTag selects the mean usage_user per hour and value of groupTag of the
+// hosts with random values of filterTags
+//
+// Queries:
+// tag-groupby-datacenter-service
+// tag-groupby-team-region
+func (d *Devops) GroupByTag(qi query.Query, groupTag string, filterTags []string) {
+	interval := d.MustQueryInterval(devops.TagGroupByDuration)
+	filters, err := d.GetRandomTagFilters(filterTags)
+	panicIfErr(err)
+
+	filterClauses := make([]string, len(filters))
+	for i, f := range filters {
+		filterClauses[i] = fmt.Sprintf("tags['%s'] = %s", f.Key, d.bindString(f.Value))
+	}
+
+	sql := fmt.Sprintf(`
+		SELECT
+			date_trunc('hour', ts) AS hour,
+			tags['%[1]s'] AS %[1]s,
+			avg(usage_user) AS mean_usage_user
+		FROM cpu
+		WHERE %[2]s
+		  AND ts >= %[3]s
+		  AND ts < %[4]s
+		GROUP BY hour, %[1]s
+		ORDER BY hour, %[1]s`,
+		groupTag,
+		strings.Join(filterClauses, "\n\t\t  AND "),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
+
+	humanLabel := d.GetTagGroupByLabel("CrateDB", groupTag, filterTags)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
@@ -233,4 +634,6 @@ func (d *Devops) fillInQuery(qi query.Query, humanLabel, humanDesc, sql string)
 	q.HumanDescription = []byte(humanDesc)
 	q.Table = []byte("cpu")
 	q.SqlQuery = []byte(sql)
+	q.Params = d.params
+	d.params = nil
 }
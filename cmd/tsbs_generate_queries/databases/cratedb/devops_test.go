@@ -3,6 +3,7 @@ package cratedb
 import (
 	"fmt"
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	internalutils "github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/query"
 	"math/rand"
 	"reflect"
@@ -246,3 +247,570 @@ func TestDevopsGroupByTimeQuery(t *testing.T) {
 			got.SqlQuery, want.SqlQuery)
 	}
 }
+
+func TestDevopsCounterRateQuery(t *testing.T) {
+	rand.Seed(123) // Setting seed for testing purposes.
+	start := time.Date(2006, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2006, 1, 10, 20, 0, 0, 0, time.UTC)
+	d := NewDevops(start, end, 10)
+
+	want := &query.CrateDB{
+		HumanLabel:       []byte("CrateDB rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m"),
+		HumanDescription: []byte("CrateDB rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m: 2006-01-05T01:16:22Z"),
+		Table:            []byte("net"),
+		SqlQuery: []byte(`
+		SELECT
+			minute,
+			host,
+			(max_bytes_recv - lag(max_bytes_recv) OVER (PARTITION BY host ORDER BY minute)) / 60 AS rate_bytes_recv
+		FROM
+		  (
+			SELECT
+				date_trunc('minute', ts) AS minute,
+				tags['hostname'] AS host,
+				max(bytes_recv) AS max_bytes_recv
+			FROM net
+			WHERE tags['hostname'] IN ('host_9', 'host_3')
+			  AND ts >= 1136423782646
+			  AND ts < 1136427382646
+			GROUP BY minute, host
+		  ) c
+		ORDER BY host, minute`),
+	}
+
+	got := &query.CrateDB{}
+	d.CounterRate(got, "net", "bytes_recv", 2)
+
+	if !reflect.DeepEqual(want.HumanLabel, got.HumanLabel) {
+		t.Errorf("incorrect human label:\ngot: %s\n want:\n %s",
+			got.HumanLabel, want.HumanLabel)
+	}
+	if !reflect.DeepEqual(want.HumanDescription, got.HumanDescription) {
+		t.Errorf("incorrect human description:\ngot: %s\n want:\n %s",
+			got.HumanDescription, want.HumanDescription)
+	}
+	if !reflect.DeepEqual(want.SqlQuery, got.SqlQuery) {
+		t.Errorf("incorrect sql query:\ngot: %s\n want:\n %s",
+			got.SqlQuery, want.SqlQuery)
+	}
+	if !reflect.DeepEqual(want.Table, got.Table) {
+		t.Errorf("incorrect table:\ngot: %s\n want:\n %s",
+			got.Table, want.Table)
+	}
+}
+
+func TestDevopsCPUPercentilesQuery(t *testing.T) {
+	rand.Seed(123) // Setting seed for testing purposes.
+	start := time.Date(2006, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2006, 1, 10, 20, 0, 0, 0, time.UTC)
+	d := NewDevops(start, end, 10)
+
+	want := &query.CrateDB{
+		HumanLabel:       []byte("CrateDB percentiles of usage_user, random    1 hosts, random 12h0m0s by 1h"),
+		HumanDescription: []byte("CrateDB percentiles of usage_user, random    1 hosts, random 12h0m0s by 1h: 2006-01-07T02:16:22Z"),
+		Table:            []byte("cpu"),
+		SqlQuery: []byte(`
+		SELECT
+			date_trunc('hour', ts) AS hour,
+			percentile(usage_user, 0.50) AS p50_usage_user, percentile(usage_user, 0.90) AS p90_usage_user, percentile(usage_user, 0.99) AS p99_usage_user
+		FROM cpu
+		WHERE tags['hostname'] IN ('host_9')
+		  AND ts >= 1136600182646
+		  AND ts < 1136643382646
+		GROUP BY hour
+		ORDER BY hour`),
+	}
+
+	got := &query.CrateDB{}
+	d.CPUPercentiles(got, 1)
+
+	if !reflect.DeepEqual(want.HumanLabel, got.HumanLabel) {
+		t.Errorf("incorrect human label:\ngot: %s\n want:\n %s",
+			got.HumanLabel, want.HumanLabel)
+	}
+	if !reflect.DeepEqual(want.HumanDescription, got.HumanDescription) {
+		t.Errorf("incorrect human description:\ngot: %s\n want:\n %s",
+			got.HumanDescription, want.HumanDescription)
+	}
+	if !reflect.DeepEqual(want.SqlQuery, got.SqlQuery) {
+		t.Errorf("incorrect sql query:\ngot: %s\n want:\n %s",
+			got.SqlQuery, want.SqlQuery)
+	}
+	if !reflect.DeepEqual(want.Table, got.Table) {
+		t.Errorf("incorrect table:\ngot: %s\n want:\n %s",
+			got.Table, want.Table)
+	}
+}
+
+func TestDevopsTopKHostsQuery(t *testing.T) {
+	rand.Seed(123) // Setting seed for testing purposes.
+	start := time.Date(2006, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2006, 1, 10, 20, 0, 0, 0, time.UTC)
+	d := NewDevops(start, end, 10)
+
+	want := &query.CrateDB{
+		HumanLabel:       []byte("CrateDB top 5 hosts by mean usage_user, random 1h0m0s"),
+		HumanDescription: []byte("CrateDB top 5 hosts by mean usage_user, random 1h0m0s: 2006-01-05T01:16:22Z"),
+		Table:            []byte("cpu"),
+		SqlQuery: []byte(`
+		SELECT
+			tags['hostname'] AS host,
+			avg(usage_user) AS mean_usage_user
+		FROM cpu
+		WHERE ts >= 1136423782646
+		  AND ts < 1136427382646
+		GROUP BY host
+		ORDER BY mean_usage_user DESC
+		LIMIT 5`),
+	}
+
+	got := &query.CrateDB{}
+	d.TopKHosts(got, 5)
+
+	if !reflect.DeepEqual(want.HumanLabel, got.HumanLabel) {
+		t.Errorf("incorrect human label:\ngot: %s\n want:\n %s",
+			got.HumanLabel, want.HumanLabel)
+	}
+	if !reflect.DeepEqual(want.HumanDescription, got.HumanDescription) {
+		t.Errorf("incorrect human description:\ngot: %s\n want:\n %s",
+			got.HumanDescription, want.HumanDescription)
+	}
+	if !reflect.DeepEqual(want.SqlQuery, got.SqlQuery) {
+		t.Errorf("incorrect sql query:\ngot: %s\n want:\n %s",
+			got.SqlQuery, want.SqlQuery)
+	}
+	if !reflect.DeepEqual(want.Table, got.Table) {
+		t.Errorf("incorrect table:\ngot: %s\n want:\n %s",
+			got.Table, want.Table)
+	}
+}
+
+func TestDevopsCPUMemJoinQuery(t *testing.T) {
+	rand.Seed(123) // Setting seed for testing purposes.
+	start := time.Date(2006, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2006, 1, 10, 20, 0, 0, 0, time.UTC)
+	d := NewDevops(start, end, 10)
+
+	want := &query.CrateDB{
+		HumanLabel:       []byte("CrateDB cpu joined with mem, random    2 hosts, random 1h0m0s by 1m"),
+		HumanDescription: []byte("CrateDB cpu joined with mem, random    2 hosts, random 1h0m0s by 1m: 2006-01-05T01:16:22Z"),
+		Table:            []byte("cpu"),
+		SqlQuery: []byte(`
+		SELECT
+			c.minute,
+			c.host,
+			c.mean_usage_user,
+			m.mean_used_percent
+		FROM
+		  (
+			SELECT
+				date_trunc('minute', ts) AS minute,
+				tags['hostname'] AS host,
+				avg(usage_user) AS mean_usage_user
+			FROM cpu
+			WHERE tags['hostname'] IN ('host_9', 'host_3')
+			  AND ts >= 1136423782646
+			  AND ts < 1136427382646
+			GROUP BY minute, host
+		  ) c
+		JOIN
+		  (
+			SELECT
+				date_trunc('minute', ts) AS minute,
+				tags['hostname'] AS host,
+				avg(used_percent) AS mean_used_percent
+			FROM mem
+			WHERE tags['hostname'] IN ('host_9', 'host_3')
+			  AND ts >= 1136423782646
+			  AND ts < 1136427382646
+			GROUP BY minute, host
+		  ) m
+		ON c.minute = m.minute
+		  AND c.host = m.host
+		ORDER BY c.minute, c.host`),
+	}
+
+	got := &query.CrateDB{}
+	d.CPUMemJoin(got, 2)
+
+	if !reflect.DeepEqual(want.HumanLabel, got.HumanLabel) {
+		t.Errorf("incorrect human label:\ngot: %s\n want:\n %s",
+			got.HumanLabel, want.HumanLabel)
+	}
+	if !reflect.DeepEqual(want.HumanDescription, got.HumanDescription) {
+		t.Errorf("incorrect human description:\ngot: %s\n want:\n %s",
+			got.HumanDescription, want.HumanDescription)
+	}
+	if !reflect.DeepEqual(want.SqlQuery, got.SqlQuery) {
+		t.Errorf("incorrect sql query:\ngot: %s\n want:\n %s",
+			got.SqlQuery, want.SqlQuery)
+	}
+	if !reflect.DeepEqual(want.Table, got.Table) {
+		t.Errorf("incorrect table:\ngot: %s\n want:\n %s",
+			got.Table, want.Table)
+	}
+}
+
+func TestDevopsDownsampleAllQuery(t *testing.T) {
+	rand.Seed(123) // Setting seed for testing purposes.
+	start := time.Date(2006, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2006, 1, 10, 20, 0, 0, 0, time.UTC)
+	d := NewDevops(start, end, 10)
+
+	want := &query.CrateDB{
+		HumanLabel:       []byte("CrateDB mean of 2 metrics, all hosts, all data by 1h"),
+		HumanDescription: []byte("CrateDB mean of 2 metrics, all hosts, all data by 1h: 2006-01-01T10:00:00Z"),
+		Table:            []byte("cpu"),
+		SqlQuery: []byte(`
+		SELECT
+			date_trunc('hour', ts) AS hour,
+			tags['hostname'] AS host,
+			avg(usage_user) AS avg_usage_user, avg(usage_system) AS avg_usage_system
+		FROM cpu
+		WHERE ts >= 1136109600000
+		  AND ts < 1136923200000
+		GROUP BY hour, host
+		ORDER BY hour, host`),
+	}
+
+	got := &query.CrateDB{}
+	d.DownsampleAll(got, 2)
+
+	if !reflect.DeepEqual(want.HumanLabel, got.HumanLabel) {
+		t.Errorf("incorrect human label:\ngot: %s\n want:\n %s",
+			got.HumanLabel, want.HumanLabel)
+	}
+	if !reflect.DeepEqual(want.HumanDescription, got.HumanDescription) {
+		t.Errorf("incorrect human description:\ngot: %s\n want:\n %s",
+			got.HumanDescription, want.HumanDescription)
+	}
+	if !reflect.DeepEqual(want.SqlQuery, got.SqlQuery) {
+		t.Errorf("incorrect sql query:\ngot: %s\n want:\n %s",
+			got.SqlQuery, want.SqlQuery)
+	}
+	if !reflect.DeepEqual(want.Table, got.Table) {
+		t.Errorf("incorrect table:\ngot: %s\n want:\n %s",
+			got.Table, want.Table)
+	}
+}
+
+func TestDevopsDiskFullQuery(t *testing.T) {
+	rand.Seed(123) // Setting seed for testing purposes.
+	start := time.Date(2006, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2006, 1, 10, 20, 0, 0, 0, time.UTC)
+	d := NewDevops(start, end, 10)
+
+	want := &query.CrateDB{
+		HumanLabel:       []byte("CrateDB disk over threshold, all hosts, random 1h0m0s"),
+		HumanDescription: []byte("CrateDB disk over threshold, all hosts, random 1h0m0s: 2006-01-05T01:16:22Z"),
+		Table:            []byte("disk"),
+		SqlQuery: []byte(`
+		SELECT
+			tags['hostname'] AS host,
+			tags['path'] AS path,
+			max(used_percent) AS max_used_percent
+		FROM disk
+		WHERE ts >= 1136423782646
+		  AND ts < 1136427382646
+		GROUP BY host, path
+		HAVING max(used_percent) > 90.0
+		ORDER BY max_used_percent DESC, host`),
+	}
+
+	got := &query.CrateDB{}
+	d.DiskFull(got, 0)
+
+	if !reflect.DeepEqual(want.HumanLabel, got.HumanLabel) {
+		t.Errorf("incorrect human label:\ngot: %s\n want:\n %s",
+			got.HumanLabel, want.HumanLabel)
+	}
+	if !reflect.DeepEqual(want.HumanDescription, got.HumanDescription) {
+		t.Errorf("incorrect human description:\ngot: %s\n want:\n %s",
+			got.HumanDescription, want.HumanDescription)
+	}
+	if !reflect.DeepEqual(want.SqlQuery, got.SqlQuery) {
+		t.Errorf("incorrect sql query:\ngot: %s\n want:\n %s",
+			got.SqlQuery, want.SqlQuery)
+	}
+	if !reflect.DeepEqual(want.Table, got.Table) {
+		t.Errorf("incorrect table:\ngot: %s\n want:\n %s",
+			got.Table, want.Table)
+	}
+}
+
+func TestDevopsDiskFullForHostsQuery(t *testing.T) {
+	rand.Seed(123) // Setting seed for testing purposes.
+	start := time.Date(2006, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2006, 1, 10, 20, 0, 0, 0, time.UTC)
+	d := NewDevops(start, end, 10)
+
+	want := &query.CrateDB{
+		HumanLabel:       []byte("CrateDB disk over threshold, 1 host(s), random 1h0m0s"),
+		HumanDescription: []byte("CrateDB disk over threshold, 1 host(s), random 1h0m0s: 2006-01-05T01:16:22Z"),
+		Table:            []byte("disk"),
+		SqlQuery: []byte(`
+		SELECT
+			tags['hostname'] AS host,
+			tags['path'] AS path,
+			max(used_percent) AS max_used_percent
+		FROM disk
+		WHERE ts >= 1136423782646
+		  AND ts < 1136427382646
+		  AND tags['hostname'] IN ('host_9')
+		GROUP BY host, path
+		HAVING max(used_percent) > 90.0
+		ORDER BY max_used_percent DESC, host`),
+	}
+
+	got := &query.CrateDB{}
+	d.DiskFull(got, 1)
+
+	if !reflect.DeepEqual(want.HumanLabel, got.HumanLabel) {
+		t.Errorf("incorrect human label:\ngot: %s\n want:\n %s",
+			got.HumanLabel, want.HumanLabel)
+	}
+	if !reflect.DeepEqual(want.HumanDescription, got.HumanDescription) {
+		t.Errorf("incorrect human description:\ngot: %s\n want:\n %s",
+			got.HumanDescription, want.HumanDescription)
+	}
+	if !reflect.DeepEqual(want.SqlQuery, got.SqlQuery) {
+		t.Errorf("incorrect sql query:\ngot: %s\n want:\n %s",
+			got.SqlQuery, want.SqlQuery)
+	}
+	if !reflect.DeepEqual(want.Table, got.Table) {
+		t.Errorf("incorrect table:\ngot: %s\n want:\n %s",
+			got.Table, want.Table)
+	}
+}
+
+func TestDevopsRedisHitRatioQuery(t *testing.T) {
+	rand.Seed(123) // Setting seed for testing purposes.
+	start := time.Date(2006, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2006, 1, 10, 20, 0, 0, 0, time.UTC)
+	d := NewDevops(start, end, 10)
+
+	want := &query.CrateDB{
+		HumanLabel:       []byte("CrateDB redis keyspace hit ratio, random    2 hosts, random 12h0m0s by 1h"),
+		HumanDescription: []byte("CrateDB redis keyspace hit ratio, random    2 hosts, random 12h0m0s by 1h: 2006-01-07T02:16:22Z"),
+		Table:            []byte("redis"),
+		SqlQuery: []byte(`
+		SELECT
+			hour,
+			host,
+			CASE WHEN hits + misses = 0 THEN NULL ELSE hits / (hits + misses) END AS hit_ratio
+		FROM
+		  (
+			SELECT
+				date_trunc('hour', ts) AS hour,
+				tags['hostname'] AS host,
+				max(keyspace_hits) - min(keyspace_hits) AS hits,
+				max(keyspace_misses) - min(keyspace_misses) AS misses
+			FROM redis
+			WHERE tags['hostname'] IN ('host_9', 'host_3')
+			  AND ts >= 1136600182646
+			  AND ts < 1136643382646
+			GROUP BY hour, host
+		  ) r
+		ORDER BY hour, host`),
+	}
+
+	got := &query.CrateDB{}
+	d.RedisHitRatio(got, 2)
+
+	if !reflect.DeepEqual(want.HumanLabel, got.HumanLabel) {
+		t.Errorf("incorrect human label:\ngot: %s\n want:\n %s",
+			got.HumanLabel, want.HumanLabel)
+	}
+	if !reflect.DeepEqual(want.HumanDescription, got.HumanDescription) {
+		t.Errorf("incorrect human description:\ngot: %s\n want:\n %s",
+			got.HumanDescription, want.HumanDescription)
+	}
+	if !reflect.DeepEqual(want.SqlQuery, got.SqlQuery) {
+		t.Errorf("incorrect sql query:\ngot: %s\n want:\n %s",
+			got.SqlQuery, want.SqlQuery)
+	}
+	if !reflect.DeepEqual(want.Table, got.Table) {
+		t.Errorf("incorrect table:\ngot: %s\n want:\n %s",
+			got.Table, want.Table)
+	}
+}
+
+func TestDevopsNginxRequestRateQuery(t *testing.T) {
+	rand.Seed(123) // Setting seed for testing purposes.
+	start := time.Date(2006, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2006, 1, 10, 20, 0, 0, 0, time.UTC)
+	d := NewDevops(start, end, 10)
+
+	want := &query.CrateDB{
+		HumanLabel:       []byte("CrateDB nginx requests per second per service, all hosts, random 1h0m0s by 1m"),
+		HumanDescription: []byte("CrateDB nginx requests per second per service, all hosts, random 1h0m0s by 1m: 2006-01-05T01:16:22Z"),
+		Table:            []byte("nginx"),
+		SqlQuery: []byte(`
+		SELECT
+			minute,
+			service,
+			sum(requests) / 60 AS requests_per_sec
+		FROM
+		  (
+			SELECT
+				date_trunc('minute', ts) AS minute,
+				tags['hostname'] AS host,
+				tags['service'] AS service,
+				max(requests) - min(requests) AS requests
+			FROM nginx
+			WHERE ts >= 1136423782646
+			  AND ts < 1136427382646
+			GROUP BY minute, host, service
+		  ) r
+		GROUP BY minute, service
+		ORDER BY minute, service`),
+	}
+
+	got := &query.CrateDB{}
+	d.NginxRequestRate(got)
+
+	if !reflect.DeepEqual(want.HumanLabel, got.HumanLabel) {
+		t.Errorf("incorrect human label:\ngot: %s\n want:\n %s",
+			got.HumanLabel, want.HumanLabel)
+	}
+	if !reflect.DeepEqual(want.HumanDescription, got.HumanDescription) {
+		t.Errorf("incorrect human description:\ngot: %s\n want:\n %s",
+			got.HumanDescription, want.HumanDescription)
+	}
+	if !reflect.DeepEqual(want.SqlQuery, got.SqlQuery) {
+		t.Errorf("incorrect sql query:\ngot: %s\n want:\n %s",
+			got.SqlQuery, want.SqlQuery)
+	}
+	if !reflect.DeepEqual(want.Table, got.Table) {
+		t.Errorf("incorrect table:\ngot: %s\n want:\n %s",
+			got.Table, want.Table)
+	}
+}
+
+func TestDevopsGroupByTagQuery(t *testing.T) {
+	rand.Seed(123) // Setting seed for testing purposes.
+	start := time.Date(2006, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2006, 1, 10, 20, 0, 0, 0, time.UTC)
+	d := NewDevops(start, end, 10)
+
+	want := &query.CrateDB{
+		HumanLabel:       []byte("CrateDB mean usage_user per datacenter, random service and service_environment, random 12h0m0s by 1h"),
+		HumanDescription: []byte("CrateDB mean usage_user per datacenter, random service and service_environment, random 12h0m0s by 1h: 2006-01-07T02:16:22Z"),
+		Table:            []byte("cpu"),
+		SqlQuery: []byte(`
+		SELECT
+			date_trunc('hour', ts) AS hour,
+			tags['datacenter'] AS datacenter,
+			avg(usage_user) AS mean_usage_user
+		FROM cpu
+		WHERE tags['service'] = '9'
+		  AND tags['service_environment'] = 'staging'
+		  AND ts >= 1136600182646
+		  AND ts < 1136643382646
+		GROUP BY hour, datacenter
+		ORDER BY hour, datacenter`),
+	}
+
+	got := &query.CrateDB{}
+	d.GroupByTag(got, "datacenter", []string{"service", "service_environment"})
+
+	if !reflect.DeepEqual(want.HumanLabel, got.HumanLabel) {
+		t.Errorf("incorrect human label:\ngot: %s\n want:\n %s",
+			got.HumanLabel, want.HumanLabel)
+	}
+	if !reflect.DeepEqual(want.HumanDescription, got.HumanDescription) {
+		t.Errorf("incorrect human description:\ngot: %s\n want:\n %s",
+			got.HumanDescription, want.HumanDescription)
+	}
+	if !reflect.DeepEqual(want.SqlQuery, got.SqlQuery) {
+		t.Errorf("incorrect sql query:\ngot: %s\n want:\n %s",
+			got.SqlQuery, want.SqlQuery)
+	}
+	if !reflect.DeepEqual(want.Table, got.Table) {
+		t.Errorf("incorrect table:\ngot: %s\n want:\n %s",
+			got.Table, want.Table)
+	}
+}
+
+func TestDevopsGroupByTimeQueryPrepared(t *testing.T) {
+	// return the same set of random hosts deterministic
+	rand.Seed(101)
+
+	start := time.Date(2006, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2006, 1, 1, 20, 0, 0, 0, time.UTC)
+	d := NewDevops(start, end, 10)
+	d.UsePrepared = true
+
+	want := &query.CrateDB{
+		Table: []byte("cpu"),
+		SqlQuery: []byte(`
+		SELECT
+			date_trunc('minute', ts) as minute,
+			max(usage_user) AS max_usage_user, max(usage_system) AS max_usage_system
+		FROM cpu
+		WHERE tags['hostname'] IN ($1, $2)
+		  AND ts >= $3
+		  AND ts < $4
+		GROUP BY minute
+		ORDER BY minute ASC`),
+		Params: []interface{}{"host_2", "host_5", int64(1136115302666), int64(1136144102666)},
+	}
+
+	got := &query.CrateDB{}
+	d.GroupByTime(got, 2, 2, devops.MaxAllDuration)
+
+	if !reflect.DeepEqual(want.SqlQuery, got.SqlQuery) {
+		t.Errorf("incorrect sql query:\ngot: %s\n want:\n %s",
+			got.SqlQuery, want.SqlQuery)
+	}
+	if !reflect.DeepEqual(want.Params, got.Params) {
+		t.Errorf("incorrect params:\ngot: %v\n want:\n %v",
+			got.Params, want.Params)
+	}
+}
+
+func TestDevopsGroupByTimeConfiguredWindows(t *testing.T) {
+	rand.Seed(123) // Setting seed for testing purposes.
+	start := time.Date(2006, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := time.Date(2006, 1, 10, 20, 0, 0, 0, time.UTC)
+	d := NewDevops(start, end, 10)
+	if err := d.ConfigureWindows(10*time.Minute, 5*time.Minute, internalutils.WindowRecent); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := &query.CrateDB{
+		HumanLabel:       []byte("CrateDB 1 cpu metric(s), random    1 hosts, recent 10m0s by 5m"),
+		HumanDescription: []byte("CrateDB 1 cpu metric(s), random    1 hosts, recent 10m0s by 5m: 2006-01-10T19:50:00Z"),
+		Table:            []byte("cpu"),
+		SqlQuery: []byte(`
+		SELECT
+			date_bin('300 seconds'::interval, ts, 0) as minute,
+			max(usage_user) AS max_usage_user
+		FROM cpu
+		WHERE tags['hostname'] IN ('host_5')
+		  AND ts >= 1136922600000
+		  AND ts < 1136923200000
+		GROUP BY minute
+		ORDER BY minute ASC`),
+	}
+
+	got := &query.CrateDB{}
+	d.GroupByTime(got, 1, 1, time.Hour)
+
+	if !reflect.DeepEqual(want.HumanLabel, got.HumanLabel) {
+		t.Errorf("incorrect human label:\ngot: %s\n want:\n %s",
+			got.HumanLabel, want.HumanLabel)
+	}
+	if !reflect.DeepEqual(want.HumanDescription, got.HumanDescription) {
+		t.Errorf("incorrect human description:\ngot: %s\n want:\n %s",
+			got.HumanDescription, want.HumanDescription)
+	}
+	if !reflect.DeepEqual(want.SqlQuery, got.SqlQuery) {
+		t.Errorf("incorrect sql query:\ngot: %s\n want:\n %s",
+			got.SqlQuery, want.SqlQuery)
+	}
+	if !reflect.DeepEqual(want.Table, got.Table) {
+		t.Errorf("incorrect table:\ngot: %s\n want:\n %s",
+			got.Table, want.Table)
+	}
+}
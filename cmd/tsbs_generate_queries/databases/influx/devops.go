@@ -69,15 +69,16 @@ func (d *Devops) getSelectClausesAggMetrics(agg string, metrics []string) []stri
 // AND time >= '$HOUR_START' AND time < '$HOUR_END'
 // GROUP BY minute ORDER BY minute ASC
 func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange time.Duration) {
-	interval := d.Interval.MustRandWindow(timeRange)
+	interval := d.MustQueryInterval(timeRange)
+	bucket := devops.ShortDuration(d.GetSingleGroupByBucket())
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
 	selectClauses := d.getSelectClausesAggMetrics("max", metrics)
 	whereHosts := d.getHostWhereString(nHosts)
 
-	humanLabel := fmt.Sprintf("Influx %d cpu metric(s), random %4d hosts, random %s by 1m", numMetrics, nHosts, timeRange)
+	humanLabel := fmt.Sprintf("Influx %d cpu metric(s), random %4d hosts, %s by %s", numMetrics, nHosts, d.WindowLabel(timeRange), bucket)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
-	influxql := fmt.Sprintf("SELECT %s from cpu where %s and time >= '%s' and time < '%s' group by time(1m)", strings.Join(selectClauses, ", "), whereHosts, interval.StartString(), interval.EndString())
+	influxql := fmt.Sprintf("SELECT %s from cpu where %s and time >= '%s' and time < '%s' group by time(%s)", strings.Join(selectClauses, ", "), whereHosts, interval.StartString(), interval.EndString(), bucket)
 	d.fillInQuery(qi, humanLabel, humanDesc, influxql)
 }
 
@@ -87,7 +88,7 @@ func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange t
 // GROUP BY t ORDER BY t DESC
 // LIMIT $LIMIT
 func (d *Devops) GroupByOrderByLimit(qi query.Query) {
-	interval := d.Interval.MustRandWindow(time.Hour)
+	interval := d.MustQueryInterval(time.Hour)
 	where := fmt.Sprintf("WHERE time < '%s'", interval.EndString())
 
 	humanLabel := "Influx max cpu over last 5 min-intervals (random end)"
@@ -106,10 +107,10 @@ func (d *Devops) GroupByOrderByLimit(qi query.Query) {
 func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
-	interval := d.Interval.MustRandWindow(devops.DoubleGroupByDuration)
+	interval := d.MustQueryInterval(devops.DoubleGroupByDuration)
 	selectClauses := d.getSelectClausesAggMetrics("mean", metrics)
 
-	humanLabel := devops.GetDoubleGroupByLabel("Influx", numMetrics)
+	humanLabel := d.GetDoubleGroupByLabel("Influx", numMetrics)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	influxql := fmt.Sprintf("SELECT %s from cpu where time >= '%s' and time < '%s' group by time(1h),hostname", strings.Join(selectClauses, ", "), interval.StartString(), interval.EndString())
 	d.fillInQuery(qi, humanLabel, humanDesc, influxql)
@@ -123,11 +124,11 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 // AND time >= '$HOUR_START' AND time < '$HOUR_END'
 // GROUP BY hour ORDER BY hour
 func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
-	interval := d.Interval.MustRandWindow(devops.MaxAllDuration)
+	interval := d.MustQueryInterval(devops.MaxAllDuration)
 	whereHosts := d.getHostWhereString(nHosts)
 	selectClauses := d.getSelectClausesAggMetrics("max", devops.GetAllCPUMetrics())
 
-	humanLabel := devops.GetMaxAllLabel("Influx", nHosts)
+	humanLabel := d.GetMaxAllLabel("Influx", nHosts)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	influxql := fmt.Sprintf("SELECT %s from cpu where %s and time >= '%s' and time < '%s' group by time(1m)", strings.Join(selectClauses, ","), whereHosts, interval.StartString(), interval.EndString())
 	d.fillInQuery(qi, humanLabel, humanDesc, influxql)
@@ -150,7 +151,7 @@ func (d *Devops) LastPointPerHost(qi query.Query) {
 // AND time >= '$TIME_START' AND time < '$TIME_END'
 // AND (hostname = '$HOST' OR hostname = '$HOST2'...)
 func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
-	interval := d.Interval.MustRandWindow(devops.HighCPUDuration)
+	interval := d.MustQueryInterval(devops.HighCPUDuration)
 
 	var hostWhereClause string
 	if nHosts == 0 {
@@ -166,6 +167,164 @@ func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
 	d.fillInQuery(qi, humanLabel, humanDesc, influxql)
 }
 
+// CounterRate selects the per-second rate of a counter of a measurement per
+// minute for nHosts hosts, from the maximum of the counter in consecutive
+// minutes, e.g. in pseudo-SQL:
+//
+// SELECT non_negative_derivative(max(counter), 1s)
+// FROM measurement
+// WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY time(1m), hostname
+func (d *Devops) CounterRate(qi query.Query, measurement, counter string, nHosts int) {
+	interval := d.MustQueryInterval(devops.RateDuration)
+	whereHosts := d.getHostWhereString(nHosts)
+
+	humanLabel := d.GetRateLabel("Influx", measurement, counter, nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	influxql := fmt.Sprintf("SELECT non_negative_derivative(max(%s), 1s) from %s where %s and time >= '%s' and time < '%s' group by time(1m),hostname", counter, measurement, whereHosts, interval.StartString(), interval.EndString())
+	d.fillInQuery(qi, humanLabel, humanDesc, influxql)
+}
+
+// CPUPercentiles selects percentiles of usage_user per hour for nHosts hosts,
+// e.g. in pseudo-SQL:
+//
+// SELECT percentile(usage_user, 50), ...
+// FROM cpu
+// WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY time(1h)
+func (d *Devops) CPUPercentiles(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.PercentilesDuration)
+	whereHosts := d.getHostWhereString(nHosts)
+
+	percentiles := devops.GetCPUPercentiles()
+	selectClauses := make([]string, len(percentiles))
+	for i, p := range percentiles {
+		selectClauses[i] = fmt.Sprintf("percentile(usage_user, %d)", p)
+	}
+
+	humanLabel := d.GetPercentilesLabel("Influx", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	influxql := fmt.Sprintf("SELECT %s from cpu where %s and time >= '%s' and time < '%s' group by time(1h)", strings.Join(selectClauses, ", "), whereHosts, interval.StartString(), interval.EndString())
+	d.fillInQuery(qi, humanLabel, humanDesc, influxql)
+}
+
+// TopKHosts selects the k hosts with the highest mean usage_user over a random
+// hour, e.g. in pseudo-SQL:
+//
+// SELECT top(mean, hostname, $K)
+// FROM (SELECT mean(usage_user) FROM cpu WHERE time >= '$HOUR_START' AND time < '$HOUR_END' GROUP BY hostname)
+func (d *Devops) TopKHosts(qi query.Query, k int) {
+	interval := d.MustQueryInterval(devops.TopKDuration)
+
+	humanLabel := d.GetTopKLabel("Influx", k)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	influxql := fmt.Sprintf("SELECT top(mean, hostname, %d) from (SELECT mean(usage_user) from cpu where time >= '%s' and time < '%s' group by hostname)", k, interval.StartString(), interval.EndString())
+	d.fillInQuery(qi, humanLabel, humanDesc, influxql)
+}
+
+// DownsampleAll selects the MEAN of numMetrics metrics under 'cpu' per host per
+// hour over the whole time range of the dataset, e.g. in pseudo-SQL:
+//
+// SELECT MEAN(metric1), ..., MEAN(metricN)
+// FROM cpu
+// WHERE time >= '$DATASET_START' AND time < '$DATASET_END'
+// GROUP BY time(1h), hostname
+func (d *Devops) DownsampleAll(qi query.Query, numMetrics int) {
+	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
+	panicIfErr(err)
+	selectClauses := d.getSelectClausesAggMetrics("mean", metrics)
+
+	humanLabel := devops.GetDownsampleLabel("Influx", numMetrics)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, d.Interval.StartString())
+	influxql := fmt.Sprintf("SELECT %s from cpu where time >= '%s' and time < '%s' group by time(1h),hostname", strings.Join(selectClauses, ", "), d.Interval.StartString(), d.Interval.EndString())
+	d.fillInQuery(qi, humanLabel, humanDesc, influxql)
+}
+
+// DiskFull selects the disks of nHosts hosts (or all hosts if nHosts is 0)
+// whose used_percent exceeded devops.DiskFullThreshold in a random hour,
+// e.g. in pseudo-SQL:
+//
+// SELECT *
+// FROM (SELECT max(used_percent) AS max_used_percent FROM disk WHERE ... GROUP BY hostname, path)
+// WHERE max_used_percent > $THRESHOLD
+func (d *Devops) DiskFull(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.DiskFullDuration)
+
+	var hostWhereClause string
+	if nHosts > 0 {
+		hostWhereClause = fmt.Sprintf("%s and ", d.getHostWhereString(nHosts))
+	}
+
+	humanLabel, err := d.GetDiskFullLabel("Influx", nHosts)
+	panicIfErr(err)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	influxql := fmt.Sprintf("SELECT * from (SELECT max(used_percent) as max_used_percent from disk where %stime >= '%s' and time < '%s' group by hostname,path) where max_used_percent > %0.1f", hostWhereClause, interval.StartString(), interval.EndString(), devops.DiskFullThreshold)
+	d.fillInQuery(qi, humanLabel, humanDesc, influxql)
+}
+
+// RedisHitRatio selects the share of redis keyspace lookups that were hits
+// per hour and host for nHosts hosts, from the increase of the hit and miss
+// counters within the hour, e.g. in pseudo-SQL:
+//
+// SELECT spread(keyspace_hits) / (spread(keyspace_hits) + spread(keyspace_misses))
+// FROM redis
+// WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY time(1h), hostname
+func (d *Devops) RedisHitRatio(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.RedisHitRatioDuration)
+	whereHosts := d.getHostWhereString(nHosts)
+
+	humanLabel := d.GetRedisHitRatioLabel("Influx", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	influxql := fmt.Sprintf("SELECT spread(keyspace_hits) / (spread(keyspace_hits) + spread(keyspace_misses)) as hit_ratio from redis where %s and time >= '%s' and time < '%s' group by time(1h),hostname", whereHosts, interval.StartString(), interval.EndString())
+	d.fillInQuery(qi, humanLabel, humanDesc, influxql)
+}
+
+// NginxRequestRate selects the nginx requests per second of each service per
+// minute over a random hour, from the increase of the requests counter of
+// every host within the minute, e.g. in pseudo-SQL:
+//
+// SELECT sum(requests) / 60
+// FROM (SELECT spread(requests) AS requests FROM nginx WHERE ... GROUP BY time(1m), hostname, service)
+// WHERE time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY time(1m), service
+func (d *Devops) NginxRequestRate(qi query.Query) {
+	interval := d.MustQueryInterval(devops.NginxRateDuration)
+	whereTime := fmt.Sprintf("time >= '%s' and time < '%s'", interval.StartString(), interval.EndString())
+
+	humanLabel := d.GetNginxRateLabel("Influx")
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	influxql := fmt.Sprintf("SELECT sum(requests) / 60 as requests_per_sec from (SELECT spread(requests) as requests from nginx where %s group by time(1m),hostname,service) where %s group by time(1m),service", whereTime, whereTime)
+	d.fillInQuery(qi, humanLabel, humanDesc, influxql)
+}
+
+// GroupByTag selects the mean usage_user per hour and value of groupTag of the
+// hosts with random values of filterTags, e.g. in pseudo-SQL:
+//
+// SELECT mean(usage_user)
+// FROM cpu
+// WHERE service = '$SERVICE' AND service_environment = '$ENVIRONMENT'
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY time(1h), datacenter
+func (d *Devops) GroupByTag(qi query.Query, groupTag string, filterTags []string) {
+	interval := d.MustQueryInterval(devops.TagGroupByDuration)
+	filters, err := d.GetRandomTagFilters(filterTags)
+	panicIfErr(err)
+
+	filterClauses := make([]string, len(filters))
+	for i, f := range filters {
+		filterClauses[i] = fmt.Sprintf("%s = '%s'", f.Key, f.Value)
+	}
+
+	humanLabel := d.GetTagGroupByLabel("Influx", groupTag, filterTags)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	influxql := fmt.Sprintf("SELECT mean(usage_user) from cpu where %s and time >= '%s' and time < '%s' group by time(1h),%s", strings.Join(filterClauses, " and "), interval.StartString(), interval.EndString(), groupTag)
+	d.fillInQuery(qi, humanLabel, humanDesc, influxql)
+}
+
 func (d *Devops) fillInQuery(qi query.Query, humanLabel, humanDesc, influxql string) {
 	v := url.Values{}
 	v.Set("q", influxql)
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	internalutils "github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/query"
 )
 
@@ -146,6 +147,32 @@ func TestDevopsGroupByTime(t *testing.T) {
 	verifyQuery(t, q, expectedHumanLabel, expectedHumanDesc, expectedPath)
 }
 
+func TestDevopsGroupByTimeConfiguredWindows(t *testing.T) {
+	expectedHumanLabel := "Influx 1 cpu metric(s), random    1 hosts, recent 10m0s by 5m"
+	expectedHumanDesc := "Influx 1 cpu metric(s), random    1 hosts, recent 10m0s by 5m: 1970-01-01T00:50:00Z"
+	expectedQuery := "SELECT max(usage_user) from cpu " +
+		"where (hostname = 'host_5') and " +
+		"time >= '1970-01-01T00:50:00Z' and time < '1970-01-01T01:00:00Z' " +
+		"group by time(5m)"
+
+	v := url.Values{}
+	v.Set("q", expectedQuery)
+	expectedPath := fmt.Sprintf("/query?%s", v.Encode())
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(time.Hour)
+	d := NewDevops(s, e, 10)
+	if err := d.ConfigureWindows(10*time.Minute, 5*time.Minute, internalutils.WindowRecent); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	q := d.GenerateEmptyQuery()
+	d.GroupByTime(q, 1, 1, time.Second)
+
+	verifyQuery(t, q, expectedHumanLabel, expectedHumanDesc, expectedPath)
+}
+
 func TestDevopsGroupByOrderByLimit(t *testing.T) {
 	expectedHumanLabel := "Influx max cpu over last 5 min-intervals (random end)"
 	expectedHumanDesc := "Influx max cpu over last 5 min-intervals (random end): 1970-01-01T00:16:22Z"
@@ -362,6 +389,239 @@ func TestDevopsFillInQuery(t *testing.T) {
 	}
 }
 
+func TestCounterRate(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "Influx rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "Influx rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m: 1970-01-01T00:16:22Z",
+			expectedQuery: "SELECT non_negative_derivative(max(bytes_recv), 1s) " +
+				"from net " +
+				"where (hostname = 'host_9' or hostname = 'host_3') and time >= '1970-01-01T00:16:22Z' and time < '1970-01-01T01:16:22Z' " +
+				"group by time(1m),hostname",
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.CounterRate(q, "net", "bytes_recv", 2)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestCPUPercentiles(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "Influx percentiles of usage_user, random    1 hosts, random 12h0m0s by 1h",
+			expectedHumanDesc:  "Influx percentiles of usage_user, random    1 hosts, random 12h0m0s by 1h: 1970-01-01T00:16:22Z",
+			expectedQuery: "SELECT percentile(usage_user, 50), percentile(usage_user, 90), percentile(usage_user, 99) " +
+				"from cpu " +
+				"where (hostname = 'host_9') and time >= '1970-01-01T00:16:22Z' and time < '1970-01-01T12:16:22Z' " +
+				"group by time(1h)",
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.CPUPercentiles(q, 1)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(devops.PercentilesDuration).Add(time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestTopKHosts(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "Influx top 5 hosts by mean usage_user, random 1h0m0s",
+			expectedHumanDesc:  "Influx top 5 hosts by mean usage_user, random 1h0m0s: 1970-01-01T00:16:22Z",
+			expectedQuery: "SELECT top(mean, hostname, 5) " +
+				"from (SELECT mean(usage_user) " +
+				"from cpu " +
+				"where time >= '1970-01-01T00:16:22Z' and time < '1970-01-01T01:16:22Z' " +
+				"group by hostname)",
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.TopKHosts(q, 5)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestDownsampleAll(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "Influx mean of 2 metrics, all hosts, all data by 1h",
+			expectedHumanDesc:  "Influx mean of 2 metrics, all hosts, all data by 1h: 1970-01-01T00:00:00Z",
+			expectedQuery: "SELECT mean(usage_user), mean(usage_system) " +
+				"from cpu " +
+				"where time >= '1970-01-01T00:00:00Z' and time < '1970-01-01T02:00:00Z' " +
+				"group by time(1h),hostname",
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.DownsampleAll(q, 2)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestDiskFull(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "all hosts",
+			expectedHumanLabel: "Influx disk over threshold, all hosts, random 1h0m0s",
+			expectedHumanDesc:  "Influx disk over threshold, all hosts, random 1h0m0s: 1970-01-01T00:16:22Z",
+			expectedQuery: "SELECT * " +
+				"from (SELECT max(used_percent) as max_used_percent " +
+				"from disk " +
+				"where time >= '1970-01-01T00:16:22Z' and time < '1970-01-01T01:16:22Z' " +
+				"group by hostname,path) " +
+				"where max_used_percent > 90.0",
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.DiskFull(q, 0)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestDiskFullForHosts(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "one host",
+			expectedHumanLabel: "Influx disk over threshold, 1 host(s), random 1h0m0s",
+			expectedHumanDesc:  "Influx disk over threshold, 1 host(s), random 1h0m0s: 1970-01-01T00:16:22Z",
+			expectedQuery: "SELECT * " +
+				"from (SELECT max(used_percent) as max_used_percent " +
+				"from disk " +
+				"where (hostname = 'host_9') and time >= '1970-01-01T00:16:22Z' and time < '1970-01-01T01:16:22Z' " +
+				"group by hostname,path) " +
+				"where max_used_percent > 90.0",
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.DiskFull(q, 1)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestRedisHitRatio(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "Influx redis keyspace hit ratio, random    2 hosts, random 12h0m0s by 1h",
+			expectedHumanDesc:  "Influx redis keyspace hit ratio, random    2 hosts, random 12h0m0s by 1h: 1970-01-01T00:16:22Z",
+			expectedQuery: "SELECT spread(keyspace_hits) / (spread(keyspace_hits) + spread(keyspace_misses)) as hit_ratio " +
+				"from redis " +
+				"where (hostname = 'host_9' or hostname = 'host_3') and time >= '1970-01-01T00:16:22Z' and time < '1970-01-01T12:16:22Z' " +
+				"group by time(1h),hostname",
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.RedisHitRatio(q, 2)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(devops.RedisHitRatioDuration).Add(time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestNginxRequestRate(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "Influx nginx requests per second per service, all hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "Influx nginx requests per second per service, all hosts, random 1h0m0s by 1m: 1970-01-01T00:16:22Z",
+			expectedQuery: "SELECT sum(requests) / 60 as requests_per_sec " +
+				"from (SELECT spread(requests) as requests " +
+				"from nginx " +
+				"where time >= '1970-01-01T00:16:22Z' and time < '1970-01-01T01:16:22Z' " +
+				"group by time(1m),hostname,service) " +
+				"where time >= '1970-01-01T00:16:22Z' and time < '1970-01-01T01:16:22Z' " +
+				"group by time(1m),service",
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.NginxRequestRate(q)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestGroupByTag(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "Influx mean usage_user per datacenter, random service and service_environment, random 12h0m0s by 1h",
+			expectedHumanDesc:  "Influx mean usage_user per datacenter, random service and service_environment, random 12h0m0s by 1h: 1970-01-01T00:16:22Z",
+			expectedQuery: "SELECT mean(usage_user) " +
+				"from cpu " +
+				"where service = '9' and service_environment = 'staging' and time >= '1970-01-01T00:16:22Z' and time < '1970-01-01T12:16:22Z' " +
+				"group by time(1h),datacenter",
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.GroupByTag(q, "datacenter", []string{"service", "service_environment"})
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(devops.TagGroupByDuration).Add(time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
 type testCase struct {
 	desc               string
 	input              int
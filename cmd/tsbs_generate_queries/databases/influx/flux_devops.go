@@ -0,0 +1,447 @@
+package influx
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	internalutils "github.com/timescale/tsbs/internal/utils"
+	"github.com/timescale/tsbs/query"
+)
+
+// DefaultBucket is the bucket Flux queries read from unless configured
+// otherwise, i.e. the default database name of tsbs_load_influx
+const DefaultBucket = "benchmark"
+
+// FluxDevops produces Flux queries of InfluxDB 2.x for all the devops query
+// types, which tsbs_run_queries_influx posts to /api/v2/query.
+type FluxDevops struct {
+	*devops.Core
+
+	// Bucket is the bucket the queries read from
+	Bucket string
+}
+
+// NewFluxDevops makes a FluxDevops object ready to generate Queries.
+func NewFluxDevops(start, end time.Time, scale int) *FluxDevops {
+	core, err := devops.NewCore(start, end, scale)
+	panicIfErr(err)
+	return &FluxDevops{Core: core, Bucket: DefaultBucket}
+}
+
+// GenerateEmptyQuery returns an empty query.HTTP
+func (d *FluxDevops) GenerateEmptyQuery() query.Query {
+	return query.NewHTTP()
+}
+
+// pipe chains the stages of a Flux query with the pipe-forward operator
+func pipe(stages ...string) string {
+	return strings.Join(stages, " |> ")
+}
+
+// fromRange returns the stages reading the bucket over the time interval
+func (d *FluxDevops) fromRange(interval *internalutils.TimeInterval) string {
+	return fmt.Sprintf(`from(bucket: "%s") |> range(start: %s, stop: %s)`, d.Bucket, interval.StartString(), interval.EndString())
+}
+
+// filterMeasurement returns the stage keeping the given fields of the
+// measurement, or all of its fields if none are given
+func filterMeasurement(measurement string, fields ...string) string {
+	if len(fields) == 0 {
+		return fmt.Sprintf(`filter(fn: (r) => r._measurement == "%s")`, measurement)
+	}
+	fieldClauses := make([]string, len(fields))
+	for i, f := range fields {
+		fieldClauses[i] = fmt.Sprintf(`r._field == "%s"`, f)
+	}
+	return fmt.Sprintf(`filter(fn: (r) => r._measurement == "%s" and (%s))`, measurement, strings.Join(fieldClauses, " or "))
+}
+
+func (d *FluxDevops) getHostFilterWithHostnames(hostnames []string) string {
+	hostnameClauses := make([]string, len(hostnames))
+	for i, s := range hostnames {
+		hostnameClauses[i] = fmt.Sprintf(`r.hostname == "%s"`, s)
+	}
+	return fmt.Sprintf("filter(fn: (r) => %s)", strings.Join(hostnameClauses, " or "))
+}
+
+func (d *FluxDevops) getHostFilter(nHosts int) string {
+	hostnames, err := d.GetRandomHosts(nHosts)
+	panicIfErr(err)
+	return d.getHostFilterWithHostnames(hostnames)
+}
+
+// aggregateWindow returns the stage aggregating each table per window of the
+// given width with the aggregate function fn
+func aggregateWindow(every, fn string) string {
+	return fmt.Sprintf("aggregateWindow(every: %s, fn: %s, createEmpty: false)", every, fn)
+}
+
+// GroupByTime selects the MAX for numMetrics metrics under 'cpu',
+// per minute for nhosts hosts,
+// e.g. in pseudo-SQL:
+//
+// SELECT minute, max(metric1), ..., max(metricN)
+// FROM cpu
+// WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY minute ORDER BY minute ASC
+func (d *FluxDevops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange time.Duration) {
+	interval := d.MustQueryInterval(timeRange)
+	bucket := devops.ShortDuration(d.GetSingleGroupByBucket())
+	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
+	panicIfErr(err)
+
+	humanLabel := fmt.Sprintf("Influx [Flux] %d cpu metric(s), random %4d hosts, %s by %s", numMetrics, nHosts, d.WindowLabel(timeRange), bucket)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	flux := pipe(
+		d.fromRange(interval),
+		filterMeasurement("cpu", metrics...),
+		d.getHostFilter(nHosts),
+		`group(columns: ["_field"])`,
+		aggregateWindow(bucket, "max"))
+	d.fillInQuery(qi, humanLabel, humanDesc, flux)
+}
+
+// GroupByOrderByLimit benchmarks a query that has a time WHERE clause, that groups by a truncated date, orders by that date, and takes a limit:
+// SELECT date_trunc('minute', time) AS t, MAX(cpu) FROM cpu
+// WHERE time < '$TIME'
+// GROUP BY t ORDER BY t DESC
+// LIMIT $LIMIT
+func (d *FluxDevops) GroupByOrderByLimit(qi query.Query) {
+	interval := d.MustQueryInterval(time.Hour)
+	untilEnd, err := internalutils.NewTimeInterval(d.Interval.Start(), interval.End())
+	panicIfErr(err)
+
+	humanLabel := "Influx [Flux] max cpu over last 5 min-intervals (random end)"
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	flux := pipe(
+		d.fromRange(untilEnd),
+		filterMeasurement("cpu", "usage_user"),
+		"group()",
+		aggregateWindow("1m", "max"),
+		`sort(columns: ["_time"], desc: true)`,
+		"limit(n: 5)")
+	d.fillInQuery(qi, humanLabel, humanDesc, flux)
+}
+
+// GroupByTimeAndPrimaryTag selects the AVG of numMetrics metrics under 'cpu' per device per hour for a day,
+// e.g. in pseudo-SQL:
+//
+// SELECT AVG(metric1), ..., AVG(metricN)
+// FROM cpu
+// WHERE time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hour, hostname ORDER BY hour, hostname
+func (d *FluxDevops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
+	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
+	panicIfErr(err)
+	interval := d.MustQueryInterval(devops.DoubleGroupByDuration)
+
+	humanLabel := d.GetDoubleGroupByLabel("Influx [Flux]", numMetrics)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	flux := pipe(
+		d.fromRange(interval),
+		filterMeasurement("cpu", metrics...),
+		`group(columns: ["hostname", "_field"])`,
+		aggregateWindow("1h", "mean"))
+	d.fillInQuery(qi, humanLabel, humanDesc, flux)
+}
+
+// MaxAllCPU selects the MAX of all metrics under 'cpu' per hour for nhosts hosts,
+// e.g. in pseudo-SQL:
+//
+// SELECT MAX(metric1), ..., MAX(metricN)
+// FROM cpu WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hour ORDER BY hour
+func (d *FluxDevops) MaxAllCPU(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.MaxAllDuration)
+
+	humanLabel := d.GetMaxAllLabel("Influx [Flux]", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	flux := pipe(
+		d.fromRange(interval),
+		filterMeasurement("cpu", devops.GetAllCPUMetrics()...),
+		d.getHostFilter(nHosts),
+		`group(columns: ["_field"])`,
+		aggregateWindow("1h", "max"))
+	d.fillInQuery(qi, humanLabel, humanDesc, flux)
+}
+
+// LastPointPerHost finds the last row for every host in the dataset
+func (d *FluxDevops) LastPointPerHost(qi query.Query) {
+	humanLabel := "Influx [Flux] last row per host"
+	humanDesc := humanLabel + ": cpu"
+	flux := pipe(
+		d.fromRange(d.Interval),
+		filterMeasurement("cpu"),
+		`group(columns: ["hostname", "_field"])`,
+		"last()")
+	d.fillInQuery(qi, humanLabel, humanDesc, flux)
+}
+
+// HighCPUForHosts populates a query that gets CPU metrics when the CPU has high
+// usage between a time period for a number of hosts (if 0, it will search all hosts),
+// e.g. in pseudo-SQL:
+//
+// SELECT * FROM cpu
+// WHERE usage_user > 90.0
+// AND time >= '$TIME_START' AND time < '$TIME_END'
+// AND (hostname = '$HOST' OR hostname = '$HOST2'...)
+func (d *FluxDevops) HighCPUForHosts(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.HighCPUDuration)
+
+	stages := []string{d.fromRange(interval), filterMeasurement("cpu")}
+	if nHosts > 0 {
+		stages = append(stages, d.getHostFilter(nHosts))
+	}
+	stages = append(stages,
+		`pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")`,
+		"filter(fn: (r) => r.usage_user > 90.0)")
+
+	humanLabel, err := devops.GetHighCPULabel("Influx [Flux]", nHosts)
+	panicIfErr(err)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, pipe(stages...))
+}
+
+// CounterRate selects the per-second rate of a counter of a measurement per
+// minute for nHosts hosts, from the maximum of the counter in consecutive
+// minutes, e.g. in pseudo-SQL:
+//
+// SELECT non_negative_derivative(max(counter), 1s)
+// FROM measurement
+// WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY time(1m), hostname
+func (d *FluxDevops) CounterRate(qi query.Query, measurement, counter string, nHosts int) {
+	interval := d.MustQueryInterval(devops.RateDuration)
+
+	humanLabel := d.GetRateLabel("Influx [Flux]", measurement, counter, nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	flux := pipe(
+		d.fromRange(interval),
+		filterMeasurement(measurement, counter),
+		d.getHostFilter(nHosts),
+		`group(columns: ["hostname"])`,
+		aggregateWindow("1m", "max"),
+		"derivative(unit: 1s, nonNegative: true)")
+	d.fillInQuery(qi, humanLabel, humanDesc, flux)
+}
+
+// CPUPercentiles selects percentiles of usage_user per hour for nHosts hosts,
+// e.g. in pseudo-SQL:
+//
+// SELECT percentile(usage_user, 50), ...
+// FROM cpu
+// WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY time(1h)
+func (d *FluxDevops) CPUPercentiles(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.PercentilesDuration)
+	data := pipe(
+		d.fromRange(interval),
+		filterMeasurement("cpu", "usage_user"),
+		d.getHostFilter(nHosts),
+		"group()")
+
+	percentiles := devops.GetCPUPercentiles()
+	tables := make([]string, len(percentiles))
+	for i, p := range percentiles {
+		tables[i] = pipe(
+			"data",
+			aggregateWindow("1h", fmt.Sprintf("(column, tables=<-) => tables |> quantile(q: %0.2f, column: column)", float64(p)/100)),
+			fmt.Sprintf(`set(key: "percentile", value: "%d")`, p))
+	}
+
+	humanLabel := d.GetPercentilesLabel("Influx [Flux]", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	flux := fmt.Sprintf("data = %s\nunion(tables: [%s])", data, strings.Join(tables, ", "))
+	d.fillInQuery(qi, humanLabel, humanDesc, flux)
+}
+
+// TopKHosts selects the k hosts with the highest mean usage_user over a random
+// hour, e.g. in pseudo-SQL:
+//
+// SELECT hostname, mean(usage_user) AS mean_usage_user
+// FROM cpu WHERE time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hostname ORDER BY mean_usage_user DESC LIMIT $K
+func (d *FluxDevops) TopKHosts(qi query.Query, k int) {
+	interval := d.MustQueryInterval(devops.TopKDuration)
+
+	humanLabel := d.GetTopKLabel("Influx [Flux]", k)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	flux := pipe(
+		d.fromRange(interval),
+		filterMeasurement("cpu", "usage_user"),
+		`group(columns: ["hostname"])`,
+		"mean()",
+		"group()",
+		fmt.Sprintf("top(n: %d)", k))
+	d.fillInQuery(qi, humanLabel, humanDesc, flux)
+}
+
+// CPUMemJoin selects the mean usage_user of cpu and the mean used_percent of
+// mem per minute and host for nHosts hosts, joined on minute and host,
+// e.g. in pseudo-SQL:
+//
+// SELECT minute, hostname, mean_usage_user, mean_used_percent
+// FROM (SELECT minute, hostname, avg(usage_user) AS mean_usage_user FROM cpu WHERE ... GROUP BY minute, hostname) c
+// JOIN (SELECT minute, hostname, avg(used_percent) AS mean_used_percent FROM mem WHERE ... GROUP BY minute, hostname) m
+// ON c.minute = m.minute AND c.hostname = m.hostname
+func (d *FluxDevops) CPUMemJoin(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.CPUMemJoinDuration)
+	hostFilter := d.getHostFilter(nHosts)
+	meanPerMinute := func(measurement, field string) string {
+		return pipe(
+			d.fromRange(interval),
+			filterMeasurement(measurement, field),
+			hostFilter,
+			`group(columns: ["hostname"])`,
+			aggregateWindow("1m", "mean"))
+	}
+
+	humanLabel := d.GetCPUMemJoinLabel("Influx [Flux]", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	flux := fmt.Sprintf("cpu = %s\nmem = %s\njoin(tables: {cpu: cpu, mem: mem}, on: [\"_time\", \"hostname\"])",
+		meanPerMinute("cpu", "usage_user"), meanPerMinute("mem", "used_percent"))
+	d.fillInQuery(qi, humanLabel, humanDesc, flux)
+}
+
+// DownsampleAll selects the MEAN of numMetrics metrics under 'cpu' per host per
+// hour over the whole time range of the dataset, e.g. in pseudo-SQL:
+//
+// SELECT MEAN(metric1), ..., MEAN(metricN)
+// FROM cpu
+// WHERE time >= '$DATASET_START' AND time < '$DATASET_END'
+// GROUP BY time(1h), hostname
+func (d *FluxDevops) DownsampleAll(qi query.Query, numMetrics int) {
+	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
+	panicIfErr(err)
+
+	humanLabel := devops.GetDownsampleLabel("Influx [Flux]", numMetrics)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, d.Interval.StartString())
+	flux := pipe(
+		d.fromRange(d.Interval),
+		filterMeasurement("cpu", metrics...),
+		`group(columns: ["hostname", "_field"])`,
+		aggregateWindow("1h", "mean"))
+	d.fillInQuery(qi, humanLabel, humanDesc, flux)
+}
+
+// DiskFull selects the disks of nHosts hosts (or all hosts if nHosts is 0)
+// whose used_percent exceeded devops.DiskFullThreshold in a random hour,
+// e.g. in pseudo-SQL:
+//
+// SELECT *
+// FROM (SELECT max(used_percent) AS max_used_percent FROM disk WHERE ... GROUP BY hostname, path)
+// WHERE max_used_percent > $THRESHOLD
+func (d *FluxDevops) DiskFull(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.DiskFullDuration)
+
+	stages := []string{d.fromRange(interval), filterMeasurement("disk", "used_percent")}
+	if nHosts > 0 {
+		stages = append(stages, d.getHostFilter(nHosts))
+	}
+	stages = append(stages,
+		`group(columns: ["hostname", "path"])`,
+		"max()",
+		fmt.Sprintf("filter(fn: (r) => r._value > %0.1f)", devops.DiskFullThreshold))
+
+	humanLabel, err := d.GetDiskFullLabel("Influx [Flux]", nHosts)
+	panicIfErr(err)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, pipe(stages...))
+}
+
+// RedisHitRatio selects the share of redis keyspace lookups that were hits
+// per hour and host for nHosts hosts, from the increase of the hit and miss
+// counters within the hour, e.g. in pseudo-SQL:
+//
+// SELECT spread(keyspace_hits) / (spread(keyspace_hits) + spread(keyspace_misses))
+// FROM redis
+// WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY time(1h), hostname
+func (d *FluxDevops) RedisHitRatio(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.RedisHitRatioDuration)
+
+	humanLabel := d.GetRedisHitRatioLabel("Influx [Flux]", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	flux := pipe(
+		d.fromRange(interval),
+		filterMeasurement("redis", "keyspace_hits", "keyspace_misses"),
+		d.getHostFilter(nHosts),
+		`group(columns: ["hostname", "_field"])`,
+		aggregateWindow("1h", "spread"),
+		`pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")`,
+		"map(fn: (r) => ({_time: r._time, hostname: r.hostname, "+
+			"hit_ratio: float(v: r.keyspace_hits) / (float(v: r.keyspace_hits) + float(v: r.keyspace_misses))}))")
+	d.fillInQuery(qi, humanLabel, humanDesc, flux)
+}
+
+// NginxRequestRate selects the nginx requests per second of each service per
+// minute over a random hour, from the increase of the requests counter of
+// every host within the minute, e.g. in pseudo-SQL:
+//
+// SELECT sum(requests) / 60
+// FROM (SELECT spread(requests) AS requests FROM nginx WHERE ... GROUP BY time(1m), hostname, service)
+// WHERE time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY time(1m), service
+func (d *FluxDevops) NginxRequestRate(qi query.Query) {
+	interval := d.MustQueryInterval(devops.NginxRateDuration)
+
+	humanLabel := d.GetNginxRateLabel("Influx [Flux]")
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	flux := pipe(
+		d.fromRange(interval),
+		filterMeasurement("nginx", "requests"),
+		`group(columns: ["hostname", "service"])`,
+		aggregateWindow("1m", "spread"),
+		`group(columns: ["service", "_time"])`,
+		"sum()",
+		`group(columns: ["service"])`,
+		"map(fn: (r) => ({r with _value: float(v: r._value) / 60.0}))")
+	d.fillInQuery(qi, humanLabel, humanDesc, flux)
+}
+
+// GroupByTag selects the mean usage_user per hour and value of groupTag of the
+// hosts with random values of filterTags, e.g. in pseudo-SQL:
+//
+// SELECT mean(usage_user)
+// FROM cpu
+// WHERE service = '$SERVICE' AND service_environment = '$ENVIRONMENT'
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY time(1h), datacenter
+func (d *FluxDevops) GroupByTag(qi query.Query, groupTag string, filterTags []string) {
+	interval := d.MustQueryInterval(devops.TagGroupByDuration)
+	filters, err := d.GetRandomTagFilters(filterTags)
+	panicIfErr(err)
+
+	filterClauses := make([]string, len(filters))
+	for i, f := range filters {
+		filterClauses[i] = fmt.Sprintf(`r.%s == "%s"`, f.Key, f.Value)
+	}
+
+	humanLabel := d.GetTagGroupByLabel("Influx [Flux]", groupTag, filterTags)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	flux := pipe(
+		d.fromRange(interval),
+		filterMeasurement("cpu", "usage_user"),
+		fmt.Sprintf("filter(fn: (r) => %s)", strings.Join(filterClauses, " and ")),
+		fmt.Sprintf(`group(columns: ["%s"])`, groupTag),
+		aggregateWindow("1h", "mean"))
+	d.fillInQuery(qi, humanLabel, humanDesc, flux)
+}
+
+// fillInQuery fills the query with a Flux script posted to /api/v2/query, to
+// which tsbs_run_queries_influx adds the organization
+func (d *FluxDevops) fillInQuery(qi query.Query, humanLabel, humanDesc, flux string) {
+	q := qi.(*query.HTTP)
+	q.HumanLabel = []byte(humanLabel)
+	q.HumanDescription = []byte(humanDesc)
+	q.Method = []byte("POST")
+	q.Path = []byte("/api/v2/query")
+	q.Body = []byte(flux)
+}
@@ -0,0 +1,294 @@
+package influx
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	"github.com/timescale/tsbs/query"
+)
+
+func TestFluxFilterMeasurement(t *testing.T) {
+	cases := []struct {
+		desc   string
+		fields []string
+		want   string
+	}{
+		{
+			desc: "all fields",
+			want: `filter(fn: (r) => r._measurement == "cpu")`,
+		},
+		{
+			desc:   "single field",
+			fields: []string{"usage_user"},
+			want:   `filter(fn: (r) => r._measurement == "cpu" and (r._field == "usage_user"))`,
+		},
+		{
+			desc:   "multiple fields",
+			fields: []string{"usage_user", "usage_system"},
+			want:   `filter(fn: (r) => r._measurement == "cpu" and (r._field == "usage_user" or r._field == "usage_system"))`,
+		},
+	}
+
+	for _, c := range cases {
+		if got := filterMeasurement("cpu", c.fields...); got != c.want {
+			t.Errorf("%s: incorrect output:\ngot\n%s\nwant\n%s", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestFluxGetHostFilterWithHostnames(t *testing.T) {
+	d := NewFluxDevops(time.Now(), time.Now(), 10)
+	want := `filter(fn: (r) => r.hostname == "foo1" or r.hostname == "foo2")`
+	if got := d.getHostFilterWithHostnames([]string{"foo1", "foo2"}); got != want {
+		t.Errorf("incorrect output: got %s want %s", got, want)
+	}
+}
+
+func TestFluxGroupByTime(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "Influx [Flux] 2 cpu metric(s), random    2 hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "Influx [Flux] 2 cpu metric(s), random    2 hosts, random 1h0m0s by 1m: 1970-01-01T00:16:22Z",
+			expectedQuery: `from(bucket: "tsbs") |> range(start: 1970-01-01T00:16:22Z, stop: 1970-01-01T01:16:22Z) ` +
+				`|> filter(fn: (r) => r._measurement == "cpu" and (r._field == "usage_user" or r._field == "usage_system")) ` +
+				`|> filter(fn: (r) => r.hostname == "host_9" or r.hostname == "host_3") ` +
+				`|> group(columns: ["_field"]) ` +
+				`|> aggregateWindow(every: 1m, fn: max, createEmpty: false)`,
+		},
+	}
+
+	testFunc := func(d *FluxDevops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.GroupByTime(q, 2, 2, time.Hour)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runFluxTestCases(t, testFunc, start, end, cases)
+}
+
+func TestFluxGroupByOrderByLimit(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "Influx [Flux] max cpu over last 5 min-intervals (random end)",
+			expectedHumanDesc:  "Influx [Flux] max cpu over last 5 min-intervals (random end): 1970-01-01T00:16:22Z",
+			expectedQuery: `from(bucket: "tsbs") |> range(start: 1970-01-01T00:00:00Z, stop: 1970-01-01T01:16:22Z) ` +
+				`|> filter(fn: (r) => r._measurement == "cpu" and (r._field == "usage_user")) ` +
+				`|> group() ` +
+				`|> aggregateWindow(every: 1m, fn: max, createEmpty: false) ` +
+				`|> sort(columns: ["_time"], desc: true) ` +
+				`|> limit(n: 5)`,
+		},
+	}
+
+	testFunc := func(d *FluxDevops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.GroupByOrderByLimit(q)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runFluxTestCases(t, testFunc, start, end, cases)
+}
+
+func TestFluxHighCPUForHosts(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "all hosts",
+			input:              0,
+			expectedHumanLabel: "Influx [Flux] CPU over threshold, all hosts",
+			expectedHumanDesc:  "Influx [Flux] CPU over threshold, all hosts: 1970-01-01T00:16:22Z",
+			expectedQuery: `from(bucket: "tsbs") |> range(start: 1970-01-01T00:16:22Z, stop: 1970-01-01T12:16:22Z) ` +
+				`|> filter(fn: (r) => r._measurement == "cpu") ` +
+				`|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value") ` +
+				`|> filter(fn: (r) => r.usage_user > 90.0)`,
+		},
+		{
+			desc:               "1 host",
+			input:              1,
+			expectedHumanLabel: "Influx [Flux] CPU over threshold, 1 host(s)",
+			expectedHumanDesc:  "Influx [Flux] CPU over threshold, 1 host(s): 1970-01-01T00:54:10Z",
+			expectedQuery: `from(bucket: "tsbs") |> range(start: 1970-01-01T00:54:10Z, stop: 1970-01-01T12:54:10Z) ` +
+				`|> filter(fn: (r) => r._measurement == "cpu") ` +
+				`|> filter(fn: (r) => r.hostname == "host_3") ` +
+				`|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value") ` +
+				`|> filter(fn: (r) => r.usage_user > 90.0)`,
+		},
+	}
+
+	testFunc := func(d *FluxDevops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.HighCPUForHosts(q, c.input)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(devops.HighCPUDuration).Add(time.Hour)
+
+	runFluxTestCases(t, testFunc, start, end, cases)
+}
+
+func TestFluxCPUPercentiles(t *testing.T) {
+	percentile := func(q, p string) string {
+		return `data |> aggregateWindow(every: 1h, fn: (column, tables=<-) => tables |> quantile(q: ` + q + `, column: column), createEmpty: false) ` +
+			`|> set(key: "percentile", value: "` + p + `")`
+	}
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "Influx [Flux] percentiles of usage_user, random    1 hosts, random 12h0m0s by 1h",
+			expectedHumanDesc:  "Influx [Flux] percentiles of usage_user, random    1 hosts, random 12h0m0s by 1h: 1970-01-01T00:16:22Z",
+			expectedQuery: `data = from(bucket: "tsbs") |> range(start: 1970-01-01T00:16:22Z, stop: 1970-01-01T12:16:22Z) ` +
+				`|> filter(fn: (r) => r._measurement == "cpu" and (r._field == "usage_user")) ` +
+				`|> filter(fn: (r) => r.hostname == "host_9") ` +
+				`|> group()` + "\n" +
+				`union(tables: [` + percentile("0.50", "50") + ", " + percentile("0.90", "90") + ", " + percentile("0.99", "99") + `])`,
+		},
+	}
+
+	testFunc := func(d *FluxDevops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.CPUPercentiles(q, 1)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(devops.PercentilesDuration).Add(time.Hour)
+
+	runFluxTestCases(t, testFunc, start, end, cases)
+}
+
+func TestFluxCPUMemJoin(t *testing.T) {
+	meanPerMinute := func(measurement, field string) string {
+		return `from(bucket: "tsbs") |> range(start: 1970-01-01T00:16:22Z, stop: 1970-01-01T01:16:22Z) ` +
+			`|> filter(fn: (r) => r._measurement == "` + measurement + `" and (r._field == "` + field + `")) ` +
+			`|> filter(fn: (r) => r.hostname == "host_9" or r.hostname == "host_3") ` +
+			`|> group(columns: ["hostname"]) ` +
+			`|> aggregateWindow(every: 1m, fn: mean, createEmpty: false)`
+	}
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "Influx [Flux] cpu joined with mem, random    2 hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "Influx [Flux] cpu joined with mem, random    2 hosts, random 1h0m0s by 1m: 1970-01-01T00:16:22Z",
+			expectedQuery: "cpu = " + meanPerMinute("cpu", "usage_user") + "\n" +
+				"mem = " + meanPerMinute("mem", "used_percent") + "\n" +
+				`join(tables: {cpu: cpu, mem: mem}, on: ["_time", "hostname"])`,
+		},
+	}
+
+	testFunc := func(d *FluxDevops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.CPUMemJoin(q, 2)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runFluxTestCases(t, testFunc, start, end, cases)
+}
+
+func TestFluxRedisHitRatio(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "Influx [Flux] redis keyspace hit ratio, random    1 hosts, random 12h0m0s by 1h",
+			expectedHumanDesc:  "Influx [Flux] redis keyspace hit ratio, random    1 hosts, random 12h0m0s by 1h: 1970-01-01T00:16:22Z",
+			expectedQuery: `from(bucket: "tsbs") |> range(start: 1970-01-01T00:16:22Z, stop: 1970-01-01T12:16:22Z) ` +
+				`|> filter(fn: (r) => r._measurement == "redis" and (r._field == "keyspace_hits" or r._field == "keyspace_misses")) ` +
+				`|> filter(fn: (r) => r.hostname == "host_9") ` +
+				`|> group(columns: ["hostname", "_field"]) ` +
+				`|> aggregateWindow(every: 1h, fn: spread, createEmpty: false) ` +
+				`|> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value") ` +
+				`|> map(fn: (r) => ({_time: r._time, hostname: r.hostname, hit_ratio: float(v: r.keyspace_hits) / (float(v: r.keyspace_hits) + float(v: r.keyspace_misses))}))`,
+		},
+	}
+
+	testFunc := func(d *FluxDevops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.RedisHitRatio(q, 1)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(devops.RedisHitRatioDuration).Add(time.Hour)
+
+	runFluxTestCases(t, testFunc, start, end, cases)
+}
+
+func TestFluxGroupByTag(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "Influx [Flux] mean usage_user per datacenter, random service and service_environment, random 12h0m0s by 1h",
+			expectedHumanDesc:  "Influx [Flux] mean usage_user per datacenter, random service and service_environment, random 12h0m0s by 1h: 1970-01-01T00:16:22Z",
+			expectedQuery: `from(bucket: "tsbs") |> range(start: 1970-01-01T00:16:22Z, stop: 1970-01-01T12:16:22Z) ` +
+				`|> filter(fn: (r) => r._measurement == "cpu" and (r._field == "usage_user")) ` +
+				`|> filter(fn: (r) => r.service == "9" and r.service_environment == "staging") ` +
+				`|> group(columns: ["datacenter"]) ` +
+				`|> aggregateWindow(every: 1h, fn: mean, createEmpty: false)`,
+		},
+	}
+
+	testFunc := func(d *FluxDevops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.GroupByTag(q, "datacenter", []string{"service", "service_environment"})
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(devops.TagGroupByDuration).Add(time.Hour)
+
+	runFluxTestCases(t, testFunc, start, end, cases)
+}
+
+func runFluxTestCases(t *testing.T, testFunc func(*FluxDevops, testCase) query.Query, s time.Time, e time.Time, cases []testCase) {
+	rand.Seed(123) // Setting seed for testing purposes.
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			d := NewFluxDevops(s, e, 10)
+			d.Bucket = "tsbs"
+
+			q := testFunc(d, c)
+			verifyFluxQuery(t, q, c.expectedHumanLabel, c.expectedHumanDesc, c.expectedQuery)
+		})
+	}
+}
+
+func verifyFluxQuery(t *testing.T, q query.Query, humanLabel, humanDesc, flux string) {
+	fluxQuery, ok := q.(*query.HTTP)
+
+	if !ok {
+		t.Fatal("Filled query is not *query.HTTP type")
+	}
+
+	if got := string(fluxQuery.HumanLabel); got != humanLabel {
+		t.Errorf("incorrect human label:\ngot\n%s\nwant\n%s", got, humanLabel)
+	}
+
+	if got := string(fluxQuery.HumanDescription); got != humanDesc {
+		t.Errorf("incorrect human description:\ngot\n%s\nwant\n%s", got, humanDesc)
+	}
+
+	if got := string(fluxQuery.Method); got != "POST" {
+		t.Errorf("incorrect method:\ngot\n%s\nwant POST", got)
+	}
+
+	if got := string(fluxQuery.Path); got != "/api/v2/query" {
+		t.Errorf("incorrect path:\ngot\n%s\nwant /api/v2/query", got)
+	}
+
+	if got := string(fluxQuery.Body); got != flux {
+		t.Errorf("incorrect body:\ngot\n%s\nwant\n%s", got, flux)
+	}
+}
@@ -110,13 +110,14 @@ func getTimeFilterDocs(interval *utils.TimeInterval) []interface{} {
 // AND time >= '$HOUR_START' AND time < '$HOUR_END'
 // GROUP BY minute ORDER BY minute ASC
 func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange time.Duration) {
-	interval := d.Interval.MustRandWindow(timeRange)
+	interval := d.MustQueryInterval(timeRange)
 	hostnames, err := d.GetRandomHosts(nHosts)
 	panicIfErr(err)
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
 	docs := getTimeFilterDocs(interval)
-	bucketNano := time.Minute.Nanoseconds()
+	bucket := d.GetSingleGroupByBucket()
+	bucketNano := bucket.Nanoseconds()
 
 	pipelineQuery := []bson.M{
 		{
@@ -164,7 +165,7 @@ func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange t
 	pipelineQuery = append(pipelineQuery, group)
 	pipelineQuery = append(pipelineQuery, bson.M{"$sort": bson.M{"_id": 1}})
 
-	humanLabel := []byte(fmt.Sprintf("Mongo %d cpu metric(s), random %4d hosts, random %s by 1m", numMetrics, nHosts, timeRange))
+	humanLabel := []byte(fmt.Sprintf("Mongo %d cpu metric(s), random %4d hosts, %s by %s", numMetrics, nHosts, d.WindowLabel(timeRange), devops.ShortDuration(bucket)))
 	q := qi.(*query.Mongo)
 	q.HumanLabel = humanLabel
 	q.BsonDoc = pipelineQuery
@@ -180,7 +181,7 @@ func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange t
 // AND time >= '$HOUR_START' AND time < '$HOUR_END'
 // GROUP BY hour ORDER BY hour
 func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
-	interval := d.Interval.MustRandWindow(devops.MaxAllDuration)
+	interval := d.MustQueryInterval(devops.MaxAllDuration)
 	hostnames, err := d.GetRandomHosts(nHosts)
 	panicIfErr(err)
 	docs := getTimeFilterDocs(interval)
@@ -233,7 +234,7 @@ func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
 	pipelineQuery = append(pipelineQuery, group)
 	pipelineQuery = append(pipelineQuery, bson.M{"$sort": bson.M{"_id": 1}})
 
-	humanLabel := devops.GetMaxAllLabel("Mongo", nHosts)
+	humanLabel := d.GetMaxAllLabel("Mongo", nHosts)
 	q := qi.(*query.Mongo)
 	q.HumanLabel = []byte(humanLabel)
 	q.BsonDoc = pipelineQuery
@@ -249,9 +250,16 @@ func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
 // WHERE time >= '$HOUR_START' AND time < '$HOUR_END'
 // GROUP BY hour, hostname ORDER BY hour, hostname
 func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
-	interval := d.Interval.MustRandWindow(devops.DoubleGroupByDuration)
+	interval := d.MustQueryInterval(devops.DoubleGroupByDuration)
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
+	humanLabel := d.GetDoubleGroupByLabel("Mongo", numMetrics)
+	fillInHourlyMeanPerHostQuery(qi, humanLabel, metrics, interval)
+}
+
+// fillInHourlyMeanPerHostQuery fills in a query for the AVG of metrics under
+// 'cpu' per host per hour within interval
+func fillInHourlyMeanPerHostQuery(qi query.Query, humanLabel string, metrics []string, interval *utils.TimeInterval) {
 	docs := getTimeFilterDocs(interval)
 	bucketNano := time.Hour.Nanoseconds()
 
@@ -313,7 +321,70 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 		{"$sort": bson.M{"_id.time": 1}},
 	}...)
 
-	humanLabel := devops.GetDoubleGroupByLabel("Mongo", numMetrics)
+	q := qi.(*query.Mongo)
+	q.HumanLabel = []byte(humanLabel)
+	q.BsonDoc = pipelineQuery
+	q.CollectionName = []byte("point_data")
+	q.HumanDescription = []byte(fmt.Sprintf("%s: %s (%s)", humanLabel, interval.StartString(), q.CollectionName))
+}
+
+// DownsampleAll selects the AVG of numMetrics metrics under 'cpu' per host per
+// hour over the whole time range of the dataset,
+// e.g. in pseudo-SQL:
+//
+// SELECT AVG(metric1), ..., AVG(metricN)
+// FROM cpu
+// WHERE time >= '$DATASET_START' AND time < '$DATASET_END'
+// GROUP BY hour, hostname ORDER BY hour, hostname
+func (d *Devops) DownsampleAll(qi query.Query, numMetrics int) {
+	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
+	panicIfErr(err)
+	humanLabel := devops.GetDownsampleLabel("Mongo", numMetrics)
+	fillInHourlyMeanPerHostQuery(qi, humanLabel, metrics, d.Interval)
+}
+
+// TopKHosts selects the k hosts with the highest mean usage_user over a random
+// hour, e.g. in pseudo-SQL:
+//
+// SELECT hostname, AVG(usage_user) AS avg_usage_user
+// FROM cpu
+// WHERE time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hostname ORDER BY avg_usage_user DESC LIMIT $K
+func (d *Devops) TopKHosts(qi query.Query, k int) {
+	interval := d.MustQueryInterval(devops.TopKDuration)
+	docs := getTimeFilterDocs(interval)
+
+	pipelineQuery := []bson.M{
+		{
+			"$match": bson.M{
+				"measurement": "cpu",
+				"key_id": bson.M{
+					"$in": docs,
+				},
+			},
+		},
+		{
+			"$project": bson.M{
+				"_id":    0,
+				"events": 1,
+				"key_id": 1,
+				"tags":   "$tags.hostname",
+			},
+		},
+	}
+	pipelineQuery = append(pipelineQuery, getTimeFilterPipeline(interval)...)
+	pipelineQuery = append(pipelineQuery, []bson.M{
+		{
+			"$group": bson.M{
+				"_id":            "$tags",
+				"avg_usage_user": bson.M{"$avg": "$events.usage_user"},
+			},
+		},
+		{"$sort": bson.M{"avg_usage_user": -1}},
+		{"$limit": k},
+	}...)
+
+	humanLabel := d.GetTopKLabel("Mongo", k)
 	q := qi.(*query.Mongo)
 	q.HumanLabel = []byte(humanLabel)
 	q.BsonDoc = pipelineQuery
@@ -330,7 +401,7 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 // AND time >= '$TIME_START' AND time < '$TIME_END'
 // AND (hostname = '$HOST' OR hostname = '$HOST2'...)
 func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
-	interval := d.Interval.MustRandWindow(devops.HighCPUDuration)
+	interval := d.MustQueryInterval(devops.HighCPUDuration)
 	hostnames, err := d.GetRandomHosts(nHosts)
 	panicIfErr(err)
 	docs := getTimeFilterDocs(interval)
@@ -463,7 +534,7 @@ func (d *Devops) LastPointPerHost(qi query.Query) {
 // GROUP BY t ORDER BY t DESC
 // LIMIT $LIMIT
 func (d *Devops) GroupByOrderByLimit(qi query.Query) {
-	interval := d.Interval.MustRandWindow(time.Hour)
+	interval := d.MustQueryInterval(time.Hour)
 	interval, err := utils.NewTimeInterval(d.Interval.Start(), interval.End())
 	if err != nil {
 		panic(err.Error())
@@ -73,20 +73,21 @@ const goTimeFmt = "2006-01-02 15:04:05Z"
 //
 // select max(1m) from (`groupHost1` | ...) & (`groupMetric1` | ...) between 'time1' and 'time2'
 func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange time.Duration) {
-	interval := d.Interval.MustRandWindow(timeRange)
+	interval := d.MustQueryInterval(timeRange)
+	bucket := devops.ShortDuration(d.GetSingleGroupByBucket())
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
 	whereMetrics := d.getMetricWhereString(metrics)
 	whereHosts := d.getHostWhereString(nHosts)
 
-	humanLabel := fmt.Sprintf("SiriDB %d cpu metric(s), random %4d hosts, random %s by 1m", numMetrics, nHosts, timeRange)
+	humanLabel := fmt.Sprintf("SiriDB %d cpu metric(s), random %4d hosts, %s by %s", numMetrics, nHosts, d.WindowLabel(timeRange), bucket)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 
 	var siriql string
 	if numMetrics == 1 {
-		siriql = fmt.Sprintf("select max(1m) from %s & %s between '%s' and '%s' merge as 'max %s for %s' using max(1)", whereHosts, whereMetrics, interval.StartString(), interval.EndString(), whereMetrics, whereHosts)
+		siriql = fmt.Sprintf("select max(%s) from %s & %s between '%s' and '%s' merge as 'max %s for %s' using max(1)", bucket, whereHosts, whereMetrics, interval.StartString(), interval.EndString(), whereMetrics, whereHosts)
 	} else {
-		siriql = fmt.Sprintf("select max(1m) from %s & %s between '%s' and '%s'", whereHosts, whereMetrics, interval.StartString(), interval.EndString())
+		siriql = fmt.Sprintf("select max(%s) from %s & %s between '%s' and '%s'", bucket, whereHosts, whereMetrics, interval.StartString(), interval.EndString())
 	}
 	d.fillInQuery(qi, humanLabel, humanDesc, siriql)
 }
@@ -95,7 +96,7 @@ func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange t
 //
 // select max(1m) from `usage_user` between time - 5m and 'roundedTime' merge as 'max usage user of the last 5 aggregate readings' using max(1)
 func (d *Devops) GroupByOrderByLimit(qi query.Query) {
-	interval := d.Interval.MustRandWindow(time.Hour)
+	interval := d.MustQueryInterval(time.Hour)
 	timeStr := interval.End().Format(goTimeFmt)
 
 	timestrRounded := timeStr[:len(timeStr)-4] + ":00Z"
@@ -112,12 +113,12 @@ func (d *Devops) GroupByOrderByLimit(qi query.Query) {
 //
 // select mean(1h) from (`groupMetric1` | ...) between 'time1' and 'time2'
 func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
-	interval := d.Interval.MustRandWindow(devops.DoubleGroupByDuration)
+	interval := d.MustQueryInterval(devops.DoubleGroupByDuration)
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
 	whereMetrics := d.getMetricWhereString(metrics)
 
-	humanLabel := devops.GetDoubleGroupByLabel("SiriDB", numMetrics)
+	humanLabel := d.GetDoubleGroupByLabel("SiriDB", numMetrics)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	siriql := fmt.Sprintf("select mean(1h) from %s between '%s' and '%s'", whereMetrics, interval.StartString(), interval.EndString())
 	d.fillInQuery(qi, humanLabel, humanDesc, siriql)
@@ -132,12 +133,12 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 //
 // select max(1h) from (`groupHost1` | ...) & `cpu` between 'time1' and 'time2'
 func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
-	interval := d.Interval.MustRandWindow(devops.MaxAllDuration)
+	interval := d.MustQueryInterval(devops.MaxAllDuration)
 
 	whereMetrics := "`cpu`"
 	whereHosts := d.getHostWhereString(nHosts)
 
-	humanLabel := devops.GetMaxAllLabel("SiriDB", nHosts)
+	humanLabel := d.GetMaxAllLabel("SiriDB", nHosts)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	siriql := fmt.Sprintf("select max(1h) from %s & %s between '%s' and '%s'", whereHosts, whereMetrics, interval.StartString(), interval.EndString())
 	d.fillInQuery(qi, humanLabel, humanDesc, siriql)
@@ -171,7 +172,7 @@ func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
 	} else {
 		whereHosts = "& " + d.getHostWhereString(nHosts)
 	}
-	interval := d.Interval.MustRandWindow(devops.HighCPUDuration)
+	interval := d.MustQueryInterval(devops.HighCPUDuration)
 
 	humanLabel, err := devops.GetHighCPULabel("SiriDB", nHosts)
 	panicIfErr(err)
@@ -180,6 +181,38 @@ func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
 	d.fillInQuery(qi, humanLabel, humanDesc, siriql)
 }
 
+// CounterRate selects the per-second rate of a counter of a measurement per
+// minute for nhosts hosts, from the maximum of the counter in consecutive
+// minutes. Series of a counter are matched by a regular expression, since
+// groups are only created for the cpu metrics:
+//
+// select max(1m) => derivative(1s) from /^measurement[|].*[|]counter$/ & (`groupHost1` | ...) between 'time1' and 'time2'
+func (d *Devops) CounterRate(qi query.Query, measurement, counter string, nHosts int) {
+	interval := d.MustQueryInterval(devops.RateDuration)
+	whereHosts := d.getHostWhereString(nHosts)
+
+	humanLabel := d.GetRateLabel("SiriDB", measurement, counter, nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	siriql := fmt.Sprintf("select max(1m) => derivative(1s) from /^%s[|].*[|]%s$/ & %s between '%s' and '%s'", measurement, counter, whereHosts, interval.StartString(), interval.EndString())
+	d.fillInQuery(qi, humanLabel, humanDesc, siriql)
+}
+
+// DownsampleAll selects the AVG of numMetrics metrics in the group `cpu` per
+// host per hour over the whole time range of the dataset, i.e. for every
+// series of these metrics:
+//
+// select mean(1h) from (`groupMetric1` | ...) between 'start' and 'end'
+func (d *Devops) DownsampleAll(qi query.Query, numMetrics int) {
+	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
+	panicIfErr(err)
+	whereMetrics := d.getMetricWhereString(metrics)
+
+	humanLabel := devops.GetDownsampleLabel("SiriDB", numMetrics)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, d.Interval.StartString())
+	siriql := fmt.Sprintf("select mean(1h) from %s between '%s' and '%s'", whereMetrics, d.Interval.StartString(), d.Interval.EndString())
+	d.fillInQuery(qi, humanLabel, humanDesc, siriql)
+}
+
 func (d *Devops) fillInQuery(qi query.Query, humanLabel, humanDesc, sql string) {
 	q := qi.(*query.SiriDB)
 	q.HumanLabel = []byte(humanLabel)
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	internalutils "github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/query"
 )
 
@@ -106,6 +107,37 @@ func TestGroupByTime(t *testing.T) {
 	runTestCases(t, testFunc, start, end, cases)
 }
 
+func TestGroupByTimeConfiguredWindows(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "recent window with 5m buckets",
+			input:              1,
+			expectedHumanLabel: "SiriDB 1 cpu metric(s), random    1 hosts, recent 10m0s by 5m",
+			expectedHumanDesc: "SiriDB 1 cpu metric(s), random    1 hosts, " +
+				"recent 10m0s by 5m: 1970-01-01T01:50:00Z",
+			expectedQuery: "select max(5m) " +
+				"from (`host_5`) & (`usage_user`) " +
+				"between '1970-01-01T01:50:00Z' and '1970-01-01T02:00:00Z' " +
+				"merge as 'max (`usage_user`) " +
+				"for (`host_5`)' using max(1)",
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		if err := d.ConfigureWindows(10*time.Minute, 5*time.Minute, internalutils.WindowRecent); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		q := d.GenerateEmptyQuery()
+		d.GroupByTime(q, 1, c.input, time.Second)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
 func TestGroupByOrderByLimit(t *testing.T) {
 	cases := []testCase{
 		{
@@ -340,6 +372,54 @@ func TestDevopsFillInQuery(t *testing.T) {
 	}
 }
 
+func TestCounterRate(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "SiriDB rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "SiriDB rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m: 1970-01-01T00:16:22Z",
+			expectedQuery: "select max(1m) => derivative(1s) " +
+				"from /^net[|].*[|]bytes_recv$/ & (`host_9`|`host_3`) " +
+				"between '1970-01-01T00:16:22Z' and '1970-01-01T01:16:22Z'",
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.CounterRate(q, "net", "bytes_recv", 2)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
+func TestDownsampleAll(t *testing.T) {
+	cases := []testCase{
+		{
+			desc:               "happy path",
+			expectedHumanLabel: "SiriDB mean of 2 metrics, all hosts, all data by 1h",
+			expectedHumanDesc:  "SiriDB mean of 2 metrics, all hosts, all data by 1h: 1970-01-01T00:00:00Z",
+			expectedQuery: "select mean(1h) " +
+				"from (`usage_user`|`usage_system`) " +
+				"between '1970-01-01T00:00:00Z' and '1970-01-01T02:00:00Z'",
+		},
+	}
+
+	testFunc := func(d *Devops, c testCase) query.Query {
+		q := d.GenerateEmptyQuery()
+		d.DownsampleAll(q, 2)
+		return q
+	}
+
+	start := time.Unix(0, 0)
+	end := start.Add(2 * time.Hour)
+
+	runTestCases(t, testFunc, start, end, cases)
+}
+
 type testCase struct {
 	desc               string
 	input              int
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	"github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/query"
 )
 
@@ -22,6 +23,8 @@ const (
 
 	timeBucketFmt    = "time_bucket('%d seconds', time)"
 	nonTimeBucketFmt = "to_timestamp(((extract(epoch from time)::int)/%d)*%d)"
+
+	aggTimeBucketFmt = "time_bucket('%d seconds', bucket)"
 )
 
 // Devops produces TimescaleDB-specific queries for all the devops query types.
@@ -30,6 +33,16 @@ type Devops struct {
 	UseJSON       bool
 	UseTags       bool
 	UseTimeBucket bool
+
+	// UsePrepared makes the queries statement templates with placeholders
+	// for the times and tags they select, whose values are bound as params
+	UsePrepared bool
+	params      []interface{}
+
+	// ContinuousAggregates are the bucket widths of the continuous
+	// aggregates of cpu made by tsbs_load_timescaledb, which groupby queries
+	// read from instead of cpu when one evenly divides their buckets
+	ContinuousAggregates []time.Duration
 }
 
 // NewDevops makes an Devops object ready to generate Queries.
@@ -47,23 +60,43 @@ func (d *Devops) GenerateEmptyQuery() query.Query {
 	return query.NewTimescaleDB()
 }
 
+// bind returns the SQL for a value of a query: literal, or a placeholder for
+// v when generating prepared statements, in which case v is bound as a param
+func (d *Devops) bind(v interface{}, literal string) string {
+	if !d.UsePrepared {
+		return literal
+	}
+	d.params = append(d.params, v)
+	return fmt.Sprintf("$%d", len(d.params))
+}
+
+// bindString returns the SQL for a string value of a query
+func (d *Devops) bindString(s string) string {
+	return d.bind(s, "'"+s+"'")
+}
+
+// bindTime returns the SQL for a time value of a query
+func (d *Devops) bindTime(t time.Time) string {
+	return d.bindString(t.Format(goTimeFmt))
+}
+
 // getHostWhereWithHostnames creates WHERE SQL statement for multiple hostnames.
 // NOTE 'WHERE' itself is not included, just hostname filter clauses, ready to concatenate to 'WHERE' string
 func (d *Devops) getHostWhereWithHostnames(hostnames []string) string {
 	hostnameClauses := []string{}
 	if d.UseJSON {
 		for _, s := range hostnames {
-			hostnameClauses = append(hostnameClauses, fmt.Sprintf("tagset @> '{\"hostname\": \"%s\"}'", s))
+			hostnameClauses = append(hostnameClauses, "tagset @> "+d.bindString(fmt.Sprintf("{\"hostname\": \"%s\"}", s)))
 		}
 		return fmt.Sprintf("tags_id IN (SELECT id FROM tags WHERE %s)", strings.Join(hostnameClauses, " OR "))
 	} else if d.UseTags {
 		for _, s := range hostnames {
-			hostnameClauses = append(hostnameClauses, fmt.Sprintf("'%s'", s))
+			hostnameClauses = append(hostnameClauses, d.bindString(s))
 		}
 		return fmt.Sprintf("tags_id IN (SELECT id FROM tags WHERE hostname IN (%s))", strings.Join(hostnameClauses, ","))
 	} else {
 		for _, s := range hostnames {
-			hostnameClauses = append(hostnameClauses, fmt.Sprintf("hostname = '%s'", s))
+			hostnameClauses = append(hostnameClauses, "hostname = "+d.bindString(s))
 		}
 		combinedHostnameClause := strings.Join(hostnameClauses, " OR ")
 
@@ -78,6 +111,44 @@ func (d *Devops) getHostWhereString(nHosts int) string {
 	return d.getHostWhereWithHostnames(hostnames)
 }
 
+// getHostGroupClauses returns the column to group rows of a measurement by
+// host on, the expression selecting the hostname of such a group from a
+// subquery with the given alias, and the JOIN clause that expression needs
+func (d *Devops) getHostGroupClauses(alias string) (groupColumn, hostnameField, joinClause string) {
+	if d.UseJSON {
+		return "tags_id", "tags.tagset->>'hostname'", fmt.Sprintf("JOIN tags ON %s.tags_id = tags.id", alias)
+	} else if d.UseTags {
+		return "tags_id", "tags.hostname", fmt.Sprintf("JOIN tags ON %s.tags_id = tags.id", alias)
+	}
+	return "hostname", alias + ".hostname", ""
+}
+
+// getTagField returns the expression selecting a host tag other than the
+// hostname from the tags table, which is the only place such tags are stored
+func (d *Devops) getTagField(tag string) string {
+	if d.UseJSON {
+		return fmt.Sprintf("tags.tagset->>'%s'", tag)
+	}
+	return "tags." + tag
+}
+
+// getTagFilterWhere returns the WHERE clauses restricting rows joined with the
+// tags table to the hosts matching all filters
+func (d *Devops) getTagFilterWhere(filters []devops.TagFilter) string {
+	if d.UseJSON {
+		pairs := make([]string, len(filters))
+		for i, f := range filters {
+			pairs[i] = fmt.Sprintf("\"%s\": \"%s\"", f.Key, f.Value)
+		}
+		return "tags.tagset @> " + d.bindString(fmt.Sprintf("{%s}", strings.Join(pairs, ", ")))
+	}
+	clauses := make([]string, len(filters))
+	for i, f := range filters {
+		clauses[i] = fmt.Sprintf("%s = %s", d.getTagField(f.Key), d.bindString(f.Value))
+	}
+	return strings.Join(clauses, " AND ")
+}
+
 func (d *Devops) getTimeBucket(seconds int) string {
 	if d.UseTimeBucket {
 		return fmt.Sprintf(timeBucketFmt, seconds)
@@ -94,6 +165,55 @@ func (d *Devops) getSelectClausesAggMetrics(agg string, metrics []string) []stri
 	return selectClauses
 }
 
+// ConfigureContinuousAggregates sets the bucket widths of the continuous
+// aggregates of cpu from a comma-separated list of durations, e.g. 1m,1h
+func (d *Devops) ConfigureContinuousAggregates(buckets string) error {
+	aggBuckets, err := utils.ParseAggregateBuckets(buckets)
+	if err != nil {
+		return err
+	}
+	d.ContinuousAggregates = aggBuckets
+	return nil
+}
+
+// getContinuousAggregate returns the name and bucket width in seconds of the
+// widest continuous aggregate of cpu whose buckets evenly divide buckets of
+// the given width, or an empty name if there is none. Continuous aggregates
+// are named after their table and bucket width by tsbs_load_timescaledb.
+func (d *Devops) getContinuousAggregate(seconds int) (string, int) {
+	var aggBucket time.Duration
+	for _, bucket := range d.ContinuousAggregates {
+		if seconds%int(bucket.Seconds()) == 0 && bucket > aggBucket {
+			aggBucket = bucket
+		}
+	}
+	if aggBucket == 0 {
+		return "", 0
+	}
+	return utils.ContinuousAggregateName("cpu", aggBucket), int(aggBucket.Seconds())
+}
+
+// getAggLabel returns the suffix of the label of queries reading from the
+// continuous aggregate agg, or an empty string if agg is empty. Only whole
+// buckets of agg are read, so the partial buckets at the start and end of a
+// query's time range are left out or read in full, and the results differ
+// from those of querying cpu.
+func getAggLabel(agg string) string {
+	if len(agg) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" [%s, whole buckets]", agg)
+}
+
+// getAggTimeBucket returns the expression bucketing the rows of a continuous
+// aggregate with buckets of aggSeconds into buckets of the given width
+func getAggTimeBucket(seconds, aggSeconds int) string {
+	if seconds == aggSeconds {
+		return "bucket"
+	}
+	return fmt.Sprintf(aggTimeBucketFmt, seconds)
+}
+
 const goTimeFmt = "2006-01-02 15:04:05.999999 -0700"
 
 // GroupByTime selects the MAX for numMetrics metrics under 'cpu',
@@ -105,8 +225,12 @@ const goTimeFmt = "2006-01-02 15:04:05.999999 -0700"
 // WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
 // AND time >= '$HOUR_START' AND time < '$HOUR_END'
 // GROUP BY minute ORDER BY minute ASC
+//
+// With a matching continuous aggregate, the maxes of its buckets are read
+// instead.
 func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange time.Duration) {
-	interval := d.Interval.MustRandWindow(timeRange)
+	interval := d.MustQueryInterval(timeRange)
+	bucket := d.GetSingleGroupByBucket()
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
 	selectClauses := d.getSelectClausesAggMetrics("max", metrics)
@@ -114,18 +238,29 @@ func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange t
 		panic(fmt.Sprintf("invalid number of select clauses: got %d", len(selectClauses)))
 	}
 
+	table, timeColumn, timeBucket := "cpu", "time", d.getTimeBucket(int(bucket.Seconds()))
+	agg, aggSeconds := d.getContinuousAggregate(int(bucket.Seconds()))
+	if len(agg) > 0 {
+		// the max of the max of each aggregated bucket
+		table, timeColumn, timeBucket = agg, "bucket", getAggTimeBucket(int(bucket.Seconds()), aggSeconds)
+		for i, m := range metrics {
+			selectClauses[i] = fmt.Sprintf("max(max_%[1]s) as max_%[1]s", m)
+		}
+	}
+
 	sql := fmt.Sprintf(`SELECT %s AS minute,
         %s
-        FROM cpu
-        WHERE %s AND time >= '%s' AND time < '%s'
+        FROM %s
+        WHERE %s AND %s >= %s AND %s < %s
         GROUP BY minute ORDER BY minute ASC`,
-		d.getTimeBucket(oneMinute),
+		timeBucket,
 		strings.Join(selectClauses, ", "),
+		table,
 		d.getHostWhereString(nHosts),
-		interval.Start().Format(goTimeFmt),
-		interval.End().Format(goTimeFmt))
+		timeColumn, d.bindTime(interval.Start()),
+		timeColumn, d.bindTime(interval.End()))
 
-	humanLabel := fmt.Sprintf("TimescaleDB %d cpu metric(s), random %4d hosts, random %s by 1m", numMetrics, nHosts, timeRange)
+	humanLabel := fmt.Sprintf("TimescaleDB %d cpu metric(s), random %4d hosts, %s by %s%s", numMetrics, nHosts, d.WindowLabel(timeRange), devops.ShortDuration(bucket), getAggLabel(agg))
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
@@ -135,18 +270,29 @@ func (d *Devops) GroupByTime(qi query.Query, nHosts, numMetrics int, timeRange t
 // WHERE time < '$TIME'
 // GROUP BY t ORDER BY t DESC
 // LIMIT $LIMIT
+//
+// With a matching continuous aggregate, the maxes of its buckets are read
+// instead.
 func (d *Devops) GroupByOrderByLimit(qi query.Query) {
-	interval := d.Interval.MustRandWindow(time.Hour)
-	sql := fmt.Sprintf(`SELECT %s AS minute, max(usage_user)
-        FROM cpu
-        WHERE time < '%s'
+	interval := d.MustQueryInterval(time.Hour)
+	table, timeColumn, timeBucket, maxUsageUser := "cpu", "time", d.getTimeBucket(oneMinute), "max(usage_user)"
+	agg, aggSeconds := d.getContinuousAggregate(oneMinute)
+	if len(agg) > 0 {
+		table, timeColumn, timeBucket, maxUsageUser = agg, "bucket", getAggTimeBucket(oneMinute, aggSeconds), "max(max_usage_user)"
+	}
+	sql := fmt.Sprintf(`SELECT %s AS minute, %s
+        FROM %s
+        WHERE %s < %s
         GROUP BY minute
         ORDER BY minute DESC
         LIMIT 5`,
-		d.getTimeBucket(oneMinute),
-		interval.End().Format(goTimeFmt))
+		timeBucket,
+		maxUsageUser,
+		table,
+		timeColumn,
+		d.bindTime(interval.End()))
 
-	humanLabel := "TimescaleDB max cpu over last 5 min-intervals (random end)"
+	humanLabel := "TimescaleDB max cpu over last 5 min-intervals (random end)" + getAggLabel(agg)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.EndString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
@@ -158,16 +304,30 @@ func (d *Devops) GroupByOrderByLimit(qi query.Query) {
 // FROM cpu
 // WHERE time >= '$HOUR_START' AND time < '$HOUR_END'
 // GROUP BY hour, hostname ORDER BY hour
+//
+// With a matching continuous aggregate, the means are computed from the sums
+// and row counts of its buckets instead.
 func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
 	panicIfErr(err)
-	interval := d.Interval.MustRandWindow(devops.DoubleGroupByDuration)
+	interval := d.MustQueryInterval(devops.DoubleGroupByDuration)
+
+	table, timeColumn, timeBucket := "cpu", "time", d.getTimeBucket(oneHour)
+	agg, aggSeconds := d.getContinuousAggregate(oneHour)
+	if len(agg) > 0 {
+		table, timeColumn, timeBucket = agg, "bucket", getAggTimeBucket(oneHour, aggSeconds)
+	}
 
 	selectClauses := make([]string, numMetrics)
 	meanClauses := make([]string, numMetrics)
 	for i, m := range metrics {
 		meanClauses[i] = "mean_" + m
-		selectClauses[i] = fmt.Sprintf("avg(%s) as %s", m, meanClauses[i])
+		if len(agg) > 0 {
+			// the mean of all rows of the aggregated buckets
+			selectClauses[i] = fmt.Sprintf("sum(sum_%s) / sum(num_rows) as %s", m, meanClauses[i])
+		} else {
+			selectClauses[i] = fmt.Sprintf("avg(%s) as %s", m, meanClauses[i])
+		}
 	}
 
 	hostnameField := "hostname"
@@ -185,21 +345,22 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
         WITH cpu_avg AS (
           SELECT %s as hour, tags_id,
           %s
-          FROM cpu
-          WHERE time >= '%s' AND time < '%s'
+          FROM %s
+          WHERE %s >= %s AND %s < %s
           GROUP BY hour, tags_id
         )
         SELECT hour, %s, %s
         FROM cpu_avg
         %s
         ORDER BY hour, %s`,
-		d.getTimeBucket(oneHour),
+		timeBucket,
 		strings.Join(selectClauses, ", "),
-		interval.Start().Format(goTimeFmt),
-		interval.End().Format(goTimeFmt),
+		table,
+		timeColumn, d.bindTime(interval.Start()),
+		timeColumn, d.bindTime(interval.End()),
 		hostnameField, strings.Join(meanClauses, ", "),
 		joinStr, hostnameField)
-	humanLabel := devops.GetDoubleGroupByLabel("TimescaleDB", numMetrics)
+	humanLabel := d.GetDoubleGroupByLabel("TimescaleDB", numMetrics) + getAggLabel(agg)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
@@ -212,7 +373,7 @@ func (d *Devops) GroupByTimeAndPrimaryTag(qi query.Query, numMetrics int) {
 // AND time >= '$HOUR_START' AND time < '$HOUR_END'
 // GROUP BY hour ORDER BY hour
 func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
-	interval := d.Interval.MustRandWindow(devops.MaxAllDuration)
+	interval := d.MustQueryInterval(devops.MaxAllDuration)
 
 	metrics := devops.GetAllCPUMetrics()
 	selectClauses := d.getSelectClausesAggMetrics("max", metrics)
@@ -220,15 +381,15 @@ func (d *Devops) MaxAllCPU(qi query.Query, nHosts int) {
 	sql := fmt.Sprintf(`SELECT %s AS hour,
         %s
         FROM cpu
-        WHERE %s AND time >= '%s' AND time < '%s'
+        WHERE %s AND time >= %s AND time < %s
         GROUP BY hour ORDER BY hour`,
 		d.getTimeBucket(oneHour),
 		strings.Join(selectClauses, ", "),
 		d.getHostWhereString(nHosts),
-		interval.Start().Format(goTimeFmt),
-		interval.End().Format(goTimeFmt))
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
 
-	humanLabel := devops.GetMaxAllLabel("TimescaleDB", nHosts)
+	humanLabel := d.GetMaxAllLabel("TimescaleDB", nHosts)
 	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
@@ -264,10 +425,10 @@ func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
 	} else {
 		hostWhereClause = fmt.Sprintf("AND %s", d.getHostWhereString(nHosts))
 	}
-	interval := d.Interval.MustRandWindow(devops.HighCPUDuration)
+	interval := d.MustQueryInterval(devops.HighCPUDuration)
 
-	sql := fmt.Sprintf(`SELECT * FROM cpu WHERE usage_user > 90.0 and time >= '%s' AND time < '%s' %s`,
-		interval.Start().Format(goTimeFmt), interval.End().Format(goTimeFmt), hostWhereClause)
+	sql := fmt.Sprintf(`SELECT * FROM cpu WHERE usage_user > 90.0 and time >= %s AND time < %s %s`,
+		d.bindTime(interval.Start()), d.bindTime(interval.End()), hostWhereClause)
 
 	humanLabel, err := devops.GetHighCPULabel("TimescaleDB", nHosts)
 	panicIfErr(err)
@@ -275,6 +436,375 @@ func (d *Devops) HighCPUForHosts(qi query.Query, nHosts int) {
 	d.fillInQuery(qi, humanLabel, humanDesc, sql)
 }
 
+// CounterRate selects the per-second rate of a counter of a measurement per
+// minute for nHosts hosts, from the maximum of the counter in consecutive
+// minutes, e.g. in pseudo-SQL:
+//
+// WITH counter_max AS (
+// SELECT minute, hostname, max(counter) AS max_counter
+// FROM measurement
+// WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY minute, hostname
+// )
+// SELECT minute, hostname,
+// (max_counter - lag(max_counter) OVER (PARTITION BY hostname ORDER BY minute)) / 60
+// FROM counter_max ORDER BY hostname, minute
+func (d *Devops) CounterRate(qi query.Query, measurement, counter string, nHosts int) {
+	interval := d.MustQueryInterval(devops.RateDuration)
+	groupColumn, hostnameField, joinClause := d.getHostGroupClauses("c")
+
+	sql := fmt.Sprintf(`
+        WITH counter_max AS (
+          SELECT %[1]s AS minute, %[2]s, max(%[3]s) AS max_%[3]s
+          FROM %[4]s
+          WHERE %[5]s AND time >= %[6]s AND time < %[7]s
+          GROUP BY minute, %[2]s
+        )
+        SELECT minute, %[8]s AS hostname,
+        (max_%[3]s - lag(max_%[3]s) OVER (PARTITION BY c.%[2]s ORDER BY minute)) / %[9]d AS rate_%[3]s
+        FROM counter_max c
+        %[10]s
+        ORDER BY hostname, minute`,
+		d.getTimeBucket(oneMinute),
+		groupColumn,
+		counter,
+		measurement,
+		d.getHostWhereString(nHosts),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
+		hostnameField,
+		oneMinute,
+		joinClause)
+
+	humanLabel := d.GetRateLabel("TimescaleDB", measurement, counter, nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	qi.(*query.TimescaleDB).Hypertable = []byte(measurement)
+}
+
+// CPUPercentiles selects percentiles of usage_user per hour for nHosts hosts,
+// e.g. in pseudo-SQL:
+//
+// SELECT hour, percentile_cont(0.5) WITHIN GROUP (ORDER BY usage_user), ...
+// FROM cpu
+// WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hour ORDER BY hour
+func (d *Devops) CPUPercentiles(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.PercentilesDuration)
+
+	percentiles := devops.GetCPUPercentiles()
+	selectClauses := make([]string, len(percentiles))
+	for i, p := range percentiles {
+		selectClauses[i] = fmt.Sprintf("percentile_cont(%0.2f) WITHIN GROUP (ORDER BY usage_user) AS p%d_usage_user", float64(p)/100, p)
+	}
+
+	sql := fmt.Sprintf(`SELECT %s AS hour,
+        %s
+        FROM cpu
+        WHERE %s AND time >= %s AND time < %s
+        GROUP BY hour ORDER BY hour`,
+		d.getTimeBucket(oneHour),
+		strings.Join(selectClauses, ", "),
+		d.getHostWhereString(nHosts),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
+
+	humanLabel := d.GetPercentilesLabel("TimescaleDB", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// TopKHosts selects the k hosts with the highest mean usage_user over a random
+// hour, e.g. in pseudo-SQL:
+//
+// SELECT hostname, avg(usage_user) AS mean_usage_user
+// FROM cpu
+// WHERE time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hostname ORDER BY mean_usage_user DESC LIMIT $K
+func (d *Devops) TopKHosts(qi query.Query, k int) {
+	interval := d.MustQueryInterval(devops.TopKDuration)
+	groupColumn, hostnameField, joinClause := d.getHostGroupClauses("c")
+
+	sql := fmt.Sprintf(`
+        WITH top_k AS (
+          SELECT %s, avg(usage_user) AS mean_usage_user
+          FROM cpu
+          WHERE time >= %s AND time < %s
+          GROUP BY %s
+          ORDER BY mean_usage_user DESC
+          LIMIT %d
+        )
+        SELECT %s AS hostname, mean_usage_user
+        FROM top_k c
+        %s
+        ORDER BY mean_usage_user DESC`,
+		groupColumn,
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
+		groupColumn,
+		k,
+		hostnameField,
+		joinClause)
+
+	humanLabel := d.GetTopKLabel("TimescaleDB", k)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// CPUMemJoin selects the mean usage_user of cpu and the mean used_percent of
+// mem per minute and host for nHosts hosts, joined on minute and host,
+// e.g. in pseudo-SQL:
+//
+// SELECT minute, hostname, mean_usage_user, mean_used_percent
+// FROM (SELECT minute, hostname, avg(usage_user) AS mean_usage_user FROM cpu WHERE ... GROUP BY minute, hostname) c
+// JOIN (SELECT minute, hostname, avg(used_percent) AS mean_used_percent FROM mem WHERE ... GROUP BY minute, hostname) m
+// ON c.minute = m.minute AND c.hostname = m.hostname
+// ORDER BY minute, hostname
+func (d *Devops) CPUMemJoin(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.CPUMemJoinDuration)
+	hostnames, err := d.GetRandomHosts(nHosts)
+	panicIfErr(err)
+	groupColumn, hostnameField, joinClause := d.getHostGroupClauses("c")
+
+	sql := fmt.Sprintf(`
+        WITH cpu_avg AS (
+          SELECT %[1]s AS minute, %[2]s, avg(usage_user) AS mean_usage_user
+          FROM cpu
+          WHERE %[3]s AND time >= %[4]s AND time < %[5]s
+          GROUP BY minute, %[2]s
+        ), mem_avg AS (
+          SELECT %[1]s AS minute, %[2]s, avg(used_percent) AS mean_used_percent
+          FROM mem
+          WHERE %[3]s AND time >= %[4]s AND time < %[5]s
+          GROUP BY minute, %[2]s
+        )
+        SELECT c.minute, %[6]s AS hostname, c.mean_usage_user, m.mean_used_percent
+        FROM cpu_avg c
+        JOIN mem_avg m ON c.minute = m.minute AND c.%[2]s = m.%[2]s
+        %[7]s
+        ORDER BY c.minute, hostname`,
+		d.getTimeBucket(oneMinute),
+		groupColumn,
+		d.getHostWhereWithHostnames(hostnames),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
+		hostnameField,
+		joinClause)
+
+	humanLabel := d.GetCPUMemJoinLabel("TimescaleDB", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// DownsampleAll selects the AVG of numMetrics metrics under 'cpu' per host per
+// hour over the whole time range of the dataset, e.g. in pseudo-SQL:
+//
+// SELECT hour, hostname, AVG(metric1), ..., AVG(metricN)
+// FROM cpu
+// WHERE time >= '$DATASET_START' AND time < '$DATASET_END'
+// GROUP BY hour, hostname ORDER BY hour, hostname
+func (d *Devops) DownsampleAll(qi query.Query, numMetrics int) {
+	metrics, err := devops.GetCPUMetricsSlice(numMetrics)
+	panicIfErr(err)
+	groupColumn, hostnameField, joinClause := d.getHostGroupClauses("c")
+
+	selectClauses := make([]string, numMetrics)
+	meanClauses := make([]string, numMetrics)
+	for i, m := range metrics {
+		meanClauses[i] = "mean_" + m
+		selectClauses[i] = fmt.Sprintf("avg(%s) as %s", m, meanClauses[i])
+	}
+
+	sql := fmt.Sprintf(`
+        WITH cpu_avg AS (
+          SELECT %s as hour, %s,
+          %s
+          FROM cpu
+          WHERE time >= %s AND time < %s
+          GROUP BY hour, %s
+        )
+        SELECT hour, %s AS hostname, %s
+        FROM cpu_avg c
+        %s
+        ORDER BY hour, hostname`,
+		d.getTimeBucket(oneHour),
+		groupColumn,
+		strings.Join(selectClauses, ", "),
+		d.bindTime(d.Interval.Start()),
+		d.bindTime(d.Interval.End()),
+		groupColumn,
+		hostnameField,
+		strings.Join(meanClauses, ", "),
+		joinClause)
+
+	humanLabel := devops.GetDownsampleLabel("TimescaleDB", numMetrics)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, d.Interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
+// DiskFull selects the disks of nHosts hosts (or all hosts if nHosts is 0)
+// whose used_percent exceeded devops.DiskFullThreshold in a random hour,
+// e.g. in pseudo-SQL:
+//
+// SELECT hostname, path, max(used_percent) AS max_used_percent
+// FROM disk
+// WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hostname, path HAVING max(used_percent) > $THRESHOLD
+// ORDER BY max_used_percent DESC
+func (d *Devops) DiskFull(qi query.Query, nHosts int) {
+	var hostWhereClause string
+	if nHosts > 0 {
+		hostWhereClause = fmt.Sprintf("%s AND ", d.getHostWhereString(nHosts))
+	}
+	interval := d.MustQueryInterval(devops.DiskFullDuration)
+	groupColumn, hostnameField, joinClause := d.getHostGroupClauses("c")
+
+	sql := fmt.Sprintf(`
+        WITH disk_max AS (
+          SELECT %[1]s, additional_tags->>'path' AS path, max(used_percent) AS max_used_percent
+          FROM disk
+          WHERE %[2]stime >= %[3]s AND time < %[4]s
+          GROUP BY %[1]s, path
+          HAVING max(used_percent) > %.1[5]f
+        )
+        SELECT %[6]s AS hostname, path, max_used_percent
+        FROM disk_max c
+        %[7]s
+        ORDER BY max_used_percent DESC, hostname`,
+		groupColumn,
+		hostWhereClause,
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
+		devops.DiskFullThreshold,
+		hostnameField,
+		joinClause)
+
+	humanLabel, err := d.GetDiskFullLabel("TimescaleDB", nHosts)
+	panicIfErr(err)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	qi.(*query.TimescaleDB).Hypertable = []byte("disk")
+}
+
+// RedisHitRatio selects the share of redis keyspace lookups that were hits
+// per hour and host for nHosts hosts, from the increase of the hit and miss
+// counters within the hour, e.g. in pseudo-SQL:
+//
+// SELECT hour, hostname, hits / (hits + misses) AS hit_ratio
+// FROM (
+// SELECT hour, hostname,
+// max(keyspace_hits) - min(keyspace_hits) AS hits,
+// max(keyspace_misses) - min(keyspace_misses) AS misses
+// FROM redis
+// WHERE (hostname = '$HOSTNAME_1' OR ... OR hostname = '$HOSTNAME_N')
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hour, hostname
+// )
+// ORDER BY hour, hostname
+func (d *Devops) RedisHitRatio(qi query.Query, nHosts int) {
+	interval := d.MustQueryInterval(devops.RedisHitRatioDuration)
+	groupColumn, hostnameField, joinClause := d.getHostGroupClauses("c")
+
+	sql := fmt.Sprintf(`
+        WITH redis_lookups AS (
+          SELECT %[1]s AS hour, %[2]s,
+          max(keyspace_hits) - min(keyspace_hits) AS hits,
+          max(keyspace_misses) - min(keyspace_misses) AS misses
+          FROM redis
+          WHERE %[3]s AND time >= %[4]s AND time < %[5]s
+          GROUP BY hour, %[2]s
+        )
+        SELECT hour, %[6]s AS hostname, hits / nullif(hits + misses, 0) AS hit_ratio
+        FROM redis_lookups c
+        %[7]s
+        ORDER BY hour, hostname`,
+		d.getTimeBucket(oneHour),
+		groupColumn,
+		d.getHostWhereString(nHosts),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
+		hostnameField,
+		joinClause)
+
+	humanLabel := d.GetRedisHitRatioLabel("TimescaleDB", nHosts)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	qi.(*query.TimescaleDB).Hypertable = []byte("redis")
+}
+
+// NginxRequestRate selects the nginx requests per second of each service per
+// minute over a random hour, from the increase of the requests counter of
+// every host within the minute, e.g. in pseudo-SQL:
+//
+// SELECT minute, service, sum(requests) / 60 AS requests_per_sec
+// FROM (
+// SELECT minute, hostname, max(requests) - min(requests) AS requests
+// FROM nginx
+// WHERE time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY minute, hostname
+// )
+// GROUP BY minute, service ORDER BY minute, service
+func (d *Devops) NginxRequestRate(qi query.Query) {
+	interval := d.MustQueryInterval(devops.NginxRateDuration)
+
+	sql := fmt.Sprintf(`
+        WITH host_requests AS (
+          SELECT %s AS minute, tags_id, max(requests) - min(requests) AS requests
+          FROM nginx
+          WHERE time >= %s AND time < %s
+          GROUP BY minute, tags_id
+        )
+        SELECT minute, %s AS service, sum(requests) / %d AS requests_per_sec
+        FROM host_requests c
+        JOIN tags ON c.tags_id = tags.id
+        GROUP BY minute, service
+        ORDER BY minute, service`,
+		d.getTimeBucket(oneMinute),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()),
+		d.getTagField("service"),
+		oneMinute)
+
+	humanLabel := d.GetNginxRateLabel("TimescaleDB")
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+	qi.(*query.TimescaleDB).Hypertable = []byte("nginx")
+}
+
+// GroupByTag selects the mean usage_user per hour and value of groupTag of the
+// hosts with random values of filterTags, e.g. in pseudo-SQL:
+//
+// SELECT hour, datacenter, avg(usage_user) AS mean_usage_user
+// FROM cpu JOIN tags ON cpu.tags_id = tags.id
+// WHERE service = '$SERVICE' AND service_environment = '$ENVIRONMENT'
+// AND time >= '$HOUR_START' AND time < '$HOUR_END'
+// GROUP BY hour, datacenter ORDER BY hour, datacenter
+func (d *Devops) GroupByTag(qi query.Query, groupTag string, filterTags []string) {
+	interval := d.MustQueryInterval(devops.TagGroupByDuration)
+	filters, err := d.GetRandomTagFilters(filterTags)
+	panicIfErr(err)
+
+	sql := fmt.Sprintf(`SELECT %[1]s AS hour, %[2]s AS %[3]s, avg(usage_user) AS mean_usage_user
+        FROM cpu
+        JOIN tags ON cpu.tags_id = tags.id
+        WHERE %[4]s AND time >= %[5]s AND time < %[6]s
+        GROUP BY hour, %[3]s
+        ORDER BY hour, %[3]s`,
+		d.getTimeBucket(oneHour),
+		d.getTagField(groupTag),
+		groupTag,
+		d.getTagFilterWhere(filters),
+		d.bindTime(interval.Start()),
+		d.bindTime(interval.End()))
+
+	humanLabel := d.GetTagGroupByLabel("TimescaleDB", groupTag, filterTags)
+	humanDesc := fmt.Sprintf("%s: %s", humanLabel, interval.StartString())
+	d.fillInQuery(qi, humanLabel, humanDesc, sql)
+}
+
 // fill Query fills the query struct with data
 func (d *Devops) fillInQuery(qi query.Query, humanLabel, humanDesc, sql string) {
 	q := qi.(*query.TimescaleDB)
@@ -282,4 +812,6 @@ func (d *Devops) fillInQuery(qi query.Query, humanLabel, humanDesc, sql string)
 	q.HumanDescription = []byte(humanDesc)
 	q.Hypertable = []byte("cpu")
 	q.SqlQuery = []byte(sql)
+	q.Params = d.params
+	d.params = nil
 }
@@ -3,11 +3,13 @@ package timescaledb
 import (
 	"fmt"
 	"math/rand"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
+	internalutils "github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/query"
 )
 
@@ -181,6 +183,30 @@ func TestDevopsGroupByTime(t *testing.T) {
 	verifyQuery(t, q, expectedHumanLabel, expectedHumanDesc, expectedHypertable, expectedSQLQuery)
 }
 
+func TestDevopsGroupByTimeConfiguredWindows(t *testing.T) {
+	expectedHumanLabel := "TimescaleDB 1 cpu metric(s), random    1 hosts, recent 10m0s by 5m"
+	expectedHumanDesc := "TimescaleDB 1 cpu metric(s), random    1 hosts, recent 10m0s by 5m: 1970-01-01T00:50:00Z"
+	expectedHypertable := "cpu"
+	expectedSQLQuery := `SELECT time_bucket('300 seconds', time) AS minute,
+        max(usage_user) as max_usage_user
+        FROM cpu
+        WHERE (hostname = 'host_5') AND time >= '1970-01-01 00:50:00 +0000' AND time < '1970-01-01 01:00:00 +0000'
+        GROUP BY minute ORDER BY minute ASC`
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(time.Hour)
+	d := NewDevops(s, e, 10)
+	if err := d.ConfigureWindows(10*time.Minute, 5*time.Minute, internalutils.WindowRecent); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	q := d.GenerateEmptyQuery()
+	d.GroupByTime(q, 1, 1, time.Second)
+
+	verifyQuery(t, q, expectedHumanLabel, expectedHumanDesc, expectedHypertable, expectedSQLQuery)
+}
+
 func TestGroupByOrderByLimit(t *testing.T) {
 	expectedHumanLabel := "TimescaleDB max cpu over last 5 min-intervals (random end)"
 	expectedHumanDesc := "TimescaleDB max cpu over last 5 min-intervals (random end): 1970-01-01T01:16:22Z"
@@ -311,6 +337,132 @@ func TestGroupByTimeAndPrimaryTag(t *testing.T) {
 	}
 }
 
+func TestConfigureContinuousAggregates(t *testing.T) {
+	d := NewDevops(time.Now(), time.Now(), 10)
+	if err := d.ConfigureContinuousAggregates("1m, 1h"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []time.Duration{time.Minute, time.Hour}
+	if !reflect.DeepEqual(d.ContinuousAggregates, want) {
+		t.Errorf("incorrect continuous aggregates: got %v want %v", d.ContinuousAggregates, want)
+	}
+	if err := d.ConfigureContinuousAggregates(""); err != nil || d.ContinuousAggregates != nil {
+		t.Errorf("incorrect continuous aggregates for empty list: got %v (error %v)", d.ContinuousAggregates, err)
+	}
+	for _, buckets := range []string{"1m,foo", "1500ms", "0s", "-1h"} {
+		err := d.ConfigureContinuousAggregates(buckets)
+		if err == nil {
+			t.Errorf("unexpected lack of error for %s", buckets)
+		}
+	}
+}
+
+func TestGetContinuousAggregate(t *testing.T) {
+	d := NewDevops(time.Now(), time.Now(), 10)
+	d.ContinuousAggregates = []time.Duration{time.Minute, 10 * time.Minute, time.Hour}
+	cases := []struct {
+		seconds    int
+		want       string
+		wantBucket string
+	}{
+		{seconds: 60, want: "cpu_60s", wantBucket: "bucket"},
+		{seconds: 300, want: "cpu_60s", wantBucket: "time_bucket('300 seconds', bucket)"},
+		{seconds: 1800, want: "cpu_600s", wantBucket: "time_bucket('1800 seconds', bucket)"},
+		{seconds: 7200, want: "cpu_3600s", wantBucket: "time_bucket('7200 seconds', bucket)"},
+		{seconds: 30, want: ""},
+		{seconds: 90, want: ""},
+	}
+	for _, c := range cases {
+		got, aggSeconds := d.getContinuousAggregate(c.seconds)
+		if got != c.want {
+			t.Errorf("incorrect continuous aggregate for %ds buckets: got %q want %q", c.seconds, got, c.want)
+		}
+		if len(got) > 0 {
+			if bucket := getAggTimeBucket(c.seconds, aggSeconds); bucket != c.wantBucket {
+				t.Errorf("incorrect time bucket for %ds buckets: got %s want %s", c.seconds, bucket, c.wantBucket)
+			}
+		}
+	}
+}
+
+func TestGroupByTimeContinuousAggregate(t *testing.T) {
+	expectedHumanLabel := "TimescaleDB 2 cpu metric(s), random    1 hosts, recent 10m0s by 5m [cpu_60s, whole buckets]"
+	expectedHumanDesc := "TimescaleDB 2 cpu metric(s), random    1 hosts, recent 10m0s by 5m [cpu_60s, whole buckets]: 1970-01-01T00:50:00Z"
+	expectedHypertable := "cpu"
+	expectedSQLQuery := `SELECT time_bucket('300 seconds', bucket) AS minute,
+        max(max_usage_user) as max_usage_user, max(max_usage_system) as max_usage_system
+        FROM cpu_60s
+        WHERE (hostname = 'host_5') AND bucket >= '1970-01-01 00:50:00 +0000' AND bucket < '1970-01-01 01:00:00 +0000'
+        GROUP BY minute ORDER BY minute ASC`
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(time.Hour)
+	d := NewDevops(s, e, 10)
+	d.ContinuousAggregates = []time.Duration{time.Minute}
+	if err := d.ConfigureWindows(10*time.Minute, 5*time.Minute, internalutils.WindowRecent); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	q := d.GenerateEmptyQuery()
+	d.GroupByTime(q, 1, 2, time.Second)
+
+	verifyQuery(t, q, expectedHumanLabel, expectedHumanDesc, expectedHypertable, expectedSQLQuery)
+}
+
+func TestGroupByOrderByLimitContinuousAggregate(t *testing.T) {
+	expectedHumanLabel := "TimescaleDB max cpu over last 5 min-intervals (random end) [cpu_60s, whole buckets]"
+	expectedHumanDesc := "TimescaleDB max cpu over last 5 min-intervals (random end) [cpu_60s, whole buckets]: 1970-01-01T01:16:22Z"
+	expectedHypertable := "cpu"
+	expectedSQLQuery := `SELECT bucket AS minute, max(max_usage_user)
+        FROM cpu_60s
+        WHERE bucket < '1970-01-01 01:16:22.646325 +0000'
+        GROUP BY minute
+        ORDER BY minute DESC
+        LIMIT 5`
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(2 * time.Hour)
+	d := NewDevops(s, e, 10)
+	d.ContinuousAggregates = []time.Duration{time.Minute, time.Hour}
+
+	q := d.GenerateEmptyQuery()
+	d.GroupByOrderByLimit(q)
+
+	verifyQuery(t, q, expectedHumanLabel, expectedHumanDesc, expectedHypertable, expectedSQLQuery)
+}
+
+func TestGroupByTimeAndPrimaryTagContinuousAggregate(t *testing.T) {
+	expectedHumanLabel := "TimescaleDB mean of 2 metrics, all hosts, random 12h0m0s by 1h [cpu_3600s, whole buckets]"
+	expectedHumanDesc := "TimescaleDB mean of 2 metrics, all hosts, random 12h0m0s by 1h [cpu_3600s, whole buckets]: 1970-01-01T00:16:22Z"
+	expectedHypertable := "cpu"
+	expectedSQLQuery := `
+        WITH cpu_avg AS (
+          SELECT bucket as hour, tags_id,
+          sum(sum_usage_user) / sum(num_rows) as mean_usage_user, sum(sum_usage_system) / sum(num_rows) as mean_usage_system
+          FROM cpu_3600s
+          WHERE bucket >= '1970-01-01 00:16:22.646325 +0000' AND bucket < '1970-01-01 12:16:22.646325 +0000'
+          GROUP BY hour, tags_id
+        )
+        SELECT hour, tags.hostname, mean_usage_user, mean_usage_system
+        FROM cpu_avg
+        JOIN tags ON cpu_avg.tags_id = tags.id
+        ORDER BY hour, tags.hostname`
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(devops.DoubleGroupByDuration).Add(time.Hour)
+	d := NewDevops(s, e, 10)
+	d.UseTags = true
+	d.ContinuousAggregates = []time.Duration{time.Minute, time.Hour}
+
+	q := d.GenerateEmptyQuery()
+	d.GroupByTimeAndPrimaryTag(q, 2)
+
+	verifyQuery(t, q, expectedHumanLabel, expectedHumanDesc, expectedHypertable, expectedSQLQuery)
+}
+
 func TestMaxAllCPU(t *testing.T) {
 	expectedHumanLabel := "TimescaleDB max of all CPU metrics, random    1 hosts, random 8h0m0s by 1h"
 	expectedHumanDesc := "TimescaleDB max of all CPU metrics, random    1 hosts, random 8h0m0s by 1h: 1970-01-01T00:16:22Z"
@@ -451,6 +603,472 @@ func TestHighCPUForHosts(t *testing.T) {
 	}
 }
 
+func TestCounterRate(t *testing.T) {
+	cases := []struct {
+		desc               string
+		useTags            bool
+		expectedHumanLabel string
+		expectedHumanDesc  string
+		expectedHypertable string
+		expectedSQLQuery   string
+	}{
+		{
+			desc:               "no JSON or tags",
+			expectedHumanLabel: "TimescaleDB rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "TimescaleDB rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m: 1970-01-01T00:16:22Z",
+			expectedHypertable: "net",
+			expectedSQLQuery: `
+        WITH counter_max AS (
+          SELECT time_bucket('60 seconds', time) AS minute, hostname, max(bytes_recv) AS max_bytes_recv
+          FROM net
+          WHERE (hostname = 'host_9' OR hostname = 'host_3') AND time >= '1970-01-01 00:16:22.646325 +0000' AND time < '1970-01-01 01:16:22.646325 +0000'
+          GROUP BY minute, hostname
+        )
+        SELECT minute, c.hostname AS hostname,
+        (max_bytes_recv - lag(max_bytes_recv) OVER (PARTITION BY c.hostname ORDER BY minute)) / 60 AS rate_bytes_recv
+        FROM counter_max c
+        
+        ORDER BY hostname, minute`,
+		},
+		{
+			desc:               "use tags",
+			useTags:            true,
+			expectedHumanLabel: "TimescaleDB rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "TimescaleDB rate of net bytes_recv, random    2 hosts, random 1h0m0s by 1m: 1970-01-01T00:37:12Z",
+			expectedHypertable: "net",
+			expectedSQLQuery: `
+        WITH counter_max AS (
+          SELECT time_bucket('60 seconds', time) AS minute, tags_id, max(bytes_recv) AS max_bytes_recv
+          FROM net
+          WHERE tags_id IN (SELECT id FROM tags WHERE hostname IN ('host_9','host_5')) AND time >= '1970-01-01 00:37:12.342805 +0000' AND time < '1970-01-01 01:37:12.342805 +0000'
+          GROUP BY minute, tags_id
+        )
+        SELECT minute, tags.hostname AS hostname,
+        (max_bytes_recv - lag(max_bytes_recv) OVER (PARTITION BY c.tags_id ORDER BY minute)) / 60 AS rate_bytes_recv
+        FROM counter_max c
+        JOIN tags ON c.tags_id = tags.id
+        ORDER BY hostname, minute`,
+		},
+	}
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(2 * time.Hour)
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			d := NewDevops(s, e, 10)
+			d.UseTags = c.useTags
+
+			q := d.GenerateEmptyQuery()
+			d.CounterRate(q, "net", "bytes_recv", 2)
+
+			verifyQuery(t, q, c.expectedHumanLabel, c.expectedHumanDesc, c.expectedHypertable, c.expectedSQLQuery)
+		})
+	}
+}
+
+func TestCPUPercentiles(t *testing.T) {
+	expectedHumanLabel := "TimescaleDB percentiles of usage_user, random    1 hosts, random 12h0m0s by 1h"
+	expectedHumanDesc := "TimescaleDB percentiles of usage_user, random    1 hosts, random 12h0m0s by 1h: 1970-01-01T00:16:22Z"
+	expectedHypertable := "cpu"
+	expectedSQLQuery := `SELECT time_bucket('3600 seconds', time) AS hour,
+        percentile_cont(0.50) WITHIN GROUP (ORDER BY usage_user) AS p50_usage_user, percentile_cont(0.90) WITHIN GROUP (ORDER BY usage_user) AS p90_usage_user, percentile_cont(0.99) WITHIN GROUP (ORDER BY usage_user) AS p99_usage_user
+        FROM cpu
+        WHERE (hostname = 'host_9') AND time >= '1970-01-01 00:16:22.646325 +0000' AND time < '1970-01-01 12:16:22.646325 +0000'
+        GROUP BY hour ORDER BY hour`
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(devops.PercentilesDuration).Add(time.Hour)
+	d := NewDevops(s, e, 10)
+
+	q := d.GenerateEmptyQuery()
+	d.CPUPercentiles(q, 1)
+
+	verifyQuery(t, q, expectedHumanLabel, expectedHumanDesc, expectedHypertable, expectedSQLQuery)
+}
+
+func TestTopKHosts(t *testing.T) {
+	expectedHumanLabel := "TimescaleDB top 5 hosts by mean usage_user, random 1h0m0s"
+	expectedHumanDesc := "TimescaleDB top 5 hosts by mean usage_user, random 1h0m0s: 1970-01-01T00:16:22Z"
+	expectedHypertable := "cpu"
+	expectedSQLQuery := `
+        WITH top_k AS (
+          SELECT hostname, avg(usage_user) AS mean_usage_user
+          FROM cpu
+          WHERE time >= '1970-01-01 00:16:22.646325 +0000' AND time < '1970-01-01 01:16:22.646325 +0000'
+          GROUP BY hostname
+          ORDER BY mean_usage_user DESC
+          LIMIT 5
+        )
+        SELECT c.hostname AS hostname, mean_usage_user
+        FROM top_k c
+        
+        ORDER BY mean_usage_user DESC`
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(2 * time.Hour)
+	d := NewDevops(s, e, 10)
+
+	q := d.GenerateEmptyQuery()
+	d.TopKHosts(q, 5)
+
+	verifyQuery(t, q, expectedHumanLabel, expectedHumanDesc, expectedHypertable, expectedSQLQuery)
+}
+
+func TestCPUMemJoin(t *testing.T) {
+	expectedHumanLabel := "TimescaleDB cpu joined with mem, random    2 hosts, random 1h0m0s by 1m"
+	expectedHumanDesc := "TimescaleDB cpu joined with mem, random    2 hosts, random 1h0m0s by 1m: 1970-01-01T00:16:22Z"
+	expectedHypertable := "cpu"
+	expectedSQLQuery := `
+        WITH cpu_avg AS (
+          SELECT time_bucket('60 seconds', time) AS minute, hostname, avg(usage_user) AS mean_usage_user
+          FROM cpu
+          WHERE (hostname = 'host_9' OR hostname = 'host_3') AND time >= '1970-01-01 00:16:22.646325 +0000' AND time < '1970-01-01 01:16:22.646325 +0000'
+          GROUP BY minute, hostname
+        ), mem_avg AS (
+          SELECT time_bucket('60 seconds', time) AS minute, hostname, avg(used_percent) AS mean_used_percent
+          FROM mem
+          WHERE (hostname = 'host_9' OR hostname = 'host_3') AND time >= '1970-01-01 00:16:22.646325 +0000' AND time < '1970-01-01 01:16:22.646325 +0000'
+          GROUP BY minute, hostname
+        )
+        SELECT c.minute, c.hostname AS hostname, c.mean_usage_user, m.mean_used_percent
+        FROM cpu_avg c
+        JOIN mem_avg m ON c.minute = m.minute AND c.hostname = m.hostname
+        
+        ORDER BY c.minute, hostname`
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(2 * time.Hour)
+	d := NewDevops(s, e, 10)
+
+	q := d.GenerateEmptyQuery()
+	d.CPUMemJoin(q, 2)
+
+	verifyQuery(t, q, expectedHumanLabel, expectedHumanDesc, expectedHypertable, expectedSQLQuery)
+}
+
+func TestCPUMemJoinPrepared(t *testing.T) {
+	expectedSQLQuery := `
+        WITH cpu_avg AS (
+          SELECT time_bucket('60 seconds', time) AS minute, hostname, avg(usage_user) AS mean_usage_user
+          FROM cpu
+          WHERE (hostname = $1 OR hostname = $2) AND time >= $3 AND time < $4
+          GROUP BY minute, hostname
+        ), mem_avg AS (
+          SELECT time_bucket('60 seconds', time) AS minute, hostname, avg(used_percent) AS mean_used_percent
+          FROM mem
+          WHERE (hostname = $1 OR hostname = $2) AND time >= $3 AND time < $4
+          GROUP BY minute, hostname
+        )
+        SELECT c.minute, c.hostname AS hostname, c.mean_usage_user, m.mean_used_percent
+        FROM cpu_avg c
+        JOIN mem_avg m ON c.minute = m.minute AND c.hostname = m.hostname
+        
+        ORDER BY c.minute, hostname`
+	expectedParams := []interface{}{"host_9", "host_3", "1970-01-01 00:16:22.646325 +0000", "1970-01-01 01:16:22.646325 +0000"}
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(2 * time.Hour)
+	d := NewDevops(s, e, 10)
+	d.UsePrepared = true
+
+	for i := 0; i < 2; i++ {
+		q := d.GenerateEmptyQuery().(*query.TimescaleDB)
+		d.CPUMemJoin(q, 2)
+		if got := string(q.SqlQuery); got != expectedSQLQuery {
+			t.Errorf("incorrect SQL query:\ngot\n%s\nwant\n%s", got, expectedSQLQuery)
+		}
+		if !reflect.DeepEqual(q.Params, expectedParams) {
+			t.Errorf("incorrect params: got %v want %v", q.Params, expectedParams)
+		}
+		q.Release()
+
+		// the next query is numbered from $1 again
+		rand.Seed(123)
+	}
+}
+
+func TestDownsampleAll(t *testing.T) {
+	expectedHumanLabel := "TimescaleDB mean of 2 metrics, all hosts, all data by 1h"
+	expectedHumanDesc := "TimescaleDB mean of 2 metrics, all hosts, all data by 1h: 1970-01-01T00:00:00Z"
+	expectedHypertable := "cpu"
+	expectedSQLQuery := `
+        WITH cpu_avg AS (
+          SELECT time_bucket('3600 seconds', time) as hour, hostname,
+          avg(usage_user) as mean_usage_user, avg(usage_system) as mean_usage_system
+          FROM cpu
+          WHERE time >= '1970-01-01 00:00:00 +0000' AND time < '1970-01-01 02:00:00 +0000'
+          GROUP BY hour, hostname
+        )
+        SELECT hour, c.hostname AS hostname, mean_usage_user, mean_usage_system
+        FROM cpu_avg c
+        
+        ORDER BY hour, hostname`
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(2 * time.Hour)
+	d := NewDevops(s, e, 10)
+
+	q := d.GenerateEmptyQuery()
+	d.DownsampleAll(q, 2)
+
+	verifyQuery(t, q, expectedHumanLabel, expectedHumanDesc, expectedHypertable, expectedSQLQuery)
+}
+
+func TestDiskFull(t *testing.T) {
+	cases := []struct {
+		desc               string
+		useJSON            bool
+		useTags            bool
+		expectedHumanLabel string
+		expectedHumanDesc  string
+		expectedHypertable string
+		expectedSQLQuery   string
+	}{
+		{
+			desc:               "no JSON or tags",
+			expectedHumanLabel: "TimescaleDB disk over threshold, all hosts, random 1h0m0s",
+			expectedHumanDesc:  "TimescaleDB disk over threshold, all hosts, random 1h0m0s: 1970-01-01T00:16:22Z",
+			expectedHypertable: "disk",
+			expectedSQLQuery: `
+        WITH disk_max AS (
+          SELECT hostname, additional_tags->>'path' AS path, max(used_percent) AS max_used_percent
+          FROM disk
+          WHERE time >= '1970-01-01 00:16:22.646325 +0000' AND time < '1970-01-01 01:16:22.646325 +0000'
+          GROUP BY hostname, path
+          HAVING max(used_percent) > 90.0
+        )
+        SELECT c.hostname AS hostname, path, max_used_percent
+        FROM disk_max c
+        
+        ORDER BY max_used_percent DESC, hostname`,
+		},
+		{
+			desc:               "use tags",
+			useTags:            true,
+			expectedHumanLabel: "TimescaleDB disk over threshold, all hosts, random 1h0m0s",
+			expectedHumanDesc:  "TimescaleDB disk over threshold, all hosts, random 1h0m0s: 1970-01-01T00:54:10Z",
+			expectedHypertable: "disk",
+			expectedSQLQuery: `
+        WITH disk_max AS (
+          SELECT tags_id, additional_tags->>'path' AS path, max(used_percent) AS max_used_percent
+          FROM disk
+          WHERE time >= '1970-01-01 00:54:10.138978 +0000' AND time < '1970-01-01 01:54:10.138978 +0000'
+          GROUP BY tags_id, path
+          HAVING max(used_percent) > 90.0
+        )
+        SELECT tags.hostname AS hostname, path, max_used_percent
+        FROM disk_max c
+        JOIN tags ON c.tags_id = tags.id
+        ORDER BY max_used_percent DESC, hostname`,
+		},
+	}
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(2 * time.Hour)
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			d := NewDevops(s, e, 10)
+			d.UseJSON = c.useJSON
+			d.UseTags = c.useTags
+
+			q := d.GenerateEmptyQuery()
+			d.DiskFull(q, 0)
+
+			verifyQuery(t, q, c.expectedHumanLabel, c.expectedHumanDesc, c.expectedHypertable, c.expectedSQLQuery)
+		})
+	}
+}
+
+func TestDiskFullForHosts(t *testing.T) {
+	expectedHumanLabel := "TimescaleDB disk over threshold, 1 host(s), random 1h0m0s"
+	expectedHumanDesc := "TimescaleDB disk over threshold, 1 host(s), random 1h0m0s: 1970-01-01T00:54:10Z"
+	expectedHypertable := "disk"
+	expectedSQLQuery := `
+        WITH disk_max AS (
+          SELECT hostname, additional_tags->>'path' AS path, max(used_percent) AS max_used_percent
+          FROM disk
+          WHERE (hostname = 'host_5') AND time >= '1970-01-01 00:54:10.138978 +0000' AND time < '1970-01-01 01:54:10.138978 +0000'
+          GROUP BY hostname, path
+          HAVING max(used_percent) > 90.0
+        )
+        SELECT c.hostname AS hostname, path, max_used_percent
+        FROM disk_max c
+        
+        ORDER BY max_used_percent DESC, hostname`
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(2 * time.Hour)
+	d := NewDevops(s, e, 10)
+
+	q := d.GenerateEmptyQuery()
+	d.DiskFull(q, 1)
+
+	verifyQuery(t, q, expectedHumanLabel, expectedHumanDesc, expectedHypertable, expectedSQLQuery)
+}
+
+func TestRedisHitRatio(t *testing.T) {
+	expectedHumanLabel := "TimescaleDB redis keyspace hit ratio, random    2 hosts, random 12h0m0s by 1h"
+	expectedHumanDesc := "TimescaleDB redis keyspace hit ratio, random    2 hosts, random 12h0m0s by 1h: 1970-01-01T00:16:22Z"
+	expectedHypertable := "redis"
+	expectedSQLQuery := `
+        WITH redis_lookups AS (
+          SELECT time_bucket('3600 seconds', time) AS hour, hostname,
+          max(keyspace_hits) - min(keyspace_hits) AS hits,
+          max(keyspace_misses) - min(keyspace_misses) AS misses
+          FROM redis
+          WHERE (hostname = 'host_9' OR hostname = 'host_3') AND time >= '1970-01-01 00:16:22.646325 +0000' AND time < '1970-01-01 12:16:22.646325 +0000'
+          GROUP BY hour, hostname
+        )
+        SELECT hour, c.hostname AS hostname, hits / nullif(hits + misses, 0) AS hit_ratio
+        FROM redis_lookups c
+        
+        ORDER BY hour, hostname`
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(devops.RedisHitRatioDuration).Add(time.Hour)
+	d := NewDevops(s, e, 10)
+
+	q := d.GenerateEmptyQuery()
+	d.RedisHitRatio(q, 2)
+
+	verifyQuery(t, q, expectedHumanLabel, expectedHumanDesc, expectedHypertable, expectedSQLQuery)
+}
+
+func TestNginxRequestRate(t *testing.T) {
+	cases := []struct {
+		desc               string
+		useJSON            bool
+		useTags            bool
+		expectedHumanLabel string
+		expectedHumanDesc  string
+		expectedHypertable string
+		expectedSQLQuery   string
+	}{
+		{
+			desc:               "use JSON",
+			useJSON:            true,
+			expectedHumanLabel: "TimescaleDB nginx requests per second per service, all hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "TimescaleDB nginx requests per second per service, all hosts, random 1h0m0s by 1m: 1970-01-01T00:16:22Z",
+			expectedHypertable: "nginx",
+			expectedSQLQuery: `
+        WITH host_requests AS (
+          SELECT time_bucket('60 seconds', time) AS minute, tags_id, max(requests) - min(requests) AS requests
+          FROM nginx
+          WHERE time >= '1970-01-01 00:16:22.646325 +0000' AND time < '1970-01-01 01:16:22.646325 +0000'
+          GROUP BY minute, tags_id
+        )
+        SELECT minute, tags.tagset->>'service' AS service, sum(requests) / 60 AS requests_per_sec
+        FROM host_requests c
+        JOIN tags ON c.tags_id = tags.id
+        GROUP BY minute, service
+        ORDER BY minute, service`,
+		},
+		{
+			desc:               "use tags",
+			useTags:            true,
+			expectedHumanLabel: "TimescaleDB nginx requests per second per service, all hosts, random 1h0m0s by 1m",
+			expectedHumanDesc:  "TimescaleDB nginx requests per second per service, all hosts, random 1h0m0s by 1m: 1970-01-01T00:54:10Z",
+			expectedHypertable: "nginx",
+			expectedSQLQuery: `
+        WITH host_requests AS (
+          SELECT time_bucket('60 seconds', time) AS minute, tags_id, max(requests) - min(requests) AS requests
+          FROM nginx
+          WHERE time >= '1970-01-01 00:54:10.138978 +0000' AND time < '1970-01-01 01:54:10.138978 +0000'
+          GROUP BY minute, tags_id
+        )
+        SELECT minute, tags.service AS service, sum(requests) / 60 AS requests_per_sec
+        FROM host_requests c
+        JOIN tags ON c.tags_id = tags.id
+        GROUP BY minute, service
+        ORDER BY minute, service`,
+		},
+	}
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(2 * time.Hour)
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			d := NewDevops(s, e, 10)
+			d.UseJSON = c.useJSON
+			d.UseTags = c.useTags
+
+			q := d.GenerateEmptyQuery()
+			d.NginxRequestRate(q)
+
+			verifyQuery(t, q, c.expectedHumanLabel, c.expectedHumanDesc, c.expectedHypertable, c.expectedSQLQuery)
+		})
+	}
+}
+
+func TestGroupByTag(t *testing.T) {
+	cases := []struct {
+		desc               string
+		useJSON            bool
+		useTags            bool
+		expectedHumanLabel string
+		expectedHumanDesc  string
+		expectedHypertable string
+		expectedSQLQuery   string
+	}{
+		{
+			desc:               "use tags",
+			useTags:            true,
+			expectedHumanLabel: "TimescaleDB mean usage_user per datacenter, random service and service_environment, random 12h0m0s by 1h",
+			expectedHumanDesc:  "TimescaleDB mean usage_user per datacenter, random service and service_environment, random 12h0m0s by 1h: 1970-01-01T00:16:22Z",
+			expectedHypertable: "cpu",
+			expectedSQLQuery: `SELECT time_bucket('3600 seconds', time) AS hour, tags.datacenter AS datacenter, avg(usage_user) AS mean_usage_user
+        FROM cpu
+        JOIN tags ON cpu.tags_id = tags.id
+        WHERE tags.service = '9' AND tags.service_environment = 'staging' AND time >= '1970-01-01 00:16:22.646325 +0000' AND time < '1970-01-01 12:16:22.646325 +0000'
+        GROUP BY hour, datacenter
+        ORDER BY hour, datacenter`,
+		},
+		{
+			desc:               "use JSON",
+			useJSON:            true,
+			expectedHumanLabel: "TimescaleDB mean usage_user per datacenter, random service and service_environment, random 12h0m0s by 1h",
+			expectedHumanDesc:  "TimescaleDB mean usage_user per datacenter, random service and service_environment, random 12h0m0s by 1h: 1970-01-01T00:37:12Z",
+			expectedHypertable: "cpu",
+			expectedSQLQuery: `SELECT time_bucket('3600 seconds', time) AS hour, tags.tagset->>'datacenter' AS datacenter, avg(usage_user) AS mean_usage_user
+        FROM cpu
+        JOIN tags ON cpu.tags_id = tags.id
+        WHERE tags.tagset @> '{"service": "19", "service_environment": "test"}' AND time >= '1970-01-01 00:37:12.342805 +0000' AND time < '1970-01-01 12:37:12.342805 +0000'
+        GROUP BY hour, datacenter
+        ORDER BY hour, datacenter`,
+		},
+	}
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	s := time.Unix(0, 0)
+	e := s.Add(devops.TagGroupByDuration).Add(time.Hour)
+
+	for _, c := range cases {
+		t.Run(c.desc, func(t *testing.T) {
+			d := NewDevops(s, e, 10)
+			d.UseJSON = c.useJSON
+			d.UseTags = c.useTags
+
+			q := d.GenerateEmptyQuery()
+			d.GroupByTag(q, "datacenter", []string{"service", "service_environment"})
+
+			verifyQuery(t, q, c.expectedHumanLabel, c.expectedHumanDesc, c.expectedHypertable, c.expectedSQLQuery)
+		})
+	}
+}
+
 func verifyQuery(t *testing.T, q query.Query, humanLabel, humanDesc, hypertable, sqlQuery string) {
 	tsq, ok := q.(*query.TimescaleDB)
 
@@ -29,14 +29,55 @@ var useCaseMatrix = map[string]map[string]utils.QueryFillerMaker{
 		devops.LabelHighCPU + "-all":          devops.NewHighCPU(0),
 		devops.LabelHighCPU + "-1":            devops.NewHighCPU(1),
 		devops.LabelLastpoint:                 devops.NewLastPointPerHost,
+		devops.LabelRate + "-net-1":           devops.NewCounterRate("net", "bytes_recv", 1),
+		devops.LabelRate + "-net-8":           devops.NewCounterRate("net", "bytes_recv", 8),
+		devops.LabelRate + "-diskio-1":        devops.NewCounterRate("diskio", "write_bytes", 1),
+		devops.LabelPercentiles + "-1":        devops.NewCPUPercentiles(1),
+		devops.LabelPercentiles + "-8":        devops.NewCPUPercentiles(8),
+		devops.LabelTopK + "-5":               devops.NewTopKHosts(5),
+		devops.LabelTopK + "-20":              devops.NewTopKHosts(20),
+		devops.LabelCPUMemJoin + "-1":         devops.NewCPUMemJoin(1),
+		devops.LabelCPUMemJoin + "-8":         devops.NewCPUMemJoin(8),
+		devops.LabelDownsample + "-1":         devops.NewDownsampleAll(1),
+		devops.LabelDownsample + "-all":       devops.NewDownsampleAll(devops.GetCPUMetricsLen()),
+		devops.LabelDiskFull + "-all":         devops.NewDiskFull(0),
+		devops.LabelDiskFull + "-1":           devops.NewDiskFull(1),
+		devops.LabelRedisHitRatio + "-1":      devops.NewRedisHitRatio(1),
+		devops.LabelRedisHitRatio + "-8":      devops.NewRedisHitRatio(8),
+		devops.LabelNginxRate:                 devops.NewNginxRequestRate,
+
+		// hosts are selected by tags other than hostname, e.g. by service
+		devops.LabelTagGroupby + "-datacenter-service": devops.NewTagGroupBy("datacenter", "service", "service_environment"),
+		devops.LabelTagGroupby + "-team-region":        devops.NewTagGroupBy("team", "region"),
 	},
 }
 
+// cpuOnlyExcluded are the devops query types that need measurements other than
+// cpu, so cannot be run against the cpu-only use case
+var cpuOnlyExcluded = []string{
+	devops.LabelRate + "-net-1",
+	devops.LabelRate + "-net-8",
+	devops.LabelRate + "-diskio-1",
+	devops.LabelCPUMemJoin + "-1",
+	devops.LabelCPUMemJoin + "-8",
+	devops.LabelDiskFull + "-all",
+	devops.LabelDiskFull + "-1",
+	devops.LabelRedisHitRatio + "-1",
+	devops.LabelRedisHitRatio + "-8",
+	devops.LabelNginxRate,
+}
+
 var config = &inputs.QueryGeneratorConfig{}
 
 // Parse args:
 func init() {
-	useCaseMatrix["cpu-only"] = useCaseMatrix["devops"]
+	useCaseMatrix["cpu-only"] = map[string]utils.QueryFillerMaker{}
+	for queryType, maker := range useCaseMatrix["devops"] {
+		useCaseMatrix["cpu-only"][queryType] = maker
+	}
+	for _, queryType := range cpuOnlyExcluded {
+		delete(useCaseMatrix["cpu-only"], queryType)
+	}
 	// Change the Usage function to print the use case matrix of choices:
 	oldUsage := flag.Usage
 	flag.Usage = func() {
@@ -56,10 +97,14 @@ func init() {
 	flag.Uint64Var(&config.Limit, "queries", 1000, "Number of queries to generate.")
 
 	flag.BoolVar(&config.ClickhouseUseTags, "clickhouse-use-tags", true, "ClickHouse only: Use separate tags table when querying")
+	flag.BoolVar(&config.InfluxUseFlux, "influx-use-flux", false, "InfluxDB only: Generate Flux queries for the InfluxDB 2.x API instead of InfluxQL")
+	flag.StringVar(&config.InfluxBucket, "influx-bucket", "benchmark", "InfluxDB only: Bucket the Flux queries read from, i.e. the -db-name the data was loaded into")
 	flag.BoolVar(&config.MongoUseNaive, "mongo-use-naive", true, "MongoDB only: Generate queries for the 'naive' data storage format for Mongo")
 	flag.BoolVar(&config.TimescaleUseJSON, "timescale-use-json", false, "TimescaleDB only: Use separate JSON tags table when querying")
 	flag.BoolVar(&config.TimescaleUseTags, "timescale-use-tags", true, "TimescaleDB only: Use separate tags table when querying")
 	flag.BoolVar(&config.TimescaleUseTimeBucket, "timescale-use-time-bucket", true, "TimescaleDB only: Use time bucket. Set to false to test on native PostgreSQL")
+	flag.StringVar(&config.TimescaleContinuousAggregates, "timescale-continuous-aggregates", "", "TimescaleDB only: Comma-separated bucket widths of the continuous aggregates made by tsbs_load_timescaledb -continuous-aggregates, e.g. 1m,1h, to run groupby queries against")
+	flag.BoolVar(&config.UsePreparedStatements, "use-prepared-statements", false, "ClickHouse, CrateDB and TimescaleDB only: Generate statement templates with bound parameters, prepared once per worker by the query runners")
 
 	flag.Parse()
 }
@@ -2,8 +2,10 @@ package devops
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"reflect"
+	"strings"
 	"time"
 
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
@@ -17,6 +19,30 @@ const (
 	errNoMetrics            = "cannot get 0 metrics"
 	errTooManyMetrics       = "too many metrics asked for"
 	errMoreItemsThanScale   = "cannot get random permutation with more items than scale"
+	errUnknownTagFmt        = "no values known for tag %s"
+
+	errUnknownHostDistFmt = "unknown host distribution '%s' (choices: %s)"
+	errBadHostSkewFmt     = "zipfian host skew has to be larger than 1; got %v"
+	errBadHostHotsetFmt   = "host hotset has to be a percentage larger than 0; got %v"
+
+	// HostsUniform picks every host with the same probability
+	HostsUniform = "uniform"
+	// HostsZipfian picks host_0 most often, host_1 second most often and so
+	// on, following a zipfian distribution with skew HostSkew
+	HostsZipfian = "zipfian"
+	// HostsHotset picks one of the first HostHotset percent of hosts with
+	// HotsetProbability, and any host otherwise
+	HostsHotset = "hotset"
+
+	// HotsetProbability is the probability of HostsHotset picking a host from
+	// the hot set
+	HotsetProbability = 0.9
+	// maxSkewedPicks is how often a host is picked from a skewed distribution
+	// before a host that was not picked yet is chosen uniformly at random
+	maxSkewedPicks = 10
+
+	// SingleGroupByBucket is the default width of the time buckets of SingleGroupby queries
+	SingleGroupByBucket = time.Minute
 
 	// DoubleGroupByDuration is the how big the time range for DoubleGroupBy query is
 	DoubleGroupByDuration = 12 * time.Hour
@@ -24,6 +50,25 @@ const (
 	HighCPUDuration = 12 * time.Hour
 	// MaxAllDuration is the how big the time range for MaxAll query is
 	MaxAllDuration = 8 * time.Hour
+	// RateDuration is the how big the time range for CounterRate query is
+	RateDuration = time.Hour
+	// PercentilesDuration is the how big the time range for CPUPercentiles query is
+	PercentilesDuration = 12 * time.Hour
+	// TopKDuration is the how big the time range for TopKHosts query is
+	TopKDuration = time.Hour
+	// CPUMemJoinDuration is the how big the time range for CPUMemJoin query is
+	CPUMemJoinDuration = time.Hour
+	// DiskFullDuration is the how big the time range for DiskFull query is
+	DiskFullDuration = time.Hour
+	// RedisHitRatioDuration is the how big the time range for RedisHitRatio query is
+	RedisHitRatioDuration = 12 * time.Hour
+	// NginxRateDuration is the how big the time range for NginxRequestRate query is
+	NginxRateDuration = time.Hour
+	// TagGroupByDuration is the how big the time range for GroupByTag query is
+	TagGroupByDuration = 12 * time.Hour
+
+	// DiskFullThreshold is the used_percent of disk above which a disk counts as full
+	DiskFullThreshold = 90.0
 
 	// LabelSingleGroupby is the label prefix for queries of the single groupby variety
 	LabelSingleGroupby = "single-groupby"
@@ -37,6 +82,24 @@ const (
 	LabelGroupbyOrderbyLimit = "groupby-orderby-limit"
 	// LabelHighCPU is the prefix for queries of the high-CPU variety
 	LabelHighCPU = "high-cpu"
+	// LabelRate is the prefix for queries of the counter rate variety
+	LabelRate = "rate"
+	// LabelPercentiles is the prefix for queries of the cpu-percentiles variety
+	LabelPercentiles = "cpu-percentiles"
+	// LabelTopK is the prefix for queries of the top-k hosts variety
+	LabelTopK = "top-k"
+	// LabelCPUMemJoin is the prefix for queries of the cpu-mem-join variety
+	LabelCPUMemJoin = "cpu-mem-join"
+	// LabelDownsample is the prefix for queries of the downsample variety
+	LabelDownsample = "downsample"
+	// LabelDiskFull is the prefix for queries of the disk-full variety
+	LabelDiskFull = "disk-full"
+	// LabelRedisHitRatio is the prefix for queries of the redis-hit-ratio variety
+	LabelRedisHitRatio = "redis-hit-ratio"
+	// LabelNginxRate is the label for the nginx requests per service query
+	LabelNginxRate = "nginx-rate-per-service"
+	// LabelTagGroupby is the prefix for queries filtering and grouping on host tags other than hostname
+	LabelTagGroupby = "tag-groupby"
 )
 
 // Core is the common component of all generators for all systems
@@ -46,8 +109,32 @@ type Core struct {
 
 	// Scale is the cardinality of the dataset in terms of devices/hosts
 	Scale int
+
+	// Window, if non-zero, is the time range of every query instead of the
+	// default of its query type
+	Window time.Duration
+	// Bucket, if non-zero, is the width of the time buckets of SingleGroupby
+	// queries instead of SingleGroupByBucket
+	Bucket time.Duration
+	// Placement is where the time range of a query is placed within Interval,
+	// one of internalutils.WindowPlacements; empty means WindowRandom
+	Placement string
+
+	// HostDistribution is how likely GetRandomHosts is to pick each host, one
+	// of HostDistributions; empty means HostsUniform
+	HostDistribution string
+	// HostSkew is the skew of HostsZipfian, larger than 1
+	HostSkew float64
+	// HostHotset is the percentage of hosts in the hot set of HostsHotset
+	HostHotset float64
+
+	zipf *rand.Zipf
 }
 
+// HostDistributions are all the distributions GetRandomHosts can pick hosts
+// with
+var HostDistributions = []string{HostsUniform, HostsZipfian, HostsHotset}
+
 // NewCore returns a new Core for the given time range and cardinality
 func NewCore(start, end time.Time, scale int) (*Core, error) {
 	ti, err := internalutils.NewTimeInterval(start, end)
@@ -58,9 +145,123 @@ func NewCore(start, end time.Time, scale int) (*Core, error) {
 	return &Core{Interval: ti, Scale: scale}, nil
 }
 
-// GetRandomHosts returns a random set of nHosts from a given Core
+// ConfigureWindows sets the time range, bucket width and time range placement
+// of the queries generated; zero values keep the defaults
+func (d *Core) ConfigureWindows(window, bucket time.Duration, placement string) error {
+	if placement != "" {
+		if err := internalutils.ValidateWindowPlacement(placement); err != nil {
+			return err
+		}
+	}
+	d.Window = window
+	d.Bucket = bucket
+	d.Placement = placement
+	return nil
+}
+
+// QueryWindow returns the length of the time range of a query whose query type
+// defaults to window
+func (d *Core) QueryWindow(window time.Duration) time.Duration {
+	if d.Window > 0 {
+		return d.Window
+	}
+	return window
+}
+
+// MustQueryInterval returns the time range of a query whose query type
+// defaults to window, placed within Interval as configured. It panics if the
+// time range does not fit into Interval.
+func (d *Core) MustQueryInterval(window time.Duration) *internalutils.TimeInterval {
+	return d.Interval.MustPlaceWindow(d.QueryWindow(window), d.getPlacement())
+}
+
+// getPlacement returns the configured window placement or the default
+func (d *Core) getPlacement() string {
+	if d.Placement == "" {
+		return internalutils.WindowRandom
+	}
+	return d.Placement
+}
+
+// WindowLabel describes the time range of a query whose query type defaults
+// to window for use in Query human-readable labels, e.g. "random 12h0m0s"
+func (d *Core) WindowLabel(window time.Duration) string {
+	return fmt.Sprintf("%s %s", d.getPlacement(), d.QueryWindow(window))
+}
+
+// GetSingleGroupByBucket returns the width of the time buckets of
+// SingleGroupby queries
+func (d *Core) GetSingleGroupByBucket() time.Duration {
+	if d.Bucket > 0 {
+		return d.Bucket
+	}
+	return SingleGroupByBucket
+}
+
+// ShortDuration formats a duration without zero minutes and seconds, e.g. 1m
+// or 1h instead of 1m0s or 1h0m0s, as used in labels and many query languages
+func ShortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// ConfigureHosts sets the distribution GetRandomHosts picks hosts with, where
+// skew is only used by HostsZipfian and hotset only by HostsHotset
+func (d *Core) ConfigureHosts(distribution string, skew, hotset float64) error {
+	switch distribution {
+	case "", HostsUniform:
+	case HostsZipfian:
+		if skew <= 1 {
+			return fmt.Errorf(errBadHostSkewFmt, skew)
+		}
+	case HostsHotset:
+		if hotset <= 0 || hotset > 100 {
+			return fmt.Errorf(errBadHostHotsetFmt, hotset)
+		}
+	default:
+		return fmt.Errorf(errUnknownHostDistFmt, distribution, strings.Join(HostDistributions, ", "))
+	}
+	d.HostDistribution = distribution
+	d.HostSkew = skew
+	d.HostHotset = hotset
+	d.zipf = nil
+	return nil
+}
+
+// GetRandomHosts returns a random set of nHosts from a given Core, picked
+// with its HostDistribution
 func (d *Core) GetRandomHosts(nHosts int) ([]string, error) {
-	return getRandomHosts(nHosts, d.Scale)
+	switch d.HostDistribution {
+	case "", HostsUniform:
+		return getRandomHosts(nHosts, d.Scale)
+	}
+	return getSkewedRandomHosts(nHosts, d.Scale, d.pickHost)
+}
+
+// pickHost returns the number of a host picked with the skewed
+// HostDistribution of d
+func (d *Core) pickHost() int {
+	if d.HostDistribution == HostsZipfian {
+		if d.zipf == nil {
+			// seeded from the global source on first use, so the hosts
+			// picked only depend on the seed of query generation
+			r := rand.New(rand.NewSource(rand.Int63()))
+			d.zipf = rand.NewZipf(r, d.HostSkew, 1, uint64(d.Scale-1))
+		}
+		return int(d.zipf.Uint64())
+	}
+
+	if rand.Float64() < HotsetProbability {
+		hot := int(math.Ceil(float64(d.Scale) * d.HostHotset / 100))
+		return rand.Intn(hot)
+	}
+	return rand.Intn(d.Scale)
 }
 
 // cpuMetrics is the list of metric names for CPU
@@ -98,6 +299,47 @@ func GetCPUMetricsLen() int {
 	return len(cpuMetrics)
 }
 
+// TagFilter restricts a query to the hosts whose tag Key has the value Value
+type TagFilter struct {
+	Key   string
+	Value string
+}
+
+// tagValues are the values the devops data generator picks from for the host
+// tags other than hostname; they have to be kept in sync with it
+var tagValues = map[string][]string{
+	"region":              {"us-east-1", "us-west-1", "us-west-2", "eu-west-1", "eu-central-1", "ap-southeast-1", "ap-southeast-2", "ap-northeast-1", "sa-east-1"},
+	"datacenter":          {"us-east-1a", "us-east-1b", "us-east-1c", "us-east-1e", "us-west-1a", "us-west-1b", "us-west-2a", "us-west-2b", "us-west-2c", "eu-west-1a", "eu-west-1b", "eu-west-1c", "eu-central-1a", "eu-central-1b", "ap-southeast-1a", "ap-southeast-1b", "ap-southeast-2a", "ap-southeast-2b", "ap-northeast-1a", "ap-northeast-1c", "sa-east-1a", "sa-east-1b", "sa-east-1c"},
+	"os":                  {"Ubuntu16.10", "Ubuntu16.04LTS", "Ubuntu15.10"},
+	"arch":                {"x64", "x86"},
+	"team":                {"SF", "NYC", "LON", "CHI"},
+	"service":             {"0", "1", "2", "3", "4", "5", "6", "7", "8", "9", "10", "11", "12", "13", "14", "15", "16", "17", "18", "19"},
+	"service_version":     {"0", "1"},
+	"service_environment": {"production", "staging", "test"},
+}
+
+// GetRandomTagFilters returns a TagFilter with a random value for each of the
+// given host tags
+func (d *Core) GetRandomTagFilters(tags []string) ([]TagFilter, error) {
+	filters := make([]TagFilter, len(tags))
+	for i, tag := range tags {
+		values, ok := tagValues[tag]
+		if !ok {
+			return nil, fmt.Errorf(errUnknownTagFmt, tag)
+		}
+		filters[i] = TagFilter{Key: tag, Value: values[rand.Intn(len(values))]}
+	}
+	return filters, nil
+}
+
+// cpuPercentiles is the list of percentiles computed by CPUPercentiles queries
+var cpuPercentiles = []int{50, 90, 99}
+
+// GetCPUPercentiles returns the percentiles computed by CPUPercentiles queries
+func GetCPUPercentiles() []int {
+	return cpuPercentiles
+}
+
 // SingleGroupbyFiller is a type that can fill in a single groupby query
 type SingleGroupbyFiller interface {
 	GroupByTime(query.Query, int, int, time.Duration)
@@ -128,9 +370,54 @@ type HighCPUFiller interface {
 	HighCPUForHosts(query.Query, int)
 }
 
+// RateFiller is a type that can fill in a rate query over a counter
+type RateFiller interface {
+	CounterRate(qi query.Query, measurement, counter string, nHosts int)
+}
+
+// PercentilesFiller is a type that can fill in a cpu-percentiles query
+type PercentilesFiller interface {
+	CPUPercentiles(query.Query, int)
+}
+
+// TopKFiller is a type that can fill in a top-k hosts query
+type TopKFiller interface {
+	TopKHosts(query.Query, int)
+}
+
+// CPUMemJoinFiller is a type that can fill in a query joining the cpu and mem measurements
+type CPUMemJoinFiller interface {
+	CPUMemJoin(query.Query, int)
+}
+
+// DownsampleFiller is a type that can fill in a downsampling query over the whole dataset
+type DownsampleFiller interface {
+	DownsampleAll(query.Query, int)
+}
+
+// DiskFullFiller is a type that can fill in a disk-full query
+type DiskFullFiller interface {
+	DiskFull(query.Query, int)
+}
+
+// RedisHitRatioFiller is a type that can fill in a redis keyspace hit ratio query
+type RedisHitRatioFiller interface {
+	RedisHitRatio(query.Query, int)
+}
+
+// NginxRateFiller is a type that can fill in an nginx requests per service query
+type NginxRateFiller interface {
+	NginxRequestRate(query.Query)
+}
+
+// TagGroupByFiller is a type that can fill in a query filtering and grouping on host tags
+type TagGroupByFiller interface {
+	GroupByTag(qi query.Query, groupTag string, filterTags []string)
+}
+
 // GetDoubleGroupByLabel returns the Query human-readable label for DoubleGroupBy queries
-func GetDoubleGroupByLabel(dbName string, numMetrics int) string {
-	return fmt.Sprintf("%s mean of %d metrics, all hosts, random %s by 1h", dbName, numMetrics, DoubleGroupByDuration)
+func (d *Core) GetDoubleGroupByLabel(dbName string, numMetrics int) string {
+	return fmt.Sprintf("%s mean of %d metrics, all hosts, %s by 1h", dbName, numMetrics, d.WindowLabel(DoubleGroupByDuration))
 }
 
 // GetHighCPULabel returns the Query human-readable label for HighCPU queries
@@ -147,14 +434,73 @@ func GetHighCPULabel(dbName string, nHosts int) (string, error) {
 }
 
 // GetMaxAllLabel returns the Query human-readable label for MaxAllCPU queries
-func GetMaxAllLabel(dbName string, nHosts int) string {
-	return fmt.Sprintf("%s max of all CPU metrics, random %4d hosts, random %s by 1h", dbName, nHosts, MaxAllDuration)
+func (d *Core) GetMaxAllLabel(dbName string, nHosts int) string {
+	return fmt.Sprintf("%s max of all CPU metrics, random %4d hosts, %s by 1h", dbName, nHosts, d.WindowLabel(MaxAllDuration))
+}
+
+// GetRateLabel returns the Query human-readable label for CounterRate queries
+func (d *Core) GetRateLabel(dbName, measurement, counter string, nHosts int) string {
+	return fmt.Sprintf("%s rate of %s %s, random %4d hosts, %s by 1m", dbName, measurement, counter, nHosts, d.WindowLabel(RateDuration))
+}
+
+// GetPercentilesLabel returns the Query human-readable label for CPUPercentiles queries
+func (d *Core) GetPercentilesLabel(dbName string, nHosts int) string {
+	return fmt.Sprintf("%s percentiles of usage_user, random %4d hosts, %s by 1h", dbName, nHosts, d.WindowLabel(PercentilesDuration))
+}
+
+// GetTopKLabel returns the Query human-readable label for TopKHosts queries
+func (d *Core) GetTopKLabel(dbName string, k int) string {
+	return fmt.Sprintf("%s top %d hosts by mean usage_user, %s", dbName, k, d.WindowLabel(TopKDuration))
+}
+
+// GetCPUMemJoinLabel returns the Query human-readable label for CPUMemJoin queries
+func (d *Core) GetCPUMemJoinLabel(dbName string, nHosts int) string {
+	return fmt.Sprintf("%s cpu joined with mem, random %4d hosts, %s by 1m", dbName, nHosts, d.WindowLabel(CPUMemJoinDuration))
+}
+
+// GetDownsampleLabel returns the Query human-readable label for DownsampleAll queries
+func GetDownsampleLabel(dbName string, numMetrics int) string {
+	return fmt.Sprintf("%s mean of %d metrics, all hosts, all data by 1h", dbName, numMetrics)
+}
+
+// GetDiskFullLabel returns the Query human-readable label for DiskFull queries
+func (d *Core) GetDiskFullLabel(dbName string, nHosts int) (string, error) {
+	label := dbName + " disk over threshold, "
+	if nHosts > 0 {
+		label += fmt.Sprintf("%d host(s)", nHosts)
+	} else if nHosts == 0 {
+		label += allHosts
+	} else {
+		return "", fmt.Errorf(errNHostsCannotNegative)
+	}
+	return label + ", " + d.WindowLabel(DiskFullDuration), nil
+}
+
+// GetRedisHitRatioLabel returns the Query human-readable label for RedisHitRatio queries
+func (d *Core) GetRedisHitRatioLabel(dbName string, nHosts int) string {
+	return fmt.Sprintf("%s redis keyspace hit ratio, random %4d hosts, %s by 1h", dbName, nHosts, d.WindowLabel(RedisHitRatioDuration))
+}
+
+// GetNginxRateLabel returns the Query human-readable label for NginxRequestRate queries
+func (d *Core) GetNginxRateLabel(dbName string) string {
+	return fmt.Sprintf("%s nginx requests per second per service, all hosts, %s by 1m", dbName, d.WindowLabel(NginxRateDuration))
+}
+
+// GetTagGroupByLabel returns the Query human-readable label for GroupByTag queries
+func (d *Core) GetTagGroupByLabel(dbName, groupTag string, filterTags []string) string {
+	return fmt.Sprintf("%s mean usage_user per %s, random %s, %s by 1h", dbName, groupTag, strings.Join(filterTags, " and "), d.WindowLabel(TagGroupByDuration))
 }
 
 // getRandomHosts returns a subset of numHosts hostnames of a permutation of hostnames,
 // numbered from 0 to totalHosts.
 // Ex.: host_12, host_7, host_25 for numHosts=3 and totalHosts=30 (3 out of 30)
 func getRandomHosts(numHosts int, totalHosts int) ([]string, error) {
+	return getSkewedRandomHosts(numHosts, totalHosts, nil)
+}
+
+// getSkewedRandomHosts is like getRandomHosts, but picks hosts with pick
+// instead of uniformly at random unless pick is nil
+func getSkewedRandomHosts(numHosts, totalHosts int, pick func() int) ([]string, error) {
 	if numHosts < 1 {
 		return nil, fmt.Errorf("number of hosts cannot be < 1; got %d", numHosts)
 	}
@@ -162,7 +508,13 @@ func getRandomHosts(numHosts int, totalHosts int) ([]string, error) {
 		return nil, fmt.Errorf("number of hosts (%d) larger than total hosts. See --scale (%d)", numHosts, totalHosts)
 	}
 
-	randomNumbers, err := getRandomSubsetPerm(numHosts, totalHosts)
+	var randomNumbers []int
+	var err error
+	if pick == nil {
+		randomNumbers, err = getRandomSubsetPerm(numHosts, totalHosts)
+	} else {
+		randomNumbers, err = getSkewedSubsetPerm(numHosts, totalHosts, pick)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -202,6 +554,32 @@ func getRandomSubsetPerm(numItems int, totalItems int) ([]int, error) {
 	return res, nil
 }
 
+// getSkewedSubsetPerm is like getRandomSubsetPerm, but picks items with pick.
+// Since a skewed pick likely returns an item that was already picked, it
+// falls back to uniformly random picks after maxSkewedPicks tries.
+func getSkewedSubsetPerm(numItems int, totalItems int, pick func() int) ([]int, error) {
+	if numItems > totalItems {
+		// Cannot make a subset longer than the original set
+		return nil, fmt.Errorf(errMoreItemsThanScale)
+	}
+
+	seen := map[int]bool{}
+	res := []int{}
+	for i := 0; i < numItems; i++ {
+		n := pick()
+		for tries := 1; seen[n]; tries++ {
+			if tries < maxSkewedPicks {
+				n = pick()
+			} else {
+				n = rand.Intn(totalItems)
+			}
+		}
+		seen[n] = true
+		res = append(res, n)
+	}
+	return res, nil
+}
+
 func panicUnimplementedQuery(dg utils.QueryGenerator) {
 	panic(fmt.Sprintf("database (%v) does not implement query", reflect.TypeOf(dg)))
 }
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	datagen "github.com/timescale/tsbs/cmd/tsbs_generate_data/devops"
 	"github.com/timescale/tsbs/internal/utils"
 )
 
@@ -69,6 +70,261 @@ func TestCoreGetRandomHosts(t *testing.T) {
 	}
 }
 
+func TestCoreConfigureWindows(t *testing.T) {
+	c, err := NewCore(time.Now(), time.Now(), 10)
+	if err != nil {
+		t.Fatalf("unexpected error for NewCore: %v", err)
+	}
+	if err := c.ConfigureWindows(5*time.Minute, 10*time.Second, utils.WindowRecent); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if c.Window != 5*time.Minute || c.Bucket != 10*time.Second || c.Placement != utils.WindowRecent {
+		t.Errorf("windows not configured: got %v, %v, %s", c.Window, c.Bucket, c.Placement)
+	}
+
+	err = c.ConfigureWindows(0, 0, "foo")
+	want := fmt.Sprintf(utils.ErrUnknownPlacementFmt, "foo", strings.Join(utils.WindowPlacements, ", "))
+	if err == nil || err.Error() != want {
+		t.Errorf("incorrect error for unknown placement: got %v want %s", err, want)
+	}
+}
+
+func TestCoreMustQueryInterval(t *testing.T) {
+	s := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	e := s.Add(24 * time.Hour)
+	cases := []struct {
+		desc      string
+		window    time.Duration
+		placement string
+		wantLen   time.Duration
+		wantEnd   bool
+	}{
+		{desc: "defaults", wantLen: time.Hour},
+		{desc: "random override", window: 5 * time.Minute, placement: utils.WindowRandom, wantLen: 5 * time.Minute},
+		{desc: "recent default", placement: utils.WindowRecent, wantLen: time.Hour, wantEnd: true},
+		{desc: "recent override", window: 24 * time.Hour, placement: utils.WindowRecent, wantLen: 24 * time.Hour, wantEnd: true},
+		{desc: "exponential", placement: utils.WindowExponential, wantLen: time.Hour},
+		{desc: "hot-cold override", window: 5 * time.Minute, placement: utils.WindowHotCold, wantLen: 5 * time.Minute},
+	}
+	for _, c := range cases {
+		core, err := NewCore(s, e, 10)
+		if err != nil {
+			t.Fatalf("unexpected error for NewCore: %v", err)
+		}
+		if err := core.ConfigureWindows(c.window, 0, c.placement); err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+		interval := core.MustQueryInterval(time.Hour)
+		if got := interval.Duration(); got != c.wantLen {
+			t.Errorf("%s: incorrect duration: got %v want %v", c.desc, got, c.wantLen)
+		}
+		if interval.Start().Before(s) || interval.End().After(e) {
+			t.Errorf("%s: interval outside of dataset: %s - %s", c.desc, interval.StartString(), interval.EndString())
+		}
+		if c.wantEnd && interval.End() != e {
+			t.Errorf("%s: recent interval does not end at dataset end: got %s", c.desc, interval.EndString())
+		}
+	}
+}
+
+func TestCoreMustQueryIntervalRecentTooLarge(t *testing.T) {
+	s := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	core, err := NewCore(s, s.Add(time.Hour), 10)
+	if err != nil {
+		t.Fatalf("unexpected error for NewCore: %v", err)
+	}
+	core.Placement = utils.WindowRecent
+	defer func() {
+		want := fmt.Sprintf(utils.ErrRecentWindowTooLargeFmt, 2*time.Hour, time.Hour)
+		if r := recover(); r != want {
+			t.Errorf("incorrect panic: got %v want %s", r, want)
+		}
+	}()
+	core.MustQueryInterval(2 * time.Hour)
+}
+
+func TestCoreWindowLabel(t *testing.T) {
+	c := &Core{}
+	if got, want := c.WindowLabel(12*time.Hour), "random 12h0m0s"; got != want {
+		t.Errorf("incorrect default label: got %s want %s", got, want)
+	}
+	c.Window = 5 * time.Minute
+	c.Placement = utils.WindowRecent
+	if got, want := c.WindowLabel(12*time.Hour), "recent 5m0s"; got != want {
+		t.Errorf("incorrect configured label: got %s want %s", got, want)
+	}
+}
+
+func TestCoreGetSingleGroupByBucket(t *testing.T) {
+	c := &Core{}
+	if got := c.GetSingleGroupByBucket(); got != SingleGroupByBucket {
+		t.Errorf("incorrect default bucket: got %v want %v", got, SingleGroupByBucket)
+	}
+	c.Bucket = 10 * time.Second
+	if got := c.GetSingleGroupByBucket(); got != 10*time.Second {
+		t.Errorf("incorrect configured bucket: got %v want %v", got, 10*time.Second)
+	}
+}
+
+func TestShortDuration(t *testing.T) {
+	cases := map[time.Duration]string{
+		time.Minute:                   "1m",
+		5 * time.Minute:               "5m",
+		time.Hour:                     "1h",
+		90 * time.Minute:              "1h30m",
+		30 * time.Second:              "30s",
+		time.Minute + 30*time.Second:  "1m30s",
+		24*time.Hour + 10*time.Second: "24h0m10s",
+	}
+	for d, want := range cases {
+		if got := ShortDuration(d); got != want {
+			t.Errorf("incorrect short duration for %v: got %s want %s", d, got, want)
+		}
+	}
+}
+
+func TestCoreConfigureHosts(t *testing.T) {
+	cases := []struct {
+		desc         string
+		distribution string
+		skew         float64
+		hotset       float64
+		wantErr      string
+	}{
+		{desc: "default", distribution: ""},
+		{desc: "uniform", distribution: HostsUniform},
+		{desc: "zipfian", distribution: HostsZipfian, skew: 1.5},
+		{desc: "hotset", distribution: HostsHotset, hotset: 10},
+		{desc: "unknown", distribution: "foo", wantErr: fmt.Sprintf(errUnknownHostDistFmt, "foo", "uniform, zipfian, hotset")},
+		{desc: "zipfian skew too small", distribution: HostsZipfian, skew: 1, wantErr: fmt.Sprintf(errBadHostSkewFmt, 1.0)},
+		{desc: "hotset too small", distribution: HostsHotset, hotset: 0, wantErr: fmt.Sprintf(errBadHostHotsetFmt, 0.0)},
+		{desc: "hotset too large", distribution: HostsHotset, hotset: 101, wantErr: fmt.Sprintf(errBadHostHotsetFmt, 101.0)},
+	}
+	for _, c := range cases {
+		core := &Core{Scale: 10}
+		err := core.ConfigureHosts(c.distribution, c.skew, c.hotset)
+		if c.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", c.desc, err)
+			} else if core.HostDistribution != c.distribution {
+				t.Errorf("%s: incorrect distribution: got %s want %s", c.desc, core.HostDistribution, c.distribution)
+			}
+		} else if err == nil {
+			t.Errorf("%s: unexpected lack of error", c.desc)
+		} else if got := err.Error(); got != c.wantErr {
+			t.Errorf("%s: incorrect error: got %s want %s", c.desc, got, c.wantErr)
+		}
+	}
+}
+
+func TestCoreGetRandomHostsSkewed(t *testing.T) {
+	const scale = 100
+	cases := []struct {
+		desc         string
+		distribution string
+		skew         float64
+		hotset       float64
+		wantHot      int // minimum number of picks in the 10 first hosts
+	}{
+		{desc: "zipfian", distribution: HostsZipfian, skew: 1.5, wantHot: 700},
+		{desc: "hotset", distribution: HostsHotset, hotset: 10, wantHot: 850},
+	}
+	for _, c := range cases {
+		core, err := NewCore(time.Now(), time.Now(), scale)
+		if err != nil {
+			t.Fatalf("unexpected error for NewCore: %v", err)
+		}
+		if err := core.ConfigureHosts(c.distribution, c.skew, c.hotset); err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+
+		rand.Seed(123) // Setting seed for testing purposes.
+		hot := 0
+		for i := 0; i < 1000; i++ {
+			hosts, err := core.GetRandomHosts(1)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: %v", c.desc, err)
+			}
+			var n int
+			if _, err := fmt.Sscanf(hosts[0], "host_%d", &n); err != nil {
+				t.Fatalf("%s: unexpected hostname %s", c.desc, hosts[0])
+			}
+			if n < 10 {
+				hot++
+			}
+		}
+		if hot < c.wantHot {
+			t.Errorf("%s: too few picks of hot hosts: got %d want at least %d", c.desc, hot, c.wantHot)
+		}
+
+		// all hosts can still be picked without duplicates
+		hosts, err := core.GetRandomHosts(scale)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", c.desc, err)
+		}
+		seen := map[string]bool{}
+		for _, h := range hosts {
+			if seen[h] {
+				t.Errorf("%s: duplicate host %s", c.desc, h)
+			}
+			seen[h] = true
+		}
+		if len(seen) != scale {
+			t.Errorf("%s: incorrect number of hosts: got %d want %d", c.desc, len(seen), scale)
+		}
+	}
+}
+
+func TestCoreGetRandomTagFilters(t *testing.T) {
+	c, err := NewCore(time.Now(), time.Now(), 10)
+	if err != nil {
+		t.Fatalf("unexpected error for NewCore: %v", err)
+	}
+
+	tags := []string{"service", "service_environment"}
+	filters, err := c.GetRandomTagFilters(tags)
+	if err != nil {
+		t.Fatalf("unexpected error for GetRandomTagFilters: %v", err)
+	}
+	if got := len(filters); got != len(tags) {
+		t.Fatalf("incorrect number of filters: got %d want %d", got, len(tags))
+	}
+	for i, f := range filters {
+		if f.Key != tags[i] {
+			t.Errorf("incorrect filter key: got %s want %s", f.Key, tags[i])
+		}
+		found := false
+		for _, v := range tagValues[f.Key] {
+			found = found || v == f.Value
+		}
+		if !found {
+			t.Errorf("unknown value for tag %s: %s", f.Key, f.Value)
+		}
+	}
+
+	if _, err := c.GetRandomTagFilters([]string{"hostname"}); err == nil {
+		t.Errorf("expected error for tag without known values")
+	}
+}
+
+func TestTagValuesMatchDataGenerator(t *testing.T) {
+	cases := map[string][][]byte{
+		"os":                  datagen.MachineOSChoices,
+		"arch":                datagen.MachineArchChoices,
+		"team":                datagen.MachineTeamChoices,
+		"service_environment": datagen.MachineServiceEnvironmentChoices,
+	}
+	for tag, choices := range cases {
+		want := make([]string, len(choices))
+		for i, c := range choices {
+			want[i] = string(c)
+		}
+		if got := strings.Join(tagValues[tag], ","); got != strings.Join(want, ",") {
+			t.Errorf("incorrect values for tag %s: got %s want %s", tag, got, strings.Join(want, ","))
+		}
+	}
+}
+
 func TestGetCPUMetricsSlice(t *testing.T) {
 	cases := []struct {
 		desc      string
@@ -215,8 +471,9 @@ func TestGetRandomHosts(t *testing.T) {
 }
 
 func TestGetDoubleGroupByLabel(t *testing.T) {
+	d := &Core{}
 	want := fmt.Sprintf("Foo mean of 10 metrics, all hosts, random %s by 1h", DoubleGroupByDuration)
-	got := GetDoubleGroupByLabel("Foo", 10)
+	got := d.GetDoubleGroupByLabel("Foo", 10)
 	if got != want {
 		t.Errorf("incorrect output:\ngot\n%s\nwant\n%s", got, want)
 	}
@@ -263,13 +520,123 @@ func TestGetHighCPULabel(t *testing.T) {
 }
 
 func TestGetMaxAllLabel(t *testing.T) {
+	d := &Core{}
 	want := fmt.Sprintf("Foo max of all CPU metrics, random  100 hosts, random %s by 1h", MaxAllDuration)
-	got := GetMaxAllLabel("Foo", 100)
+	got := d.GetMaxAllLabel("Foo", 100)
+	if got != want {
+		t.Errorf("incorrect output: got %s want %s", got, want)
+	}
+}
+
+func TestGetRateLabel(t *testing.T) {
+	d := &Core{}
+	want := fmt.Sprintf("Foo rate of net bytes_recv, random    8 hosts, random %s by 1m", RateDuration)
+	got := d.GetRateLabel("Foo", "net", "bytes_recv", 8)
+	if got != want {
+		t.Errorf("incorrect output: got %s want %s", got, want)
+	}
+}
+
+func TestGetPercentilesLabel(t *testing.T) {
+	d := &Core{}
+	want := fmt.Sprintf("Foo percentiles of usage_user, random    1 hosts, random %s by 1h", PercentilesDuration)
+	got := d.GetPercentilesLabel("Foo", 1)
+	if got != want {
+		t.Errorf("incorrect output: got %s want %s", got, want)
+	}
+}
+
+func TestGetTopKLabel(t *testing.T) {
+	d := &Core{}
+	want := fmt.Sprintf("Foo top 5 hosts by mean usage_user, random %s", TopKDuration)
+	got := d.GetTopKLabel("Foo", 5)
+	if got != want {
+		t.Errorf("incorrect output: got %s want %s", got, want)
+	}
+}
+
+func TestGetCPUMemJoinLabel(t *testing.T) {
+	d := &Core{}
+	want := fmt.Sprintf("Foo cpu joined with mem, random    8 hosts, random %s by 1m", CPUMemJoinDuration)
+	got := d.GetCPUMemJoinLabel("Foo", 8)
 	if got != want {
 		t.Errorf("incorrect output: got %s want %s", got, want)
 	}
 }
 
+func TestGetDownsampleLabel(t *testing.T) {
+	want := "Foo mean of 10 metrics, all hosts, all data by 1h"
+	got := GetDownsampleLabel("Foo", 10)
+	if got != want {
+		t.Errorf("incorrect output: got %s want %s", got, want)
+	}
+}
+
+func TestGetDiskFullLabel(t *testing.T) {
+	d := &Core{}
+	cases := []struct {
+		desc      string
+		nHosts    int
+		want      string
+		shouldErr bool
+	}{
+		{
+			desc:      "nHosts < 0",
+			nHosts:    -1,
+			shouldErr: true,
+		},
+		{
+			desc:   "nHosts = 0",
+			nHosts: 0,
+			want:   fmt.Sprintf("Foo disk over threshold, %s, random %s", allHosts, DiskFullDuration),
+		},
+		{
+			desc:   "nHosts > 0",
+			nHosts: 1,
+			want:   fmt.Sprintf("Foo disk over threshold, %d host(s), random %s", 1, DiskFullDuration),
+		},
+	}
+	for _, c := range cases {
+		if c.shouldErr {
+			_, err := d.GetDiskFullLabel("Foo", c.nHosts)
+			if got := err.Error(); got != errNHostsCannotNegative {
+				t.Errorf("%s: incorrect error: got %s want %s", c.desc, got, errNHostsCannotNegative)
+			}
+		} else {
+			got, err := d.GetDiskFullLabel("Foo", c.nHosts)
+			if err != nil {
+				t.Fatalf("%s: unexpected error: got %v", c.desc, err)
+			} else if got != c.want {
+				t.Errorf("%s: incorrect output:\ngot\n%s\nwant\n%s", c.desc, got, c.want)
+			}
+		}
+	}
+}
+
+func TestGetRedisHitRatioLabel(t *testing.T) {
+	d := &Core{}
+	want := "Foo redis keyspace hit ratio, random    8 hosts, random 12h0m0s by 1h"
+	if got := d.GetRedisHitRatioLabel("Foo", 8); got != want {
+		t.Errorf("incorrect output: got %s want %s", got, want)
+	}
+}
+
+func TestGetNginxRateLabel(t *testing.T) {
+	d := &Core{}
+	want := "Foo nginx requests per second per service, all hosts, random 1h0m0s by 1m"
+	if got := d.GetNginxRateLabel("Foo"); got != want {
+		t.Errorf("incorrect output: got %s want %s", got, want)
+	}
+}
+
+func TestGetTagGroupByLabel(t *testing.T) {
+	d := &Core{}
+	want := "Foo mean usage_user per datacenter, random service and service_environment, random 12h0m0s by 1h"
+	if got := d.GetTagGroupByLabel("Foo", "datacenter", []string{"service", "service_environment"}); got != want {
+		t.Errorf("incorrect output: got %s want %s", got, want)
+	}
+}
+
 func TestGetRandomSubsetPerm(t *testing.T) {
 	cases := []struct {
 		scale  int
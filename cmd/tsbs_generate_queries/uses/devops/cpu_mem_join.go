@@ -0,0 +1,33 @@
+package devops
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/query"
+)
+
+// CPUMemJoin contains info for filling in a query.Query that joins the cpu
+// and mem measurements of the same hosts
+type CPUMemJoin struct {
+	core  utils.QueryGenerator
+	hosts int
+}
+
+// NewCPUMemJoin produces a new function that produces a new CPUMemJoin
+func NewCPUMemJoin(hosts int) utils.QueryFillerMaker {
+	return func(core utils.QueryGenerator) utils.QueryFiller {
+		return &CPUMemJoin{
+			core:  core,
+			hosts: hosts,
+		}
+	}
+}
+
+// Fill fills in the query.Query with query details
+func (d *CPUMemJoin) Fill(q query.Query) query.Query {
+	fc, ok := d.core.(CPUMemJoinFiller)
+	if !ok {
+		panicUnimplementedQuery(d.core)
+	}
+	fc.CPUMemJoin(q, d.hosts)
+	return q
+}
@@ -0,0 +1,33 @@
+package devops
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/query"
+)
+
+// DiskFull contains info for filling in a query.Query for disks whose
+// used_percent exceeds DiskFullThreshold
+type DiskFull struct {
+	core  utils.QueryGenerator
+	hosts int
+}
+
+// NewDiskFull produces a new function that produces a new DiskFull
+func NewDiskFull(hosts int) utils.QueryFillerMaker {
+	return func(core utils.QueryGenerator) utils.QueryFiller {
+		return &DiskFull{
+			core:  core,
+			hosts: hosts,
+		}
+	}
+}
+
+// Fill fills in the query.Query with query details
+func (d *DiskFull) Fill(q query.Query) query.Query {
+	fc, ok := d.core.(DiskFullFiller)
+	if !ok {
+		panicUnimplementedQuery(d.core)
+	}
+	fc.DiskFull(q, d.hosts)
+	return q
+}
@@ -0,0 +1,33 @@
+package devops
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/query"
+)
+
+// DownsampleAll contains info for filling in a query.Query that downsamples
+// CPU metrics of all hosts over the whole time range of the dataset
+type DownsampleAll struct {
+	core    utils.QueryGenerator
+	metrics int
+}
+
+// NewDownsampleAll produces a new function that produces a new DownsampleAll
+func NewDownsampleAll(numMetrics int) utils.QueryFillerMaker {
+	return func(core utils.QueryGenerator) utils.QueryFiller {
+		return &DownsampleAll{
+			core:    core,
+			metrics: numMetrics,
+		}
+	}
+}
+
+// Fill fills in the query.Query with query details
+func (d *DownsampleAll) Fill(q query.Query) query.Query {
+	fc, ok := d.core.(DownsampleFiller)
+	if !ok {
+		panicUnimplementedQuery(d.core)
+	}
+	fc.DownsampleAll(q, d.metrics)
+	return q
+}
@@ -0,0 +1,27 @@
+package devops
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/query"
+)
+
+// NginxRequestRate contains info for filling in a query.Query for the nginx
+// requests per second of each service
+type NginxRequestRate struct {
+	core utils.QueryGenerator
+}
+
+// NewNginxRequestRate returns a new NginxRequestRate for given parameters
+func NewNginxRequestRate(core utils.QueryGenerator) utils.QueryFiller {
+	return &NginxRequestRate{core}
+}
+
+// Fill fills in the query.Query with query details
+func (d *NginxRequestRate) Fill(q query.Query) query.Query {
+	fc, ok := d.core.(NginxRateFiller)
+	if !ok {
+		panicUnimplementedQuery(d.core)
+	}
+	fc.NginxRequestRate(q)
+	return q
+}
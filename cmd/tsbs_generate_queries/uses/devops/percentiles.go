@@ -0,0 +1,33 @@
+package devops
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/query"
+)
+
+// CPUPercentiles contains info for filling in a query.Query for percentiles
+// of CPU usage
+type CPUPercentiles struct {
+	core  utils.QueryGenerator
+	hosts int
+}
+
+// NewCPUPercentiles produces a new function that produces a new CPUPercentiles
+func NewCPUPercentiles(hosts int) utils.QueryFillerMaker {
+	return func(core utils.QueryGenerator) utils.QueryFiller {
+		return &CPUPercentiles{
+			core:  core,
+			hosts: hosts,
+		}
+	}
+}
+
+// Fill fills in the query.Query with query details
+func (d *CPUPercentiles) Fill(q query.Query) query.Query {
+	fc, ok := d.core.(PercentilesFiller)
+	if !ok {
+		panicUnimplementedQuery(d.core)
+	}
+	fc.CPUPercentiles(q, d.hosts)
+	return q
+}
@@ -0,0 +1,37 @@
+package devops
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/query"
+)
+
+// CounterRate contains info for filling in a query.Query for the per-second
+// rate of a monotonically increasing counter, e.g. the bytes received by net
+type CounterRate struct {
+	core        utils.QueryGenerator
+	measurement string
+	counter     string
+	hosts       int
+}
+
+// NewCounterRate produces a new function that produces a new CounterRate
+func NewCounterRate(measurement, counter string, hosts int) utils.QueryFillerMaker {
+	return func(core utils.QueryGenerator) utils.QueryFiller {
+		return &CounterRate{
+			core:        core,
+			measurement: measurement,
+			counter:     counter,
+			hosts:       hosts,
+		}
+	}
+}
+
+// Fill fills in the query.Query with query details
+func (d *CounterRate) Fill(q query.Query) query.Query {
+	fc, ok := d.core.(RateFiller)
+	if !ok {
+		panicUnimplementedQuery(d.core)
+	}
+	fc.CounterRate(q, d.measurement, d.counter, d.hosts)
+	return q
+}
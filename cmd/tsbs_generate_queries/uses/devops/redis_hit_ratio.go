@@ -0,0 +1,33 @@
+package devops
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/query"
+)
+
+// RedisHitRatio contains info for filling in a query.Query for the share of
+// redis keyspace lookups that were hits
+type RedisHitRatio struct {
+	core  utils.QueryGenerator
+	hosts int
+}
+
+// NewRedisHitRatio produces a new function that produces a new RedisHitRatio
+func NewRedisHitRatio(hosts int) utils.QueryFillerMaker {
+	return func(core utils.QueryGenerator) utils.QueryFiller {
+		return &RedisHitRatio{
+			core:  core,
+			hosts: hosts,
+		}
+	}
+}
+
+// Fill fills in the query.Query with query details
+func (d *RedisHitRatio) Fill(q query.Query) query.Query {
+	fc, ok := d.core.(RedisHitRatioFiller)
+	if !ok {
+		panicUnimplementedQuery(d.core)
+	}
+	fc.RedisHitRatio(q, d.hosts)
+	return q
+}
@@ -0,0 +1,36 @@
+package devops
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/query"
+)
+
+// TagGroupBy contains info for filling in a query.Query for the mean CPU usage
+// of the hosts with random values of some tags, grouped by another tag, e.g.
+// the mean CPU usage per datacenter of a service in an environment
+type TagGroupBy struct {
+	core       utils.QueryGenerator
+	groupTag   string
+	filterTags []string
+}
+
+// NewTagGroupBy produces a new function that produces a new TagGroupBy
+func NewTagGroupBy(groupTag string, filterTags ...string) utils.QueryFillerMaker {
+	return func(core utils.QueryGenerator) utils.QueryFiller {
+		return &TagGroupBy{
+			core:       core,
+			groupTag:   groupTag,
+			filterTags: filterTags,
+		}
+	}
+}
+
+// Fill fills in the query.Query with query details
+func (d *TagGroupBy) Fill(q query.Query) query.Query {
+	fc, ok := d.core.(TagGroupByFiller)
+	if !ok {
+		panicUnimplementedQuery(d.core)
+	}
+	fc.GroupByTag(q, d.groupTag, d.filterTags)
+	return q
+}
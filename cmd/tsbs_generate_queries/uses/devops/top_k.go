@@ -0,0 +1,33 @@
+package devops
+
+import (
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	"github.com/timescale/tsbs/query"
+)
+
+// TopKHosts contains info for filling in a query.Query for the k hosts with
+// the highest CPU usage
+type TopKHosts struct {
+	core utils.QueryGenerator
+	k    int
+}
+
+// NewTopKHosts produces a new function that produces a new TopKHosts
+func NewTopKHosts(k int) utils.QueryFillerMaker {
+	return func(core utils.QueryGenerator) utils.QueryFiller {
+		return &TopKHosts{
+			core: core,
+			k:    k,
+		}
+	}
+}
+
+// Fill fills in the query.Query with query details
+func (d *TopKHosts) Fill(q query.Query) query.Query {
+	fc, ok := d.core.(TopKFiller)
+	if !ok {
+		panicUnimplementedQuery(d.core)
+	}
+	fc.TopKHosts(q, d.k)
+	return q
+}
@@ -52,19 +52,43 @@ func (d *dbCreator) CreateDB(dbName string) error {
 		return err
 	}
 	for _, cassandraTypename := range []string{"bigint", "float", "double", "boolean", "blob"} {
-		q := fmt.Sprintf(`CREATE TABLE %s.series_%s (
+		q := getCreateTableCQL(dbName, cassandraTypename)
+		if err := d.globalSession.Query(q).Exec(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getCreateTableCQL returns the statement creating the table of the values of
+// the given type, with the compaction strategy and default TTL from the flags
+func getCreateTableCQL(dbName, cassandraTypename string) string {
+	return fmt.Sprintf(`CREATE TABLE %s.series_%s (
 					series_id text,
 					timestamp_ns bigint,
 					value %s,
 					PRIMARY KEY (series_id, timestamp_ns)
 				 )
-				 WITH COMPACT STORAGE;`,
-			dbName, cassandraTypename, cassandraTypename)
-		if err := d.globalSession.Query(q).Exec(); err != nil {
-			return err
-		}
+				 WITH COMPACT STORAGE
+				 AND compaction = %s
+				 AND default_time_to_live = %d;`,
+		dbName, cassandraTypename, cassandraTypename, compactionOptions(), int64(ttl.Seconds()))
+}
+
+// compactionOptions returns the compaction options of the tables. Time window
+// compaction uses the largest unit that evenly divides the window.
+func compactionOptions() string {
+	class := compactionMapping[compaction]
+	if compaction != "TWCS" {
+		return fmt.Sprintf("{ 'class': '%s' }", class)
 	}
-	return nil
+	unit, size := "MINUTES", int64(compactionWindow/time.Minute)
+	if compactionWindow%(24*time.Hour) == 0 {
+		unit, size = "DAYS", int64(compactionWindow/(24*time.Hour))
+	} else if compactionWindow%time.Hour == 0 {
+		unit, size = "HOURS", int64(compactionWindow/time.Hour)
+	}
+	return fmt.Sprintf("{ 'class': '%s', 'compaction_window_unit': '%s', 'compaction_window_size': %d }", class, unit, size)
 }
 
 func (d *dbCreator) PostCreateDB(dbName string) error {
@@ -81,6 +105,31 @@ func (d *dbCreator) PostCreateDB(dbName string) error {
 	return nil
 }
 
+// DBSize returns the size of the tables of the keyspace estimated by the
+// connected node in system.size_estimates, which covers the token ranges that
+// node owns and is only updated periodically (every 5 minutes by default), so
+// right after a load it may be stale or still missing
+func (d *dbCreator) DBSize(dbName string) (int64, error) {
+	iter := d.clientSession.Query("SELECT mean_partition_size, partitions_count FROM system.size_estimates WHERE keyspace_name = ?;", dbName).Iter()
+	var size, meanPartitionSize, partitionsCount int64
+	for iter.Scan(&meanPartitionSize, &partitionsCount) {
+		size += meanPartitionSize * partitionsCount
+	}
+	if err := iter.Close(); err != nil {
+		return 0, err
+	}
+	if size == 0 {
+		return 0, fmt.Errorf("system.size_estimates has no estimate for keyspace %s yet", dbName)
+	}
+	return size, nil
+}
+
+// DBSizeIsEstimate returns true, since DBSize only reads the estimates of the
+// connected node
+func (d *dbCreator) DBSizeIsEstimate() bool {
+	return true
+}
+
 func (d *dbCreator) Close() {
 	d.clientSession.Close()
 }
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompactionOptions(t *testing.T) {
+	cases := []struct {
+		compaction string
+		window     time.Duration
+		want       string
+	}{
+		{
+			compaction: "STCS",
+			window:     24 * time.Hour,
+			want:       "{ 'class': 'SizeTieredCompactionStrategy' }",
+		},
+		{
+			compaction: "TWCS",
+			window:     48 * time.Hour,
+			want:       "{ 'class': 'TimeWindowCompactionStrategy', 'compaction_window_unit': 'DAYS', 'compaction_window_size': 2 }",
+		},
+		{
+			compaction: "TWCS",
+			window:     6 * time.Hour,
+			want:       "{ 'class': 'TimeWindowCompactionStrategy', 'compaction_window_unit': 'HOURS', 'compaction_window_size': 6 }",
+		},
+		{
+			compaction: "TWCS",
+			window:     90 * time.Minute,
+			want:       "{ 'class': 'TimeWindowCompactionStrategy', 'compaction_window_unit': 'MINUTES', 'compaction_window_size': 90 }",
+		},
+	}
+
+	for _, c := range cases {
+		compaction = c.compaction
+		compactionWindow = c.window
+		if got := compactionOptions(); got != c.want {
+			t.Errorf("incorrect options for %s with window %v: got %s want %s", c.compaction, c.window, got, c.want)
+		}
+	}
+}
+
+func TestGetCreateTableCQL(t *testing.T) {
+	compaction = "TWCS"
+	compactionWindow = time.Hour
+	ttl = 7 * 24 * time.Hour
+	defer func() {
+		compaction = "STCS"
+		ttl = 0
+	}()
+
+	got := getCreateTableCQL("benchmark", "double")
+	for _, want := range []string{
+		"CREATE TABLE benchmark.series_double (",
+		"value double,",
+		"PRIMARY KEY (series_id, timestamp_ns)",
+		"AND compaction = { 'class': 'TimeWindowCompactionStrategy', 'compaction_window_unit': 'HOURS', 'compaction_window_size': 1 }",
+		"AND default_time_to_live = 604800;",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("statement does not contain %q:\n%s", want, got)
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/gocql/gocql"
+	"github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/load"
 )
 
@@ -22,6 +23,13 @@ var (
 	replicationFactor int
 	consistencyLevel  string
 	writeTimeout      time.Duration
+	batchType         string
+	batchPerPartition bool
+	writeConcurrency  int
+	preparedInserts   bool
+	ttl               time.Duration
+	compaction        string
+	compactionWindow  time.Duration
 )
 
 // Global vars
@@ -39,6 +47,21 @@ var consistencyMapping = map[string]gocql.Consistency{
 	"THREE":  gocql.Three,
 }
 
+// noBatch is the batch type of rows written one by one rather than in batches
+const noBatch = "none"
+
+// Map of user specified strings to gocql batch types
+var batchTypeMapping = map[string]gocql.BatchType{
+	"logged":   gocql.LoggedBatch,
+	"unlogged": gocql.UnloggedBatch,
+}
+
+// Map of user specified strings to Cassandra compaction strategy classes
+var compactionMapping = map[string]string{
+	"STCS": "SizeTieredCompactionStrategy",
+	"TWCS": "TimeWindowCompactionStrategy",
+}
+
 // Parse args:
 func init() {
 	loader = load.GetBenchmarkRunnerWithBatchSize(100)
@@ -49,13 +72,40 @@ func init() {
 	flag.StringVar(&consistencyLevel, "consistency", "ALL", "Desired write consistency level. See Cassandra consistency documentation. Default: ALL")
 	flag.DurationVar(&writeTimeout, "write-timeout", 10*time.Second, "Write timeout.")
 
+	flag.StringVar(&batchType, "batch-type", "logged", "How the rows of a batch are written (choices: logged, unlogged, none). none writes each row on its own.")
+	flag.BoolVar(&batchPerPartition, "batch-per-partition", false, "Whether to split each batch into one CQL batch per partition, i.e. per series_id.")
+	flag.IntVar(&writeConcurrency, "write-concurrency", 1, "Maximum number of CQL batches, or rows with -batch-type=none, of a batch each worker writes asynchronously at the same time (1 = one after the other).")
+	flag.BoolVar(&preparedInserts, "prepared-inserts", false, "Whether to bind the values of the rows to prepared INSERT statements instead of inlining them in CQL strings.")
+	flag.DurationVar(&ttl, "ttl", 0, "Default TTL of the tables, after which rows expire (0 = never).")
+	flag.StringVar(&compaction, "compaction", "STCS", "Compaction strategy of the tables (choices: STCS, TWCS).")
+	flag.DurationVar(&compactionWindow, "compaction-window", 24*time.Hour, "Time window of the TWCS compaction strategy, in whole minutes.")
+
 	flag.Parse()
 
 	if _, ok := consistencyMapping[consistencyLevel]; !ok {
 		fmt.Println("Invalid consistency level.")
 		os.Exit(1)
 	}
-
+	if _, ok := batchTypeMapping[batchType]; !ok && batchType != noBatch {
+		fmt.Println("Invalid batch type.")
+		os.Exit(1)
+	}
+	if writeConcurrency < 1 {
+		fmt.Println("Invalid write concurrency.")
+		os.Exit(1)
+	}
+	if ttl < 0 || ttl%time.Second != 0 {
+		fmt.Println("Invalid TTL, it has to be a whole number of seconds.")
+		os.Exit(1)
+	}
+	if _, ok := compactionMapping[compaction]; !ok {
+		fmt.Println("Invalid compaction strategy.")
+		os.Exit(1)
+	}
+	if compactionWindow <= 0 || compactionWindow%time.Minute != 0 {
+		fmt.Println("Invalid compaction window, it has to be a whole number of minutes.")
+		os.Exit(1)
+	}
 }
 
 type benchmark struct {
@@ -74,6 +124,10 @@ func (b *benchmark) GetPointIndexer(_ uint) load.PointIndexer {
 	return &load.ConstantIndexer{}
 }
 
+func (b *benchmark) GetTagExtractor() load.TagExtractor {
+	return &tagExtractor{}
+}
+
 func (b *benchmark) GetProcessor() load.Processor {
 	return &processor{b.dbc}
 }
@@ -93,17 +147,32 @@ type processor struct {
 func (p *processor) Init(_ int, _ bool) {}
 
 // ProcessBatch reads eventsBatches which contain rows of CQL strings and
-// creates a gocql.LoggedBatch to insert
+// writes them as one or more gocql batches, or row by row, depending on the
+// batch type
 func (p *processor) ProcessBatch(b load.Batch, doLoad bool) (uint64, uint64) {
 	events := b.(*eventsBatch)
 
 	if doLoad {
-		batch := p.dbc.clientSession.NewBatch(gocql.LoggedBatch)
-		for _, event := range events.rows {
-			batch.Query(singleMetricToInsertStatement(event))
+		inserts := make([]insert, len(events.rows))
+		for i, event := range events.rows {
+			inserts[i] = parseInsert(event)
 		}
 
-		err := p.dbc.clientSession.ExecuteBatch(batch)
+		var groups [][]insert
+		switch {
+		case batchType == noBatch:
+			for _, ins := range inserts {
+				groups = append(groups, []insert{ins})
+			}
+		case batchPerPartition:
+			groups = groupByPartition(inserts)
+		default:
+			groups = [][]insert{inserts}
+		}
+
+		err := utils.RunBounded(len(groups), writeConcurrency, func(i int) error {
+			return p.write(groups[i])
+		})
 		if err != nil {
 			log.Fatalf("Error writing: %s\n", err.Error())
 		}
@@ -113,3 +182,39 @@ func (p *processor) ProcessBatch(b load.Batch, doLoad bool) (uint64, uint64) {
 	ePool.Put(events)
 	return metricCnt, 0
 }
+
+// write writes the rows in a single gocql batch, or on its own for the batch
+// type none
+func (p *processor) write(inserts []insert) error {
+	session := p.dbc.clientSession
+	if batchType == noBatch {
+		stmt, args, err := insertQuery(inserts[0])
+		if err != nil {
+			return err
+		}
+		return session.Query(stmt, args...).Exec()
+	}
+
+	batch := session.NewBatch(batchTypeMapping[batchType])
+	for _, ins := range inserts {
+		stmt, args, err := insertQuery(ins)
+		if err != nil {
+			return err
+		}
+		batch.Query(stmt, args...)
+	}
+	return session.ExecuteBatch(batch)
+}
+
+// insertQuery returns the CQL INSERT statement of the row, and the values to
+// bind to it with -prepared-inserts
+func insertQuery(ins insert) (string, []interface{}, error) {
+	if !preparedInserts {
+		return ins.statement(), nil, nil
+	}
+	args, err := ins.args()
+	if err != nil {
+		return "", nil, err
+	}
+	return preparedInsertStatement(ins.table), args, nil
+}
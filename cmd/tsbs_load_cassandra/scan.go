@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"log"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -28,11 +29,31 @@ func (d *decoder) Decode(_ *bufio.Reader) *load.Point {
 	return load.NewPoint(d.scanner.Text())
 }
 
-// Transforms a CSV string encoding a single metric into a CQL INSERT statement.
-// We currently only support a 1-line:1-metric mapping for Cassandra. Implement
-// other functions here to support other formats.
-func singleMetricToInsertStatement(text string) string {
-	insertStatement := "INSERT INTO %s(series_id, timestamp_ns, value) VALUES('%s#%s#%s', %s, %s)"
+// tagExtractor looks up tags in a CSV line, which has the tags between the
+// table and measurement names and the last 4 parts of the line
+type tagExtractor struct{}
+
+func (e *tagExtractor) GetTagValue(item *load.Point, key string) (string, bool) {
+	parts := strings.Split(item.Data.(string), ",")
+	if len(parts) < 6 {
+		return "", false
+	}
+	return load.TagValueFromList(strings.Join(parts[2:len(parts)-4], ","), key)
+}
+
+// insert is a CSV line encoding a single metric, split into the table and
+// the columns of the row it is inserted as
+type insert struct {
+	table       string
+	seriesID    string
+	timestampNS string
+	value       string
+}
+
+// parseInsert splits a CSV line encoding a single metric into the table and
+// columns it is inserted into. We currently only support a 1-line:1-metric
+// mapping for Cassandra.
+func parseInsert(text string) insert {
 	parts := strings.Split(text, ",")
 	tagsBeginIndex := 1                  // list of tags begins after the table name
 	tagsEndIndex := (len(parts) - 1) - 4 // list of tags ends right before the last 4 parts of the line
@@ -44,7 +65,80 @@ func singleMetricToInsertStatement(text string) string {
 	timestampNS := parts[tagsEndIndex+3]                            // offset: table + numTags + numTags + measurementName + dayBucket
 	value := parts[tagsEndIndex+4]                                  // offset: table + numTags + timestamp + measurementName + dayBucket + timestampNS
 
-	return fmt.Sprintf(insertStatement, table, tags, measurementName, dayBucket, timestampNS, value)
+	return insert{
+		table:       table,
+		seriesID:    tags + "#" + measurementName + "#" + dayBucket,
+		timestampNS: timestampNS,
+		value:       value,
+	}
+}
+
+// Transforms a CSV string encoding a single metric into a CQL INSERT statement.
+// Implement other functions here to support other formats.
+func singleMetricToInsertStatement(text string) string {
+	return parseInsert(text).statement()
+}
+
+// statement returns the CQL INSERT statement of the row with its values inlined
+func (ins insert) statement() string {
+	return fmt.Sprintf("INSERT INTO %s(series_id, timestamp_ns, value) VALUES('%s', %s, %s)",
+		ins.table, ins.seriesID, ins.timestampNS, ins.value)
+}
+
+// preparedInsertStatement returns the CQL INSERT statement of the table with
+// its values bound as arguments, so that a single prepared statement is reused
+// for all rows of the table
+func preparedInsertStatement(table string) string {
+	return fmt.Sprintf("INSERT INTO %s(series_id, timestamp_ns, value) VALUES(?, ?, ?)", table)
+}
+
+// args returns the values of the row to bind to the prepared INSERT statement
+// of its table, typed according to the value column of the table
+func (ins insert) args() ([]interface{}, error) {
+	timestampNS, err := strconv.ParseInt(ins.timestampNS, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	switch strings.TrimPrefix(ins.table, "series_") {
+	case "bigint":
+		value, err = strconv.ParseInt(ins.value, 10, 64)
+	case "float":
+		var f float64
+		f, err = strconv.ParseFloat(ins.value, 32)
+		value = float32(f)
+	case "double":
+		value, err = strconv.ParseFloat(ins.value, 64)
+	case "boolean":
+		value, err = strconv.ParseBool(ins.value)
+	case "blob":
+		value = []byte(ins.value)
+	default:
+		err = fmt.Errorf("unknown table %s", ins.table)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{ins.seriesID, timestampNS, value}, nil
+}
+
+// groupByPartition groups the rows by the partition, i.e. the table and
+// series_id, they are inserted into, keeping the rows of each partition and
+// the partitions in the order they first appear
+func groupByPartition(inserts []insert) [][]insert {
+	var groups [][]insert
+	index := make(map[string]int)
+	for _, ins := range inserts {
+		key := ins.table + "," + ins.seriesID
+		i, ok := index[key]
+		if !ok {
+			i = len(groups)
+			index[key] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], ins)
+	}
+	return groups
 }
 
 type eventsBatch struct {
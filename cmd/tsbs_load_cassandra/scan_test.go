@@ -1,7 +1,10 @@
 package main
 
 import (
+	"reflect"
 	"testing"
+
+	"github.com/timescale/tsbs/load"
 )
 
 func TestSingleMetricToInsertStatement(t *testing.T) {
@@ -29,3 +32,89 @@ func TestSingleMetricToInsertStatement(t *testing.T) {
 		}
 	}
 }
+
+func TestTagExtractor(t *testing.T) {
+	e := &tagExtractor{}
+	p := load.NewPoint("series_double,cpu,hostname=host_0,region=eu-west-1,usage_guest_nice,2016-01-01,1451606400000000000,38.24")
+	cases := []struct {
+		key   string
+		want  string
+		found bool
+	}{
+		{key: "hostname", want: "host_0", found: true},
+		{key: "region", want: "eu-west-1", found: true},
+		{key: "rack", want: "", found: false},
+	}
+	for _, c := range cases {
+		got, found := e.GetTagValue(p, c.key)
+		if got != c.want || found != c.found {
+			t.Errorf("incorrect value for %s: got (%q, %v) want (%q, %v)", c.key, got, found, c.want, c.found)
+		}
+	}
+}
+
+func TestInsertArgs(t *testing.T) {
+	prefix := "cpu,hostname=host_0,usage_user,2016-01-01,1451606400000000000,"
+	seriesID := "cpu,hostname=host_0#usage_user#2016-01-01"
+	cases := []struct {
+		desc      string
+		input     string
+		want      interface{}
+		shouldErr bool
+	}{
+		{desc: "bigint", input: "series_bigint," + prefix + "388", want: int64(388)},
+		{desc: "float", input: "series_float," + prefix + "1.5", want: float32(1.5)},
+		{desc: "double", input: "series_double," + prefix + "38.24", want: float64(38.24)},
+		{desc: "boolean", input: "series_boolean," + prefix + "true", want: true},
+		{desc: "blob", input: "series_blob," + prefix + "abc", want: []byte("abc")},
+		{desc: "bad value", input: "series_bigint," + prefix + "1.5", shouldErr: true},
+		{desc: "unknown table", input: "series_text," + prefix + "abc", shouldErr: true},
+	}
+
+	for _, c := range cases {
+		args, err := parseInsert(c.input).args()
+		if c.shouldErr {
+			if err == nil {
+				t.Errorf("%s: expected an error", c.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+			continue
+		}
+		want := []interface{}{seriesID, int64(1451606400000000000), c.want}
+		if !reflect.DeepEqual(args, want) {
+			t.Errorf("%s: incorrect args: got %#v want %#v", c.desc, args, want)
+		}
+	}
+}
+
+func TestGroupByPartition(t *testing.T) {
+	rows := []string{
+		"series_double,cpu,hostname=host_0,usage_user,2016-01-01,1451606400000000000,1",
+		"series_double,cpu,hostname=host_1,usage_user,2016-01-01,1451606400000000000,2",
+		"series_double,cpu,hostname=host_0,usage_user,2016-01-01,1451606410000000000,3",
+		"series_bigint,cpu,hostname=host_0,usage_user,2016-01-01,1451606400000000000,4",
+		"series_double,cpu,hostname=host_0,usage_user,2016-01-02,1451692800000000000,5",
+	}
+	inserts := make([]insert, len(rows))
+	for i, row := range rows {
+		inserts[i] = parseInsert(row)
+	}
+
+	groups := groupByPartition(inserts)
+	wantValues := [][]string{{"1", "3"}, {"2"}, {"4"}, {"5"}}
+	if len(groups) != len(wantValues) {
+		t.Fatalf("incorrect number of groups: got %d want %d", len(groups), len(wantValues))
+	}
+	for i, group := range groups {
+		var values []string
+		for _, ins := range group {
+			values = append(values, ins.value)
+		}
+		if !reflect.DeepEqual(values, wantValues[i]) {
+			t.Errorf("incorrect values of group %d: got %v want %v", i, values, wantValues[i])
+		}
+	}
+}
@@ -3,11 +3,81 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/jmoiron/sqlx"
 )
 
+// engines are the table engines the metrics tables can be created with
+var engines = []string{"MergeTree", "ReplacingMergeTree", "SummingMergeTree"}
+
+// partitionGranularities are the choices of -partition-by
+var partitionGranularities = []string{"none", "hour", "day", "week", "month"}
+
+// partitionExprs maps each partition granularity to its PARTITION BY expression
+var partitionExprs = map[string]string{
+	"none":  "tuple()",
+	"hour":  "toStartOfHour(created_at)",
+	"day":   "created_date",
+	"week":  "toMonday(created_date)",
+	"month": "toYYYYMM(created_date)",
+}
+
+// codecRE matches a single column compression codec, with optional level or
+// bytes size argument, e.g. ZSTD(3) or Delta(4)
+var codecRE = regexp.MustCompile(`^(NONE|LZ4|LZ4HC|ZSTD|Delta|DoubleDelta|Gorilla|T64)(\(\d+\))?$`)
+
+// validateTableOptions checks the flags describing the layout of the tables
+func validateTableOptions() error {
+	found := false
+	for _, e := range engines {
+		found = found || e == engine
+	}
+	if !found {
+		return fmt.Errorf("unknown engine '%s', choices: %s", engine, strings.Join(engines, ", "))
+	}
+	if _, ok := partitionExprs[partitionBy]; !ok {
+		return fmt.Errorf("unknown partition granularity '%s', choices: %s", partitionBy, strings.Join(partitionGranularities, ", "))
+	}
+	if len(strings.TrimSpace(orderBy)) == 0 {
+		return fmt.Errorf("ORDER BY columns cannot be empty")
+	}
+	for _, codecs := range []string{timeCodec, metricCodec} {
+		if _, err := codecClause(codecs); err != nil {
+			return err
+		}
+	}
+	if len(cluster) > 0 && len(strings.TrimSpace(shardingKey)) == 0 {
+		return fmt.Errorf("sharding key cannot be empty with a cluster")
+	}
+	return nil
+}
+
+// codecClause returns the CODEC clause of a column compressed with the
+// comma-separated codecs, or an empty string if no codecs are given
+func codecClause(codecs string) (string, error) {
+	if len(codecs) == 0 {
+		return "", nil
+	}
+	parts := strings.Split(codecs, ",")
+	for i, codec := range parts {
+		parts[i] = strings.TrimSpace(codec)
+		if !codecRE.MatchString(parts[i]) {
+			return "", fmt.Errorf("unknown codec '%s'", parts[i])
+		}
+	}
+	return fmt.Sprintf(" CODEC(%s)", strings.Join(parts, ", ")), nil
+}
+
+// onCluster returns the ON CLUSTER clause of DDL statements, if any
+func onCluster() string {
+	if len(cluster) == 0 {
+		return ""
+	}
+	return " ON CLUSTER " + cluster
+}
+
 // loader.DBCreator interface implementation
 type dbCreator struct {
 	tags    string
@@ -99,7 +169,7 @@ func (d *dbCreator) RemoveOldDB(dbName string) error {
 	db := sqlx.MustConnect(dbType, getConnectString(false))
 	defer db.Close()
 
-	sql := fmt.Sprintf("DROP DATABASE IF EXISTS %s", dbName)
+	sql := fmt.Sprintf("DROP DATABASE IF EXISTS %s%s", dbName, onCluster())
 	if _, err := db.Exec(sql); err != nil {
 		panic(err)
 	}
@@ -110,7 +180,7 @@ func (d *dbCreator) RemoveOldDB(dbName string) error {
 func (d *dbCreator) CreateDB(dbName string) error {
 	// Connect to ClickHouse in general and CREATE DATABASE
 	db := sqlx.MustConnect(dbType, getConnectString(false))
-	sql := fmt.Sprintf("CREATE DATABASE %s", dbName)
+	sql := fmt.Sprintf("CREATE DATABASE %s%s", dbName, onCluster())
 	_, err := db.Exec(sql)
 	if err != nil {
 		panic(err)
@@ -146,14 +216,103 @@ func (d *dbCreator) CreateDB(dbName string) error {
 	for _, cols := range d.cols {
 		// cols content:
 		// cpu,usage_user,usage_system,usage_idle,usage_nice,usage_iowait,usage_irq,usage_softirq,usage_steal,usage_guest,usage_guest_nice
-		createMetricsTable(db, strings.Split(strings.TrimSpace(cols), ","))
+		createMetricsTable(db, dbName, strings.Split(strings.TrimSpace(cols), ","))
+	}
+
+	return nil
+}
+
+// loader.DBCreatorPost interface implementation
+func (d *dbCreator) PostCreateDB(dbName string) error {
+	if !loader.Resuming() {
+		// CreateDB has done all the work already
+		return nil
 	}
 
+	// Tables already exist when resuming, so only cache their columns
+	// (normally done while creating them) and the tags already inserted
+	parts := strings.Split(strings.TrimSpace(d.tags), ",")
+	if parts[0] != "tags" {
+		return fmt.Errorf("input header in wrong format. got '%s', expected 'tags'", parts[0])
+	}
+	tableCols["tags"] = parts[1:]
+	for _, cols := range d.cols {
+		tableSpec := strings.Split(strings.TrimSpace(cols), ",")
+		tableCols[tableSpec[0]] = tableSpec[1:]
+	}
+
+	db := sqlx.MustConnect(dbType, getConnectString(true))
+	defer db.Close()
+	loadExistingTags(db)
+
 	return nil
 }
 
+// loader.DBSizer interface implementation
+func (d *dbCreator) DBSize(dbName string) (int64, error) {
+	db := sqlx.MustConnect(dbType, getConnectString(false))
+	defer db.Close()
+
+	parts := "system.parts"
+	if len(cluster) > 0 {
+		// the parts of the tables on every node of the cluster
+		parts = fmt.Sprintf("clusterAllReplicas('%s', system.parts)", cluster)
+	}
+	sql := fmt.Sprintf("SELECT sum(bytes_on_disk) FROM %s WHERE database = '%s' AND active", parts, dbName)
+	if debug > 0 {
+		fmt.Printf(sql)
+	}
+	var size uint64
+	if err := db.Get(&size, sql); err != nil {
+		return 0, err
+	}
+	return int64(size), nil
+}
+
+// loadExistingTags fills the global hostname -> tags_id cache with the tags
+// already stored in the tags table
+func loadExistingTags(db *sqlx.DB) {
+	sql := fmt.Sprintf("SELECT id, %s FROM tags", tableCols["tags"][0])
+	if debug > 0 {
+		fmt.Printf(sql)
+	}
+	rows, err := db.Query(sql)
+	if err != nil {
+		panic(err)
+	}
+	defer rows.Close()
+
+	globalSyncCSI.mutex.Lock()
+	defer globalSyncCSI.mutex.Unlock()
+	for rows.Next() {
+		var id int64
+		var hostname string
+		if err := rows.Scan(&id, &hostname); err != nil {
+			panic(err)
+		}
+		globalSyncCSI.m[hostname] = id
+	}
+	if err := rows.Err(); err != nil {
+		panic(err)
+	}
+}
+
 // createTagsTable builds CREATE TABLE SQL statement and runs it
 func createTagsTable(db *sqlx.DB, tags []string) {
+	sql := getTagsTableSQL(tags)
+	if debug > 0 {
+		fmt.Printf(sql)
+	}
+	_, err := db.Exec(sql)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// getTagsTableSQL builds the CREATE TABLE statement of the tags table. With a
+// cluster, every node gets a full copy of the tags, so that the metrics
+// tables shards can be joined with them locally.
+func getTagsTableSQL(tags []string) string {
 	// prepare COLUMNs specification for CREATE TABLE statement
 	// all columns would be of type String
 	cols := strings.Join(tags, " String,\n ")
@@ -163,27 +322,24 @@ func createTagsTable(db *sqlx.DB, tags []string) {
 	//index := strings.Join(tags, ","	)
 	index := "id"
 
-	sql := fmt.Sprintf(`
-		CREATE TABLE tags(
+	return fmt.Sprintf(`
+		CREATE TABLE tags%s(
 			created_date Date     DEFAULT today(),
 			created_at   DateTime DEFAULT now(),
 			id           UInt32,
 			%s
-		) ENGINE = MergeTree(created_date, (%s), 8192)
+		) ENGINE = MergeTree()
+		PARTITION BY toYYYYMM(created_date)
+		ORDER BY (%s)
+		SETTINGS index_granularity = 8192
 		`,
+		onCluster(),
 		cols,
 		index)
-	if debug > 0 {
-		fmt.Printf(sql)
-	}
-	_, err := db.Exec(sql)
-	if err != nil {
-		panic(err)
-	}
 }
 
-// createMetricsTable builds CREATE TABLE SQL statement and runs it
-func createMetricsTable(db *sqlx.DB, tableSpec []string) {
+// createMetricsTable builds CREATE TABLE SQL statements and runs them
+func createMetricsTable(db *sqlx.DB, dbName string, tableSpec []string) {
 	// tableSpec contain
 	// 0: table name
 	// 1: table column name 1
@@ -193,58 +349,125 @@ func createMetricsTable(db *sqlx.DB, tableSpec []string) {
 	tableName := tableSpec[0]
 	tableCols[tableName] = tableSpec[1:]
 
-	// We'll have some service columns in table to be created and columnNames contains all column names to be created
-	columnNames := []string{}
+	for _, sql := range getMetricsTableSQL(dbName, tableSpec) {
+		if debug > 0 {
+			fmt.Printf(sql)
+		}
+		_, err := db.Exec(sql)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// getMetricsTableSQL builds the CREATE TABLE statements of the metrics table
+// described by tableSpec. With a cluster, the data goes into a local table
+// on every node, which a Distributed table of the table name shards over.
+func getMetricsTableSQL(dbName string, tableSpec []string) []string {
+	tableName := tableSpec[0]
+
+	// columnsWithType - column specifications with type. Ex.: "cpu_usage Float64"
+	columnsWithType := []string{}
 
 	if inTableTag {
 		// First column in the table - service column - partitioning field
 		partitioningColumn := tableCols["tags"][0] // would be 'hostname'
-		columnNames = append(columnNames, partitioningColumn)
+		columnsWithType = append(columnsWithType, fmt.Sprintf("%s String", partitioningColumn))
 	}
 
-	// Add all column names from tableSpec into columnNames
-	columnNames = append(columnNames, tableSpec[1:]...)
+	// codecs are validated at startup
+	timeCodecClause, _ := codecClause(timeCodec)
+	metricCodecClause, _ := codecClause(metricCodec)
 
-	// columnsWithType - column specifications with type. Ex.: "cpu_usage Float64"
-	columnsWithType := []string{}
-	for _, column := range columnNames {
+	// Add all column names from tableSpec
+	for _, column := range tableSpec[1:] {
 		if len(column) == 0 {
 			// Skip nameless columns
 			continue
 		}
-		columnsWithType = append(columnsWithType, fmt.Sprintf("%s Float64", column))
+		columnsWithType = append(columnsWithType, fmt.Sprintf("%s Float64%s", column, metricCodecClause))
 	}
 
-	sql := fmt.Sprintf(`
-			CREATE TABLE %s (
+	localTableName := tableName
+	if len(cluster) > 0 {
+		localTableName = tableName + "_local"
+	}
+
+	sqls := []string{fmt.Sprintf(`
+			CREATE TABLE %s%s (
 				created_date    Date     DEFAULT today(),
-				created_at      DateTime DEFAULT now(),
+				created_at      DateTime DEFAULT now()%s,
 				time            String,
 				tags_id         UInt32,
 				%s,
 				additional_tags String   DEFAULT ''
-			) ENGINE = MergeTree(created_date, (tags_id, created_at), 8192)
+			) ENGINE = %s()
+			PARTITION BY %s
+			ORDER BY (%s)
+			SETTINGS index_granularity = 8192
+			`,
+		localTableName,
+		onCluster(),
+		timeCodecClause,
+		strings.Join(columnsWithType, ","),
+		engine,
+		partitionExprs[partitionBy],
+		orderBy)}
+
+	if len(cluster) > 0 {
+		sqls = append(sqls, fmt.Sprintf(`
+			CREATE TABLE %s%s AS %s
+			ENGINE = Distributed(%s, %s, %s, %s)
 			`,
-		tableName,
-		strings.Join(columnsWithType, ","))
+			tableName,
+			onCluster(),
+			localTableName,
+			cluster,
+			dbName,
+			localTableName,
+			shardingKey))
+	}
+	return sqls
+}
+
+// getClusterHosts returns the host:port addresses of the nodes of the cluster
+func getClusterHosts(db *sqlx.DB) []string {
+	sql := fmt.Sprintf("SELECT DISTINCT host_address, port FROM system.clusters WHERE cluster = '%s'", cluster)
 	if debug > 0 {
 		fmt.Printf(sql)
 	}
-	_, err := db.Exec(sql)
-	if err != nil {
+	var rows []struct {
+		HostAddress string `db:"host_address"`
+		Port        uint16 `db:"port"`
+	}
+	if err := db.Select(&rows, sql); err != nil {
 		panic(err)
 	}
+	if len(rows) == 0 {
+		fatal("cluster '%s' has no nodes", cluster)
+	}
+	hosts := make([]string, 0, len(rows))
+	for _, row := range rows {
+		hosts = append(hosts, fmt.Sprintf("%s:%d", row.HostAddress, row.Port))
+	}
+	return hosts
 }
 
 // getConnectString() builds connect string to ClickHouse
 // db - whether database specification should be added to the connection string
 func getConnectString(db bool) string {
+	return getHostConnectString(host+":9000", db)
+}
+
+// getHostConnectString builds connect string to the ClickHouse server at
+// hostPort
+func getHostConnectString(hostPort string, db bool) string {
 	// connectString: tcp://127.0.0.1:9000?debug=true
 	// ClickHouse ex.:
 	// tcp://host1:9000?username=user&password=qwerty&database=clicks&read_timeout=10&write_timeout=20&alt_hosts=host2:9000,host3:9000
 	if db {
-		return fmt.Sprintf("tcp://%s:9000?username=%s&password=%s&database=%s", host, user, password, loader.DatabaseName())
+		return fmt.Sprintf("tcp://%s?username=%s&password=%s&database=%s", hostPort, user, password, loader.DatabaseName())
 	} else {
-		return fmt.Sprintf("tcp://%s:9000?username=%s&password=%s", host, user, password)
+		return fmt.Sprintf("tcp://%s?username=%s&password=%s", hostPort, user, password)
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"log"
+	"strings"
 	"testing"
 )
 
@@ -88,3 +89,138 @@ func TestDBCreatorReadDataHeader(t *testing.T) {
 		}
 	}
 }
+
+// setTableOptions sets the table layout flags to their defaults, overridden
+// by the given options, and returns a func restoring them
+func setTableOptions(opts map[string]string) func() {
+	vars := map[string]*string{
+		"engine":       &engine,
+		"partition-by": &partitionBy,
+		"order-by":     &orderBy,
+		"time-codec":   &timeCodec,
+		"metric-codec": &metricCodec,
+		"cluster":      &cluster,
+		"sharding-key": &shardingKey,
+	}
+	defaults := map[string]string{
+		"engine":       "MergeTree",
+		"partition-by": "month",
+		"order-by":     "tags_id, created_at",
+		"sharding-key": "rand()",
+	}
+	old := make(map[string]string)
+	for name, v := range vars {
+		old[name] = *v
+		*v = defaults[name]
+		if o, ok := opts[name]; ok {
+			*v = o
+		}
+	}
+	return func() {
+		for name, v := range vars {
+			*v = old[name]
+		}
+	}
+}
+
+func TestValidateTableOptions(t *testing.T) {
+	cases := []struct {
+		desc      string
+		opts      map[string]string
+		shouldErr bool
+	}{
+		{desc: "defaults"},
+		{
+			desc: "all options",
+			opts: map[string]string{
+				"engine":       "ReplacingMergeTree",
+				"partition-by": "day",
+				"order-by":     "tags_id, created_at, time",
+				"time-codec":   "DoubleDelta, LZ4",
+				"metric-codec": "Gorilla,ZSTD(3)",
+				"cluster":      "local",
+			},
+		},
+		{desc: "unknown engine", opts: map[string]string{"engine": "Log"}, shouldErr: true},
+		{desc: "unknown partition granularity", opts: map[string]string{"partition-by": "year"}, shouldErr: true},
+		{desc: "empty order by", opts: map[string]string{"order-by": " "}, shouldErr: true},
+		{desc: "unknown codec", opts: map[string]string{"metric-codec": "Gorilla,Snappy"}, shouldErr: true},
+		{desc: "bad codec argument", opts: map[string]string{"time-codec": "ZSTD(x)"}, shouldErr: true},
+		{desc: "empty sharding key", opts: map[string]string{"cluster": "local", "sharding-key": ""}, shouldErr: true},
+	}
+	for _, c := range cases {
+		restore := setTableOptions(c.opts)
+		err := validateTableOptions()
+		restore()
+		if c.shouldErr && err == nil {
+			t.Errorf("%s: did not error when it should", c.desc)
+		} else if !c.shouldErr && err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+		}
+	}
+}
+
+func TestGetMetricsTableSQL(t *testing.T) {
+	tableSpec := []string{"cpu", "usage_user", "usage_system"}
+	cases := []struct {
+		desc       string
+		opts       map[string]string
+		inTableTag bool
+		want       []string
+	}{
+		{
+			desc: "defaults",
+			want: []string{"CREATE TABLE cpu ( created_date Date DEFAULT today(), created_at DateTime DEFAULT now(), time String, tags_id UInt32, usage_user Float64,usage_system Float64, additional_tags String DEFAULT '' ) ENGINE = MergeTree() PARTITION BY toYYYYMM(created_date) ORDER BY (tags_id, created_at) SETTINGS index_granularity = 8192"},
+		},
+		{
+			desc: "engine, partitioning, ordering and codecs",
+			opts: map[string]string{
+				"engine":       "SummingMergeTree",
+				"partition-by": "hour",
+				"order-by":     "tags_id, created_at, time",
+				"time-codec":   "DoubleDelta,LZ4",
+				"metric-codec": "Gorilla",
+			},
+			inTableTag: true,
+			want:       []string{"CREATE TABLE cpu ( created_date Date DEFAULT today(), created_at DateTime DEFAULT now() CODEC(DoubleDelta, LZ4), time String, tags_id UInt32, hostname String,usage_user Float64 CODEC(Gorilla),usage_system Float64 CODEC(Gorilla), additional_tags String DEFAULT '' ) ENGINE = SummingMergeTree() PARTITION BY toStartOfHour(created_at) ORDER BY (tags_id, created_at, time) SETTINGS index_granularity = 8192"},
+		},
+		{
+			desc: "cluster",
+			opts: map[string]string{"partition-by": "none", "cluster": "local"},
+			want: []string{
+				"CREATE TABLE cpu_local ON CLUSTER local ( created_date Date DEFAULT today(), created_at DateTime DEFAULT now(), time String, tags_id UInt32, usage_user Float64,usage_system Float64, additional_tags String DEFAULT '' ) ENGINE = MergeTree() PARTITION BY tuple() ORDER BY (tags_id, created_at) SETTINGS index_granularity = 8192",
+				"CREATE TABLE cpu ON CLUSTER local AS cpu_local ENGINE = Distributed(local, benchmark, cpu_local, rand())",
+			},
+		},
+	}
+
+	oldTableCols, oldInTableTag := tableCols, inTableTag
+	defer func() {
+		tableCols, inTableTag = oldTableCols, oldInTableTag
+	}()
+	tableCols = map[string][]string{"tags": {"hostname", "region"}}
+	for _, c := range cases {
+		restore := setTableOptions(c.opts)
+		inTableTag = c.inTableTag
+		got := getMetricsTableSQL("benchmark", tableSpec)
+		restore()
+		if len(got) != len(c.want) {
+			t.Errorf("%s: incorrect number of statements: got %d want %d", c.desc, len(got), len(c.want))
+			continue
+		}
+		for i := range got {
+			if sql := strings.Join(strings.Fields(got[i]), " "); sql != c.want[i] {
+				t.Errorf("%s: incorrect statement %d: got\n%s\nwant\n%s", c.desc, i, sql, c.want[i])
+			}
+		}
+	}
+}
+
+func TestGetTagsTableSQL(t *testing.T) {
+	want := "CREATE TABLE tags ON CLUSTER local( created_date Date DEFAULT today(), created_at DateTime DEFAULT now(), id UInt32, hostname String, region String ) ENGINE = MergeTree() PARTITION BY toYYYYMM(created_date) ORDER BY (id) SETTINGS index_granularity = 8192"
+	restore := setTableOptions(map[string]string{"cluster": "local"})
+	defer restore()
+	if got := strings.Join(strings.Fields(getTagsTableSQL([]string{"hostname", "region"})), " "); got != want {
+		t.Errorf("incorrect statement: got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -6,8 +6,10 @@ package main
 import (
 	"bufio"
 	"flag"
+	"fmt"
 	"github.com/timescale/tsbs/load"
 	"log"
+	"strings"
 )
 
 const (
@@ -22,9 +24,18 @@ var (
 	user     string
 	password string
 
-	logBatches  bool
-	inTableTag  bool
-	hashWorkers bool
+	logBatches     bool
+	inTableTag     bool
+	hashWorkers    bool
+	nativeProtocol bool
+
+	engine      string
+	partitionBy string
+	orderBy     string
+	timeCodec   string
+	metricCodec string
+	cluster     string
+	shardingKey string
 
 	debug int
 )
@@ -57,9 +68,24 @@ func init() {
 	// TODO - This flag could potentially be done as a string/enum with other options besides no-hash, round-robin, etc
 	flag.BoolVar(&hashWorkers, "hash-workers", false, "Whether to consistently hash insert data to the same workers (i.e., the data for a particular host always goes to the same worker)")
 
+	flag.BoolVar(&nativeProtocol, "native-protocol", false, "Whether to insert data as columnar blocks through the native protocol directly, instead of row by row through database/sql")
+
+	flag.BoolVar(&inTableTag, "in-table-tag", false, "Whether to also store the hostname in the metrics tables, as used by queries generated with -clickhouse-use-tags=false")
+
+	flag.StringVar(&engine, "engine", "MergeTree", fmt.Sprintf("Table engine of the metrics tables (choices: %s)", strings.Join(engines, ", ")))
+	flag.StringVar(&partitionBy, "partition-by", "month", fmt.Sprintf("Time granularity to partition the metrics tables by (choices: %s)", strings.Join(partitionGranularities, ", ")))
+	flag.StringVar(&orderBy, "order-by", "tags_id, created_at", "ORDER BY (primary key) columns of the metrics tables")
+	flag.StringVar(&timeCodec, "time-codec", "", "Comma-separated compression codecs of the created_at column, e.g. DoubleDelta,LZ4 (empty = server default)")
+	flag.StringVar(&metricCodec, "metric-codec", "", "Comma-separated compression codecs of the metric columns, e.g. Gorilla,ZSTD(3) (empty = server default)")
+	flag.StringVar(&cluster, "cluster", "", "Cluster to create the database on, with the metrics tables sharded over it by Distributed tables and the tags copied to every node (empty = single server)")
+	flag.StringVar(&shardingKey, "sharding-key", "rand()", "Sharding key of the Distributed metrics tables with -cluster")
+
 	flag.IntVar(&debug, "debug", 0, "Debug printing (choices: 0, 1, 2). (default 0)")
 
 	flag.Parse()
+	if err := validateTableOptions(); err != nil {
+		log.Fatalf("invalid table options: %v", err)
+	}
 	tableCols = make(map[string][]string)
 }
 
@@ -81,13 +107,16 @@ func (b *benchmark) GetBatchFactory() load.BatchFactory {
 // loader.Benchmark interface implementation
 func (b *benchmark) GetPointIndexer(maxPartitions uint) load.PointIndexer {
 	if hashWorkers {
-		return &hostnameIndexer{
-			partitions: maxPartitions,
-		}
+		return load.NewHashIndexer(&tagExtractor{}, hostnameTag, maxPartitions)
 	}
 	return &load.ConstantIndexer{}
 }
 
+// load.BenchmarkTagExtractor interface implementation
+func (b *benchmark) GetTagExtractor() load.TagExtractor {
+	return &tagExtractor{}
+}
+
 // loader.Benchmark interface implementation
 func (b *benchmark) GetProcessor() load.Processor {
 	return &processor{}
@@ -8,7 +8,7 @@ import (
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/kshvakov/clickhouse"
+	"github.com/kshvakov/clickhouse"
 	"github.com/timescale/tsbs/load"
 )
 
@@ -253,7 +253,11 @@ func (p *processor) processCSI(tableName string, rows []*insertData) uint64 {
 	if len(newTags) > 0 {
 		// We have new tags to insert
 		p.csi.mutex.Lock()
-		hostnameToTags := insertTags(p.db, len(p.csi.m), newTags, true)
+		hostnameToTags := insertTags(p.tagDBs[0], len(p.csi.m), newTags, true)
+		// Every node of a cluster has its own copy of the tags
+		for _, db := range p.tagDBs[1:] {
+			insertTags(db, len(p.csi.m), newTags, false)
+		}
 		// Insert new tags into map as well
 		for hostName, tagsId := range hostnameToTags {
 			p.csi.m[hostName] = tagsId
@@ -298,6 +302,13 @@ func (p *processor) processCSI(tableName string, rows []*insertData) uint64 {
 		strings.Join(cols, ","),
 		strings.Repeat(",?", len(cols))[1:]) // We need '?,?,?', but repeat ",?" thus we need to chop off 1-st char
 
+	if nativeProtocol {
+		if err := p.insertBlock(sql, dataRows); err != nil {
+			panic(err)
+		}
+		return ret
+	}
+
 	tx := p.db.MustBegin()
 	stmt, err := tx.Prepare(sql)
 	for _, r := range dataRows {
@@ -318,18 +329,113 @@ func (p *processor) processCSI(tableName string, rows []*insertData) uint64 {
 	return ret
 }
 
+// blockWriter is the part of *data.Block that rows are written with
+type blockWriter interface {
+	WriteDate(c int, v time.Time) error
+	WriteDateTime(c int, v time.Time) error
+	WriteString(c int, v string) error
+	WriteUInt32(c int, v uint32) error
+	WriteFloat64(c int, v float64) error
+}
+
+// writeBlockRows writes rows of a metrics table into a reserved block, value
+// by value
+func writeBlockRows(block blockWriter, rows [][]interface{}) error {
+	for _, r := range rows {
+		for c, v := range r {
+			if err := writeBlockValue(block, c, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeBlockValue writes the value v of column c of a row of a metrics table
+// into block. The first two columns are created_date and created_at, followed
+// by string columns, tags_id and metric values.
+func writeBlockValue(block blockWriter, c int, v interface{}) error {
+	switch c {
+	case 0:
+		return block.WriteDate(c, v.(time.Time)) // created_date
+	case 1:
+		return block.WriteDateTime(c, v.(time.Time)) // created_at
+	}
+	switch v := v.(type) {
+	case string:
+		return block.WriteString(c, v)
+	case int64:
+		return block.WriteUInt32(c, uint32(v)) // tags_id
+	case float64:
+		return block.WriteFloat64(c, v)
+	default:
+		return fmt.Errorf("cannot write value of type %T to column %d of a block", v, c)
+	}
+}
+
+// insertBlock inserts rows with the INSERT statement sql as a single columnar
+// block through the native protocol, skipping the per-row conversions of
+// database/sql
+func (p *processor) insertBlock(sql string, rows [][]interface{}) error {
+	if _, err := p.conn.Begin(); err != nil {
+		return err
+	}
+	if _, err := p.conn.Prepare(sql); err != nil {
+		p.conn.Rollback()
+		return err
+	}
+	block, err := p.conn.Block()
+	if err != nil {
+		p.conn.Rollback()
+		return err
+	}
+	block.Reserve()
+	block.NumRows = uint64(len(rows))
+	if err := writeBlockRows(block, rows); err != nil {
+		p.conn.Rollback()
+		return err
+	}
+	if err := p.conn.WriteBlock(block); err != nil {
+		p.conn.Rollback()
+		return err
+	}
+	return p.conn.Commit()
+}
+
 // load.Processor interface implementation
 type processor struct {
-	db  *sqlx.DB
-	csi *syncCSI
+	db     *sqlx.DB
+	tagDBs []*sqlx.DB            // connections to insert tags with, one per node of the cluster
+	conn   clickhouse.Clickhouse // direct connection for --native-protocol
+	csi    *syncCSI
 }
 
 // load.Processor interface implementation
 func (p *processor) Init(workerNum int, doLoad bool) {
 	if doLoad {
 		p.db = sqlx.MustConnect(dbType, getConnectString(true))
+		if len(cluster) > 0 {
+			for _, hostPort := range getClusterHosts(p.db) {
+				p.tagDBs = append(p.tagDBs, sqlx.MustConnect(dbType, getHostConnectString(hostPort, true)))
+			}
+		} else {
+			p.tagDBs = []*sqlx.DB{p.db}
+		}
+		if nativeProtocol {
+			conn, err := clickhouse.OpenDirect(getConnectString(true))
+			if err != nil {
+				panic(err)
+			}
+			p.conn = conn
+		}
 		if hashWorkers {
+			// Start from the tags already in the DB, if any (e.g., when resuming)
 			p.csi = newSyncCSI()
+			globalSyncCSI.mutex.RLock()
+			for k, v := range globalSyncCSI.m {
+				p.csi.m[k] = v
+			}
+			globalSyncCSI.mutex.RUnlock()
 		} else {
 			p.csi = globalSyncCSI
 		}
@@ -339,7 +445,15 @@ func (p *processor) Init(workerNum int, doLoad bool) {
 // load.ProcessorCloser interface implementation
 func (p *processor) Close(doLoad bool) {
 	if doLoad {
+		if len(cluster) > 0 {
+			for _, db := range p.tagDBs {
+				db.Close()
+			}
+		}
 		p.db.Close()
+		if p.conn != nil {
+			p.conn.Close()
+		}
 	}
 }
 
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kshvakov/clickhouse/lib/data"
+)
+
+// recordingBlock records the values written to it per column in the order
+// they are written, along with the type they are written as
+type recordingBlock struct {
+	columns map[int][]string
+}
+
+func (b *recordingBlock) write(c int, typ string, v interface{}) error {
+	if b.columns == nil {
+		b.columns = make(map[int][]string)
+	}
+	b.columns[c] = append(b.columns[c], fmt.Sprintf("%s(%v)", typ, v))
+	return nil
+}
+
+func (b *recordingBlock) WriteDate(c int, v time.Time) error {
+	return b.write(c, "Date", v.Format("2006-01-02"))
+}
+func (b *recordingBlock) WriteDateTime(c int, v time.Time) error {
+	return b.write(c, "DateTime", v.Format(time.RFC3339))
+}
+func (b *recordingBlock) WriteString(c int, v string) error   { return b.write(c, "String", v) }
+func (b *recordingBlock) WriteUInt32(c int, v uint32) error   { return b.write(c, "UInt32", v) }
+func (b *recordingBlock) WriteFloat64(c int, v float64) error { return b.write(c, "Float64", v) }
+
+func TestWriteBlockRows(t *testing.T) {
+	t1 := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	t2 := time.Date(2016, 1, 2, 3, 4, 5, 0, time.UTC)
+	// created_date, created_at, time, tags_id, additional_tags, hostname,
+	// usage_user, usage_system
+	rows := [][]interface{}{
+		{t1, t1, "2016-01-01 00:00:00 +0000", int64(1), "{}", "host_0", 58.0, 2.5},
+		{t2, t2, "2016-01-02 03:04:05 +0000", int64(4294967295), `{"a":"b"}`, "host_1", 0.0, 100.0},
+	}
+	block := &recordingBlock{}
+	if err := writeBlockRows(block, rows); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[int][]string{
+		0: {"Date(2016-01-01)", "Date(2016-01-02)"},
+		1: {"DateTime(2016-01-01T00:00:00Z)", "DateTime(2016-01-02T03:04:05Z)"},
+		2: {"String(2016-01-01 00:00:00 +0000)", "String(2016-01-02 03:04:05 +0000)"},
+		3: {"UInt32(1)", "UInt32(4294967295)"},
+		4: {"String({})", `String({"a":"b"})`},
+		5: {"String(host_0)", "String(host_1)"},
+		6: {"Float64(58)", "Float64(0)"},
+		7: {"Float64(2.5)", "Float64(100)"},
+	}
+	if !reflect.DeepEqual(block.columns, want) {
+		t.Errorf("incorrect values written\ngot  %v\nwant %v", block.columns, want)
+	}
+}
+
+func TestWriteBlockRowsUnknownType(t *testing.T) {
+	t1 := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	rows := [][]interface{}{{t1, t1, "2016-01-01 00:00:00 +0000", int64(1), "{}", true}}
+	block := &recordingBlock{}
+	if err := writeBlockRows(block, rows); err == nil {
+		t.Errorf("unexpected lack of error for value of unknown type")
+	}
+	if got := len(block.columns[5]); got != 0 {
+		t.Errorf("value of unknown type was written: %v", block.columns[5])
+	}
+}
+
+func TestWriteBlockValueUnknownType(t *testing.T) {
+	block := &data.Block{}
+	if err := writeBlockValue(block, 5, true); err == nil {
+		t.Errorf("unexpected lack of error for value of unknown type")
+	}
+}
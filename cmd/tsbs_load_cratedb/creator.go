@@ -187,6 +187,23 @@ func (d *dbCreator) RemoveOldDB(dbName string) error {
 	return nil
 }
 
+// loader.DBSizer interface implementation
+//
+// returns the size of the primary shards of the tables in a schema, without
+// their replicas
+func (d *dbCreator) DBSize(dbName string) (int64, error) {
+	var size int64
+	err := d.conn.QueryRow(`
+		SELECT coalesce(sum(size), 0)
+		FROM sys.shards
+		WHERE schema_name = $1 AND "primary" = true`, dbName,
+	).Scan(&size)
+	if err != nil {
+		return 0, err
+	}
+	return size, nil
+}
+
 func (d *dbCreator) getTables(dbName string) ([]tableDef, error) {
 	rows, err := d.conn.Query(`
 		SELECT table_schema, table_name
@@ -30,6 +30,10 @@ func (b *benchmark) GetPointIndexer(maxPartitions uint) load.PointIndexer {
 	return &load.ConstantIndexer{}
 }
 
+func (b *benchmark) GetTagExtractor() load.TagExtractor {
+	return &tagExtractor{}
+}
+
 func (b *benchmark) GetProcessor() load.Processor {
 	return &processor{
 		tableDefs: b.dbc.tableDefs,
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
 	"strconv"
 	"strings"
 	"sync"
@@ -18,6 +19,18 @@ type point struct {
 	row   row
 }
 
+// tagExtractor looks up tags in the JSON object of tags of a point's row
+type tagExtractor struct{}
+
+func (e *tagExtractor) GetTagValue(item *load.Point, key string) (string, bool) {
+	var tags map[string]string
+	if err := json.Unmarshal(item.Data.(*point).row[0].([]byte), &tags); err != nil {
+		return "", false
+	}
+	v, ok := tags[key]
+	return v, ok
+}
+
 // scan.Batch interface implementation
 type eventsBatch struct {
 	batches map[string][]*row
@@ -57,7 +70,8 @@ type decoder struct {
 // scan.PointDecoder interface implementation
 //
 // Decodes a data point of a following format:
-//       <measurement_type>\t<tags>\t<timestamp>\t<metric1>\t...\t<metricN>
+//
+//	<measurement_type>\t<tags>\t<timestamp>\t<metric1>\t...\t<metricN>
 //
 // Converts metric values to double-precision floating-point number, timestamp
 // to time.Time and tags to bytes array.
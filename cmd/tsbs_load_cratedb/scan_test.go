@@ -165,3 +165,31 @@ func TestDecodeEOF(t *testing.T) {
 		t.Errorf("expected p to be nil, got %v", p)
 	}
 }
+
+func TestTagExtractor(t *testing.T) {
+	e := &tagExtractor{}
+	p := load.NewPoint(&point{
+		table: "cpu",
+		row:   row{[]byte(`{"hostname":"host_0","region":"eu-west-1"}`), time.Unix(0, 0), 1.0},
+	})
+	cases := []struct {
+		key   string
+		want  string
+		found bool
+	}{
+		{key: "hostname", want: "host_0", found: true},
+		{key: "region", want: "eu-west-1", found: true},
+		{key: "rack", want: "", found: false},
+	}
+	for _, c := range cases {
+		got, found := e.GetTagValue(p, c.key)
+		if got != c.want || found != c.found {
+			t.Errorf("incorrect value for %s: got (%q, %v) want (%q, %v)", c.key, got, found, c.want, c.found)
+		}
+	}
+
+	p = load.NewPoint(&point{table: "cpu", row: row{[]byte("null"), time.Unix(0, 0), 1.0}})
+	if _, found := e.GetTagValue(p, "hostname"); found {
+		t.Errorf("found tag in point without tags")
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// bucketCreator creates the bucket of the organization to load the data into
+// through the InfluxDB 2.x API, in place of the database of the 1.x API
+type bucketCreator struct {
+	daemonURL string
+	client    http.Client
+}
+
+func (d *bucketCreator) Init() {
+	d.daemonURL = daemonURLs[0] // pick first one since it always exists
+}
+
+func (d *bucketCreator) DBExists(dbName string) bool {
+	id, err := d.bucketID(dbName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return len(id) > 0
+}
+
+func (d *bucketCreator) RemoveOldDB(dbName string) error {
+	id, err := d.bucketID(dbName)
+	if err != nil || len(id) == 0 {
+		return err
+	}
+	return d.do("DELETE", "/api/v2/buckets/"+url.PathEscape(id), nil, nil, nil, http.StatusNoContent)
+}
+
+func (d *bucketCreator) CreateDB(dbName string) error {
+	var orgs struct {
+		Orgs []struct {
+			ID string `json:"id"`
+		} `json:"orgs"`
+	}
+	err := d.do("GET", "/api/v2/orgs", url.Values{"org": {org}}, nil, &orgs, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	if len(orgs.Orgs) == 0 {
+		return fmt.Errorf("organization %s not found", org)
+	}
+
+	bucket := map[string]interface{}{
+		"orgID":          orgs.Orgs[0].ID,
+		"name":           dbName,
+		"retentionRules": []interface{}{},
+	}
+	return d.do("POST", "/api/v2/buckets", nil, bucket, nil, http.StatusCreated)
+}
+
+// DBSize returns the disk size of the shards of the bucket on the node of the
+// first of the URLs, as reported by the storage_shard_disk_size gauges of its
+// Prometheus metrics
+func (d *bucketCreator) DBSize(dbName string) (int64, error) {
+	id, err := d.bucketID(dbName)
+	if err != nil {
+		return 0, err
+	}
+	if len(id) == 0 {
+		return 0, fmt.Errorf("bucket %s not found", dbName)
+	}
+
+	var metrics []byte
+	if err := d.do("GET", "/metrics", nil, nil, &metrics, http.StatusOK); err != nil {
+		return 0, err
+	}
+
+	// storage_shard_disk_size{bucket="<id>",engine="tsm1",id="1",...} 12345
+	var size int64
+	label := fmt.Sprintf(`bucket="%s"`, id)
+	for _, line := range strings.Split(string(metrics), "\n") {
+		if !strings.HasPrefix(line, "storage_shard_disk_size{") || !strings.Contains(line, label) {
+			continue
+		}
+		fields := strings.Fields(line)
+		n, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+		if err != nil {
+			return 0, fmt.Errorf("cannot parse metric %q: %v", line, err)
+		}
+		size += int64(n)
+	}
+	return size, nil
+}
+
+// bucketID returns the ID of the bucket of the organization with the given
+// name, or an empty string if there is none
+func (d *bucketCreator) bucketID(name string) (string, error) {
+	var buckets struct {
+		Buckets []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"buckets"`
+	}
+	err := d.do("GET", "/api/v2/buckets", url.Values{"org": {org}, "name": {name}}, nil, &buckets, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+	for _, b := range buckets.Buckets {
+		if b.Name == name {
+			return b.ID, nil
+		}
+	}
+	return "", nil
+}
+
+// do sends a request to the API with the JSON encoding of in as its body if
+// not nil, checks that the response has the wanted status, and decodes its
+// JSON body into out if not nil, or stores the body itself if out is a *[]byte
+func (d *bucketCreator) do(method, path string, params url.Values, in, out interface{}, wantStatus int) error {
+	u := d.daemonURL + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if len(token) > 0 {
+		req.Header.Set(headerAuthorization, "Token "+token)
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s error: %s", method, path, err.Error())
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != wantStatus {
+		return fmt.Errorf("%s %s returned code %d: %s", method, path, resp.StatusCode, respBody)
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw = respBody
+		return nil
+	} else if out != nil {
+		return json.Unmarshal(respBody, out)
+	}
+	return nil
+}
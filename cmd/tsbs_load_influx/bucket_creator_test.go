@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testOrg   = "tsbs"
+	testOrgID = "0123456789abcdef"
+	testToken = "secret"
+)
+
+// bucketStub is a local stand-in for the bucket and organization endpoints of
+// the InfluxDB 2.x API, keeping the buckets of testOrg in memory
+type bucketStub struct {
+	mu      sync.Mutex
+	buckets map[string]string // by ID
+	nextID  int
+}
+
+func (s *bucketStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Token "+testToken {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/api/v2/orgs":
+		orgs := []map[string]string{}
+		if r.URL.Query().Get("org") == testOrg {
+			orgs = append(orgs, map[string]string{"id": testOrgID, "name": testOrg})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"orgs": orgs})
+	case r.Method == "GET" && r.URL.Path == "/api/v2/buckets":
+		buckets := []map[string]string{}
+		for id, name := range s.buckets {
+			if r.URL.Query().Get("org") == testOrg && r.URL.Query().Get("name") == name {
+				buckets = append(buckets, map[string]string{"id": id, "name": name})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"buckets": buckets})
+	case r.Method == "POST" && r.URL.Path == "/api/v2/buckets":
+		var bucket struct {
+			OrgID string `json:"orgID"`
+			Name  string `json:"name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&bucket); err != nil || bucket.OrgID != testOrgID {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.nextID++
+		id := fmt.Sprintf("b%d", s.nextID)
+		s.buckets[id] = bucket.Name
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"id": id, "name": bucket.Name})
+	case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/api/v2/buckets/"):
+		id := strings.TrimPrefix(r.URL.Path, "/api/v2/buckets/")
+		if _, ok := s.buckets[id]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.buckets, id)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "GET" && r.URL.Path == "/metrics":
+		fmt.Fprintln(w, "# TYPE storage_shard_disk_size gauge")
+		for id := range s.buckets {
+			fmt.Fprintf(w, "storage_shard_disk_size{bucket=%q,engine=\"tsm1\",id=\"1\"} 1000\n", id)
+			fmt.Fprintf(w, "storage_shard_disk_size{bucket=%q,engine=\"tsm1\",id=\"2\"} 234\n", id)
+		}
+		fmt.Fprintln(w, `storage_shard_disk_size{bucket="other",engine="tsm1",id="3"} 5000`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestBucketCreator(t *testing.T) {
+	server := httptest.NewServer(&bucketStub{buckets: map[string]string{}})
+	defer server.Close()
+
+	oldURLs, oldOrg, oldToken := daemonURLs, org, token
+	defer func() {
+		daemonURLs, org, token = oldURLs, oldOrg, oldToken
+	}()
+	daemonURLs = []string{server.URL}
+	org = testOrg
+	token = testToken
+
+	d := &bucketCreator{}
+	d.Init()
+	if d.DBExists("benchmark") {
+		t.Fatalf("bucket exists before being created")
+	}
+	if err := d.CreateDB("benchmark"); err != nil {
+		t.Fatalf("unexpected error creating the bucket: %v", err)
+	}
+	if !d.DBExists("benchmark") {
+		t.Errorf("bucket does not exist after being created")
+	}
+	if d.DBExists("other") {
+		t.Errorf("other bucket exists without being created")
+	}
+	if size, err := d.DBSize("benchmark"); err != nil || size != 1234 {
+		t.Errorf("incorrect bucket size: got %d, %v want 1234", size, err)
+	}
+	if _, err := d.DBSize("other"); err == nil {
+		t.Errorf("unexpected lack of error for the size of a missing bucket")
+	}
+	if err := d.RemoveOldDB("benchmark"); err != nil {
+		t.Fatalf("unexpected error removing the bucket: %v", err)
+	}
+	if d.DBExists("benchmark") {
+		t.Errorf("bucket exists after being removed")
+	}
+	if err := d.RemoveOldDB("benchmark"); err != nil {
+		t.Errorf("unexpected error removing a missing bucket: %v", err)
+	}
+
+	org = "unknown"
+	if err := d.CreateDB("benchmark"); err == nil {
+		t.Errorf("unexpected lack of error creating a bucket of an unknown organization")
+	}
+
+	org = testOrg
+	token = "wrong"
+	if err := d.CreateDB("benchmark"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("unexpected error with a wrong token: %v", err)
+	}
+}
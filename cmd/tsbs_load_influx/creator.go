@@ -72,6 +72,55 @@ func (d *dbCreator) listDatabases() ([]string, error) {
 	return ret, nil
 }
 
+// DBSize returns the disk size of the shards of the database on the node of
+// the first of the URLs, as reported in its shard statistics
+func (d *dbCreator) DBSize(dbName string) (int64, error) {
+	u := fmt.Sprintf("%s/query?q=%s", d.daemonURL, url.QueryEscape("SHOW STATS FOR 'shard'"))
+	resp, err := http.Get(u)
+	if err != nil {
+		return 0, fmt.Errorf("show stats error: %s", err.Error())
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return 0, fmt.Errorf("show stats returned non-200 code: %d", resp.StatusCode)
+	}
+
+	// {"results":[{"series":[{"name":"shard","tags":{"database":"benchmark",...},"columns":[...,"diskBytes",...],"values":[[...]]}]}]}
+	var stats struct {
+		Results []struct {
+			Series []struct {
+				Name    string
+				Tags    map[string]string
+				Columns []string
+				Values  [][]interface{}
+			}
+		}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		return 0, err
+	}
+
+	var size int64
+	for _, r := range stats.Results {
+		for _, s := range r.Series {
+			if s.Name != "shard" || s.Tags["database"] != dbName {
+				continue
+			}
+			for i, c := range s.Columns {
+				if c != "diskBytes" {
+					continue
+				}
+				for _, v := range s.Values {
+					if n, ok := v[i].(float64); ok {
+						size += int64(n)
+					}
+				}
+			}
+		}
+	}
+	return size, nil
+}
+
 func (d *dbCreator) RemoveOldDB(dbName string) error {
 	u := fmt.Sprintf("%s/query?q=drop+database+%s", d.daemonURL, dbName)
 	resp, err := http.Post(u, "text/plain", nil)
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDBCreatorDBSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/query" || r.URL.Query().Get("q") != "SHOW STATS FOR 'shard'" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"results":[{"series":[` +
+			`{"name":"shard","tags":{"database":"benchmark","id":"1"},"columns":["diskBytes","writePointsOk"],"values":[[1000,10]]},` +
+			`{"name":"shard","tags":{"database":"benchmark","id":"2"},"columns":["diskBytes","writePointsOk"],"values":[[234,5]]},` +
+			`{"name":"shard","tags":{"database":"_internal","id":"3"},"columns":["diskBytes","writePointsOk"],"values":[[5000,1]]}` +
+			`]}]}`))
+	}))
+	defer server.Close()
+
+	d := &dbCreator{daemonURL: server.URL}
+	if size, err := d.DBSize("benchmark"); err != nil || size != 1234 {
+		t.Errorf("incorrect database size: got %d, %v want 1234", size, err)
+	}
+	if size, err := d.DBSize("other"); err != nil || size != 0 {
+		t.Errorf("incorrect size of a missing database: got %d, %v want 0", size, err)
+	}
+
+	d.daemonURL = server.URL + "/wrong"
+	if _, err := d.DBSize("benchmark"); err == nil {
+		t.Errorf("unexpected lack of error for a non-200 code")
+	}
+}
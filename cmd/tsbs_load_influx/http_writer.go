@@ -15,6 +15,7 @@ const (
 	httpClientName        = "tsbs_load_influx"
 	headerContentEncoding = "Content-Encoding"
 	headerGzip            = "gzip"
+	headerAuthorization   = "Authorization"
 )
 
 var (
@@ -36,6 +37,13 @@ type HTTPWriterConfig struct {
 	// Name of the target database into which points will be written.
 	Database string
 
+	// Organization of the bucket named Database to write into through the
+	// InfluxDB 2.x API. The 1.x API is used if empty.
+	Org string
+
+	// Authentication token sent with every request, if not empty.
+	Token string
+
 	// Debug label for more informative errors.
 	DebugInfo string
 }
@@ -44,13 +52,15 @@ type HTTPWriterConfig struct {
 type HTTPWriter struct {
 	client fasthttp.Client
 
-	c   HTTPWriterConfig
-	url []byte
+	c    HTTPWriterConfig
+	url  []byte
+	auth []byte
 }
 
 // NewHTTPWriter returns a new HTTPWriter from the supplied HTTPWriterConfig.
+// The consistency only applies to the 1.x API.
 func NewHTTPWriter(c HTTPWriterConfig, consistency string) *HTTPWriter {
-	return &HTTPWriter{
+	w := &HTTPWriter{
 		client: fasthttp.Client{
 			Name: httpClientName,
 		},
@@ -58,6 +68,13 @@ func NewHTTPWriter(c HTTPWriterConfig, consistency string) *HTTPWriter {
 		c:   c,
 		url: []byte(c.Host + "/write?consistency=" + consistency + "&db=" + url.QueryEscape(c.Database)),
 	}
+	if len(c.Org) > 0 {
+		w.url = []byte(c.Host + "/api/v2/write?org=" + url.QueryEscape(c.Org) + "&bucket=" + url.QueryEscape(c.Database) + "&precision=ns")
+	}
+	if len(c.Token) > 0 {
+		w.auth = []byte("Token " + c.Token)
+	}
+	return w
 }
 
 var (
@@ -72,6 +89,9 @@ func (w *HTTPWriter) initializeReq(req *fasthttp.Request, body []byte, isGzip bo
 	if isGzip {
 		req.Header.Add(headerContentEncoding, headerGzip)
 	}
+	if len(w.auth) > 0 {
+		req.Header.SetBytesV(headerAuthorization, w.auth)
+	}
 	req.SetBody(body)
 }
 
@@ -83,6 +103,9 @@ func (w *HTTPWriter) executeReq(req *fasthttp.Request, resp *fasthttp.Response)
 		sc := resp.StatusCode()
 		if sc == 500 && backpressurePred(resp.Body()) {
 			err = errBackoff
+		} else if sc == fasthttp.StatusTooManyRequests || sc == fasthttp.StatusServiceUnavailable {
+			// InfluxDB 2.x asks for backpressure with these
+			err = errBackoff
 		} else if sc != fasthttp.StatusNoContent {
 			err = fmt.Errorf("[DebugInfo: %s] Invalid write response (status %d): %s", w.c.DebugInfo, sc, resp.Body())
 		}
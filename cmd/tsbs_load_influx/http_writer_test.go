@@ -3,7 +3,9 @@ package main
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"strings"
 	"sync/atomic"
@@ -188,6 +190,76 @@ func TestHTTPWriterExecuteReq(t *testing.T) {
 	shutdownHTTPServer(c)
 }
 
+func TestNewHTTPWriterV2(t *testing.T) {
+	conf := HTTPWriterConfig{
+		Host:     "http://localhost:8086",
+		Database: "bench mark",
+		Org:      "tsbs",
+		Token:    "secret",
+	}
+	w := NewHTTPWriter(conf, testConsistency)
+	want := "http://localhost:8086/api/v2/write?org=tsbs&bucket=bench+mark&precision=ns"
+	if got := string(w.url); got != want {
+		t.Errorf("incorrect url: got %s want %s", got, want)
+	}
+
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	w.initializeReq(req, []byte("cpu value=1 0"), false)
+	if got := string(req.Header.Peek(headerAuthorization)); got != "Token secret" {
+		t.Errorf("incorrect Authorization header: got %s want Token secret", got)
+	}
+
+	w = NewHTTPWriter(testConf, testConsistency)
+	req.Reset()
+	w.initializeReq(req, []byte("cpu value=1 0"), false)
+	if got := string(req.Header.Peek(headerAuthorization)); got != "" {
+		t.Errorf("Authorization header is not empty without a token: got %s", got)
+	}
+}
+
+func TestHTTPWriterV2WriteLineProtocol(t *testing.T) {
+	body := "cpu,hostname=host_0 usage_user=58 1451606400000000000\n"
+	var tooMany int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		b, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.URL.Path != "/api/v2/write" || q.Get("org") != "tsbs" || q.Get("bucket") != "benchmark" || q.Get("precision") != "ns":
+			w.WriteHeader(http.StatusNotFound)
+		case r.Header.Get("Authorization") != "Token secret":
+			w.WriteHeader(http.StatusUnauthorized)
+		case string(b) != body:
+			w.WriteHeader(http.StatusBadRequest)
+		case atomic.AddInt64(&tooMany, 1) == 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	conf := HTTPWriterConfig{
+		Host:     server.URL,
+		Database: "benchmark",
+		Org:      "tsbs",
+		Token:    "secret",
+	}
+	w := NewHTTPWriter(conf, testConsistency)
+	if _, err := w.WriteLineProtocol([]byte(body), false); err != errBackoff {
+		t.Errorf("unexpected error response received (not backoff error): %v", err)
+	}
+	if _, err := w.WriteLineProtocol([]byte(body), false); err != nil {
+		t.Errorf("unexpected error received: %v", err)
+	}
+
+	conf.Token = "wrong"
+	w = NewHTTPWriter(conf, testConsistency)
+	if _, err := w.WriteLineProtocol([]byte(body), false); err == nil || err == errBackoff {
+		t.Errorf("unexpected error with a wrong token: %v", err)
+	}
+}
+
 func TestBackpressurePred(t *testing.T) {
 	cases := []struct {
 		body string
@@ -24,6 +24,9 @@ var (
 	useGzip           bool
 	doAbortOnExist    bool
 	consistency       string
+	apiVersion        int
+	org               string
+	token             string
 )
 
 // Global vars
@@ -52,12 +55,21 @@ func init() {
 	flag.StringVar(&consistency, "consistency", "all", "Write consistency. Must be one of: any, one, quorum, all.")
 	flag.DurationVar(&backoff, "backoff", time.Second, "Time to sleep between requests when server indicates backpressure is needed.")
 	flag.BoolVar(&useGzip, "gzip", true, "Whether to gzip encode requests (default true).")
+	flag.IntVar(&apiVersion, "api-version", 1, "HTTP API version (choices: 1, 2). With 2 the data is written into the bucket -db-name of the organization -org.")
+	flag.StringVar(&org, "org", "", "Organization of the bucket (only applies to API version 2).")
+	flag.StringVar(&token, "token", "", "Authentication token (only applies to API version 2).")
 
 	flag.Parse()
 
 	if _, ok := consistencyChoices[consistency]; !ok {
 		log.Fatalf("invalid consistency settings")
 	}
+	if apiVersion != 1 && apiVersion != 2 {
+		log.Fatalf("invalid API version: %d", apiVersion)
+	}
+	if apiVersion == 2 && len(org) == 0 {
+		log.Fatal("missing 'org' flag for API version 2")
+	}
 
 	daemonURLs = strings.Split(csvDaemonURLs, ",")
 	if len(daemonURLs) == 0 {
@@ -79,11 +91,18 @@ func (b *benchmark) GetPointIndexer(_ uint) load.PointIndexer {
 	return &load.ConstantIndexer{}
 }
 
+func (b *benchmark) GetTagExtractor() load.TagExtractor {
+	return &tagExtractor{}
+}
+
 func (b *benchmark) GetProcessor() load.Processor {
 	return &processor{}
 }
 
 func (b *benchmark) GetDBCreator() load.DBCreator {
+	if apiVersion == 2 {
+		return &bucketCreator{}
+	}
 	return &dbCreator{}
 }
 
@@ -26,6 +26,10 @@ func (p *processor) Init(numWorker int, _ bool) {
 		DebugInfo: fmt.Sprintf("worker #%d, dest url: %s", numWorker, daemonURL),
 		Host:      daemonURL,
 		Database:  loader.DatabaseName(),
+		Token:     token,
+	}
+	if apiVersion == 2 {
+		cfg.Org = org
 	}
 	w := NewHTTPWriter(cfg, consistency)
 	p.initWithHTTPWriter(numWorker, w)
@@ -43,6 +43,24 @@ func TestProcessorInit(t *testing.T) {
 		t.Errorf("incorrect host: got %s want %s", got, daemonURLs[0])
 	}
 
+	org = "tsbs"
+	p = &processor{}
+	p.Init(0, false)
+	p.Close(true)
+	if got := p.httpWriter.c.Org; got != "" {
+		t.Errorf("org set with API version 1: got %s", got)
+	}
+
+	apiVersion = 2
+	p = &processor{}
+	p.Init(0, false)
+	p.Close(true)
+	if got := p.httpWriter.c.Org; got != org {
+		t.Errorf("incorrect org: got %s want %s", got, org)
+	}
+	apiVersion = 1
+	org = ""
+
 }
 
 func TestProcessorInitWithHTTPWriterConfig(t *testing.T) {
@@ -27,6 +27,21 @@ func (d *decoder) Decode(_ *bufio.Reader) *load.Point {
 	return load.NewPoint(d.scanner.Bytes())
 }
 
+// tagExtractor looks up tags in the key (measurement and tags) of an influx line
+type tagExtractor struct{}
+
+func (e *tagExtractor) GetTagValue(item *load.Point, key string) (string, bool) {
+	line := item.Data.([]byte)
+	if idx := bytes.IndexByte(line, ' '); idx >= 0 {
+		line = line[:idx]
+	}
+	idx := bytes.IndexByte(line, ',')
+	if idx < 0 {
+		return "", false
+	}
+	return load.TagValueFromList(string(line[idx+1:]), key)
+}
+
 type batch struct {
 	buf     *bytes.Buffer
 	rows    uint64
@@ -103,3 +103,27 @@ func TestDecodeEOF(t *testing.T) {
 		t.Errorf("expected p to be nil, got %v", p)
 	}
 }
+
+func TestTagExtractor(t *testing.T) {
+	e := &tagExtractor{}
+	p := load.NewPoint([]byte("cpu,hostname=host_0,region=eu-west-1 usage_user=1.0,usage_system=2.0 140"))
+	cases := []struct {
+		key   string
+		want  string
+		found bool
+	}{
+		{key: "hostname", want: "host_0", found: true},
+		{key: "region", want: "eu-west-1", found: true},
+		{key: "usage_user", want: "", found: false},
+	}
+	for _, c := range cases {
+		got, found := e.GetTagValue(p, c.key)
+		if got != c.want || found != c.found {
+			t.Errorf("incorrect value for %s: got (%q, %v) want (%q, %v)", c.key, got, found, c.want, c.found)
+		}
+	}
+
+	if _, found := e.GetTagValue(load.NewPoint([]byte("cpu usage_user=1.0 140")), "hostname"); found {
+		t.Errorf("found tag in line without tags")
+	}
+}
@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"hash/fnv"
 	"log"
 	"sync"
 	"time"
@@ -13,24 +12,6 @@ import (
 	"github.com/timescale/tsbs/load"
 )
 
-type hostnameIndexer struct {
-	partitions uint
-}
-
-func (i *hostnameIndexer) GetIndex(item *load.Point) int {
-	p := item.Data.(*serialize.MongoPoint)
-	t := &serialize.MongoTag{}
-	for j := 0; j < p.TagsLength(); j++ {
-		p.Tags(t, j)
-		if string(t.Key()) == "hostname" {
-			h := fnv.New32a()
-			h.Write([]byte(string(t.Value())))
-			return int(h.Sum32()) % int(i.partitions)
-		}
-	}
-	return -1
-}
-
 // aggBenchmark allows you to run a benchmark using the aggregated document format
 // for Mongo
 type aggBenchmark struct {
@@ -49,7 +30,7 @@ func (b *aggBenchmark) GetProcessor() load.Processor {
 }
 
 func (b *aggBenchmark) GetPointIndexer(maxPartitions uint) load.PointIndexer {
-	return &hostnameIndexer{partitions: maxPartitions}
+	return load.NewHashIndexer(&tagExtractor{}, "hostname", maxPartitions)
 }
 
 // point is a reusable data structure to store a BSON data document for Mongo,
@@ -69,6 +69,21 @@ func (f *factory) New() load.Batch {
 	return &batch{arr: []*serialize.MongoPoint{}}
 }
 
+// tagExtractor looks up tags in the flatbuffer tags of a MongoPoint
+type tagExtractor struct{}
+
+func (e *tagExtractor) GetTagValue(item *load.Point, key string) (string, bool) {
+	p := item.Data.(*serialize.MongoPoint)
+	t := &serialize.MongoTag{}
+	for j := 0; j < p.TagsLength(); j++ {
+		p.Tags(t, j)
+		if string(t.Key()) == key {
+			return string(t.Value()), true
+		}
+	}
+	return "", false
+}
+
 type mongoBenchmark struct {
 	l   *load.BenchmarkRunner
 	dbc *dbCreator
@@ -85,3 +100,7 @@ func (b *mongoBenchmark) GetBatchFactory() load.BatchFactory {
 func (b *mongoBenchmark) GetDBCreator() load.DBCreator {
 	return b.dbc
 }
+
+func (b *mongoBenchmark) GetTagExtractor() load.TagExtractor {
+	return &tagExtractor{}
+}
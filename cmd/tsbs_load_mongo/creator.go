@@ -104,6 +104,20 @@ func (d *dbCreator) CreateDB(dbName string) error {
 	return nil
 }
 
+// DBSize returns the size on disk of the collections and indexes of the
+// database, as reported by dbStats
+func (d *dbCreator) DBSize(dbName string) (int64, error) {
+	var stats struct {
+		StorageSize int64 `bson:"storageSize"`
+		IndexSize   int64 `bson:"indexSize"`
+	}
+	err := d.session.DB(dbName).Run(bson.D{{"dbStats", 1}}, &stats)
+	if err != nil {
+		return 0, fmt.Errorf("dbStats err: %v", err)
+	}
+	return stats.StorageSize + stats.IndexSize, nil
+}
+
 func (d *dbCreator) Close() {
 	d.session.Close()
 }
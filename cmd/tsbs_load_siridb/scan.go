@@ -5,6 +5,7 @@ import (
 	"encoding/binary"
 	"io"
 	"log"
+	"strings"
 
 	"github.com/timescale/tsbs/load"
 )
@@ -14,6 +15,22 @@ type point struct {
 	dataCnt uint64
 }
 
+// tagExtractor looks up tags in the series names of a point, which are
+// formatted as <measurement>|<tags>|<field key>
+type tagExtractor struct{}
+
+func (e *tagExtractor) GetTagValue(item *load.Point, key string) (string, bool) {
+	for name := range item.Data.(*point).data {
+		start := strings.IndexByte(name, '|')
+		end := strings.LastIndexByte(name, '|')
+		if start < 0 || end <= start {
+			return "", false
+		}
+		return load.TagValueFromList(name[start+1:end], key)
+	}
+	return "", false
+}
+
 type batch struct {
 	series    map[string][]byte
 	batchCnt  int
@@ -46,3 +46,29 @@ func TestBatch(t *testing.T) {
 		t.Errorf("batch metric count is not 2 after first append")
 	}
 }
+
+func TestTagExtractor(t *testing.T) {
+	e := &tagExtractor{}
+	p := load.NewPoint(&point{
+		data: map[string][]byte{
+			"cpu|hostname=host_0,region=eu-west-1|usage_user":   nil,
+			"cpu|hostname=host_0,region=eu-west-1|usage_system": nil,
+		},
+		dataCnt: 2,
+	})
+	cases := []struct {
+		key   string
+		want  string
+		found bool
+	}{
+		{key: "hostname", want: "host_0", found: true},
+		{key: "region", want: "eu-west-1", found: true},
+		{key: "usage_user", want: "", found: false},
+	}
+	for _, c := range cases {
+		got, found := e.GetTagValue(p, c.key)
+		if got != c.want || found != c.found {
+			t.Errorf("incorrect value for %s: got (%q, %v) want (%q, %v)", c.key, got, found, c.want, c.found)
+		}
+	}
+}
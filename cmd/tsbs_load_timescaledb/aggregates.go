@@ -0,0 +1,67 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/timescale/tsbs/internal/utils"
+)
+
+// getContinuousAggregateSQL returns the statement creating the continuous
+// aggregate of the table with buckets of the given width. It keeps the number
+// of rows, and the max and sum of each of the columns, per host and bucket,
+// so that maxes and means of any multiple of the bucket width can be derived.
+func getContinuousAggregateSQL(tableName string, columns []string, bucket time.Duration) string {
+	groupCols := []string{"bucket", "tags_id"}
+	if inTableTag {
+		groupCols = append(groupCols, tableCols[tagsKey][0])
+	}
+
+	aggDefs := []string{"count(*) AS num_rows"}
+	for _, column := range columns {
+		if len(column) == 0 {
+			continue
+		}
+		aggDefs = append(aggDefs, fmt.Sprintf("max(%[1]s) AS max_%[1]s, sum(%[1]s) AS sum_%[1]s", column))
+	}
+
+	return fmt.Sprintf("CREATE MATERIALIZED VIEW %s WITH (timescaledb.continuous) AS "+
+		"SELECT time_bucket('%d seconds', time) AS bucket, %s, %s FROM %s GROUP BY %s WITH NO DATA",
+		utils.ContinuousAggregateName(tableName, bucket),
+		int64(bucket.Seconds()),
+		strings.Join(groupCols[1:], ", "),
+		strings.Join(aggDefs, ", "),
+		tableName,
+		strings.Join(groupCols, ", "))
+}
+
+// dropContinuousAggregates drops the continuous aggregates of the table, which
+// would otherwise keep it from being dropped
+func dropContinuousAggregates(db *sql.DB, tableName string) {
+	for _, bucket := range aggBuckets {
+		MustExec(db, fmt.Sprintf("DROP MATERIALIZED VIEW IF EXISTS %s", utils.ContinuousAggregateName(tableName, bucket)))
+	}
+}
+
+// createContinuousAggregates creates the continuous aggregates of the table,
+// which are only filled once the data is loaded
+func createContinuousAggregates(db *sql.DB, tableName string, columns []string) {
+	for _, bucket := range aggBuckets {
+		MustExec(db, getContinuousAggregateSQL(tableName, columns, bucket))
+	}
+}
+
+// refreshContinuousAggregates materializes the continuous aggregates of all
+// the tables over all of their data
+func refreshContinuousAggregates(db *sql.DB, tableNames []string) {
+	for _, tableName := range tableNames {
+		for _, bucket := range aggBuckets {
+			name := utils.ContinuousAggregateName(tableName, bucket)
+			start := time.Now()
+			MustExec(db, fmt.Sprintf("CALL refresh_continuous_aggregate('%s', NULL, NULL)", name))
+			fmt.Printf("refreshed continuous aggregate %s in %0.3fsec\n", name, time.Since(start).Seconds())
+		}
+	}
+}
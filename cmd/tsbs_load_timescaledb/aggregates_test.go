@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetContinuousAggregateSQL(t *testing.T) {
+	oldInTableTag := inTableTag
+	defer func() {
+		inTableTag = oldInTableTag
+	}()
+	tableCols[tagsKey] = []string{"hostname", "region"}
+
+	cases := []struct {
+		desc       string
+		inTableTag bool
+		want       string
+	}{
+		{
+			desc: "tags table",
+			want: "CREATE MATERIALIZED VIEW cpu_60s WITH (timescaledb.continuous) AS " +
+				"SELECT time_bucket('60 seconds', time) AS bucket, tags_id, count(*) AS num_rows, " +
+				"max(usage_user) AS max_usage_user, sum(usage_user) AS sum_usage_user, max(usage_system) AS max_usage_system, sum(usage_system) AS sum_usage_system " +
+				"FROM cpu GROUP BY bucket, tags_id WITH NO DATA",
+		},
+		{
+			desc:       "in table tag",
+			inTableTag: true,
+			want: "CREATE MATERIALIZED VIEW cpu_60s WITH (timescaledb.continuous) AS " +
+				"SELECT time_bucket('60 seconds', time) AS bucket, tags_id, hostname, count(*) AS num_rows, " +
+				"max(usage_user) AS max_usage_user, sum(usage_user) AS sum_usage_user, max(usage_system) AS max_usage_system, sum(usage_system) AS sum_usage_system " +
+				"FROM cpu GROUP BY bucket, tags_id, hostname WITH NO DATA",
+		},
+	}
+	for _, c := range cases {
+		inTableTag = c.inTableTag
+		got := getContinuousAggregateSQL("cpu", []string{"usage_user", "", "usage_system"}, time.Minute)
+		if got != c.want {
+			t.Errorf("%s: incorrect statement: got\n%s\nwant\n%s", c.desc, got, c.want)
+		}
+	}
+
+}
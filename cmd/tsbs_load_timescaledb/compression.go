@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// getCompressionSQL returns the statements enabling native compression on the
+// hypertable, and adding a compression policy if set
+func getCompressionSQL(tableName string) []string {
+	ret := []string{
+		fmt.Sprintf("ALTER TABLE %s SET (timescaledb.compress, timescaledb.compress_segmentby = '%s', timescaledb.compress_orderby = '%s')",
+			tableName, compressSegmentBy, compressOrderBy),
+	}
+	if compressionPolicy > 0 {
+		ret = append(ret, fmt.Sprintf("SELECT add_compression_policy('%s', INTERVAL '%d seconds')",
+			tableName, int64(compressionPolicy.Seconds())))
+	}
+	return ret
+}
+
+// compressTable compresses all the chunks of the hypertable that are not
+// compressed yet, returning how many chunks it compressed and the sizes in
+// bytes of its compressed chunks before and after compression
+func compressTable(db *sql.DB, tableName string) (chunks, before, after int64) {
+	r := db.QueryRow(fmt.Sprintf("SELECT count(compress_chunk(c, if_not_compressed => true)) FROM show_chunks('%s') c", tableName))
+	if err := r.Scan(&chunks); err != nil {
+		panic(err)
+	}
+
+	var beforeBytes, afterBytes sql.NullInt64
+	r = db.QueryRow(fmt.Sprintf("SELECT sum(before_compression_total_bytes)::bigint, sum(after_compression_total_bytes)::bigint FROM hypertable_compression_stats('%s')", tableName))
+	if err := r.Scan(&beforeBytes, &afterBytes); err != nil {
+		panic(err)
+	}
+	return chunks, beforeBytes.Int64, afterBytes.Int64
+}
+
+// compressionSummary formats the sizes of a table, or all tables, before and
+// after compression
+func compressionSummary(name string, chunks, before, after int64, took time.Duration) string {
+	ratio := 0.0
+	if after > 0 {
+		ratio = float64(before) / float64(after)
+	}
+	return fmt.Sprintf("compressed %d chunks of %s in %0.3fsec: %d bytes uncompressed, %d bytes compressed (ratio %0.2f)\n",
+		chunks, name, took.Seconds(), before, after, ratio)
+}
+
+// compressTables compresses all the hypertables, printing the sizes of each
+// of them and in total before and after compression
+func compressTables(db *sql.DB, tableNames []string) {
+	var totalChunks, totalBefore, totalAfter int64
+	start := time.Now()
+	for _, tableName := range tableNames {
+		tableStart := time.Now()
+		chunks, before, after := compressTable(db, tableName)
+		fmt.Print(compressionSummary(tableName, chunks, before, after, time.Since(tableStart)))
+		totalChunks += chunks
+		totalBefore += before
+		totalAfter += after
+	}
+	fmt.Print(compressionSummary("all hypertables", totalChunks, totalBefore, totalAfter, time.Since(start)))
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetCompressionSQL(t *testing.T) {
+	oldSegmentBy, oldOrderBy, oldPolicy := compressSegmentBy, compressOrderBy, compressionPolicy
+	defer func() {
+		compressSegmentBy, compressOrderBy, compressionPolicy = oldSegmentBy, oldOrderBy, oldPolicy
+	}()
+	cases := []struct {
+		desc      string
+		segmentBy string
+		orderBy   string
+		policy    time.Duration
+		want      []string
+	}{
+		{
+			desc:      "no policy",
+			segmentBy: "tags_id",
+			orderBy:   "time DESC",
+			want: []string{
+				"ALTER TABLE cpu SET (timescaledb.compress, timescaledb.compress_segmentby = 'tags_id', timescaledb.compress_orderby = 'time DESC')",
+			},
+		},
+		{
+			desc:      "policy",
+			segmentBy: "tags_id, hostname",
+			orderBy:   "time",
+			policy:    7 * 24 * time.Hour,
+			want: []string{
+				"ALTER TABLE cpu SET (timescaledb.compress, timescaledb.compress_segmentby = 'tags_id, hostname', timescaledb.compress_orderby = 'time')",
+				"SELECT add_compression_policy('cpu', INTERVAL '604800 seconds')",
+			},
+		},
+	}
+	for _, c := range cases {
+		compressSegmentBy, compressOrderBy, compressionPolicy = c.segmentBy, c.orderBy, c.policy
+		got := getCompressionSQL("cpu")
+		if len(got) != len(c.want) {
+			t.Errorf("%s: incorrect number of statements: got %d want %d", c.desc, len(got), len(c.want))
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: incorrect statement %d: got\n%s\nwant\n%s", c.desc, i, got[i], c.want[i])
+			}
+		}
+	}
+}
+
+func TestCompressionSummary(t *testing.T) {
+	cases := []struct {
+		desc   string
+		chunks int64
+		before int64
+		after  int64
+		want   string
+	}{
+		{
+			desc:   "compressed",
+			chunks: 3,
+			before: 1000,
+			after:  100,
+			want:   "compressed 3 chunks of cpu in 1.500sec: 1000 bytes uncompressed, 100 bytes compressed (ratio 10.00)\n",
+		},
+		{
+			desc: "nothing compressed",
+			want: "compressed 0 chunks of cpu in 1.500sec: 0 bytes uncompressed, 0 bytes compressed (ratio 0.00)\n",
+		},
+	}
+	for _, c := range cases {
+		if got := compressionSummary("cpu", c.chunks, c.before, c.after, 1500*time.Millisecond); got != c.want {
+			t.Errorf("%s: incorrect summary: got\n%s\nwant\n%s", c.desc, got, c.want)
+		}
+	}
+}
@@ -131,11 +131,16 @@ func (d *dbCreator) PostCreateDB(dbName string) error {
 	if tags[0] != tagsKey {
 		return fmt.Errorf("input header in wrong format. got '%s', expected 'tags'", tags[0])
 	}
-	if createMetricsTable {
+	// When resuming a previous run the tables are reused as they are
+	createTables := createMetricsTable && !loader.Resuming()
+	if createTables {
 		createTagsTable(dbBench, tags[1:])
 	}
 	// tableCols is a global map. Globally cache the available tags
 	tableCols[tagsKey] = tags[1:]
+	if loader.Resuming() {
+		loadExistingTags(dbBench)
+	}
 
 	// Each table is defined in the dbCreator 'cols' list. The definition consists of a
 	// comma separated list of the table name followed by its columns. Iterate over each
@@ -147,13 +152,67 @@ func (d *dbCreator) PostCreateDB(dbName string) error {
 		tableCols[tableName] = columns[1:]
 
 		fieldDefs, indexDefs := d.getFieldAndIndexDefinitions(columns)
-		if createMetricsTable {
+		if createTables {
+			dropContinuousAggregates(dbBench, tableName)
 			d.createTableAndIndexes(dbBench, tableName, fieldDefs, indexDefs)
+			createContinuousAggregates(dbBench, tableName, columns[1:])
 		}
 	}
 	return nil
 }
 
+// PostLoadDB refreshes the continuous aggregates and compresses the
+// hypertables once the data is loaded, if set up to
+func (d *dbCreator) PostLoadDB(dbName string) error {
+	if len(aggBuckets) == 0 && !compressAfterLoad {
+		return nil
+	}
+	dbBench := MustConnect(driver, getConnectString())
+	defer dbBench.Close()
+
+	tableNames := d.tableNames()
+	refreshContinuousAggregates(dbBench, tableNames)
+	if compressAfterLoad {
+		compressTables(dbBench, tableNames)
+	}
+	return nil
+}
+
+// DBSize returns the total size of the metrics tables, including their
+// indexes, and of the tags table
+func (d *dbCreator) DBSize(dbName string) (int64, error) {
+	dbBench := MustConnect(driver, getConnectString())
+	defer dbBench.Close()
+
+	sizeFn := "pg_total_relation_size"
+	if useHypertable {
+		// includes the chunks, compressed or not
+		sizeFn = "hypertable_size"
+	}
+	var total int64
+	for _, tableName := range d.tableNames() {
+		var size int64
+		if err := dbBench.QueryRow(fmt.Sprintf("SELECT %s('%s')", sizeFn, tableName)).Scan(&size); err != nil {
+			return 0, err
+		}
+		total += size
+	}
+	var tagsSize int64
+	if err := dbBench.QueryRow("SELECT pg_total_relation_size('tags')").Scan(&tagsSize); err != nil {
+		return 0, err
+	}
+	return total + tagsSize, nil
+}
+
+// tableNames returns the names of the metrics tables in the header
+func (d *dbCreator) tableNames() []string {
+	tableNames := make([]string, 0, len(d.cols))
+	for _, tableDef := range d.cols {
+		tableNames = append(tableNames, strings.Split(strings.TrimSpace(tableDef), ",")[0])
+	}
+	return tableNames
+}
+
 // getFieldAndIndexDefinitions iterates over a list of table columns, populating lists of
 // definitions for each desired field and index. Returns separate lists of fieldDefs and indexDefs
 func (d *dbCreator) getFieldAndIndexDefinitions(columns []string) ([]string, []string) {
@@ -223,6 +282,11 @@ func (d *dbCreator) createTableAndIndexes(dbBench *sql.DB, tableName string, fie
 		MustExec(dbBench,
 			fmt.Sprintf("SELECT create_hypertable('%s'::regclass, 'time'::name, partitioning_column => '%s'::name, number_partitions => %v::smallint, chunk_time_interval => %d, create_default_indexes=>FALSE)",
 				tableName, "tags_id", numberPartitions, chunkTime.Nanoseconds()/1000))
+		if useCompression {
+			for _, compressionDef := range getCompressionSQL(tableName) {
+				MustExec(dbBench, compressionDef)
+			}
+		}
 	}
 }
 
@@ -261,3 +325,16 @@ func createTagsTable(db *sql.DB, tags []string) {
 		MustExec(db, fmt.Sprintf("CREATE INDEX ON tags(%s)", tags[0]))
 	}
 }
+
+// loadExistingTags fills the global tags cache with the tags already stored in
+// the DB, so that rows referring to them get the existing tags_id instead of
+// trying (and failing) to insert them again
+func loadExistingTags(db *sql.DB) {
+	res := MustQuery(db, "SELECT * FROM tags")
+	m := tagsMapFromRows(res)
+	globalSyncCSI.mutex.Lock()
+	for k, v := range m {
+		globalSyncCSI.m[k] = v
+	}
+	globalSyncCSI.mutex.Unlock()
+}
@@ -13,6 +13,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/load"
 )
 
@@ -48,11 +49,18 @@ var (
 	fieldIndex         string
 	fieldIndexCount    int
 
-	profileFile          string
 	replicationStatsFile string
 
 	createMetricsTable bool
 	forceTextFormat    bool
+
+	useCompression       bool
+	compressSegmentBy    string
+	compressOrderBy      string
+	compressionPolicy    time.Duration
+	compressAfterLoad    bool
+	continuousAggregates string
+	aggBuckets           []time.Duration
 )
 
 type insertData struct {
@@ -96,13 +104,31 @@ func init() {
 	flag.StringVar(&fieldIndex, "field-index", valueTimeIdx, "index types for tags (comma deliminated)")
 	flag.IntVar(&fieldIndexCount, "field-index-count", 0, "Number of indexed fields (-1 for all)")
 
-	flag.StringVar(&profileFile, "write-profile", "", "File to output CPU/memory profile to")
 	flag.StringVar(&replicationStatsFile, "write-replication-stats", "", "File to output replication stats to")
 	flag.BoolVar(&createMetricsTable, "create-metrics-table", true, "Drops existing and creates new metrics table. Can be used for both regular and hypertable")
 
 	flag.BoolVar(&forceTextFormat, "force-text-format", false, "Send/receive data in text format")
 
+	flag.BoolVar(&useCompression, "use-compression", false, "Whether to enable native compression on the hypertables")
+	flag.StringVar(&compressSegmentBy, "compress-segmentby", "tags_id", "Columns to segment compressed hypertables by (timescaledb.compress_segmentby)")
+	flag.StringVar(&compressOrderBy, "compress-orderby", "time DESC", "Order of the rows of compressed hypertables (timescaledb.compress_orderby)")
+	flag.DurationVar(&compressionPolicy, "compression-policy", 0, "Age of the chunks a background compression policy compresses, e.g. 168h (0 = no policy). Requires --use-compression")
+	flag.BoolVar(&compressAfterLoad, "compress-after-load", false, "Whether to compress all chunks once the data is loaded, reporting the uncompressed and compressed sizes. Requires --use-compression")
+	flag.StringVar(&continuousAggregates, "continuous-aggregates", "", "Comma-separated bucket widths of continuous aggregates to create of each hypertable and refresh once the data is loaded, e.g. 1m,1h")
+
 	flag.Parse()
+
+	if (compressionPolicy > 0 || compressAfterLoad) && !useCompression {
+		fatal("--compression-policy and --compress-after-load require --use-compression")
+	}
+	var err error
+	aggBuckets, err = utils.ParseAggregateBuckets(continuousAggregates)
+	if err != nil {
+		fatal("invalid --continuous-aggregates: %v", err)
+	}
+	if (useCompression || len(aggBuckets) > 0) && !useHypertable {
+		fatal("--use-compression and --continuous-aggregates require --use-hypertable")
+	}
 }
 
 type benchmark struct{}
@@ -117,11 +143,15 @@ func (b *benchmark) GetBatchFactory() load.BatchFactory {
 
 func (b *benchmark) GetPointIndexer(maxPartitions uint) load.PointIndexer {
 	if hashWorkers {
-		return &hostnameIndexer{partitions: maxPartitions}
+		return load.NewHashIndexer(&tagExtractor{}, hostnameTag, maxPartitions)
 	}
 	return &load.ConstantIndexer{}
 }
 
+func (b *benchmark) GetTagExtractor() load.TagExtractor {
+	return &tagExtractor{}
+}
+
 func (b *benchmark) GetProcessor() load.Processor {
 	return &processor{}
 }
@@ -140,11 +170,6 @@ func main() {
 	} else {
 		driver = pgxDriver
 	}
-	// If specified, generate a performance profile
-	if len(profileFile) > 0 {
-		go profileCPUAndMem(profileFile)
-	}
-
 	var replicationStatsWaitGroup sync.WaitGroup
 	if len(replicationStatsFile) > 0 {
 		go OutputReplicationStats(getConnectString(), replicationStatsFile, &replicationStatsWaitGroup)
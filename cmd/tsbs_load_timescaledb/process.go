@@ -79,33 +79,41 @@ func insertTags(db *sql.DB, tagRows [][]string, returnResults bool) map[string]i
 
 	// Results will be used to make a Golang index for faster inserts
 	if returnResults {
-		resCols, _ := res.Columns()
-		resVals := make([]interface{}, len(resCols))
-		resValsPtrs := make([]interface{}, len(resCols))
-		for i := range resVals {
-			resValsPtrs[i] = &resVals[i]
+		return tagsMapFromRows(res)
+	}
+	return nil
+}
+
+// tagsMapFromRows reads rows of the tags table (id first, followed by either
+// the tag columns or the JSONB tagset) and maps the partitioning tag (e.g.
+// hostname) to its id. The rows are closed afterwards.
+func tagsMapFromRows(res *sql.Rows) map[string]int64 {
+	defer res.Close()
+	tagCols := tableCols[tagsKey]
+	resCols, _ := res.Columns()
+	resVals := make([]interface{}, len(resCols))
+	resValsPtrs := make([]interface{}, len(resCols))
+	for i := range resVals {
+		resValsPtrs[i] = &resVals[i]
+	}
+	ret := make(map[string]int64)
+	for res.Next() {
+		err := res.Scan(resValsPtrs...)
+		if err != nil {
+			panic(err)
 		}
-		ret := make(map[string]int64)
-		for res.Next() {
-			err = res.Scan(resValsPtrs...)
-			if err != nil {
-				panic(err)
-			}
 
-			var key string
-			if useJSON {
-				decodedTagset := map[string]string{}
-				json.Unmarshal(resVals[1].([]byte), &decodedTagset)
-				key = decodedTagset[tagCols[0]]
-			} else {
-				key = fmt.Sprintf("%v", resVals[1])
-			}
-			ret[key] = resVals[0].(int64)
+		var key string
+		if useJSON {
+			decodedTagset := map[string]string{}
+			json.Unmarshal(resVals[1].([]byte), &decodedTagset)
+			key = decodedTagset[tagCols[0]]
+		} else {
+			key = fmt.Sprintf("%v", resVals[1])
 		}
-		res.Close()
-		return ret
+		ret[key] = resVals[0].(int64)
 	}
-	return nil
+	return ret
 }
 
 // splitTagsAndMetrics takes an array of insertData (sharded by hypertable) and
@@ -251,7 +259,13 @@ func (p *processor) Init(workerNum int, doLoad bool) {
 	if doLoad {
 		p.db = MustConnect(driver, getConnectString())
 		if hashWorkers {
+			// Start from the tags already in the DB, if any (e.g., when resuming)
 			p.csi = newSyncCSI()
+			globalSyncCSI.mutex.RLock()
+			for k, v := range globalSyncCSI.m {
+				p.csi.m[k] = v
+			}
+			globalSyncCSI.mutex.RUnlock()
 		} else {
 			p.csi = globalSyncCSI
 		}
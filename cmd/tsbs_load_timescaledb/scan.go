@@ -2,23 +2,20 @@ package main
 
 import (
 	"bufio"
-	"hash/fnv"
 	"strings"
 
 	"github.com/timescale/tsbs/load"
 )
 
-// hostnameIndexer is used to consistently send the same hostnames to the same worker
-type hostnameIndexer struct {
-	partitions uint
-}
+// hostnameTag is the tag that -hash-workers partitions on
+const hostnameTag = "hostname"
+
+// tagExtractor looks up tags in the comma-separated tags of a point's row
+type tagExtractor struct{}
 
-func (i *hostnameIndexer) GetIndex(item *load.Point) int {
-	p := item.Data.(*point)
-	hostname := strings.SplitN(p.row.tags, ",", 2)[0]
-	h := fnv.New32a()
-	h.Write([]byte(hostname))
-	return int(h.Sum32()) % int(i.partitions)
+// load.TagExtractor interface implementation
+func (e *tagExtractor) GetTagValue(item *load.Point, key string) (string, bool) {
+	return load.TagValueFromList(item.Data.(*point).row.tags, key)
 }
 
 // point is a single row of data keyed by which hypertable it belongs
@@ -10,56 +10,28 @@ import (
 	"github.com/timescale/tsbs/load"
 )
 
-func TestHostnameIndexer(t *testing.T) {
-	tagRows := make([]string, 1000, 1000)
-	for i := range tagRows {
-		tagRows[i] = fmt.Sprintf("host%d,foo", i)
-	}
+func TestTagExtractor(t *testing.T) {
 	p := &point{
 		hypertable: "foo",
-		row:        &insertData{fields: "0.0,1.0,2.0"},
+		row: &insertData{
+			tags:   "hostname=host_0,region=eu-west-1",
+			fields: "0.0,1.0,2.0",
+		},
 	}
-
-	// single partition check
-	indexer := &hostnameIndexer{1}
-	for _, r := range tagRows {
-		p.row.tags = r
-		idx := indexer.GetIndex(load.NewPoint(p))
-		if idx != 0 {
-			t.Errorf("did not get idx 0 for single partition")
-		}
+	e := &tagExtractor{}
+	cases := []struct {
+		key   string
+		want  string
+		found bool
+	}{
+		{key: hostnameTag, want: "host_0", found: true},
+		{key: "region", want: "eu-west-1", found: true},
+		{key: "rack", want: "", found: false},
 	}
-
-	// multiple partition check
-	cases := []uint{2, 10, 100}
-	for _, n := range cases {
-		parts := uint(n)
-		indexer = &hostnameIndexer{parts}
-		counts := make([]int, parts, parts)
-		verifier := make(map[string]int)
-		for _, r := range tagRows {
-			p.row.tags = r
-			idx := indexer.GetIndex(load.NewPoint(p))
-			// check that the partition is not out of bounds
-			if idx >= int(parts) {
-				t.Errorf("got too large a partition: got %d want %d", idx, parts)
-			}
-			counts[idx]++
-			verifier[r] = idx
-		}
-		// with 1000 items, very unlikely some partition is empty
-		for _, c := range counts {
-			if c == 0 {
-				t.Errorf("unlikely result of 0 results in a partition for %d partitions", parts)
-			}
-		}
-		// now rerun to verify same tag goes to same idx
-		for _, r := range tagRows {
-			p.row.tags = r
-			idx := indexer.GetIndex(load.NewPoint(p))
-			if idx != verifier[r] {
-				t.Errorf("indexer returned a different result on %d partitions: got %d want %d", parts, idx, verifier[r])
-			}
+	for _, c := range cases {
+		got, found := e.GetTagValue(load.NewPoint(p), c.key)
+		if got != c.want || found != c.found {
+			t.Errorf("incorrect value for %s: got (%q, %v) want (%q, %v)", c.key, got, found, c.want, c.found)
 		}
 	}
 }
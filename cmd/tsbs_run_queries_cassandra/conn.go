@@ -2,22 +2,44 @@ package main
 
 import (
 	"log"
+	"strings"
 	"time"
 
 	"github.com/gocql/gocql"
 )
 
 // NewCassandraSession creates a new Cassandra session. It is goroutine-safe
-// by default, and uses a connection pool.
+// by default, and uses a connection pool. daemonURL may list several
+// comma-separated hosts to discover the cluster from.
 func NewCassandraSession(daemonURL, keyspace string, timeout time.Duration) *gocql.Session {
-	cluster := gocql.NewCluster(daemonURL)
+	cluster := gocql.NewCluster(strings.Split(daemonURL, ",")...)
 	cluster.Keyspace = keyspace
 	cluster.Consistency = gocql.One
 	cluster.ProtoVersion = 4
 	cluster.Timeout = timeout
+	// policies keep per-session state, so every session needs its own
+	cluster.PoolConfig.HostSelectionPolicy = newHostSelectionPolicy(tokenAware, localDC)
 	session, err := cluster.CreateSession()
 	if err != nil {
 		log.Fatal(err)
 	}
 	return session
 }
+
+// newHostSelectionPolicy returns the policy choosing the hosts CQL queries are
+// sent to: round-robin over the hosts of localDC, or of all datacenters if it
+// is empty, preferring the replicas of the partition a query reads if
+// tokenAware is set. Token-aware routing needs the partition key of a query
+// to be bound as an argument, which all the CQL queries of a plan do.
+func newHostSelectionPolicy(tokenAware bool, localDC string) gocql.HostSelectionPolicy {
+	var policy gocql.HostSelectionPolicy
+	if len(localDC) > 0 {
+		policy = gocql.DCAwareRoundRobinPolicy(localDC)
+	} else {
+		policy = gocql.RoundRobinHostPolicy()
+	}
+	if tokenAware {
+		policy = gocql.TokenAwareHostPolicy(policy)
+	}
+	return policy
+}
@@ -8,6 +8,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"time"
@@ -38,6 +39,10 @@ var (
 	aggrPlanLabel  string
 	requestTimeout time.Duration
 	csiTimeout     time.Duration
+
+	subQueryParallelism int
+	tokenAware          bool
+	localDC             string
 )
 
 // Helpers for choice-like flags:
@@ -60,21 +65,27 @@ var (
 func init() {
 	runner = query.NewBenchmarkRunner()
 
-	flag.StringVar(&daemonURL, "host", "localhost:9042", "Cassandra hostname and port combination.")
+	flag.StringVar(&daemonURL, "host", "localhost:9042", "Cassandra hostname and port combination, or several comma-separated ones.")
 	flag.StringVar(&aggrPlanLabel, "aggregation-plan", "", "Aggregation plan (choices: server, client)")
 	flag.DurationVar(&requestTimeout, "read-timeout", 1*time.Second, "Maximum request timeout.")
 	flag.DurationVar(&csiTimeout, "client-side-index-timeout", 10*time.Second, "Maximum client-side index timeout (only used at initialization).")
+	flag.IntVar(&subQueryParallelism, "subquery-parallelism", 1, "Maximum number of CQL queries of a query run at the same time with the server aggregation plan (1 = one after the other).")
+	flag.BoolVar(&tokenAware, "token-aware", false, "Whether to route each CQL query to a replica of the partition it reads.")
+	flag.StringVar(&localDC, "local-dc", "", "Datacenter to route CQL queries to, only falling back to hosts of other datacenters when none is up (empty = all datacenters).")
 
 	flag.Parse()
+}
 
+func main() {
 	if _, ok := aggrPlanChoices[aggrPlanLabel]; !ok {
 		log.Fatal("invalid aggregation plan")
 	}
 	aggrPlan = aggrPlanChoices[aggrPlanLabel]
 
-}
+	if subQueryParallelism < 1 {
+		log.Fatal("invalid subquery parallelism")
+	}
 
-func main() {
 	// Make client-side index:
 	session = NewCassandraSession(daemonURL, runner.DatabaseName(), csiTimeout)
 	csi = NewClientSideIndex(FetchSeriesCollection(session))
@@ -97,13 +108,14 @@ func newProcessor() query.Processor { return &processor{} }
 func (p *processor) Init(workerNumber int) {
 	p.opts = &HLQueryExecutorDoOptions{
 		AggregationPlan:      aggrPlan,
+		SubQueryParallelism:  subQueryParallelism,
 		Debug:                runner.DebugLevel(),
 		PrettyPrintResponses: runner.DoPrintResponses(),
 	}
 	p.qe = NewHLQueryExecutor(session, csi, runner.DebugLevel())
 }
 
-func (p *processor) ProcessQuery(q query.Query, isWarm bool) ([]*query.Stat, error) {
+func (p *processor) ProcessQuery(ctx context.Context, q query.Query, isWarm bool) ([]*query.Stat, error) {
 	cq := q.(*query.Cassandra)
 	hlq := &HLQuery{*cq}
 	hlq.ForceUTC()
@@ -117,7 +129,7 @@ func (p *processor) ProcessQuery(q query.Query, isWarm bool) ([]*query.Stat, err
 			labels[i] = append(l, " (warm)"...)
 		}
 	}
-	qpLagMs, reqLagMs, err := p.qe.Do(hlq, *p.opts)
+	qpLagMs, reqLagMs, err := p.qe.Do(ctx, hlq, *p.opts)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"time"
@@ -34,7 +35,7 @@ func NewHLQueryExecutor(session *gocql.Session, csi *ClientSideIndex, debug int)
 // HLQueryExecutorDoOptions contains options used by HLQueryExecutor.
 type HLQueryExecutorDoOptions struct {
 	AggregationPlan      int
-	SubQueryParallelism  int // unused
+	SubQueryParallelism  int // CQL queries of a server aggregation plan run at the same time
 	Debug                int
 	PrettyPrintResponses bool
 }
@@ -42,7 +43,7 @@ type HLQueryExecutorDoOptions struct {
 // Do takes a high-level query, constructs a query plan using the client-side
 // index contained within the query executor, executes that query plan, then
 // aggregates the results.
-func (qe *HLQueryExecutor) Do(q *HLQuery, opts HLQueryExecutorDoOptions) (qpLagMs, requestLagMs float64, err error) {
+func (qe *HLQueryExecutor) Do(ctx context.Context, q *HLQuery, opts HLQueryExecutorDoOptions) (qpLagMs, requestLagMs float64, err error) {
 	if opts.Debug >= 1 {
 		fmt.Printf("[hlqe] Do: %s\n", q)
 	}
@@ -57,7 +58,12 @@ func (qe *HLQueryExecutor) Do(q *HLQuery, opts HLQueryExecutorDoOptions) (qpLagM
 	} else {
 		switch opts.AggregationPlan {
 		case AggrPlanTypeWithServerAggregation:
-			qp, err = q.ToQueryPlanWithServerAggregation(qe.csi)
+			var sqp *QueryPlanWithServerAggregation
+			sqp, err = q.ToQueryPlanWithServerAggregation(qe.csi)
+			if sqp != nil {
+				sqp.Parallelism = opts.SubQueryParallelism
+			}
+			qp = sqp
 		case AggrPlanTypeWithoutServerAggregation:
 			qp, err = q.ToQueryPlanWithoutServerAggregation(qe.csi)
 		default:
@@ -81,7 +87,7 @@ func (qe *HLQueryExecutor) Do(q *HLQuery, opts HLQueryExecutorDoOptions) (qpLagM
 	// execute the query plan:
 	var results []CQLResult
 	execStart := time.Now()
-	results, err = qp.Execute(qe.session)
+	results, err = qp.Execute(ctx, qe.session)
 	requestLagMs = float64(time.Now().Sub(execStart).Nanoseconds()) / 1e6
 	if err != nil {
 		return
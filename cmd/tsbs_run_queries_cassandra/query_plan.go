@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"sort"
@@ -14,7 +15,7 @@ import (
 
 // A QueryPlan is a strategy used to fulfill an HLQuery.
 type QueryPlan interface {
-	Execute(*gocql.Session) ([]CQLResult, error)
+	Execute(context.Context, *gocql.Session) ([]CQLResult, error)
 	DebugQueries(int)
 }
 
@@ -28,6 +29,10 @@ type QueryPlan interface {
 type QueryPlanWithServerAggregation struct {
 	AggregatorLabel    string
 	BucketedCQLQueries map[*utils.TimeInterval][]CQLQuery
+
+	// Parallelism is the maximum number of CQLQueries executed at the same
+	// time. Values below 2 execute them one after the other.
+	Parallelism int
 }
 
 // NewQueryPlanWithServerAggregation builds a QueryPlanWithServerAggregation.
@@ -41,9 +46,27 @@ func NewQueryPlanWithServerAggregation(aggrLabel string, bucketedCQLQueries map[
 }
 
 // Execute runs all CQLQueries in the QueryPlan and collects the results.
-//
-// TODO(rw): support parallel execution.
-func (qp *QueryPlanWithServerAggregation) Execute(session *gocql.Session) ([]CQLResult, error) {
+// Up to Parallelism queries are executed at the same time.
+func (qp *QueryPlanWithServerAggregation) Execute(ctx context.Context, session *gocql.Session) ([]CQLResult, error) {
+	return qp.execute(func(q CQLQuery) ([]float64, error) {
+		// Execute one CQLQuery and collect its result
+		//
+		// For server-side aggregation, this will return only
+		// one row; for exclusive client-side aggregation this
+		// will return a sequence.
+		iter := session.Query(q.PreparableQueryString, q.Args...).WithContext(ctx).Iter()
+		var values []float64
+		var x float64
+		for iter.Scan(&x) {
+			values = append(values, x)
+		}
+		return values, iter.Close()
+	})
+}
+
+// execute runs all CQLQueries in the QueryPlan with run, which returns the
+// values one of them selects, and aggregates them per bucket.
+func (qp *QueryPlanWithServerAggregation) execute(run func(CQLQuery) ([]float64, error)) ([]CQLResult, error) {
 	// sort the time interval buckets we'll use:
 	sortedKeys := make([]*utils.TimeInterval, 0, len(qp.BucketedCQLQueries))
 	for k := range qp.BucketedCQLQueries {
@@ -51,29 +74,38 @@ func (qp *QueryPlanWithServerAggregation) Execute(session *gocql.Session) ([]CQL
 	}
 	sort.Sort(TimeIntervals(sortedKeys))
 
-	// for each bucket, execute its queries while aggregating its results
-	// in constant space, then append them to the result set:
+	// execute the queries of all buckets, keeping the values each one
+	// returns so that they are aggregated in the same order regardless of
+	// the order the queries finish in:
+	queries := make([]CQLQuery, 0, len(qp.BucketedCQLQueries))
+	for _, k := range sortedKeys {
+		queries = append(queries, qp.BucketedCQLQueries[k]...)
+	}
+	values := make([][]float64, len(queries))
+	err := utils.RunBounded(len(queries), qp.Parallelism, func(i int) error {
+		var err error
+		values[i], err = run(queries[i])
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// for each bucket, aggregate the results of its queries in constant
+	// space, then append them to the result set:
 	results := make([]CQLResult, 0, len(qp.BucketedCQLQueries))
+	next := 0
 	for _, k := range sortedKeys {
 		agg, err := GetAggregator(qp.AggregatorLabel)
 		if err != nil {
 			return nil, err
 		}
 
-		for _, q := range qp.BucketedCQLQueries[k] {
-			// Execute one CQLQuery and collect its result
-			//
-			// For server-side aggregation, this will return only
-			// one row; for exclusive client-side aggregation this
-			// will return a sequence.
-			iter := session.Query(q.PreparableQueryString, q.Args...).Iter()
-			var x float64
-			for iter.Scan(&x) {
+		for range qp.BucketedCQLQueries[k] {
+			for _, x := range values[next] {
 				agg.Put(x)
 			}
-			if err := iter.Close(); err != nil {
-				return nil, err
-			}
+			next++
 		}
 		results = append(results, CQLResult{TimeInterval: k, Values: []float64{agg.Get()}})
 	}
@@ -163,11 +195,11 @@ func csiDebugQueries(cqlQueries []CQLQuery, label string, level int) {
 // Execute runs all CQLQueries in the QueryPlan and collects the results.
 //
 // TODO(rw): support parallel execution.
-func (qp *QueryPlanWithoutServerAggregation) Execute(session *gocql.Session) ([]CQLResult, error) {
+func (qp *QueryPlanWithoutServerAggregation) Execute(ctx context.Context, session *gocql.Session) ([]CQLResult, error) {
 	// for each query, execute it, then put each result row into the
 	// client-side aggregator that matches its time bucket:
 	for _, q := range qp.CQLQueries {
-		iter := session.Query(q.PreparableQueryString, q.Args...).Iter()
+		iter := session.Query(q.PreparableQueryString, q.Args...).WithContext(ctx).Iter()
 
 		var timestampNs int64
 		var value float64
@@ -241,7 +273,7 @@ func (a int64arr) Less(i, j int) bool { return a[i] < a[j] }
 // Execute runs all CQLQueries in the QueryPlan and collects the results.
 //
 // TODO(rw): support parallel execution.
-func (qp *QueryPlanNoAggregation) Execute(session *gocql.Session) ([]CQLResult, error) {
+func (qp *QueryPlanNoAggregation) Execute(ctx context.Context, session *gocql.Session) ([]CQLResult, error) {
 	res := make(map[int64]map[string][]float64)
 	// Useful index for placing values in a row correctly
 	fieldPos := make(map[string]int)
@@ -266,7 +298,7 @@ func (qp *QueryPlanNoAggregation) Execute(session *gocql.Session) ([]CQLResult,
 		// First pass of all queries
 		for _, q := range qp.cqlQueries {
 			if q.Field == whereParts[0] { // only handle queries for where clause field
-				iter := session.Query(q.PreparableQueryString, q.Args...).Iter()
+				iter := session.Query(q.PreparableQueryString, q.Args...).WithContext(ctx).Iter()
 
 				var timestampNs int64
 				var value float64
@@ -295,7 +327,7 @@ func (qp *QueryPlanNoAggregation) Execute(session *gocql.Session) ([]CQLResult,
 		// Second pass for non-where clause fields
 		for _, q := range qp.cqlQueries {
 			if q.Field != whereParts[0] {
-				iter := session.Query(q.PreparableQueryString, q.Args...).Iter()
+				iter := session.Query(q.PreparableQueryString, q.Args...).WithContext(ctx).Iter()
 
 				var timestampNs int64
 				var value float64
@@ -396,7 +428,7 @@ func NewQueryPlanForEvery(fields []string, forEveryTag string, forEveryNum int64
 // Execute runs all CQLQueries in the QueryPlan and collects the results.
 //
 // TODO(rw): support parallel execution.
-func (qp *QueryPlanForEvery) Execute(session *gocql.Session) ([]CQLResult, error) {
+func (qp *QueryPlanForEvery) Execute(ctx context.Context, session *gocql.Session) ([]CQLResult, error) {
 	res := make(map[string]map[int64][]float64)
 	seriesTracker := make(map[string]int)
 
@@ -407,7 +439,7 @@ func (qp *QueryPlanForEvery) Execute(session *gocql.Session) ([]CQLResult, error
 	}
 
 	for _, q := range qp.cqlQueries {
-		iter := session.Query(q.PreparableQueryString, q.Args...).Iter()
+		iter := session.Query(q.PreparableQueryString, q.Args...).WithContext(ctx).Iter()
 
 		rm := r.FindSubmatch([]byte(q.Args[0].(string)))
 		key := string(rm[1])
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/timescale/tsbs/internal/utils"
+)
+
+// testBucketedPlan returns a max aggregation plan over the given number of
+// one hour buckets with the given number of CQLQueries each, whose argument
+// is the number of the query in bucket order, along with the buckets in order
+func testBucketedPlan(t *testing.T, buckets, queries, parallelism int) (*QueryPlanWithServerAggregation, []*utils.TimeInterval) {
+	start := time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC)
+	bucketed := make(map[*utils.TimeInterval][]CQLQuery)
+	intervals := make([]*utils.TimeInterval, buckets)
+	// add the buckets in reverse so that their map order can't line up
+	// with their time order by accident
+	for b := buckets - 1; b >= 0; b-- {
+		ti, err := utils.NewTimeInterval(start.Add(time.Duration(b)*time.Hour), start.Add(time.Duration(b+1)*time.Hour))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		intervals[b] = ti
+		for q := 0; q < queries; q++ {
+			bucketed[ti] = append(bucketed[ti], CQLQuery{Args: []interface{}{b*queries + q}})
+		}
+	}
+	qp, err := NewQueryPlanWithServerAggregation("max", bucketed)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	qp.Parallelism = parallelism
+	return qp, intervals
+}
+
+func TestQueryPlanWithServerAggregationBucketOrder(t *testing.T) {
+	const buckets, queries = 4, 3
+	for _, parallelism := range []int{1, 4, buckets * queries} {
+		qp, intervals := testBucketedPlan(t, buckets, queries, parallelism)
+		results, err := qp.execute(func(q CQLQuery) ([]float64, error) {
+			i := q.Args[0].(int)
+			// let later queries finish first
+			time.Sleep(time.Duration(buckets*queries-i) * time.Millisecond)
+			return []float64{float64(i), float64(i) - 0.5}, nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error with parallelism %d: %v", parallelism, err)
+		}
+		if got := len(results); got != buckets {
+			t.Fatalf("incorrect number of results with parallelism %d: got %d want %d", parallelism, got, buckets)
+		}
+		for b, r := range results {
+			if r.TimeInterval != intervals[b] {
+				t.Errorf("incorrect interval of result %d with parallelism %d: got %v want %v", b, parallelism, r.TimeInterval, intervals[b])
+			}
+			// the maximum of a bucket is the value of its last query
+			want := []float64{float64(b*queries + queries - 1)}
+			if !reflect.DeepEqual(r.Values, want) {
+				t.Errorf("incorrect values of result %d with parallelism %d: got %v want %v", b, parallelism, r.Values, want)
+			}
+		}
+	}
+}
+
+func TestQueryPlanWithServerAggregationParallelism(t *testing.T) {
+	for _, parallelism := range []int{2, 3} {
+		qp, _ := testBucketedPlan(t, 4, 3, parallelism)
+		var mu sync.Mutex
+		running, maxRunning := 0, 0
+		_, err := qp.execute(func(q CQLQuery) ([]float64, error) {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error with parallelism %d: %v", parallelism, err)
+		}
+		if maxRunning > parallelism {
+			t.Errorf("too many queries running with parallelism %d: %d", parallelism, maxRunning)
+		}
+		if maxRunning < 2 {
+			t.Errorf("queries did not run at the same time with parallelism %d", parallelism)
+		}
+	}
+}
+
+func TestQueryPlanWithServerAggregationSerial(t *testing.T) {
+	for _, parallelism := range []int{0, 1} {
+		qp, _ := testBucketedPlan(t, 3, 2, parallelism)
+		running := 0
+		var order []int
+		_, err := qp.execute(func(q CQLQuery) ([]float64, error) {
+			running++
+			if running > 1 {
+				t.Errorf("queries running at the same time with parallelism %d", parallelism)
+			}
+			order = append(order, q.Args[0].(int))
+			time.Sleep(time.Millisecond)
+			running--
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error with parallelism %d: %v", parallelism, err)
+		}
+		if want := []int{0, 1, 2, 3, 4, 5}; !reflect.DeepEqual(order, want) {
+			t.Errorf("incorrect query order with parallelism %d: got %v want %v", parallelism, order, want)
+		}
+	}
+}
+
+func TestQueryPlanWithServerAggregationError(t *testing.T) {
+	const failing = 2
+	for _, parallelism := range []int{1, 3} {
+		qp, _ := testBucketedPlan(t, 10, 2, parallelism)
+		var mu sync.Mutex
+		started := 0
+		results, err := qp.execute(func(q CQLQuery) ([]float64, error) {
+			mu.Lock()
+			started++
+			mu.Unlock()
+			i := q.Args[0].(int)
+			if i == failing {
+				return nil, fmt.Errorf("query %d failed", i)
+			} else if i > failing {
+				// keep the queries after the failing one running until
+				// long after it has failed
+				time.Sleep(10 * time.Millisecond)
+			}
+			return []float64{1}, nil
+		})
+		if err == nil || err.Error() != "query 2 failed" {
+			t.Errorf("incorrect error with parallelism %d: %v", parallelism, err)
+		}
+		if results != nil {
+			t.Errorf("unexpected results with parallelism %d: %v", parallelism, results)
+		}
+		// only the queries running beside the failing one may have
+		// started after it
+		if max := failing + parallelism; started > max {
+			t.Errorf("too many queries started with parallelism %d: got %d want at most %d", parallelism, started, max)
+		}
+	}
+}
+
+func TestNewHostSelectionPolicy(t *testing.T) {
+	cases := []struct {
+		desc       string
+		tokenAware bool
+		localDC    string
+		want       gocql.HostSelectionPolicy
+	}{
+		{
+			desc: "defaults",
+			want: gocql.RoundRobinHostPolicy(),
+		},
+		{
+			desc:       "token aware",
+			tokenAware: true,
+			want:       gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy()),
+		},
+		{
+			desc:    "local datacenter",
+			localDC: "dc1",
+			want:    gocql.DCAwareRoundRobinPolicy("dc1"),
+		},
+		{
+			desc:       "token aware in local datacenter",
+			tokenAware: true,
+			localDC:    "dc1",
+			want:       gocql.TokenAwareHostPolicy(gocql.DCAwareRoundRobinPolicy("dc1")),
+		},
+	}
+	for _, c := range cases {
+		got := newHostSelectionPolicy(c.tokenAware, c.localDC)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: incorrect policy: got %T %v want %T %v", c.desc, got, got, c.want, c.want)
+		}
+	}
+	if reflect.DeepEqual(newHostSelectionPolicy(false, "dc1"), gocql.DCAwareRoundRobinPolicy("dc2")) {
+		t.Errorf("policies of different local datacenters are equal")
+	}
+}
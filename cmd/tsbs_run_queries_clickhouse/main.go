@@ -5,14 +5,17 @@
 package main
 
 import (
+	"context"
+	"database/sql/driver"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	_ "github.com/kshvakov/clickhouse"
+	"github.com/kshvakov/clickhouse"
 	"github.com/timescale/tsbs/query"
 )
 
@@ -23,7 +26,8 @@ var (
 	user      string
 	password  string
 
-	showExplain bool
+	showExplain    bool
+	nativeProtocol bool
 )
 
 // Global vars:
@@ -42,6 +46,7 @@ func init() {
 		"Comma separated list of ClickHouse hosts (pass multiple values for sharding reads on a multi-node setup)")
 	flag.StringVar(&user, "user", "default", "User to connect to ClickHouse as")
 	flag.StringVar(&password, "password", "", "Password to connect to ClickHouse")
+	flag.BoolVar(&nativeProtocol, "native-protocol", false, "Whether to run queries through the native protocol directly, instead of through database/sql")
 
 	flag.Parse()
 
@@ -71,9 +76,6 @@ func getConnectString(workerNumber int) string {
 // keys: 'query' which has a value of the SQL used to generate the second key
 // 'results' which is an array of each row in the return set.
 func prettyPrintResponse(rows *sqlx.Rows, q *query.ClickHouse) {
-	resp := make(map[string]interface{})
-	resp["query"] = string(q.SqlQuery)
-
 	results := []map[string]interface{}{}
 	for rows.Next() {
 		r := make(map[string]interface{})
@@ -81,6 +83,19 @@ func prettyPrintResponse(rows *sqlx.Rows, q *query.ClickHouse) {
 			panic(err)
 		}
 		results = append(results, r)
+	}
+	printResponse(q, results)
+}
+
+// printResponse prints a Query and the rows of its response like
+// prettyPrintResponse
+func printResponse(q *query.ClickHouse, results []map[string]interface{}) {
+	resp := make(map[string]interface{})
+	resp["query"] = string(q.SqlQuery)
+	if len(q.Params) > 0 {
+		resp["params"] = q.Params
+	}
+	if len(results) > 0 {
 		resp["results"] = results
 	}
 
@@ -100,8 +115,10 @@ type queryExecutorOptions struct {
 
 // query.Processor interface implementation
 type processor struct {
-	db   *sqlx.DB
-	opts *queryExecutorOptions
+	db    *sqlx.DB
+	conn  clickhouse.Clickhouse // direct connection for --native-protocol
+	opts  *queryExecutorOptions
+	stmts map[string]*sqlx.Stmt // statements prepared by this worker, by SQL
 }
 
 // query.Processor interface implementation
@@ -112,16 +129,121 @@ func newProcessor() query.Processor {
 // query.Processor interface implementation
 func (p *processor) Init(workerNumber int) {
 	p.db = sqlx.MustConnect("clickhouse", getConnectString(workerNumber))
+	p.stmts = make(map[string]*sqlx.Stmt)
+	if nativeProtocol {
+		conn, err := clickhouse.OpenDirect(getConnectString(workerNumber))
+		if err != nil {
+			panic(err)
+		}
+		p.conn = conn
+	}
 	p.opts = &queryExecutorOptions{
-		// ClickHouse could not do EXPLAIN
+		// EXPLAIN output is only captured with --plans-dir
 		showExplain:   false,
 		debug:         runner.DebugLevel() > 0,
 		printResponse: runner.DoPrintResponses(),
 	}
 }
 
+// prepare returns the prepared statement for sql, preparing it the first time
+// this worker runs it
+func (p *processor) prepare(sql string) (*sqlx.Stmt, error) {
+	if stmt, ok := p.stmts[sql]; ok {
+		return stmt, nil
+	}
+	stmt, err := p.db.Preparex(sql)
+	if err != nil {
+		return nil, err
+	}
+	p.stmts[sql] = stmt
+	return stmt, nil
+}
+
+// query runs sql, as a prepared statement with the given params if any.
+// Note that the ClickHouse driver binds the params on the client, so this
+// saves parsing the statement in the driver rather than on the server.
+func (p *processor) query(ctx context.Context, sql string, params []interface{}) (*sqlx.Rows, error) {
+	if len(params) == 0 {
+		return p.db.QueryxContext(ctx, sql)
+	}
+	stmt, err := p.prepare(sql)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryxContext(ctx, params...)
+}
+
+// queryNative runs sql with the given params through the native protocol
+// directly and reads all rows of the response, which are returned if keep is
+// set. Statements are not cached here, since preparing a query only parses
+// it for placeholders on the client.
+func (p *processor) queryNative(ctx context.Context, sql string, params []interface{}, keep bool) ([]map[string]interface{}, error) {
+	stmt, err := p.conn.Prepare(sql)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	var rows driver.Rows
+	if stmtCtx, ok := stmt.(driver.StmtQueryContext); ok {
+		args := make([]driver.NamedValue, len(params))
+		for i, v := range params {
+			args[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+		}
+		rows, err = stmtCtx.QueryContext(ctx, args)
+	} else {
+		args := make([]driver.Value, len(params))
+		for i, v := range params {
+			args[i] = v
+		}
+		rows, err = stmt.Query(args)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []map[string]interface{}
+	cols := rows.Columns()
+	values := make([]driver.Value, len(cols))
+	for {
+		err := rows.Next(values)
+		if err == io.EOF {
+			return results, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if keep {
+			r := make(map[string]interface{}, len(cols))
+			for i, col := range cols {
+				r[col] = values[i]
+			}
+			results = append(results, r)
+		}
+	}
+}
+
+// ExplainQuery returns the output of EXPLAIN for q, for --plans-dir.
+// ClickHouse has no EXPLAIN ANALYZE, so this is the plan without timings.
+func (p *processor) ExplainQuery(q query.Query) (string, error) {
+	chQuery := q.(*query.ClickHouse)
+	rows, err := p.query(context.Background(), "EXPLAIN "+string(chQuery.SqlQuery), chQuery.Params)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	text := ""
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return "", err
+		}
+		text += s + "\n"
+	}
+	return text, rows.Err()
+}
+
 // query.Processor interface implementation
-func (p *processor) ProcessQuery(q query.Query, isWarm bool) ([]*query.Stat, error) {
+func (p *processor) ProcessQuery(ctx context.Context, q query.Query, isWarm bool) ([]*query.Stat, error) {
 	// No need to run again for EXPLAIN
 	if isWarm && p.opts.showExplain {
 		return nil, nil
@@ -135,26 +257,45 @@ func (p *processor) ProcessQuery(q query.Query, isWarm bool) ([]*query.Stat, err
 	// SqlQuery is []byte, so cast is needed
 	sql := string(chQuery.SqlQuery)
 
-	// Main action - run the query
-	rows, err := p.db.Queryx(sql)
-	if err != nil {
-		return nil, err
-	}
-
 	// Print some extra info if needed
 	if p.opts.debug {
 		fmt.Println(sql)
-	}
-	if p.opts.printResponse {
-		prettyPrintResponse(rows, chQuery)
+		if len(chQuery.Params) > 0 {
+			fmt.Println(chQuery.Params)
+		}
 	}
 
-	// Finalize the query
-	rows.Close()
+	// Main action - run the query
+	if nativeProtocol {
+		results, err := p.queryNative(ctx, sql, chQuery.Params, p.opts.printResponse)
+		if err != nil {
+			return nil, err
+		}
+		if p.opts.printResponse {
+			printResponse(chQuery, results)
+		}
+	} else {
+		rows, err := p.query(ctx, sql, chQuery.Params)
+		if err != nil {
+			return nil, err
+		}
+		if p.opts.printResponse {
+			prettyPrintResponse(rows, chQuery)
+		}
+
+		// Fetch all the rows, as queryNative does, so that both protocols
+		// are measured on the full result
+		for rows.Next() {
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
 	took := float64(time.Since(start).Nanoseconds()) / 1e6
 
 	stat := query.GetStat()
 	stat.Init(q.HumanLabelName(), took)
 
-	return []*query.Stat{stat}, err
+	return []*query.Stat{stat}, nil
 }
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/pgtype"
 	"time"
 
 	_ "github.com/jackc/pgx/stdlib"
@@ -47,6 +49,7 @@ type processor struct {
 	pool    *pgx.ConnPool
 	connCfg *pgx.ConnConfig
 	opts    *executorOptions
+	stmts   map[string]string // names of the statements prepared by this worker, by SQL
 }
 
 type executorOptions struct {
@@ -82,9 +85,78 @@ func (p *processor) Init(workerNumber int) {
 		panic(err)
 	}
 	p.pool = pool
+	p.stmts = make(map[string]string)
 }
 
-func (p *processor) ProcessQuery(q query.Query, isWarm bool) ([]*query.Stat, error) {
+// paramOIDs returns the types of params, so that CrateDB does not have to
+// infer them from the statement, e.g. for timestamps bound as milliseconds
+func paramOIDs(params []interface{}) []pgtype.OID {
+	oids := make([]pgtype.OID, len(params))
+	for i, v := range params {
+		switch v.(type) {
+		case int64:
+			oids[i] = pgtype.Int8OID
+		default:
+			oids[i] = pgtype.TextOID
+		}
+	}
+	return oids
+}
+
+// prepare returns the name of the prepared statement for qry with params,
+// preparing it the first time this worker runs it
+func (p *processor) prepare(qry string, params []interface{}) (string, error) {
+	if name, ok := p.stmts[qry]; ok {
+		return name, nil
+	}
+	name := fmt.Sprintf("tsbs_%d", len(p.stmts))
+	opts := &pgx.PrepareExOptions{ParameterOIDs: paramOIDs(params)}
+	if _, err := p.pool.PrepareEx(context.Background(), name, qry, opts); err != nil {
+		return "", err
+	}
+	p.stmts[qry] = name
+	return name, nil
+}
+
+// query runs qry, as a prepared statement with the given params if any
+func (p *processor) query(ctx context.Context, qry string, params []interface{}) (*pgx.Rows, error) {
+	if len(params) == 0 {
+		return p.pool.QueryEx(ctx, qry, nil)
+	}
+	name, err := p.prepare(qry, params)
+	if err != nil {
+		return nil, err
+	}
+	return p.pool.QueryEx(ctx, name, nil, params...)
+}
+
+// ExplainQuery returns the output of EXPLAIN ANALYZE for q, for --plans-dir
+func (p *processor) ExplainQuery(q query.Query) (string, error) {
+	tq := q.(*query.CrateDB)
+	rows, err := p.query(context.Background(), "EXPLAIN ANALYZE "+string(tq.SqlQuery), tq.Params)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	text := ""
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return "", err
+		}
+		// the plan is a single object column
+		for _, v := range values {
+			line, err := json.MarshalIndent(v, "", "  ")
+			if err != nil {
+				return "", err
+			}
+			text += string(line) + "\n"
+		}
+	}
+	return text, rows.Err()
+}
+
+func (p *processor) ProcessQuery(ctx context.Context, q query.Query, isWarm bool) ([]*query.Stat, error) {
 	// No need to run again for EXPLAIN
 	if isWarm && p.opts.showExplain {
 		return nil, nil
@@ -96,13 +168,16 @@ func (p *processor) ProcessQuery(q query.Query, isWarm bool) ([]*query.Stat, err
 	if showExplain {
 		qry = "EXPLAIN ANALYZE " + qry
 	}
-	rows, err := p.pool.Query(qry)
+	rows, err := p.query(ctx, qry, tq.Params)
 	if err != nil {
 		return nil, err
 	}
 
 	if p.opts.debug {
 		fmt.Println(qry)
+		if len(tq.Params) > 0 {
+			fmt.Println(tq.Params)
+		}
 	}
 	if showExplain {
 		fmt.Printf("Explian Query:\n")
@@ -126,6 +201,9 @@ func (p *processor) ProcessQuery(q query.Query, isWarm bool) ([]*query.Stat, err
 func prettyPrintResponse(rows *pgx.Rows, q *query.CrateDB) {
 	resp := make(map[string]interface{})
 	resp["query"] = string(q.SqlQuery)
+	if len(q.Params) > 0 {
+		resp["params"] = q.Params
+	}
 	resp["results"] = mapRows(rows)
 
 	line, err := json.MarshalIndent(resp, "", "  ")
@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -30,6 +31,8 @@ type HTTPClientDoOptions struct {
 	PrettyPrintResponses bool
 	chunkSize            uint64
 	database             string
+	org                  string
+	token                string
 }
 
 // NewHTTPClient creates a new HTTPClient.
@@ -43,29 +46,48 @@ func NewHTTPClient(host string) *HTTPClient {
 }
 
 // Do performs the action specified by the given Query. It uses fasthttp, and
-// tries to minimize heap allocations.
-func (w *HTTPClient) Do(q *query.HTTP, opts *HTTPClientDoOptions) (lag float64, err error) {
+// tries to minimize heap allocations. Once ctx is done the request is aborted
+// and its error returned.
+func (w *HTTPClient) Do(ctx context.Context, q *query.HTTP, opts *HTTPClientDoOptions) (lag float64, err error) {
 	// populate uri from the reusable byte slice:
 	w.uri = w.uri[:0]
 	w.uri = append(w.uri, w.Host...)
 	//w.uri = append(w.uri, bytesSlash...)
 	w.uri = append(w.uri, q.Path...)
-	w.uri = append(w.uri, []byte("&db="+url.QueryEscape(opts.database))...)
-	if opts.chunkSize > 0 {
-		s := fmt.Sprintf("&chunked=true&chunk_size=%d", opts.chunkSize)
-		w.uri = append(w.uri, []byte(s)...)
+	var body io.Reader
+	if len(q.Body) > 0 {
+		// Flux queries of the InfluxDB 2.x API are posted as the body, and
+		// read the bucket they name
+		w.uri = append(w.uri, []byte("?org="+url.QueryEscape(opts.org))...)
+		body = bytes.NewReader(q.Body)
+	} else {
+		w.uri = append(w.uri, []byte("&db="+url.QueryEscape(opts.database))...)
+		if opts.chunkSize > 0 {
+			s := fmt.Sprintf("&chunked=true&chunk_size=%d", opts.chunkSize)
+			w.uri = append(w.uri, []byte(s)...)
+		}
 	}
 
 	// populate a request with data from the Query:
-	req, err := http.NewRequest(string(q.Method), string(w.uri), nil)
+	req, err := http.NewRequest(string(q.Method), string(w.uri), body)
 	if err != nil {
 		panic(err)
 	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/vnd.flux")
+		req.Header.Set("Accept", "application/csv")
+	}
+	if len(opts.token) > 0 {
+		req.Header.Set("Authorization", "Token "+opts.token)
+	}
 
 	// Perform the request while tracking latency:
 	start := time.Now()
 	resp, err := w.client.Do(req)
-	if err != nil {
+	if err != nil && ctx.Err() != nil {
+		return 0, err
+	} else if err != nil {
 		panic(err)
 	}
 	defer resp.Body.Close()
@@ -80,6 +102,8 @@ func (w *HTTPClient) Do(q *query.HTTP, opts *HTTPClientDoOptions) (lag float64,
 		if err == io.EOF {
 			err = nil
 			break
+		} else if err != nil && ctx.Err() != nil {
+			return 0, err
 		} else if err != nil {
 			panic(err)
 		}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/timescale/tsbs/query"
+)
+
+func TestHTTPClientDo(t *testing.T) {
+	flux := `from(bucket: "benchmark") |> range(start: 1970-01-01T00:00:00Z, stop: 1970-01-01T01:00:00Z)`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/api/v2/query":
+			if r.Method != "POST" || q.Get("org") != "tsbs" || string(body) != flux ||
+				r.Header.Get("Content-Type") != "application/vnd.flux" || r.Header.Get("Authorization") != "Token secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(",result,table,_value\n,_result,0,1\n"))
+		case "/query":
+			if r.Method != "GET" || q.Get("q") != "SELECT 1" || q.Get("db") != "benchmark" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"results":[]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	opts := &HTTPClientDoOptions{
+		database: "benchmark",
+		org:      "tsbs",
+		token:    "secret",
+	}
+	c := NewHTTPClient(server.URL)
+
+	q := query.NewHTTP()
+	q.Method = []byte("POST")
+	q.Path = []byte("/api/v2/query")
+	q.Body = []byte(flux)
+	if lag, err := c.Do(context.Background(), q, opts); err != nil || lag <= 0 {
+		t.Errorf("unexpected result of Flux query: lag %f, error %v", lag, err)
+	}
+
+	q = query.NewHTTP()
+	q.Method = []byte("GET")
+	q.Path = []byte("/query?q=SELECT+1")
+	if lag, err := c.Do(context.Background(), q, opts); err != nil || lag <= 0 {
+		t.Errorf("unexpected result of InfluxQL query: lag %f, error %v", lag, err)
+	}
+}
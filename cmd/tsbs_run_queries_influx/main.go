@@ -6,6 +6,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"strings"
@@ -17,6 +18,8 @@ import (
 var (
 	daemonUrls []string
 	chunkSize  uint64
+	org        string
+	token      string
 )
 
 // Global vars:
@@ -31,6 +34,8 @@ func init() {
 
 	flag.StringVar(&csvDaemonUrls, "urls", "http://localhost:8086", "Daemon URLs, comma-separated. Will be used in a round-robin fashion.")
 	flag.Uint64Var(&chunkSize, "chunk-response-size", 0, "Number of series to chunk results into. 0 means no chunking.")
+	flag.StringVar(&org, "org", "", "Organization to run Flux queries in (InfluxDB 2.x only).")
+	flag.StringVar(&token, "token", "", "Authentication token (InfluxDB 2.x only).")
 
 	flag.Parse()
 
@@ -57,14 +62,16 @@ func (p *processor) Init(workerNumber int) {
 		PrettyPrintResponses: runner.DoPrintResponses(),
 		chunkSize:            chunkSize,
 		database:             runner.DatabaseName(),
+		org:                  org,
+		token:                token,
 	}
 	url := daemonUrls[workerNumber%len(daemonUrls)]
 	p.w = NewHTTPClient(url)
 }
 
-func (p *processor) ProcessQuery(q query.Query, _ bool) ([]*query.Stat, error) {
+func (p *processor) ProcessQuery(ctx context.Context, q query.Query, _ bool) ([]*query.Stat, error) {
 	hq := q.(*query.HTTP)
-	lag, err := p.w.Do(hq, p.opts)
+	lag, err := p.w.Do(ctx, hq, p.opts)
 	if err != nil {
 		return nil, err
 	}
@@ -5,7 +5,9 @@
 package main
 
 import (
+	"context"
 	"encoding/gob"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -65,7 +67,22 @@ func (p *processor) Init(workerNumber int) {
 	p.collection = db.C("point_data")
 }
 
-func (p *processor) ProcessQuery(q query.Query, _ bool) ([]*query.Stat, error) {
+// ExplainQuery returns the output of explain() for the pipeline of q, for
+// --plans-dir
+func (p *processor) ExplainQuery(q query.Query) (string, error) {
+	mq := q.(*query.Mongo)
+	var result bson.M
+	if err := p.collection.Pipe(mq.BsonDoc).AllowDiskUse().Explain(&result); err != nil {
+		return "", err
+	}
+	plan, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(plan), nil
+}
+
+func (p *processor) ProcessQuery(_ context.Context, q query.Query, _ bool) ([]*query.Stat, error) {
 	mq := q.(*query.Mongo)
 	start := time.Now().UnixNano()
 	pipe := p.collection.Pipe(mq.BsonDoc).AllowDiskUse()
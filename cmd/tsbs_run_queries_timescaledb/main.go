@@ -6,6 +6,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"flag"
@@ -112,6 +113,9 @@ func getConnectString(workerNumber int) string {
 func prettyPrintResponse(rows *sql.Rows, q *query.TimescaleDB) {
 	resp := make(map[string]interface{})
 	resp["query"] = string(q.SqlQuery)
+	if len(q.Params) > 0 {
+		resp["params"] = q.Params
+	}
 	resp["results"] = mapRows(rows)
 
 	line, err := json.MarshalIndent(resp, "", "  ")
@@ -152,8 +156,9 @@ type queryExecutorOptions struct {
 }
 
 type processor struct {
-	db   *sql.DB
-	opts *queryExecutorOptions
+	db    *sql.DB
+	opts  *queryExecutorOptions
+	stmts map[string]*sql.Stmt // statements prepared by this worker, by SQL
 }
 
 func newProcessor() query.Processor { return &processor{} }
@@ -164,6 +169,7 @@ func (p *processor) Init(workerNumber int) {
 		panic(err)
 	}
 	p.db = db
+	p.stmts = make(map[string]*sql.Stmt)
 	p.opts = &queryExecutorOptions{
 		showExplain:   showExplain,
 		debug:         runner.DebugLevel() > 0,
@@ -171,7 +177,52 @@ func (p *processor) Init(workerNumber int) {
 	}
 }
 
-func (p *processor) ProcessQuery(q query.Query, isWarm bool) ([]*query.Stat, error) {
+// prepare returns the prepared statement for qry, preparing it the first time
+// this worker runs it
+func (p *processor) prepare(qry string) (*sql.Stmt, error) {
+	if stmt, ok := p.stmts[qry]; ok {
+		return stmt, nil
+	}
+	stmt, err := p.db.Prepare(qry)
+	if err != nil {
+		return nil, err
+	}
+	p.stmts[qry] = stmt
+	return stmt, nil
+}
+
+// query runs qry, as a prepared statement with the given params if any
+func (p *processor) query(ctx context.Context, qry string, params []interface{}) (*sql.Rows, error) {
+	if len(params) == 0 {
+		return p.db.QueryContext(ctx, qry)
+	}
+	stmt, err := p.prepare(qry)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.QueryContext(ctx, params...)
+}
+
+// ExplainQuery returns the output of EXPLAIN ANALYZE for q, for --plans-dir
+func (p *processor) ExplainQuery(q query.Query) (string, error) {
+	tq := q.(*query.TimescaleDB)
+	rows, err := p.query(context.Background(), "EXPLAIN ANALYZE "+string(tq.SqlQuery), tq.Params)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	text := ""
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return "", err
+		}
+		text += s + "\n"
+	}
+	return text, rows.Err()
+}
+
+func (p *processor) ProcessQuery(ctx context.Context, q query.Query, isWarm bool) ([]*query.Stat, error) {
 	// No need to run again for EXPLAIN
 	if isWarm && p.opts.showExplain {
 		return nil, nil
@@ -183,13 +234,16 @@ func (p *processor) ProcessQuery(q query.Query, isWarm bool) ([]*query.Stat, err
 	if showExplain {
 		qry = "EXPLAIN ANALYZE " + qry
 	}
-	rows, err := p.db.Query(qry)
+	rows, err := p.query(ctx, qry, tq.Params)
 	if err != nil {
 		return nil, err
 	}
 
 	if p.opts.debug {
 		fmt.Println(qry)
+		if len(tq.Params) > 0 {
+			fmt.Println(tq.Params)
+		}
 	}
 	if showExplain {
 		text := ""
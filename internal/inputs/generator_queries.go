@@ -10,6 +10,7 @@ import (
 	"math/rand"
 	"os"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/databases/cassandra"
@@ -18,13 +19,17 @@ import (
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/databases/mongo"
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/databases/siridb"
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/databases/timescaledb"
+	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	internalutils "github.com/timescale/tsbs/internal/utils"
 )
 
 // Error messages when using a QueryGenerator
 const (
 	ErrInvalidQueryConfig = "invalid config: QueryGenerator needs a QueryGeneratorConfig"
 	ErrEmptyQueryType     = "query type cannot be empty"
+	ErrNegativeWindow     = "query window cannot be negative"
+	ErrBadBucket          = "query bucket has to be a whole number of seconds"
 
 	errBadQueryTypeFmt        = "invalid query type for use case '%s': '%s'"
 	errCouldNotDebugFmt       = "could not write debug output: %v"
@@ -42,14 +47,40 @@ type QueryGeneratorConfig struct {
 	InterleavedGroupID   uint
 	InterleavedNumGroups uint
 
+	// QueryWindow and QueryBucket override the time range of every query and
+	// the bucket width of single groupby queries unless zero, and
+	// QueryWindowPlacement is where the time ranges are placed in the dataset
+	QueryWindow          time.Duration
+	QueryBucket          time.Duration
+	QueryWindowPlacement string
+
+	// QueryHostDistribution is how likely each host is to be queried, where
+	// QueryHostSkew and QueryHostHotset parameterize the skewed distributions
+	QueryHostDistribution string
+	QueryHostSkew         float64
+	QueryHostHotset       float64
+
 	// TODO - I think this needs some rethinking, but a simple, elegant solution escapes me right now
 	TimescaleUseJSON       bool
 	TimescaleUseTags       bool
 	TimescaleUseTimeBucket bool
 
+	// TimescaleContinuousAggregates lists the bucket widths of the continuous
+	// aggregates made by tsbs_load_timescaledb, e.g. 1m,1h
+	TimescaleContinuousAggregates string
+
 	ClickhouseUseTags bool
 
 	MongoUseNaive bool
+
+	// InfluxUseFlux makes InfluxDB queries Flux scripts of InfluxDB 2.x
+	// reading InfluxBucket instead of InfluxQL
+	InfluxUseFlux bool
+	InfluxBucket  string
+
+	// UsePreparedStatements makes the SQL databases' queries statement
+	// templates with bound parameters, which the runners prepare once per worker
+	UsePreparedStatements bool
 }
 
 // Validate checks that the values of the QueryGeneratorConfig are reasonable.
@@ -63,6 +94,13 @@ func (c *QueryGeneratorConfig) Validate() error {
 		return fmt.Errorf(ErrEmptyQueryType)
 	}
 
+	if c.QueryWindow < 0 {
+		return fmt.Errorf(ErrNegativeWindow)
+	}
+	if c.QueryBucket < 0 || c.QueryBucket%time.Second != 0 {
+		return fmt.Errorf(ErrBadBucket)
+	}
+
 	err = validateGroups(c.InterleavedGroupID, c.InterleavedNumGroups)
 	return err
 }
@@ -76,6 +114,27 @@ func (c *QueryGeneratorConfig) AddToFlagSet(fs *flag.FlagSet) {
 	flag.UintVar(&c.InterleavedNumGroups, "interleaved-generation-groups", 1,
 		"The number of round-robin serialization groups. Use this to scale up data generation to multiple processes.")
 
+	flag.DurationVar(&c.QueryWindow, "query-window", 0, "Time range of every query, e.g. 5m or 720h. 0 means the default of the query type")
+	flag.DurationVar(&c.QueryBucket, "query-bucket", 0, "Width of the time buckets of single-groupby queries. 0 means 1m")
+	flag.StringVar(&c.QueryWindowPlacement, "query-window-placement", internalutils.WindowRandom,
+		fmt.Sprintf("Where to place the time range of queries in the dataset (choices: %s)", strings.Join(internalutils.WindowPlacements, ", ")))
+
+	flag.StringVar(&c.QueryHostDistribution, "query-host-distribution", devops.HostsUniform,
+		fmt.Sprintf("How likely each host is to be queried (choices: %s)", strings.Join(devops.HostDistributions, ", ")))
+	flag.Float64Var(&c.QueryHostSkew, "query-host-skew", 1.1, "Skew of the zipfian host distribution, larger than 1. host_0 is queried most often")
+	flag.Float64Var(&c.QueryHostHotset, "query-host-hotset", 10, "Percentage of hosts, starting from host_0, that get 90% of the queries with the hotset host distribution")
+}
+
+// windowConfigurer is a use case generator whose query time ranges and bucket
+// widths can be configured
+type windowConfigurer interface {
+	ConfigureWindows(window, bucket time.Duration, placement string) error
+}
+
+// hostConfigurer is a use case generator whose distribution of queried hosts
+// can be configured
+type hostConfigurer interface {
+	ConfigureHosts(distribution string, skew, hotset float64) error
 }
 
 // QueryGenerator is a type of Generator for creating queries to test against a
@@ -184,9 +243,16 @@ func (g *QueryGenerator) getUseCaseGenerator(c *QueryGeneratorConfig) (utils.Que
 	case FormatClickhouse:
 		temp := clickhouse.NewDevops(g.tsStart, g.tsEnd, scale)
 		temp.UseTags = c.ClickhouseUseTags
+		temp.UsePrepared = c.UsePreparedStatements
 		ret = temp
 	case FormatInflux:
-		ret = influx.NewDevops(g.tsStart, g.tsEnd, scale)
+		if c.InfluxUseFlux {
+			temp := influx.NewFluxDevops(g.tsStart, g.tsEnd, scale)
+			temp.Bucket = c.InfluxBucket
+			ret = temp
+		} else {
+			ret = influx.NewDevops(g.tsStart, g.tsEnd, scale)
+		}
 	case FormatMongo:
 		if c.MongoUseNaive {
 			ret = mongo.NewNaiveDevops(g.tsStart, g.tsEnd, scale)
@@ -196,16 +262,34 @@ func (g *QueryGenerator) getUseCaseGenerator(c *QueryGeneratorConfig) (utils.Que
 	case FormatSiriDB:
 		ret = siridb.NewDevops(g.tsStart, g.tsEnd, scale)
 	case FormatCrateDB:
-		ret = cratedb.NewDevops(g.tsStart, g.tsEnd, scale)
+		temp := cratedb.NewDevops(g.tsStart, g.tsEnd, scale)
+		temp.UsePrepared = c.UsePreparedStatements
+		ret = temp
 	case FormatTimescaleDB:
 		temp := timescaledb.NewDevops(g.tsStart, g.tsEnd, scale)
 		temp.UseJSON = c.TimescaleUseJSON
 		temp.UseTags = c.TimescaleUseTags
 		temp.UseTimeBucket = c.TimescaleUseTimeBucket
+		temp.UsePrepared = c.UsePreparedStatements
+		if err := temp.ConfigureContinuousAggregates(c.TimescaleContinuousAggregates); err != nil {
+			return nil, err
+		}
 		ret = temp
 	default:
 		return nil, fmt.Errorf(errUnknownFormatFmt, c.Format)
 	}
+	if wc, ok := ret.(windowConfigurer); ok {
+		err := wc.ConfigureWindows(c.QueryWindow, c.QueryBucket, c.QueryWindowPlacement)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if hc, ok := ret.(hostConfigurer); ok {
+		err := hc.ConfigureHosts(c.QueryHostDistribution, c.QueryHostSkew, c.QueryHostHotset)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return ret, nil
 }
 
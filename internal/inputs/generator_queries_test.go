@@ -22,6 +22,7 @@ import (
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/databases/timescaledb"
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/uses/devops"
 	"github.com/timescale/tsbs/cmd/tsbs_generate_queries/utils"
+	internalutils "github.com/timescale/tsbs/internal/utils"
 	"github.com/timescale/tsbs/query"
 )
 
@@ -59,6 +60,27 @@ func TestQueryGeneratorConfigValidate(t *testing.T) {
 	}
 	c.QueryType = "foo"
 
+	// Test window validation
+	c.QueryWindow = -time.Minute
+	err = c.Validate()
+	if err == nil {
+		t.Errorf("unexpected lack of error for negative query window")
+	} else if got := err.Error(); got != ErrNegativeWindow {
+		t.Errorf("incorrect error for negative query window: got\n%s\nwant\n%s", got, ErrNegativeWindow)
+	}
+	c.QueryWindow = 0
+
+	for _, bucket := range []time.Duration{-time.Minute, 1500 * time.Millisecond} {
+		c.QueryBucket = bucket
+		err = c.Validate()
+		if err == nil {
+			t.Errorf("unexpected lack of error for query bucket %v", bucket)
+		} else if got := err.Error(); got != ErrBadBucket {
+			t.Errorf("incorrect error for query bucket %v: got\n%s\nwant\n%s", bucket, got, ErrBadBucket)
+		}
+	}
+	c.QueryBucket = 0
+
 	// Test groups validation
 	c.InterleavedNumGroups = 0
 	err = c.Validate()
@@ -254,6 +276,14 @@ func TestGetUseCaseGenerator(t *testing.T) {
 		t.Errorf("clickhouse UseTags not set correctly: got %v want %v", got, c.ClickhouseUseTags)
 	}
 
+	c.InfluxUseFlux = true
+	c.InfluxBucket = "tsbs"
+	useGen = checkType(FormatInflux, influx.NewFluxDevops(tsStart, tsEnd, scale))
+	if got := useGen.(*influx.FluxDevops).Bucket; got != c.InfluxBucket {
+		t.Errorf("influx Bucket not set correctly: got %v want %v", got, c.InfluxBucket)
+	}
+	c.InfluxUseFlux = false
+
 	useGen = checkType(FormatTimescaleDB, timescaledb.NewDevops(tsStart, tsEnd, scale))
 	if got := useGen.(*timescaledb.Devops).UseTags; got != c.TimescaleUseTags {
 		t.Errorf("timescaledb UseTags not set correctly: got %v want %v", got, c.TimescaleUseTags)
@@ -279,9 +309,67 @@ func TestGetUseCaseGenerator(t *testing.T) {
 		t.Errorf("timescaledb UseTimeBucket not set correctly: got %v want %v", got, c.TimescaleUseTimeBucket)
 	}
 
+	c.TimescaleContinuousAggregates = "1m,1h"
+	useGen = checkType(FormatTimescaleDB, timescaledb.NewDevops(tsStart, tsEnd, scale))
+	if got := useGen.(*timescaledb.Devops).ContinuousAggregates; len(got) != 2 {
+		t.Errorf("timescaledb ContinuousAggregates not set correctly: got %v", got)
+	}
+	c.TimescaleContinuousAggregates = "foo"
+	if _, err := g.getUseCaseGenerator(c); err == nil {
+		t.Errorf("unexpected lack of error for bad continuous aggregates")
+	}
+	c.TimescaleContinuousAggregates = ""
+
+	c.UsePreparedStatements = true
+	useGen = checkType(FormatTimescaleDB, timescaledb.NewDevops(tsStart, tsEnd, scale))
+	if got := useGen.(*timescaledb.Devops).UsePrepared; !got {
+		t.Errorf("timescaledb UsePrepared not set correctly: got %v", got)
+	}
+	useGen = checkType(FormatClickhouse, clickhouse.NewDevops(tsStart, tsEnd, scale))
+	if got := useGen.(*clickhouse.Devops).UsePrepared; !got {
+		t.Errorf("clickhouse UsePrepared not set correctly: got %v", got)
+	}
+	useGen = checkType(FormatCrateDB, cratedb.NewDevops(tsStart, tsEnd, scale))
+	if got := useGen.(*cratedb.Devops).UsePrepared; !got {
+		t.Errorf("cratedb UsePrepared not set correctly: got %v", got)
+	}
+	c.UsePreparedStatements = false
+
+	c.QueryWindow = 5 * time.Minute
+	c.QueryBucket = 10 * time.Second
+	c.QueryWindowPlacement = internalutils.WindowHotCold
+	useGen = checkType(FormatInflux, influx.NewDevops(tsStart, tsEnd, scale))
+	core := useGen.(*influx.Devops).Core
+	if core.Window != c.QueryWindow || core.Bucket != c.QueryBucket || core.Placement != c.QueryWindowPlacement {
+		t.Errorf("windows not configured correctly: got %v, %v, %s", core.Window, core.Bucket, core.Placement)
+	}
+
+	c.QueryWindowPlacement = "bad placement"
+	_, err := g.getUseCaseGenerator(c)
+	if err == nil {
+		t.Errorf("unexpected lack of error for bad window placement")
+	}
+	c.QueryWindowPlacement = ""
+
+	c.QueryHostDistribution = devops.HostsHotset
+	c.QueryHostHotset = 5
+	useGen = checkType(FormatTimescaleDB, timescaledb.NewDevops(tsStart, tsEnd, scale))
+	core = useGen.(*timescaledb.Devops).Core
+	if core.HostDistribution != c.QueryHostDistribution || core.HostHotset != c.QueryHostHotset {
+		t.Errorf("host distribution not configured correctly: got %s, %v", core.HostDistribution, core.HostHotset)
+	}
+
+	c.QueryHostDistribution = devops.HostsZipfian
+	c.QueryHostSkew = 1
+	_, err = g.getUseCaseGenerator(c)
+	if err == nil {
+		t.Errorf("unexpected lack of error for bad zipfian skew")
+	}
+	c.QueryHostDistribution = ""
+
 	// Test error condition
 	c.Format = "bad format"
-	useGen, err := g.getUseCaseGenerator(c)
+	useGen, err = g.getUseCaseGenerator(c)
 	if err == nil {
 		t.Errorf("unexpected lack of error for bad format")
 	} else if got := err.Error(); got != fmt.Sprintf(errUnknownFormatFmt, c.Format) {
@@ -0,0 +1,248 @@
+// Package metrics exposes live benchmark metrics over HTTP in the Prometheus
+// text exposition format, which Prometheus and OpenMetrics scrapers accept.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// contentType is the content type of the Prometheus text exposition format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultLatencyBuckets are histogram bucket upper bounds in seconds, suitable
+// for query and batch insert latencies
+var DefaultLatencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}
+
+// metric is a single named metric that can write itself in text format
+type metric interface {
+	write(w io.Writer) error
+}
+
+// Registry holds a set of metrics and serves them over HTTP
+type Registry struct {
+	mutex   sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry returns a new, empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) add(m metric) {
+	r.mutex.Lock()
+	r.metrics = append(r.metrics, m)
+	r.mutex.Unlock()
+}
+
+// Write writes all metrics of the Registry to w in text format
+func (r *Registry) Write(w io.Writer) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, m := range r.metrics {
+		if err := m.write(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ServeHTTP writes all metrics of the Registry as an HTTP response
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	r.Write(w)
+}
+
+// Serve starts serving the metrics of r on addr (e.g. ":9090") at /metrics
+// in the background. The returned function stops the server.
+func Serve(addr string, r *Registry) (func() error, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", r)
+	server := &http.Server{Handler: mux}
+	go server.Serve(l)
+	return server.Close, nil
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric
+func writeHeader(w io.Writer, name, help, typ string) error {
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+	return err
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	name  string
+	help  string
+	value uint64 // accessed atomically
+}
+
+// NewCounter returns a new Counter added to r
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{name: name, help: help}
+	r.add(c)
+	return c
+}
+
+// Add increases the Counter by n
+func (c *Counter) Add(n uint64) {
+	atomic.AddUint64(&c.value, n)
+}
+
+// Inc increases the Counter by 1
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) write(w io.Writer) error {
+	if err := writeHeader(w, c.name, c.help, "counter"); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %d\n", c.name, atomic.LoadUint64(&c.value))
+	return err
+}
+
+// funcMetric is a counter or gauge whose value is read from a function, for
+// values that are already tracked elsewhere
+type funcMetric struct {
+	name string
+	help string
+	typ  string
+	fn   func() float64
+}
+
+// NewCounterFunc adds a counter to r whose value is returned by fn
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.add(&funcMetric{name: name, help: help, typ: "counter", fn: fn})
+}
+
+// NewGaugeFunc adds a gauge to r whose value is returned by fn
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.add(&funcMetric{name: name, help: help, typ: "gauge", fn: fn})
+}
+
+func (m *funcMetric) write(w io.Writer) error {
+	if err := writeHeader(w, m.name, m.help, m.typ); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s %s\n", m.name, formatFloat(m.fn()))
+	return err
+}
+
+// histogram holds the observations of one series of a HistogramVec
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative; the last one is +Inf
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec is a set of histograms with the same buckets, one per value of
+// a label (e.g., one per query type). With an empty label name it is a
+// single, unlabeled histogram.
+type HistogramVec struct {
+	name    string
+	help    string
+	label   string
+	buckets []float64
+
+	mutex  sync.Mutex
+	series map[string]*histogram
+}
+
+// NewHistogramVec returns a new HistogramVec added to r, with the given
+// bucket upper bounds in increasing order
+func (r *Registry) NewHistogramVec(name, help, label string, buckets []float64) *HistogramVec {
+	h := &HistogramVec{
+		name:    name,
+		help:    help,
+		label:   label,
+		buckets: buckets,
+		series:  make(map[string]*histogram),
+	}
+	r.add(h)
+	return h
+}
+
+// Observe adds value v to the histogram for label value lv
+func (h *HistogramVec) Observe(lv string, v float64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s, ok := h.series[lv]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets)+1)}
+		h.series[lv] = s
+	}
+	s.counts[sort.SearchFloat64s(h.buckets, v)]++
+	s.sum += v
+	s.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) error {
+	if err := writeHeader(w, h.name, h.help, "histogram"); err != nil {
+		return err
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := h.series[k]
+		labels := ""
+		if len(h.label) > 0 {
+			labels = fmt.Sprintf("%s=\"%s\",", h.label, escapeLabelValue(k))
+		}
+		cumulative := uint64(0)
+		for i, c := range s.counts {
+			cumulative += c
+			le := "+Inf"
+			if i < len(h.buckets) {
+				le = formatFloat(h.buckets[i])
+			}
+			if _, err := fmt.Fprintf(w, "%s_bucket{%sle=\"%s\"} %d\n", h.name, labels, le, cumulative); err != nil {
+				return err
+			}
+		}
+		labels = strings.TrimSuffix(labels, ",")
+		if len(labels) > 0 {
+			labels = "{" + labels + "}"
+		}
+		if _, err := fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", h.name, labels, formatFloat(s.sum), h.name, labels, s.count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabelValue(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_total", "A counter.")
+	c.Inc()
+	c.Add(2)
+	r.NewCounterFunc("test_func_total", "A counter\nfrom a func.", func() float64 { return 1.5 })
+	r.NewGaugeFunc("test_gauge", "A gauge.", func() float64 { return 4 })
+	h := r.NewHistogramVec("test_seconds", "A histogram.", "label", []float64{1, 2})
+	h.Observe(`b "quoted"`, 3)
+	h.Observe("a", 0.5)
+	h.Observe("a", 1)
+	h.Observe("a", 1.5)
+	u := r.NewHistogramVec("test_unlabeled_seconds", "An unlabeled histogram.", "", []float64{1})
+	u.Observe("", 0.5)
+
+	var b bytes.Buffer
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP test_total A counter.
+# TYPE test_total counter
+test_total 3
+# HELP test_func_total A counter\nfrom a func.
+# TYPE test_func_total counter
+test_func_total 1.5
+# HELP test_gauge A gauge.
+# TYPE test_gauge gauge
+test_gauge 4
+# HELP test_seconds A histogram.
+# TYPE test_seconds histogram
+test_seconds_bucket{label="a",le="1"} 2
+test_seconds_bucket{label="a",le="2"} 3
+test_seconds_bucket{label="a",le="+Inf"} 3
+test_seconds_sum{label="a"} 3
+test_seconds_count{label="a"} 3
+test_seconds_bucket{label="b \"quoted\"",le="1"} 0
+test_seconds_bucket{label="b \"quoted\"",le="2"} 0
+test_seconds_bucket{label="b \"quoted\"",le="+Inf"} 1
+test_seconds_sum{label="b \"quoted\""} 3
+test_seconds_count{label="b \"quoted\""} 1
+# HELP test_unlabeled_seconds An unlabeled histogram.
+# TYPE test_unlabeled_seconds histogram
+test_unlabeled_seconds_bucket{le="1"} 1
+test_unlabeled_seconds_bucket{le="+Inf"} 1
+test_unlabeled_seconds_sum 0.5
+test_unlabeled_seconds_count 1
+`
+	if got := b.String(); got != want {
+		t.Errorf("incorrect output\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestServe(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "A counter.").Inc()
+	stop, err := Serve("127.0.0.1:0", r)
+	if err != nil {
+		t.Skipf("cannot listen on localhost: %v", err)
+	}
+	defer stop()
+
+	if _, err := Serve("not an address", r); err == nil {
+		t.Errorf("expected error for invalid address")
+	}
+}
+
+func TestRegistryServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("test_total", "A counter.").Inc()
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != contentType {
+		t.Errorf("incorrect content type: got %s want %s", got, contentType)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(body), "test_total 1\n") {
+		t.Errorf("counter missing from response:\n%s", body)
+	}
+}
@@ -0,0 +1,93 @@
+// Package resources samples the resource usage of processes taking part in a
+// benchmark, so it can be told whether the client or the database is the
+// bottleneck.
+package resources
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// UsageHeader is the CSV header for the values written by Usage.CSV
+const UsageHeader = "client cpu %,client rss MB,gc pauses ms,gcs,goroutines"
+
+// Usage is the resource usage of the benchmark client over a period of time
+type Usage struct {
+	CPUPercent float64       // CPU time used as a percentage of one core
+	RSS        uint64        // resident set size in bytes at the end of the period
+	GCPauses   time.Duration // total time garbage collection stopped the world
+	NumGC      uint32        // number of completed garbage collections
+	Goroutines int           // number of goroutines at the end of the period
+}
+
+// CSV returns the values of u as CSV matching UsageHeader
+func (u Usage) CSV() string {
+	return fmt.Sprintf("%0.2f,%0.2f,%0.3f,%d,%d", u.CPUPercent, float64(u.RSS)/(1<<20), float64(u.GCPauses.Nanoseconds())/1e6, u.NumGC, u.Goroutines)
+}
+
+// String returns a human readable description of u
+func (u Usage) String() string {
+	return fmt.Sprintf("cpu: %0.2f%%, rss: %0.2fMB, gc pauses: %0.3fms in %d gcs, goroutines: %d", u.CPUPercent, float64(u.RSS)/(1<<20), float64(u.GCPauses.Nanoseconds())/1e6, u.NumGC, u.Goroutines)
+}
+
+// ClientSampler samples the resource usage of the current process, i.e. the
+// benchmark client itself
+type ClientSampler struct {
+	proc *process.Process
+
+	prevTime    time.Time
+	prevCPU     float64 // user + system CPU seconds
+	prevPauseNs uint64
+	prevNumGC   uint32
+}
+
+// NewClientSampler returns a ClientSampler whose first sample covers the time
+// from now
+func NewClientSampler() (*ClientSampler, error) {
+	proc, err := process.NewProcess(int32(os.Getpid()))
+	if err != nil {
+		return nil, err
+	}
+	s := &ClientSampler{proc: proc}
+	if _, err = s.Sample(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Sample returns the resource usage since the previous call to Sample (or
+// since the ClientSampler was created)
+func (s *ClientSampler) Sample() (Usage, error) {
+	now := time.Now()
+	times, err := s.proc.Times()
+	if err != nil {
+		return Usage{}, err
+	}
+	mem, err := s.proc.MemoryInfo()
+	if err != nil {
+		return Usage{}, err
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	cpu := times.User + times.System
+	u := Usage{
+		RSS:        mem.RSS,
+		GCPauses:   time.Duration(ms.PauseTotalNs - s.prevPauseNs),
+		NumGC:      ms.NumGC - s.prevNumGC,
+		Goroutines: runtime.NumGoroutine(),
+	}
+	if took := now.Sub(s.prevTime).Seconds(); !s.prevTime.IsZero() && took > 0 {
+		u.CPUPercent = (cpu - s.prevCPU) / took * 100
+	}
+
+	s.prevTime = now
+	s.prevCPU = cpu
+	s.prevPauseNs = ms.PauseTotalNs
+	s.prevNumGC = ms.NumGC
+	return u, nil
+}
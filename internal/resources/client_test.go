@@ -0,0 +1,63 @@
+package resources
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestUsageFormat(t *testing.T) {
+	u := Usage{
+		CPUPercent: 150.5,
+		RSS:        3 << 20,
+		GCPauses:   1500 * time.Microsecond,
+		NumGC:      2,
+		Goroutines: 12,
+	}
+	if got, want := u.CSV(), "150.50,3.00,1.500,2,12"; got != want {
+		t.Errorf("incorrect CSV: got %s want %s", got, want)
+	}
+	if got, want := u.String(), "cpu: 150.50%, rss: 3.00MB, gc pauses: 1.500ms in 2 gcs, goroutines: 12"; got != want {
+		t.Errorf("incorrect string: got %s want %s", got, want)
+	}
+}
+
+func TestClientSampler(t *testing.T) {
+	s, err := NewClientSampler()
+	if err != nil {
+		t.Skipf("cannot sample own process on this platform: %v", err)
+	}
+
+	// burn some CPU and force a garbage collection
+	x := 0
+	for start := time.Now(); time.Since(start) < 50*time.Millisecond; {
+		x++
+	}
+	runtime.GC()
+
+	u, err := s.Sample()
+	if err != nil {
+		t.Fatalf("could not sample: %v", err)
+	}
+	if u.CPUPercent <= 0 {
+		t.Errorf("no CPU usage recorded: %v", u.CPUPercent)
+	}
+	if u.RSS == 0 {
+		t.Errorf("no RSS recorded")
+	}
+	if u.NumGC == 0 {
+		t.Errorf("forced garbage collection not recorded")
+	}
+	if u.Goroutines == 0 {
+		t.Errorf("no goroutines recorded")
+	}
+
+	// the next sample only covers the time since the previous one
+	u, err = s.Sample()
+	if err != nil {
+		t.Fatalf("could not sample: %v", err)
+	}
+	if u.NumGC != 0 {
+		t.Errorf("garbage collection counted twice: got %d", u.NumGC)
+	}
+}
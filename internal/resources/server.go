@@ -0,0 +1,260 @@
+package resources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/process"
+)
+
+// ServerUsageHeader is the CSV header for the values written by ServerUsage.CSV
+const ServerUsageHeader = "time,processes,cpu %,rss MB,disk read MB,disk write MB,open files"
+
+// cgroupRoot is where relative cgroup paths are looked up
+const cgroupRoot = "/sys/fs/cgroup"
+
+const (
+	errServerTargetMsg = "exactly one of a server PID, process name pattern or cgroup has to be given"
+	errNoServerProcMsg = "no server process found"
+)
+
+// ServerConfig selects the database server processes to sample on the local
+// host and where to write the samples to
+type ServerConfig struct {
+	PID     int           // PID of the server process
+	Pattern string        // regular expression matched against the command line of server processes
+	Cgroup  string        // cgroup holding the server processes, absolute or relative to /sys/fs/cgroup
+	File    string        // file to write samples to as CSV
+	Period  time.Duration // time between samples
+}
+
+// Enabled returns whether c selects any server processes
+func (c ServerConfig) Enabled() bool {
+	return c.PID > 0 || len(c.Pattern) > 0 || len(c.Cgroup) > 0
+}
+
+// ServerUsage is the combined resource usage of the server processes over a
+// period of time
+type ServerUsage struct {
+	Time       time.Time // end of the period
+	Processes  int       // number of server processes sampled
+	CPUPercent float64   // CPU time used as a percentage of one core
+	RSS        uint64    // resident set size in bytes at the end of the period
+	ReadBytes  uint64    // bytes read from storage
+	WriteBytes uint64    // bytes written to storage
+	OpenFiles  int64     // open file descriptors at the end of the period
+}
+
+// CSV returns the values of u as CSV matching ServerUsageHeader
+func (u ServerUsage) CSV() string {
+	return fmt.Sprintf("%d,%d,%0.2f,%0.2f,%0.2f,%0.2f,%d", u.Time.Unix(), u.Processes, u.CPUPercent,
+		float64(u.RSS)/(1<<20), float64(u.ReadBytes)/(1<<20), float64(u.WriteBytes)/(1<<20), u.OpenFiles)
+}
+
+// procCounters are the cumulative counters of a process at a sample
+type procCounters struct {
+	cpu        float64 // user + system CPU seconds
+	readBytes  uint64
+	writeBytes uint64
+}
+
+// ServerSampler samples the resource usage of database server processes on
+// the same host. Processes are looked up again at every sample, so servers
+// that fork a process per connection (e.g., PostgreSQL) are fully covered.
+type ServerSampler struct {
+	pids func() ([]int32, error)
+
+	prevTime time.Time
+	prev     map[int32]procCounters
+}
+
+// NewServerSampler returns a ServerSampler for the processes selected by c,
+// whose first sample covers the time from now
+func NewServerSampler(c ServerConfig) (*ServerSampler, error) {
+	s := &ServerSampler{}
+	switch {
+	case c.PID > 0 && len(c.Pattern) == 0 && len(c.Cgroup) == 0:
+		pid := int32(c.PID)
+		s.pids = func() ([]int32, error) { return []int32{pid}, nil }
+	case len(c.Pattern) > 0 && c.PID == 0 && len(c.Cgroup) == 0:
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		s.pids = func() ([]int32, error) { return matchingPIDs(re) }
+	case len(c.Cgroup) > 0 && c.PID == 0 && len(c.Pattern) == 0:
+		path := c.Cgroup
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(cgroupRoot, path)
+		}
+		path = filepath.Join(path, "cgroup.procs")
+		s.pids = func() ([]int32, error) { return cgroupPIDs(path) }
+	default:
+		return nil, errors.New(errServerTargetMsg)
+	}
+
+	u, err := s.Sample()
+	if err != nil {
+		return nil, err
+	}
+	if u.Processes == 0 {
+		return nil, errors.New(errNoServerProcMsg)
+	}
+	return s, nil
+}
+
+// matchingPIDs returns the PIDs of all processes except the current one whose
+// command line (or name, if it has none) matches re
+func matchingPIDs(re *regexp.Regexp) ([]int32, error) {
+	all, err := process.Pids()
+	if err != nil {
+		return nil, err
+	}
+	self := int32(os.Getpid())
+	var pids []int32
+	for _, pid := range all {
+		if pid == self {
+			continue
+		}
+		p, err := process.NewProcess(pid)
+		if err != nil {
+			continue // exited in the meantime
+		}
+		cmd, _ := p.Cmdline()
+		if len(cmd) == 0 {
+			cmd, _ = p.Name()
+		}
+		if re.MatchString(cmd) {
+			pids = append(pids, pid)
+		}
+	}
+	return pids, nil
+}
+
+// cgroupPIDs returns the PIDs listed in a cgroup.procs file
+func cgroupPIDs(path string) ([]int32, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pids []int32
+	for _, line := range strings.Fields(string(data)) {
+		pid, err := strconv.ParseInt(line, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PID in %s: %s", path, line)
+		}
+		pids = append(pids, int32(pid))
+	}
+	return pids, nil
+}
+
+// Sample returns the resource usage since the previous call to Sample (or
+// since the ServerSampler was created). Processes that exit in the meantime
+// are skipped, and processes that appeared since the previous sample count
+// with all of their usage. Disk IO and open files are only available for
+// processes of the same user, unless running as root.
+func (s *ServerSampler) Sample() (ServerUsage, error) {
+	pids, err := s.pids()
+	if err != nil {
+		return ServerUsage{}, err
+	}
+	u := ServerUsage{Time: time.Now()}
+	counters := make(map[int32]procCounters, len(pids))
+	var cpu float64
+	for _, pid := range pids {
+		p, err := process.NewProcess(pid)
+		if err != nil {
+			continue
+		}
+		times, err := p.Times()
+		if err != nil {
+			continue
+		}
+		mem, err := p.MemoryInfo()
+		if err != nil {
+			continue
+		}
+		c := procCounters{cpu: times.User + times.System}
+		if ioc, err := p.IOCounters(); err == nil {
+			c.readBytes = ioc.ReadBytes
+			c.writeBytes = ioc.WriteBytes
+		}
+		if fds, err := p.NumFDs(); err == nil {
+			u.OpenFiles += int64(fds)
+		}
+		counters[pid] = c
+		u.Processes++
+		u.RSS += mem.RSS
+
+		prev := s.prev[pid]
+		cpu += c.cpu - prev.cpu
+		if c.readBytes >= prev.readBytes {
+			u.ReadBytes += c.readBytes - prev.readBytes
+		}
+		if c.writeBytes >= prev.writeBytes {
+			u.WriteBytes += c.writeBytes - prev.writeBytes
+		}
+	}
+	if took := u.Time.Sub(s.prevTime).Seconds(); !s.prevTime.IsZero() && took > 0 {
+		u.CPUPercent = cpu / took * 100
+	}
+
+	s.prevTime = u.Time
+	s.prev = counters
+	return u, nil
+}
+
+// Run writes a sample to w every period until ctx is done
+func (s *ServerSampler) Run(ctx context.Context, w io.Writer, period time.Duration) error {
+	if _, err := fmt.Fprintln(w, ServerUsageHeader); err != nil {
+		return err
+	}
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			u, err := s.Sample()
+			if err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(w, u.CSV()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// SampleServer samples the server processes selected by c every c.Period into
+// c.File until ctx is done. The returned channel receives the error that
+// stopped sampling, or nil, once sampling has stopped and the file is closed.
+func SampleServer(ctx context.Context, c ServerConfig) (<-chan error, error) {
+	s, err := NewServerSampler(c)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(c.File)
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() {
+		err := s.Run(ctx, f, c.Period)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		done <- err
+	}()
+	return done, nil
+}
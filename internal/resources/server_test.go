@@ -0,0 +1,159 @@
+package resources
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerUsageFormat(t *testing.T) {
+	u := ServerUsage{
+		Time:       time.Unix(1500000000, 0),
+		Processes:  3,
+		CPUPercent: 80,
+		RSS:        2 << 20,
+		ReadBytes:  1 << 19,
+		WriteBytes: 5 << 20,
+		OpenFiles:  42,
+	}
+	if got, want := u.CSV(), "1500000000,3,80.00,2.00,0.50,5.00,42"; got != want {
+		t.Errorf("incorrect CSV: got %s want %s", got, want)
+	}
+	if got, want := len(strings.Split(u.CSV(), ",")), len(strings.Split(ServerUsageHeader, ",")); got != want {
+		t.Errorf("CSV does not match header: got %d columns want %d", got, want)
+	}
+}
+
+func TestServerConfigEnabled(t *testing.T) {
+	cases := []struct {
+		c    ServerConfig
+		want bool
+	}{
+		{c: ServerConfig{File: "usage.csv"}, want: false},
+		{c: ServerConfig{PID: 1}, want: true},
+		{c: ServerConfig{Pattern: "postgres"}, want: true},
+		{c: ServerConfig{Cgroup: "system.slice/postgresql.service"}, want: true},
+	}
+	for _, c := range cases {
+		if got := c.c.Enabled(); got != c.want {
+			t.Errorf("incorrect enabled for %+v: got %v want %v", c.c, got, c.want)
+		}
+	}
+}
+
+func TestNewServerSamplerErrors(t *testing.T) {
+	cases := []struct {
+		desc string
+		c    ServerConfig
+	}{
+		{desc: "no target", c: ServerConfig{}},
+		{desc: "two targets", c: ServerConfig{PID: os.Getpid(), Pattern: "postgres"}},
+		{desc: "invalid pattern", c: ServerConfig{Pattern: "("}},
+		{desc: "no matching process", c: ServerConfig{Pattern: "^no such tsbs server process$"}},
+		{desc: "missing cgroup", c: ServerConfig{Cgroup: filepath.Join(os.TempDir(), "no-such-tsbs-cgroup")}},
+	}
+	for _, c := range cases {
+		if _, err := NewServerSampler(c.c); err == nil {
+			t.Errorf("%s: expected error", c.desc)
+		}
+	}
+}
+
+func TestServerSamplerPID(t *testing.T) {
+	s, err := NewServerSampler(ServerConfig{PID: os.Getpid()})
+	if err != nil {
+		t.Skipf("cannot sample own process on this platform: %v", err)
+	}
+
+	// burn some CPU so there is usage to sample
+	x := 0
+	for start := time.Now(); time.Since(start) < 50*time.Millisecond; {
+		x++
+	}
+
+	u, err := s.Sample()
+	if err != nil {
+		t.Fatalf("could not sample: %v", err)
+	}
+	if u.Processes != 1 {
+		t.Errorf("incorrect number of processes: got %d want %d", u.Processes, 1)
+	}
+	if u.CPUPercent <= 0 {
+		t.Errorf("no CPU usage recorded: %v", u.CPUPercent)
+	}
+	if u.RSS == 0 {
+		t.Errorf("no RSS recorded")
+	}
+}
+
+func TestServerSamplerCgroup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tsbs-cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	procs := fmt.Sprintf("%d\n", os.Getpid())
+	if err := ioutil.WriteFile(filepath.Join(dir, "cgroup.procs"), []byte(procs), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewServerSampler(ServerConfig{Cgroup: dir})
+	if err != nil {
+		t.Skipf("cannot sample own process on this platform: %v", err)
+	}
+	u, err := s.Sample()
+	if err != nil {
+		t.Fatalf("could not sample: %v", err)
+	}
+	if u.Processes != 1 {
+		t.Errorf("incorrect number of processes: got %d want %d", u.Processes, 1)
+	}
+}
+
+func TestServerSamplerPattern(t *testing.T) {
+	cmd := exec.Command("sleep", "31.4159")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start a process to sample: %v", err)
+	}
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	s, err := NewServerSampler(ServerConfig{Pattern: `^sleep 31\.4159$`})
+	if err != nil {
+		t.Fatalf("could not find process: %v", err)
+	}
+	u, err := s.Sample()
+	if err != nil {
+		t.Fatalf("could not sample: %v", err)
+	}
+	if u.Processes != 1 {
+		t.Errorf("incorrect number of processes: got %d want %d", u.Processes, 1)
+	}
+}
+
+func TestServerSamplerRun(t *testing.T) {
+	s, err := NewServerSampler(ServerConfig{PID: os.Getpid()})
+	if err != nil {
+		t.Skipf("cannot sample own process on this platform: %v", err)
+	}
+	var b bytes.Buffer
+	ctx, cancel := context.WithTimeout(context.Background(), 55*time.Millisecond)
+	defer cancel()
+	if err := s.Run(ctx, &b, 10*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if lines[0] != ServerUsageHeader {
+		t.Errorf("incorrect header: got %s want %s", lines[0], ServerUsageHeader)
+	}
+	if len(lines) < 3 {
+		t.Errorf("too few samples: got %d lines", len(lines))
+	}
+}
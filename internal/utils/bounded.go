@@ -0,0 +1,52 @@
+package utils
+
+import "sync"
+
+// RunBounded calls fn for each index below n, with up to parallelism calls
+// running at the same time, and returns the first error any of them returns
+// once all started calls have returned. Once a call fails, no further calls
+// are started. A parallelism below 2 calls fn one index after the other.
+func RunBounded(n, parallelism int, fn func(i int) error) error {
+	if parallelism < 2 {
+		for i := 0; i < n; i++ {
+			if err := fn(i); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	sem := make(chan struct{}, parallelism)
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := fn(i); err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return firstErr
+}
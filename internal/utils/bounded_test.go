@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRunBounded(t *testing.T) {
+	for _, parallelism := range []int{0, 1, 3} {
+		var mu sync.Mutex
+		running, maxRunning := 0, 0
+		called := make([]bool, 10)
+		err := RunBounded(len(called), parallelism, func(i int) error {
+			mu.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+			called[i] = true
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		})
+		if err != nil {
+			t.Errorf("unexpected error with parallelism %d: %v", parallelism, err)
+		}
+		want := parallelism
+		if want < 1 {
+			want = 1
+		}
+		if maxRunning > want {
+			t.Errorf("too many calls running with parallelism %d: %d", parallelism, maxRunning)
+		}
+		if parallelism == 3 && maxRunning < 2 {
+			t.Errorf("calls did not run at the same time with parallelism %d", parallelism)
+		}
+		for i, ok := range called {
+			if !ok {
+				t.Errorf("fn not called for %d with parallelism %d", i, parallelism)
+			}
+		}
+	}
+}
+
+func TestRunBoundedSerial(t *testing.T) {
+	for _, parallelism := range []int{-1, 0, 1} {
+		var order []int
+		err := RunBounded(5, parallelism, func(i int) error {
+			order = append(order, i)
+			return nil
+		})
+		if err != nil {
+			t.Errorf("unexpected error with parallelism %d: %v", parallelism, err)
+		}
+		if fmt.Sprint(order) != "[0 1 2 3 4]" {
+			t.Errorf("incorrect call order with parallelism %d: got %v", parallelism, order)
+		}
+	}
+}
+
+func TestRunBoundedError(t *testing.T) {
+	for _, parallelism := range []int{1, 3} {
+		var mu sync.Mutex
+		started := 0
+		err := RunBounded(100, parallelism, func(i int) error {
+			mu.Lock()
+			started++
+			mu.Unlock()
+			if i == 4 {
+				return fmt.Errorf("call %d failed", i)
+			} else if i > 4 {
+				// keep the calls after the failing one running until
+				// long after it has failed
+				time.Sleep(10 * time.Millisecond)
+			}
+			return nil
+		})
+		if err == nil || err.Error() != "call 4 failed" {
+			t.Errorf("incorrect error with parallelism %d: %v", parallelism, err)
+		}
+		// besides the calls up to the failing one, at most the ones
+		// already running beside it have started
+		if max := 5 + parallelism - 1; started > max {
+			t.Errorf("too many calls started after the error with parallelism %d: got %d want at most %d", parallelism, started, max)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const errBadAggregateBucketFmt = "continuous aggregate bucket width has to be a positive whole number of seconds: %s"
+
+// ParseAggregateBuckets parses the comma-separated bucket widths of
+// continuous aggregates, e.g. 1m,1h
+func ParseAggregateBuckets(buckets string) ([]time.Duration, error) {
+	if len(buckets) == 0 {
+		return nil, nil
+	}
+	var ret []time.Duration
+	for _, s := range strings.Split(buckets, ",") {
+		bucket, err := time.ParseDuration(strings.TrimSpace(s))
+		if err != nil || bucket <= 0 || bucket%time.Second != 0 {
+			return nil, fmt.Errorf(errBadAggregateBucketFmt, s)
+		}
+		ret = append(ret, bucket)
+	}
+	return ret, nil
+}
+
+// ContinuousAggregateName returns the name of the continuous aggregate of the
+// table with buckets of the given width, e.g. cpu_60s. tsbs_load_timescaledb
+// creates continuous aggregates by this name and tsbs_generate_queries
+// queries them.
+func ContinuousAggregateName(tableName string, bucket time.Duration) string {
+	return fmt.Sprintf("%s_%ds", tableName, int64(bucket.Seconds()))
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseAggregateBuckets(t *testing.T) {
+	cases := []struct {
+		desc    string
+		buckets string
+		want    []time.Duration
+		errMsg  string
+	}{
+		{desc: "empty"},
+		{desc: "one bucket", buckets: "1m", want: []time.Duration{time.Minute}},
+		{desc: "many buckets", buckets: "1m, 1h,10s", want: []time.Duration{time.Minute, time.Hour, 10 * time.Second}},
+		{desc: "not a duration", buckets: "1m,foo", errMsg: fmt.Sprintf(errBadAggregateBucketFmt, "foo")},
+		{desc: "fraction of a second", buckets: "1500ms", errMsg: fmt.Sprintf(errBadAggregateBucketFmt, "1500ms")},
+		{desc: "not positive", buckets: "0s", errMsg: fmt.Sprintf(errBadAggregateBucketFmt, "0s")},
+		{desc: "negative", buckets: "-1h", errMsg: fmt.Sprintf(errBadAggregateBucketFmt, "-1h")},
+	}
+	for _, c := range cases {
+		got, err := ParseAggregateBuckets(c.buckets)
+		if len(c.errMsg) > 0 {
+			if err == nil || err.Error() != c.errMsg {
+				t.Errorf("%s: incorrect error: got %v want %s", c.desc, err, c.errMsg)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.desc, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: incorrect buckets: got %v want %v", c.desc, got, c.want)
+		}
+	}
+}
+
+func TestContinuousAggregateName(t *testing.T) {
+	cases := []struct {
+		bucket time.Duration
+		want   string
+	}{
+		{bucket: 10 * time.Second, want: "cpu_10s"},
+		{bucket: time.Minute, want: "cpu_60s"},
+		{bucket: time.Hour, want: "cpu_3600s"},
+	}
+	for _, c := range cases {
+		if got := ContinuousAggregateName("cpu", c.bucket); got != c.want {
+			t.Errorf("incorrect name for %v: got %s want %s", c.bucket, got, c.want)
+		}
+	}
+}
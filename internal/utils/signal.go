@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"os/signal"
+)
+
+// NotifyContext returns a copy of parent that is cancelled as soon as one of
+// the given signals is received, or when the returned CancelFunc is called.
+// Once the first signal has arrived the default signal behavior is restored,
+// so a second signal terminates the process immediately.
+func NotifyContext(parent context.Context, sigs ...os.Signal) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, sigs...)
+	go func() {
+		select {
+		case <-c:
+			cancel()
+		case <-ctx.Done():
+		}
+		signal.Stop(c)
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestNotifyContextCancel(t *testing.T) {
+	ctx, cancel := NotifyContext(context.Background(), os.Interrupt)
+	if ctx.Err() != nil {
+		t.Fatalf("context done before cancel: %v", ctx.Err())
+	}
+	cancel()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("context not done after cancel")
+	}
+}
+
+func TestNotifyContextParent(t *testing.T) {
+	parent, parentCancel := context.WithCancel(context.Background())
+	ctx, cancel := NotifyContext(parent, os.Interrupt)
+	defer cancel()
+	parentCancel()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("context not done after parent cancel")
+	}
+}
+
+func TestNotifyContextSignal(t *testing.T) {
+	ctx, cancel := NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("could not find own process: %v", err)
+	}
+	if err := p.Signal(os.Interrupt); err != nil {
+		t.Skipf("cannot send interrupt on this platform: %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Errorf("context not done after signal")
+	}
+}
@@ -2,7 +2,9 @@ package utils
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
+	"strings"
 	"time"
 )
 
@@ -10,10 +12,58 @@ const (
 	// ErrEndBeforeStart is the error message for when a TimeInterval's end time
 	// would be before its start.
 	ErrEndBeforeStart = "end time before start time"
+	// ErrRecentWindowTooLargeFmt is the format of the error message for when
+	// a recent window would not fit in a TimeInterval.
+	ErrRecentWindowTooLargeFmt = "recent window larger than TimeInterval: window %v, interval %v"
+	// ErrUnknownPlacementFmt is the format of the error message for a window
+	// placement that is not one of WindowPlacements.
+	ErrUnknownPlacementFmt = "unknown window placement '%s' (choices: %s)"
 
 	errWindowTooLargeFmt = "random window equal to or larger than TimeInterval: window %v, interval %v"
 )
 
+// Window placements, i.e. strategies for where PlaceWindow puts a window
+// within a TimeInterval
+const (
+	// WindowRandom starts the window at a uniformly random time, like RandWindow
+	WindowRandom = "random"
+	// WindowRecent ends the window at the end of the TimeInterval
+	WindowRecent = "recent"
+	// WindowExponential starts the window at a random time that is
+	// exponentially distributed away from the latest possible start
+	WindowExponential = "exponential"
+	// WindowHotCold starts the window in the most recent (hot) part of the
+	// TimeInterval most of the time, and anywhere otherwise
+	WindowHotCold = "hot-cold"
+)
+
+// WindowPlacements are all the window placements known to PlaceWindow
+var WindowPlacements = []string{WindowRandom, WindowRecent, WindowExponential, WindowHotCold}
+
+const (
+	// ExponentialMeanFraction is the mean distance of windows placed with
+	// WindowExponential from the latest possible start, as a fraction of the
+	// range of possible starts
+	ExponentialMeanFraction = 0.05
+	// HotFraction is the part of the range of possible starts, at its end,
+	// that is hot for WindowHotCold
+	HotFraction = 0.1
+	// HotProbability is the probability of a window placed with
+	// WindowHotCold starting in the hot part
+	HotProbability = 0.9
+)
+
+// ValidateWindowPlacement returns an error if placement is not one of
+// WindowPlacements
+func ValidateWindowPlacement(placement string) error {
+	for _, p := range WindowPlacements {
+		if p == placement {
+			return nil
+		}
+	}
+	return fmt.Errorf(ErrUnknownPlacementFmt, placement, strings.Join(WindowPlacements, ", "))
+}
+
 // TimeInterval represents an interval of time in UTC. That is, regardless of
 // what timezone(s) are used for the beginning and end times, they will be
 // converted to UTC and methods will return them as such.
@@ -103,6 +153,55 @@ func (ti *TimeInterval) MustRandWindow(window time.Duration) *TimeInterval {
 	return res
 }
 
+// PlaceWindow creates a TimeInterval of duration `window` within the time
+// period represented by this TimeInterval, placed according to `placement`,
+// which is one of WindowPlacements.
+func (ti *TimeInterval) PlaceWindow(window time.Duration, placement string) (*TimeInterval, error) {
+	lower := ti.start.UnixNano()
+	upper := ti.end.Add(-window).UnixNano()
+
+	var start int64
+	switch placement {
+	case WindowRandom:
+		return ti.RandWindow(window)
+	case WindowRecent:
+		if upper < lower {
+			return nil, fmt.Errorf(ErrRecentWindowTooLargeFmt, window, ti.end.Sub(ti.start))
+		}
+		start = upper
+	case WindowExponential, WindowHotCold:
+		if upper <= lower {
+			return nil, fmt.Errorf(errWindowTooLargeFmt, window, ti.end.Sub(ti.start))
+		}
+		span := upper - lower
+		if placement == WindowExponential {
+			// wrap around rather than clamp, so the start of the TimeInterval
+			// does not get all of the tail
+			offset := math.Mod(rand.ExpFloat64()*ExponentialMeanFraction*float64(span), float64(span))
+			start = upper - int64(offset)
+		} else if rand.Float64() < HotProbability {
+			hot := int64(HotFraction*float64(span)) + 1
+			start = upper - rand.Int63n(hot)
+		} else {
+			start = lower + rand.Int63n(span)
+		}
+	default:
+		return nil, ValidateWindowPlacement(placement)
+	}
+
+	return NewTimeInterval(time.Unix(0, start), time.Unix(0, start+window.Nanoseconds()))
+}
+
+// MustPlaceWindow is the form of PlaceWindow that cannot error; if it does
+// error, it causes a panic.
+func (ti *TimeInterval) MustPlaceWindow(window time.Duration, placement string) *TimeInterval {
+	res, err := ti.PlaceWindow(window, placement)
+	if err != nil {
+		panic(err.Error())
+	}
+	return res
+}
+
 // Start returns the starting time in UTC.
 func (ti *TimeInterval) Start() time.Time {
 	return ti.start
@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"math/rand"
 	"testing"
 	"time"
 )
@@ -318,3 +319,94 @@ func TestTimeIntervalMustRandWindow(t *testing.T) {
 		})
 	}
 }
+
+func TestTimeIntervalPlaceWindow(t *testing.T) {
+	start := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2016, time.January, 1, 1, 0, 0, 0, time.UTC)
+	ti, err := NewTimeInterval(start, end) // 1 hour duration
+	if err != nil {
+		t.Fatalf("unexpected error creating TimeInterval: got %v", err)
+	}
+	window := 5 * time.Minute
+	latestStart := end.Add(-window)
+
+	rand.Seed(123) // Setting seed for testing purposes.
+	for _, placement := range WindowPlacements {
+		t.Run(placement, func(t *testing.T) {
+			const n = 1000
+			hot := 0
+			for i := 0; i < n; i++ {
+				x, err := ti.PlaceWindow(window, placement)
+				if err != nil {
+					t.Fatalf("unexpected error: got %v", err)
+				}
+				if x.Duration() != window {
+					t.Fatalf("incorrect duration: got %v want %v", x.Duration(), window)
+				}
+				if x.Start().Before(start) || x.End().After(end) {
+					t.Fatalf("window outside of interval: %s - %s", x.StartString(), x.EndString())
+				}
+				if latestStart.Sub(x.Start()) <= 6*time.Minute { // i.e., the last 10% of possible starts
+					hot++
+				}
+			}
+
+			switch placement {
+			case WindowRecent:
+				if hot != n {
+					t.Errorf("recent windows not at the end: %d of %d", hot, n)
+				}
+			case WindowExponential, WindowHotCold:
+				// about 87% and 90% of them, compared to 10% for uniformly random windows
+				if hot < n*8/10 {
+					t.Errorf("too few windows at the end: %d of %d", hot, n)
+				}
+			case WindowRandom:
+				if hot > n*2/10 {
+					t.Errorf("too many windows at the end: %d of %d", hot, n)
+				}
+			}
+		})
+	}
+}
+
+func TestTimeIntervalPlaceWindowErrors(t *testing.T) {
+	start := time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2016, time.January, 1, 1, 0, 0, 0, time.UTC)
+	ti, err := NewTimeInterval(start, end) // 1 hour duration
+	if err != nil {
+		t.Fatalf("unexpected error creating TimeInterval: got %v", err)
+	}
+
+	// a recent window can cover all of the interval, other placements cannot
+	x, err := ti.PlaceWindow(time.Hour, WindowRecent)
+	if err != nil {
+		t.Errorf("unexpected error for recent window as large as interval: got %v", err)
+	} else if x.Start() != start || x.End() != end {
+		t.Errorf("incorrect recent window: got %s - %s", x.StartString(), x.EndString())
+	}
+	for _, placement := range WindowPlacements {
+		window := time.Hour
+		if placement == WindowRecent {
+			window += time.Second
+		}
+		if _, err := ti.PlaceWindow(window, placement); err == nil {
+			t.Errorf("%s: unexpected lack of error for window too large", placement)
+		}
+	}
+
+	_, err = ti.PlaceWindow(time.Minute, "foo")
+	want := fmt.Sprintf(ErrUnknownPlacementFmt, "foo", "random, recent, exponential, hot-cold")
+	if err == nil {
+		t.Errorf("unexpected lack of error for unknown placement")
+	} else if got := err.Error(); got != want {
+		t.Errorf("unexpected error:\ngot\n%v\nwant\n%v", got, want)
+	}
+
+	defer func() {
+		if r := recover(); r != want {
+			t.Errorf("unexpected panic:\ngot\n%v\nwant\n%v", r, want)
+		}
+	}()
+	ti.MustPlaceWindow(time.Minute, "foo")
+}
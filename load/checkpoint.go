@@ -0,0 +1,141 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// checkpointTracker keeps track of which input items have been loaded so that
+// an interrupted load can later be resumed. Items are identified by their
+// position in scan order; a Batch is identified by the position of its first
+// item, since every item belongs to exactly one Batch.
+type checkpointTracker struct {
+	start uint64 // items skipped before scanning started (i.e., resumed from)
+	read  uint64 // items read by the scanner so far; accessed atomically
+
+	mutex    sync.Mutex
+	pending  map[Batch]uint64    // batches being filled or waiting for a worker
+	inFlight map[uint64]struct{} // first items of batches a worker is processing
+}
+
+// newCheckpointTracker returns a checkpointTracker for a scan that starts after
+// skipping the first start items of the input
+func newCheckpointTracker(start uint64) *checkpointTracker {
+	return &checkpointTracker{
+		start:    start,
+		pending:  make(map[Batch]uint64),
+		inFlight: make(map[uint64]struct{}),
+	}
+}
+
+// startBatch records that item number first is the first item put into b
+func (t *checkpointTracker) startBatch(b Batch, first uint64) {
+	t.mutex.Lock()
+	t.pending[b] = first
+	t.mutex.Unlock()
+}
+
+// setRead records that the scanner has read n items. It must be called after
+// startBatch for the Batch the n-th item was put into.
+func (t *checkpointTracker) setRead(n uint64) {
+	atomic.StoreUint64(&t.read, n)
+}
+
+// claim is called by a worker before processing b. Since a Batch may be
+// recycled (e.g. via a sync.Pool) once processed, the returned identifier
+// should be passed to done instead of b itself.
+func (t *checkpointTracker) claim(b Batch) uint64 {
+	t.mutex.Lock()
+	first := t.pending[b]
+	delete(t.pending, b)
+	t.inFlight[first] = struct{}{}
+	t.mutex.Unlock()
+	return first
+}
+
+// done is called by a worker after the Batch identified by first is processed
+func (t *checkpointTracker) done(first uint64) {
+	t.mutex.Lock()
+	delete(t.inFlight, first)
+	t.mutex.Unlock()
+}
+
+// offset returns the number of leading input items that are known to be
+// loaded, including any items skipped at start
+func (t *checkpointTracker) offset() uint64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	ret := atomic.LoadUint64(&t.read)
+	for _, first := range t.pending {
+		if first < ret {
+			ret = first
+		}
+	}
+	for first := range t.inFlight {
+		if first < ret {
+			ret = first
+		}
+	}
+	return t.start + ret
+}
+
+// readCheckpoint returns the offset stored in the checkpoint file fileName
+func readCheckpoint(fileName string) (uint64, error) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return 0, err
+	}
+	offset, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid checkpoint file %s: %v", fileName, err)
+	}
+	return offset, nil
+}
+
+// writeCheckpoint stores offset in the checkpoint file fileName. The file is
+// replaced atomically so a crash never leaves a partially written checkpoint.
+func writeCheckpoint(fileName string, offset uint64) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = fmt.Fprintf(tmp, "%d\n", offset); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), fileName)
+}
+
+// checkpoint periodically writes the offset of t to the checkpoint file until
+// ctx is done, at which point a final checkpoint is written
+func (l *BenchmarkRunner) checkpoint(ctx context.Context, t *checkpointTracker, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := writeCheckpoint(l.checkpointFile, t.offset()); err != nil {
+				fatal("cannot write checkpoint file %s: %v", l.checkpointFile, err)
+			}
+			return
+		case <-ticker.C:
+			if err := writeCheckpoint(l.checkpointFile, t.offset()); err != nil {
+				fatal("cannot write checkpoint file %s: %v", l.checkpointFile, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package load
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpointTrackerOffset(t *testing.T) {
+	cp := newCheckpointTracker(100)
+	if got := cp.offset(); got != 100 {
+		t.Errorf("incorrect offset before scanning: got %d want %d", got, 100)
+	}
+
+	// items 0 and 2 go to b1, item 1 goes to b2
+	b1 := &testBatch{}
+	b2 := &testBatch{}
+	cp.startBatch(b1, 0)
+	cp.setRead(1)
+	cp.startBatch(b2, 1)
+	cp.setRead(2)
+	cp.setRead(3)
+	if got := cp.offset(); got != 100 {
+		t.Errorf("incorrect offset with pending batches: got %d want %d", got, 100)
+	}
+
+	// b2 done, but b1 (with the first item) is not
+	cp.done(cp.claim(b2))
+	if got := cp.offset(); got != 100 {
+		t.Errorf("incorrect offset with out of order ack: got %d want %d", got, 100)
+	}
+
+	// b1 in flight still holds the offset back
+	first := cp.claim(b1)
+	if got := cp.offset(); got != 100 {
+		t.Errorf("incorrect offset with in flight batch: got %d want %d", got, 100)
+	}
+
+	// b1 recycled and reused for item 3 before its done is reported
+	cp.startBatch(b1, 3)
+	cp.setRead(4)
+	cp.done(first)
+	if got := cp.offset(); got != 103 {
+		t.Errorf("incorrect offset with recycled batch: got %d want %d", got, 103)
+	}
+
+	cp.done(cp.claim(b1))
+	if got := cp.offset(); got != 104 {
+		t.Errorf("incorrect offset with all batches done: got %d want %d", got, 104)
+	}
+}
+
+func TestReadWriteCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "load.checkpoint")
+
+	if _, err := readCheckpoint(fileName); err == nil {
+		t.Errorf("expected error reading missing checkpoint file")
+	}
+
+	for _, want := range []uint64{0, 12345, 42} {
+		if err := writeCheckpoint(fileName, want); err != nil {
+			t.Fatalf("could not write checkpoint: %v", err)
+		}
+		got, err := readCheckpoint(fileName)
+		if err != nil {
+			t.Fatalf("could not read checkpoint: %v", err)
+		}
+		if got != want {
+			t.Errorf("incorrect checkpoint: got %d want %d", got, want)
+		}
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("temporary checkpoint files left behind: got %d files want 1", len(files))
+	}
+
+	if err := ioutil.WriteFile(fileName, []byte("not a number\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readCheckpoint(fileName); err == nil {
+		t.Errorf("expected error reading invalid checkpoint file")
+	}
+}
+
+func TestCheckpointFinalWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	br := &BenchmarkRunner{checkpointFile: filepath.Join(dir, "load.checkpoint")}
+	cp := newCheckpointTracker(5)
+	cp.setRead(7)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		br.checkpoint(ctx, cp, time.Hour)
+		close(done)
+	}()
+	cancel()
+	<-done
+
+	got, err := readCheckpoint(br.checkpointFile)
+	if err != nil {
+		t.Fatalf("could not read checkpoint: %v", err)
+	}
+	if got != 12 {
+		t.Errorf("incorrect final checkpoint: got %d want %d", got, 12)
+	}
+}
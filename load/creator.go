@@ -34,3 +34,31 @@ type DBCreatorPost interface {
 	// PostCreateDB does further initialization after the database is created
 	PostCreateDB(dbName string) error
 }
+
+// DBCreatorPostLoad is a DBCreator that also needs to do some work on the
+// database once all the data is loaded (e.g., compressing it). The time it
+// takes is not included in the load rates.
+type DBCreatorPostLoad interface {
+	DBCreator
+
+	// PostLoadDB runs once all the workers are done, before the summary
+	PostLoadDB(dbName string) error
+}
+
+// DBSizer is a DBCreator that can also tell how much disk space the loaded
+// data takes, which the summary reports per point and per metric
+type DBSizer interface {
+	DBCreator
+
+	// DBSize returns the size in bytes of the data of the database
+	DBSize(dbName string) (int64, error)
+}
+
+// DBSizeEstimator is a DBSizer whose sizes are only estimates the database
+// updates periodically, which the summary points out
+type DBSizeEstimator interface {
+	DBSizer
+
+	// DBSizeIsEstimate returns whether DBSize only returns an estimate
+	DBSizeIsEstimate() bool
+}
@@ -0,0 +1,71 @@
+package load
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+// TagExtractor looks up tag values in Points of a particular data system,
+// so that Points can be partitioned by tag without knowing their format
+type TagExtractor interface {
+	// GetTagValue returns the value of tag key in the given Point and whether
+	// the Point has such a tag
+	GetTagValue(p *Point, key string) (string, bool)
+}
+
+// BenchmarkTagExtractor is an optional interface for a Benchmark whose Points
+// can be partitioned by tag value, e.g. via the --partition-by-tag flag
+type BenchmarkTagExtractor interface {
+	Benchmark
+
+	// GetTagExtractor returns the TagExtractor to use for this Benchmark
+	GetTagExtractor() TagExtractor
+}
+
+// HashIndexer consistently puts Points with the same value for a tag on the
+// same channel, by hashing that value. Points without the tag all go to the
+// first channel.
+type HashIndexer struct {
+	extractor  TagExtractor
+	tagKey     string
+	partitions uint
+}
+
+// NewHashIndexer returns a HashIndexer that partitions Points into partitions
+// channels by the value of tag tagKey, as looked up by extractor
+func NewHashIndexer(extractor TagExtractor, tagKey string, partitions uint) *HashIndexer {
+	return &HashIndexer{
+		extractor:  extractor,
+		tagKey:     tagKey,
+		partitions: partitions,
+	}
+}
+
+// GetIndex returns the partition of the Point based on the hash of its tag value
+func (i *HashIndexer) GetIndex(p *Point) int {
+	v, ok := i.extractor.GetTagValue(p, i.tagKey)
+	if !ok {
+		return 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(v))
+	return int(h.Sum32() % uint32(i.partitions))
+}
+
+// TagValueFromList returns the value of tag key in tags, a comma-separated
+// list of key=value pairs (e.g. "hostname=host_0,region=eu-west-1"), as used
+// by several of the data formats
+func TagValueFromList(tags, key string) (string, bool) {
+	for len(tags) > 0 {
+		var pair string
+		if idx := strings.IndexByte(tags, ','); idx >= 0 {
+			pair, tags = tags[:idx], tags[idx+1:]
+		} else {
+			pair, tags = tags, ""
+		}
+		if len(pair) > len(key) && pair[len(key)] == '=' && pair[:len(key)] == key {
+			return pair[len(key)+1:], true
+		}
+	}
+	return "", false
+}
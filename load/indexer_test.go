@@ -0,0 +1,86 @@
+package load
+
+import (
+	"fmt"
+	"testing"
+)
+
+// testExtractor treats Point data as a comma-separated list of key=value tags
+type testExtractor struct{}
+
+func (e *testExtractor) GetTagValue(p *Point, key string) (string, bool) {
+	return TagValueFromList(p.Data.(string), key)
+}
+
+func TestTagValueFromList(t *testing.T) {
+	cases := []struct {
+		desc  string
+		tags  string
+		key   string
+		want  string
+		found bool
+	}{
+		{desc: "empty tags", tags: "", key: "hostname"},
+		{desc: "first tag", tags: "hostname=host_0,region=eu-west-1", key: "hostname", want: "host_0", found: true},
+		{desc: "last tag", tags: "hostname=host_0,region=eu-west-1", key: "region", want: "eu-west-1", found: true},
+		{desc: "empty value", tags: "hostname=,region=eu-west-1", key: "hostname", want: "", found: true},
+		{desc: "missing tag", tags: "hostname=host_0,region=eu-west-1", key: "rack"},
+		{desc: "key is prefix of other key", tags: "hostnames=host_0", key: "hostname"},
+		{desc: "key is suffix of other key", tags: "myhostname=host_0", key: "hostname"},
+	}
+	for _, c := range cases {
+		got, found := TagValueFromList(c.tags, c.key)
+		if got != c.want || found != c.found {
+			t.Errorf("%s: got (%q, %v) want (%q, %v)", c.desc, got, found, c.want, c.found)
+		}
+	}
+}
+
+func TestHashIndexer(t *testing.T) {
+	partitions := uint(4)
+	idx := NewHashIndexer(&testExtractor{}, "hostname", partitions)
+	seen := make(map[int]bool)
+	for i := 0; i < 100; i++ {
+		host := fmt.Sprintf("host_%d", i)
+		p := NewPoint("region=eu-west-1,hostname=" + host)
+		got := idx.GetIndex(p)
+		if got < 0 || got >= int(partitions) {
+			t.Fatalf("index out of range for %s: got %d", host, got)
+		}
+		// same tag value with different other tags must go to the same partition
+		other := NewPoint("hostname=" + host + ",region=us-east-1")
+		if got2 := idx.GetIndex(other); got2 != got {
+			t.Errorf("inconsistent index for %s: got %d and %d", host, got, got2)
+		}
+		seen[got] = true
+	}
+	if len(seen) != int(partitions) {
+		t.Errorf("not all partitions used: got %d want %d", len(seen), partitions)
+	}
+
+	if got := idx.GetIndex(NewPoint("region=eu-west-1")); got != 0 {
+		t.Errorf("incorrect index for point without tag: got %d want 0", got)
+	}
+}
+
+type testTagBenchmark struct {
+	testBenchmark
+}
+
+func (b *testTagBenchmark) GetTagExtractor() TagExtractor { return &testExtractor{} }
+
+func TestGetPointIndexer(t *testing.T) {
+	br := &BenchmarkRunner{}
+	if _, ok := br.getPointIndexer(&testBenchmark{}, 4).(*ConstantIndexer); !ok {
+		t.Errorf("expected benchmark's indexer without --partition-by-tag")
+	}
+
+	br = &BenchmarkRunner{partitionByTag: "hostname", extractor: (&testTagBenchmark{}).GetTagExtractor()}
+	idx, ok := br.getPointIndexer(&testTagBenchmark{}, 4).(*HashIndexer)
+	if !ok {
+		t.Fatalf("expected HashIndexer with --partition-by-tag")
+	}
+	if idx.tagKey != "hostname" || idx.partitions != 4 {
+		t.Errorf("incorrect HashIndexer: got key %s partitions %d", idx.tagKey, idx.partitions)
+	}
+}
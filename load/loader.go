@@ -2,6 +2,7 @@ package load
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log"
@@ -9,7 +10,11 @@ import (
 	"os"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
+
+	"github.com/timescale/tsbs/internal/resources"
+	"github.com/timescale/tsbs/internal/utils"
 )
 
 const (
@@ -22,7 +27,16 @@ const (
 	// SingleQueue is the value for using a single shared queue across all workers
 	SingleQueue = 1
 
-	errDBExistsFmt = "database \"%s\" exists: aborting."
+	errDBExistsFmt       = "database \"%s\" exists: aborting."
+	errResumeNoDBFmt     = "database \"%s\" does not exist: cannot resume."
+	errResumeNoFileMsg   = "--resume requires --checkpoint-file to be set"
+	errNoTagExtractorFmt = "%T does not support --partition-by-tag"
+	errRampPerQueueMsg   = "--ramp-start-workers requires workers to share queues: cannot be used when each worker has its own queue"
+	errRampWorkersFmt    = "--ramp-start-workers must be between the number of queues (%d) and --workers (%d)"
+	errRampStepMsg       = "--ramp-step must be greater than 0"
+	errServerNoFileMsg   = "sampling the server's resource usage requires --server-usage-file to be set"
+
+	interruptedMsg = "interrupted: stopped reading input, waiting for workers to finish outstanding batches\n"
 )
 
 // change for more useful testing
@@ -64,10 +78,30 @@ type BenchmarkRunner struct {
 	reportingPeriod time.Duration
 	fileName        string
 
+	checkpointFile   string
+	checkpointPeriod time.Duration
+	doResume         bool
+
+	partitionByTag string
+
+	rampStartWorkers uint
+	rampStep         uint
+	rampInterval     time.Duration
+
+	metricsAddr       string
+	reportClientUsage bool
+	server            resources.ServerConfig
+
 	// non-flag fields
-	br        *bufio.Reader
-	metricCnt uint64
-	rowCnt    uint64
+	br           *bufio.Reader
+	metricCnt    uint64
+	rowCnt       uint64
+	resumeOffset uint64
+	checkpoints  *checkpointTracker
+	extractor    TagExtractor
+
+	activeWorkers int64 // accessed atomically
+	metrics       *loadMetrics
 }
 
 var loader = &BenchmarkRunner{}
@@ -91,6 +125,20 @@ func GetBenchmarkRunnerWithBatchSize(batchSize uint) *BenchmarkRunner {
 	flag.BoolVar(&loader.doAbortOnExist, "do-abort-on-exist", false, "Whether to abort if a database with the given name already exists.")
 	flag.DurationVar(&loader.reportingPeriod, "reporting-period", 10*time.Second, "Period to report write stats")
 	flag.StringVar(&loader.fileName, "file", "", "File name to read data from")
+	flag.StringVar(&loader.checkpointFile, "checkpoint-file", "", "File to periodically store the number of loaded input items in, for use with --resume (empty = no checkpoints)")
+	flag.DurationVar(&loader.checkpointPeriod, "checkpoint-period", 30*time.Second, "Period to write the checkpoint file")
+	flag.BoolVar(&loader.doResume, "resume", false, "Whether to resume a previous run by skipping the input items stored in --checkpoint-file. The database is not (re)created.")
+	flag.UintVar(&loader.rampStartWorkers, "ramp-start-workers", 0, "Number of workers to start with, adding --ramp-step workers every --ramp-interval up to --workers (0 = start all workers at once)")
+	flag.UintVar(&loader.rampStep, "ramp-step", 1, "Number of workers to add at each step when ramping up workers")
+	flag.DurationVar(&loader.rampInterval, "ramp-interval", 30*time.Second, "Period between steps when ramping up workers")
+	flag.StringVar(&loader.metricsAddr, "metrics-addr", "", "Address to serve live load metrics on in Prometheus text format at /metrics, e.g. :9090 (empty = disabled)")
+	flag.BoolVar(&loader.reportClientUsage, "report-client-usage", false, "Whether to add the CPU, memory, GC and goroutine usage of this client to periodic reports and the summary")
+	flag.IntVar(&loader.server.PID, "server-pid", 0, "PID of a database server process on this host to sample the resource usage of into --server-usage-file")
+	flag.StringVar(&loader.server.Pattern, "server-process", "", "Regular expression matching the command lines of the database server processes on this host to sample the resource usage of into --server-usage-file")
+	flag.StringVar(&loader.server.Cgroup, "server-cgroup", "", "Cgroup (absolute or relative to /sys/fs/cgroup) of the database server processes on this host to sample the resource usage of into --server-usage-file")
+	flag.StringVar(&loader.server.File, "server-usage-file", "", "File to write the CPU, memory, disk IO and open files of the database server to, as CSV")
+	flag.DurationVar(&loader.server.Period, "server-usage-period", time.Second, "Period to sample the resource usage of the database server")
+	flag.StringVar(&loader.partitionByTag, "partition-by-tag", "", "Tag key to consistently hash points on, giving each worker its own queue (e.g., hostname; empty = use the database's default partitioning)")
 
 	return loader
 }
@@ -100,27 +148,150 @@ func (l *BenchmarkRunner) DatabaseName() string {
 	return l.dbName
 }
 
+// Resuming returns whether this run resumes a previous one (--resume flag), in
+// which case the database and its tables already exist and should be reused
+func (l *BenchmarkRunner) Resuming() bool {
+	return l.doResume
+}
+
 // RunBenchmark takes in a Benchmark b, a bufio.Reader br, and holders for number of metrics and rows
-// and uses those to run the load benchmark
+// and uses those to run the load benchmark. Receiving SIGINT or SIGTERM stops the
+// benchmark early, but still lets the workers drain and prints the summary.
 func (l *BenchmarkRunner) RunBenchmark(b Benchmark, workQueues uint) {
+	ctx, stop := utils.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	l.RunBenchmarkContext(ctx, b, workQueues)
+}
+
+// RunBenchmarkContext is like RunBenchmark, but stops scanning input once ctx
+// is done. Batches already handed to workers are still processed, and the
+// summary covers everything loaded up to that point. The post-load step and
+// the size of the database are skipped for such a partial load.
+func (l *BenchmarkRunner) RunBenchmarkContext(ctx context.Context, b Benchmark, workQueues uint) {
 	l.br = l.GetBufferedReader()
 
+	// Find out where a previous run stopped, before touching the DB
+	if l.doResume {
+		if len(l.checkpointFile) == 0 {
+			fatal(errResumeNoFileMsg)
+			return
+		}
+		offset, err := readCheckpoint(l.checkpointFile)
+		if err != nil {
+			fatal("cannot read checkpoint file %s: %v", l.checkpointFile, err)
+			return
+		}
+		l.resumeOffset = offset
+	}
+
+	// Partitioning by tag overrides the queues requested by the Benchmark,
+	// since each partition needs to be handled by a single worker
+	if len(l.partitionByTag) > 0 {
+		tb, ok := b.(BenchmarkTagExtractor)
+		if !ok {
+			fatal(errNoTagExtractorFmt, b)
+			return
+		}
+		l.extractor = tb.GetTagExtractor()
+		workQueues = WorkerPerQueue
+	}
+
+	// Workers can only be added at runtime if they share existing queues
+	startWorkers := l.workers
+	if l.rampStartWorkers > 0 {
+		if workQueues == WorkerPerQueue {
+			fatal(errRampPerQueueMsg)
+			return
+		}
+		if l.rampStartWorkers < workQueues || l.rampStartWorkers > l.workers {
+			fatal(errRampWorkersFmt, workQueues, l.workers)
+			return
+		}
+		if l.rampStep == 0 {
+			fatal(errRampStepMsg)
+			return
+		}
+		startWorkers = l.rampStartWorkers
+	}
+
 	// Create required DB
-	cleanupFn := l.useDBCreator(b.GetDBCreator())
+	dbc := b.GetDBCreator()
+	cleanupFn := l.useDBCreator(dbc)
 	defer cleanupFn()
 
+	if len(l.metricsAddr) > 0 {
+		stopMetrics := l.serveMetrics()
+		defer stopMetrics()
+	}
+
 	channels := l.createChannels(workQueues)
+	if len(l.checkpointFile) > 0 {
+		l.checkpoints = newCheckpointTracker(l.resumeOffset)
+	}
 
-	// Launch all worker processes in background
+	var clientUsage *resources.ClientSampler
+	if l.reportClientUsage {
+		clientUsage = l.newClientSampler()
+	}
+	stopServerSampling := func() {}
+	if l.server.Enabled() {
+		stopServerSampling = l.sampleServer()
+	}
+
+	// Launch all worker processes (or the first ones when ramping) in background
 	var wg sync.WaitGroup
-	for i := 0; i < int(l.workers); i++ {
+	startWorker := func(workerNum int) {
 		wg.Add(1)
-		go l.work(b, &wg, channels[i%len(channels)], i)
+		go l.work(b, &wg, channels[workerNum%len(channels)], workerNum)
+	}
+	for i := 0; i < int(startWorkers); i++ {
+		startWorker(i)
+	}
+
+	// Start background reporting and checkpointing processes. They are not
+	// stopped by ctx, but only once workers are done, so they cover draining
+	bgCtx, stopBg := context.WithCancel(context.Background())
+	defer stopBg()
+	var bgWg sync.WaitGroup
+	if l.reportingPeriod.Nanoseconds() > 0 {
+		bgWg.Add(1)
+		go func() {
+			l.report(bgCtx, l.reportingPeriod)
+			bgWg.Done()
+		}()
+	}
+	if l.checkpoints != nil {
+		bgWg.Add(1)
+		go func() {
+			l.checkpoint(bgCtx, l.checkpoints, l.checkpointPeriod)
+			bgWg.Done()
+		}()
 	}
 
 	// Start scan process - actual data read process
 	start := time.Now()
-	l.scan(b, channels)
+	var rr *rampRecorder
+	rampCtx, stopRamp := context.WithCancel(ctx)
+	defer stopRamp()
+	rampDone := make(chan struct{})
+	if startWorkers < l.workers {
+		rr = newRampRecorder(start, startWorkers)
+		go func() {
+			l.ramp(rampCtx, rr, startWorker)
+			close(rampDone)
+		}()
+	} else {
+		close(rampDone)
+	}
+	l.scan(ctx, b, channels)
+	interrupted := ctx.Err() != nil
+	if interrupted {
+		fmt.Fprint(os.Stderr, interruptedMsg)
+	}
+
+	// No workers may be added once shutdown begins
+	stopRamp()
+	<-rampDone
 
 	// After scan process completed (no more data to come) - begin shutdown process
 
@@ -133,7 +304,31 @@ func (l *BenchmarkRunner) RunBenchmark(b Benchmark, workQueues uint) {
 	wg.Wait()
 	end := time.Now()
 
+	// Stop reporting so no stats line is printed after the summary, and
+	// write the final checkpoint
+	stopBg()
+	bgWg.Wait()
+	stopServerSampling()
+	if !interrupted {
+		l.postLoad(dbc)
+	}
+
 	l.summary(end.Sub(start))
+	if !interrupted {
+		l.sizeSummary(dbc)
+	}
+	if clientUsage != nil {
+		u, err := clientUsage.Sample()
+		if err != nil {
+			fatal("cannot sample client resource usage: %v", err)
+			return
+		}
+		printFn("client resource usage: %s\n", u)
+	}
+	if rr != nil {
+		rr.next(end, 0, l.metricCnt, l.rowCnt)
+		l.rampSummary(rr.steps)
+	}
 }
 
 // GetBufferedReader returns the buffered Reader that should be used by the loader
@@ -174,13 +369,15 @@ func (l *BenchmarkRunner) useDBCreator(dbc DBCreator) func() {
 
 		// Check whether required DB already exists
 		exists := dbc.DBExists(l.dbName)
-		if exists && l.doAbortOnExist {
+		if l.doResume && !exists {
+			panic(fmt.Sprintf(errResumeNoDBFmt, l.dbName))
+		} else if exists && l.doAbortOnExist && !l.doResume {
 			panic(fmt.Sprintf(errDBExistsFmt, l.dbName))
 		}
 
-		// Create required DB if need be
+		// Create required DB if need be (resumed runs reuse the existing one)
 		// In case DB already exists - delete it
-		if l.doCreateDB {
+		if l.doCreateDB && !l.doResume {
 			if exists {
 				err := dbc.RemoveOldDB(l.dbName)
 				if err != nil {
@@ -201,6 +398,19 @@ func (l *BenchmarkRunner) useDBCreator(dbc DBCreator) func() {
 	return closeFn
 }
 
+// postLoad runs the post-load step of dbc, if it has one
+func (l *BenchmarkRunner) postLoad(dbc DBCreator) {
+	if !l.doLoad {
+		return
+	}
+	switch dbcp := dbc.(type) {
+	case DBCreatorPostLoad:
+		if err := dbcp.PostLoadDB(l.dbName); err != nil {
+			fatal("post-load of database %s failed: %v", l.dbName, err)
+		}
+	}
+}
+
 // createChannels create channels from which workers would receive tasks
 // Number of workers may be different from number of channels, thus we may have
 // multiple workers per channel
@@ -227,22 +437,33 @@ func (l *BenchmarkRunner) createChannels(workQueues uint) []*duplexChannel {
 	return channels
 }
 
-// scan launches any needed reporting mechanism and proceeds to scan input data
-// to distribute to workers
-func (l *BenchmarkRunner) scan(b Benchmark, channels []*duplexChannel) uint64 {
-	// Start background reporting process
-	// TODO why it is here? May be it could be moved one level up?
-	if l.reportingPeriod.Nanoseconds() > 0 {
-		go l.report(l.reportingPeriod)
+// scan scans input data to distribute to workers until the input is exhausted,
+// the limit is reached or ctx is done. When resuming, the items loaded by the
+// previous run are skipped first.
+func (l *BenchmarkRunner) scan(ctx context.Context, b Benchmark, channels []*duplexChannel) uint64 {
+	decoder := b.GetPointDecoder(l.br)
+	if l.resumeOffset > 0 {
+		skipped := skipItems(l.resumeOffset, l.br, decoder)
+		printFn("resuming: skipped %d items\n", skipped)
 	}
+	return scanWithIndexer(ctx, channels, l.batchSize, l.limit, l.br, decoder, b.GetBatchFactory(), l.getPointIndexer(b, uint(len(channels))), l.checkpoints)
+}
 
-	// Scan incoming data
-	return scanWithIndexer(channels, l.batchSize, l.limit, l.br, b.GetPointDecoder(l.br), b.GetBatchFactory(), b.GetPointIndexer(uint(len(channels))))
+// getPointIndexer returns a HashIndexer on the --partition-by-tag tag if set,
+// or otherwise the Benchmark's own PointIndexer
+func (l *BenchmarkRunner) getPointIndexer(b Benchmark, maxPartitions uint) PointIndexer {
+	if l.extractor != nil {
+		return NewHashIndexer(l.extractor, l.partitionByTag, maxPartitions)
+	}
+	return b.GetPointIndexer(maxPartitions)
 }
 
 // work is the processing function for each worker in the loader
 func (l *BenchmarkRunner) work(b Benchmark, wg *sync.WaitGroup, c *duplexChannel, workerNum int) {
 
+	atomic.AddInt64(&l.activeWorkers, 1)
+	defer atomic.AddInt64(&l.activeWorkers, -1)
+
 	// Prepare processor
 	proc := b.GetProcessor()
 	proc.Init(workerNum, l.doLoad)
@@ -250,9 +471,18 @@ func (l *BenchmarkRunner) work(b Benchmark, wg *sync.WaitGroup, c *duplexChannel
 	// Process batches coming from duplexChannel.toWorker queue
 	// and send ACKs into duplexChannel.toScanner queue
 	for b := range c.toWorker {
+		var first uint64
+		if l.checkpoints != nil {
+			first = l.checkpoints.claim(b)
+		}
+		batchStart := time.Now()
 		metricCnt, rowCnt := proc.ProcessBatch(b, l.doLoad)
+		l.metrics.observeBatch(time.Since(batchStart))
 		atomic.AddUint64(&l.metricCnt, metricCnt)
 		atomic.AddUint64(&l.rowCnt, rowCnt)
+		if l.checkpoints != nil {
+			l.checkpoints.done(first)
+		}
 		c.sendToScanner()
 	}
 
@@ -276,15 +506,97 @@ func (l *BenchmarkRunner) summary(took time.Duration) {
 	}
 }
 
-// report handles periodic reporting of loading stats
-func (l *BenchmarkRunner) report(period time.Duration) {
+// sizeSummary prints the size of the database, and how many bytes each loaded
+// point (row) and metric takes, if dbc can tell
+func (l *BenchmarkRunner) sizeSummary(dbc DBCreator) {
+	if !l.doLoad {
+		return
+	}
+	switch dbcs := dbc.(type) {
+	case DBSizer:
+		size, err := dbcs.DBSize(l.dbName)
+		if err != nil {
+			printFn("cannot get the size of database %s: %v\n", l.dbName, err)
+			return
+		}
+		printFn("database %s takes %d bytes", l.dbName, size)
+		if dbce, ok := dbc.(DBSizeEstimator); ok && dbce.DBSizeIsEstimate() {
+			printFn(" (estimate)")
+		}
+		if l.doResume {
+			// the counts only cover this run
+			printFn(" (including previous runs)\n")
+			return
+		}
+		if l.rowCnt > 0 {
+			printFn(", %0.2f bytes/point", float64(size)/float64(l.rowCnt))
+		}
+		if l.metricCnt > 0 {
+			printFn(", %0.2f bytes/metric", float64(size)/float64(l.metricCnt))
+		}
+		printFn("\n")
+	}
+}
+
+// newClientSampler returns a sampler of the resource usage of this client
+func (l *BenchmarkRunner) newClientSampler() *resources.ClientSampler {
+	s, err := resources.NewClientSampler()
+	if err != nil {
+		fatal("cannot sample client resource usage: %v", err)
+		return nil
+	}
+	return s
+}
+
+// sampleServer starts sampling the resource usage of the database server
+// into --server-usage-file and returns a function to stop sampling
+func (l *BenchmarkRunner) sampleServer() func() {
+	if len(l.server.File) == 0 {
+		fatal(errServerNoFileMsg)
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done, err := resources.SampleServer(ctx, l.server)
+	if err != nil {
+		cancel()
+		fatal("cannot sample server resource usage: %v", err)
+		return func() {}
+	}
+	return func() {
+		cancel()
+		if err := <-done; err != nil {
+			fatal("cannot sample server resource usage: %v", err)
+		}
+	}
+}
+
+// report handles periodic reporting of loading stats until ctx is done
+func (l *BenchmarkRunner) report(ctx context.Context, period time.Duration) {
 	start := time.Now()
 	prevTime := start
 	prevColCount := uint64(0)
 	prevRowCount := uint64(0)
 
-	printFn("time,per. metric/s,metric total,overall metric/s,per. row/s,row total,overall row/s\n")
-	for now := range time.NewTicker(period).C {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	// When reporting client usage, its columns are added to each line
+	var clientUsage *resources.ClientSampler
+	usageHeader := ""
+	if l.reportClientUsage {
+		clientUsage = l.newClientSampler()
+		usageHeader = "," + resources.UsageHeader
+	}
+
+	printFn("time,per. metric/s,metric total,overall metric/s,per. row/s,row total,overall row/s%s\n", usageHeader)
+	for {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+
 		cCount := atomic.LoadUint64(&l.metricCnt)
 		rCount := atomic.LoadUint64(&l.rowCnt)
 
@@ -292,12 +604,21 @@ func (l *BenchmarkRunner) report(period time.Duration) {
 		took := now.Sub(prevTime)
 		colrate := float64(cCount-prevColCount) / float64(took.Seconds())
 		overallColRate := float64(cCount) / float64(sinceStart.Seconds())
+		usage := ""
+		if clientUsage != nil {
+			u, err := clientUsage.Sample()
+			if err != nil {
+				fatal("cannot sample client resource usage: %v", err)
+				return
+			}
+			usage = "," + u.CSV()
+		}
 		if rCount > 0 {
 			rowrate := float64(rCount-prevRowCount) / float64(took.Seconds())
 			overallRowRate := float64(rCount) / float64(sinceStart.Seconds())
-			printFn("%d,%0.2f,%E,%0.2f,%0.2f,%E,%0.2f\n", now.Unix(), colrate, float64(cCount), overallColRate, rowrate, float64(rCount), overallRowRate)
+			printFn("%d,%0.2f,%E,%0.2f,%0.2f,%E,%0.2f%s\n", now.Unix(), colrate, float64(cCount), overallColRate, rowrate, float64(rCount), overallRowRate, usage)
 		} else {
-			printFn("%d,%0.2f,%E,%0.2f,-,-,-\n", now.Unix(), colrate, float64(cCount), overallColRate)
+			printFn("%d,%0.2f,%E,%0.2f,-,-,-%s\n", now.Unix(), colrate, float64(cCount), overallColRate, usage)
 		}
 
 		prevColCount = cCount
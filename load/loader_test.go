@@ -3,12 +3,18 @@ package load
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/timescale/tsbs/internal/resources"
 )
 
 type testProcessor struct {
@@ -78,6 +84,50 @@ func (c *testCreatorClose) Close() {
 	c.closedCalled = true
 }
 
+type testCreatorPostLoad struct {
+	testCreator
+	errPostLoad bool
+
+	postLoadCalled bool
+}
+
+func (c *testCreatorPostLoad) PostLoadDB(dbName string) error {
+	c.postLoadCalled = true
+	if c.errPostLoad {
+		return fmt.Errorf("post-load error")
+	}
+	return nil
+}
+
+type testCreatorSizer struct {
+	testCreator
+	size    int64
+	errSize bool
+}
+
+func (c *testCreatorSizer) DBSize(dbName string) (int64, error) {
+	if c.errSize {
+		return 0, fmt.Errorf("size error")
+	}
+	return c.size, nil
+}
+
+type testCreatorSizeEstimator struct {
+	testCreatorSizer
+}
+
+func (c *testCreatorSizeEstimator) DBSizeIsEstimate() bool { return true }
+
+type testCreatorPostLoadSizer struct {
+	testCreatorPostLoad
+	sizeCalled bool
+}
+
+func (c *testCreatorPostLoadSizer) DBSize(dbName string) (int64, error) {
+	c.sizeCalled = true
+	return 1000, nil
+}
+
 type testBenchmark struct {
 	processors []*testProcessor
 	offset     int64
@@ -156,6 +206,7 @@ func TestUseDBCreator(t *testing.T) {
 		doCreate     bool
 		doPost       bool
 		doClose      bool
+		resume       bool
 
 		shouldPanic bool
 		errRemove   bool
@@ -220,6 +271,28 @@ func TestUseDBCreator(t *testing.T) {
 			errCreate:   true,
 			shouldPanic: true,
 		},
+		{
+			desc:     "resume, exists = true, does not recreate",
+			doLoad:   true,
+			doCreate: true,
+			exists:   true,
+			doPost:   true,
+			resume:   true,
+		},
+		{
+			desc:         "resume, exists, doAbortOnExist = true, does not panic",
+			doLoad:       true,
+			exists:       true,
+			abortOnExist: true,
+			resume:       true,
+		},
+		{
+			desc:        "resume, exists = false, should panic",
+			doLoad:      true,
+			doCreate:    true,
+			resume:      true,
+			shouldPanic: true,
+		},
 	}
 	testPanic := func(r *BenchmarkRunner, dbc DBCreator, desc string) {
 		defer func() {
@@ -234,6 +307,7 @@ func TestUseDBCreator(t *testing.T) {
 			doLoad:         c.doLoad,
 			doCreateDB:     c.doCreate,
 			doAbortOnExist: c.abortOnExist,
+			doResume:       c.resume,
 		}
 		core := testCreator{
 			exists:    c.exists,
@@ -269,7 +343,7 @@ func TestUseDBCreator(t *testing.T) {
 			if !core.initCalled {
 				t.Errorf("%s: doLoad is true but Init not called", c.desc)
 			}
-			if c.doCreate {
+			if c.doCreate && !c.resume {
 				if !core.createCalled {
 					t.Errorf("%s: doCreate is true but CreateDB not called", c.desc)
 				}
@@ -281,7 +355,9 @@ func TestUseDBCreator(t *testing.T) {
 					t.Errorf("%s: exists is false but RemoveDB was called", c.desc)
 				}
 			} else if core.createCalled {
-				t.Errorf("%s: doCreate is false but CreateDB was called", c.desc)
+				t.Errorf("%s: doCreate is false or resuming but CreateDB was called", c.desc)
+			} else if core.removeCalled {
+				t.Errorf("%s: doCreate is false or resuming but RemoveDB was called", c.desc)
 			}
 			if c.doPost && !core.postCalled {
 				t.Errorf("%s: doPost is true but PostCreateDB not called", c.desc)
@@ -299,6 +375,98 @@ func TestUseDBCreator(t *testing.T) {
 	}
 }
 
+func TestPostLoad(t *testing.T) {
+	oldFatal := fatal
+	defer func() {
+		fatal = oldFatal
+	}()
+	cases := []struct {
+		desc        string
+		doLoad      bool
+		errPostLoad bool
+		wantCalled  bool
+		wantFatal   bool
+	}{
+		{desc: "doLoad is false", doLoad: false},
+		{desc: "post-load", doLoad: true, wantCalled: true},
+		{desc: "post-load error", doLoad: true, errPostLoad: true, wantCalled: true, wantFatal: true},
+	}
+	for _, c := range cases {
+		fatalCalled := false
+		fatal = func(format string, args ...interface{}) {
+			fatalCalled = true
+		}
+		r := &BenchmarkRunner{doLoad: c.doLoad}
+		dbc := &testCreatorPostLoad{errPostLoad: c.errPostLoad}
+		r.postLoad(dbc)
+		if dbc.postLoadCalled != c.wantCalled {
+			t.Errorf("%s: incorrect PostLoadDB call: got %v want %v", c.desc, dbc.postLoadCalled, c.wantCalled)
+		}
+		if fatalCalled != c.wantFatal {
+			t.Errorf("%s: incorrect fatal call: got %v want %v", c.desc, fatalCalled, c.wantFatal)
+		}
+	}
+
+	// DBCreators without a post-load step are skipped
+	r := &BenchmarkRunner{doLoad: true}
+	r.postLoad(&testCreator{})
+}
+
+// testLoadBenchmark is a testBenchmark that can be run, reading a point per
+// byte of input
+type testLoadBenchmark struct {
+	testBenchmark
+	dbc DBCreator
+}
+
+func (b *testLoadBenchmark) GetPointDecoder(_ *bufio.Reader) PointDecoder { return &testDecoder{} }
+func (b *testLoadBenchmark) GetBatchFactory() BatchFactory                { return &testFactory{} }
+func (b *testLoadBenchmark) GetDBCreator() DBCreator                      { return b.dbc }
+
+func TestRunBenchmarkContextPostLoad(t *testing.T) {
+	oldPrintFn := printFn
+	defer func() {
+		printFn = oldPrintFn
+	}()
+	printFn = func(s string, args ...interface{}) (n int, err error) { return 0, nil }
+
+	cases := []struct {
+		desc        string
+		interrupted bool
+		wantCalled  bool
+	}{
+		{desc: "complete load", wantCalled: true},
+		{desc: "interrupted load", interrupted: true},
+	}
+	for _, c := range cases {
+		ctx, cancel := context.WithCancel(context.Background())
+		if c.interrupted {
+			cancel()
+		}
+		r := &BenchmarkRunner{
+			dbName:    "benchmark",
+			batchSize: 1,
+			workers:   1,
+			doLoad:    true,
+			br:        bufio.NewReader(bytes.NewReader([]byte("abc"))),
+		}
+		dbc := &testCreatorPostLoadSizer{}
+		b := &testLoadBenchmark{
+			testBenchmark: testBenchmark{processors: []*testProcessor{{}}},
+			dbc:           dbc,
+		}
+		r.RunBenchmarkContext(ctx, b, SingleQueue)
+		cancel()
+
+		if dbc.postLoadCalled != c.wantCalled {
+			t.Errorf("%s: incorrect PostLoadDB call: got %v want %v", c.desc, dbc.postLoadCalled, c.wantCalled)
+		}
+		if dbc.sizeCalled != c.wantCalled {
+			t.Errorf("%s: incorrect DBSize call: got %v want %v", c.desc, dbc.sizeCalled, c.wantCalled)
+		}
+	}
+}
+
 func TestCreateChannelsAndPartitions(t *testing.T) {
 	cases := []struct {
 		desc           string
@@ -463,6 +631,86 @@ func TestSummary(t *testing.T) {
 	}
 }
 
+func TestSizeSummary(t *testing.T) {
+	cases := []struct {
+		desc    string
+		dbc     DBCreator
+		doLoad  bool
+		resume  bool
+		metrics uint64
+		rows    uint64
+		want    string
+	}{
+		{
+			desc:   "not a sizer",
+			dbc:    &testCreator{},
+			doLoad: true,
+			want:   "",
+		},
+		{
+			desc:    "doLoad is false",
+			dbc:     &testCreatorSizer{size: 1000},
+			metrics: 100,
+			want:    "",
+		},
+		{
+			desc:    "metrics and rows",
+			dbc:     &testCreatorSizer{size: 1000},
+			doLoad:  true,
+			metrics: 400,
+			rows:    40,
+			want:    "database benchmark takes 1000 bytes, 25.00 bytes/point, 2.50 bytes/metric\n",
+		},
+		{
+			desc:    "metrics only",
+			dbc:     &testCreatorSizer{size: 1000},
+			doLoad:  true,
+			metrics: 300,
+			want:    "database benchmark takes 1000 bytes, 3.33 bytes/metric\n",
+		},
+		{
+			desc:    "resumed",
+			dbc:     &testCreatorSizer{size: 1000},
+			doLoad:  true,
+			resume:  true,
+			metrics: 300,
+			want:    "database benchmark takes 1000 bytes (including previous runs)\n",
+		},
+		{
+			desc:    "estimate",
+			dbc:     &testCreatorSizeEstimator{testCreatorSizer{size: 1000}},
+			doLoad:  true,
+			metrics: 400,
+			rows:    40,
+			want:    "database benchmark takes 1000 bytes (estimate), 25.00 bytes/point, 2.50 bytes/metric\n",
+		},
+		{
+			desc:   "size error",
+			dbc:    &testCreatorSizer{errSize: true},
+			doLoad: true,
+			want:   "cannot get the size of database benchmark: size error\n",
+		},
+	}
+
+	oldPrintFn := printFn
+	defer func() {
+		printFn = oldPrintFn
+	}()
+	for _, c := range cases {
+		br := &BenchmarkRunner{dbName: "benchmark", doLoad: c.doLoad, doResume: c.resume}
+		br.metricCnt = c.metrics
+		br.rowCnt = c.rows
+		var b bytes.Buffer
+		printFn = func(s string, args ...interface{}) (n int, err error) {
+			return fmt.Fprintf(&b, s, args...)
+		}
+		br.sizeSummary(c.dbc)
+		if got := b.String(); got != c.want {
+			t.Errorf("%s: incorrect size summary\ngot %s\nwant %s", c.desc, got, c.want)
+		}
+	}
+}
+
 func TestReport(t *testing.T) {
 	var b bytes.Buffer
 	counter := int64(0)
@@ -475,7 +723,12 @@ func TestReport(t *testing.T) {
 	}
 	br := &BenchmarkRunner{}
 	duration := 200 * time.Millisecond
-	go br.report(duration)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		br.report(ctx, duration)
+		close(done)
+	}()
 
 	time.Sleep(25 * time.Millisecond)
 	if got := atomic.LoadInt64(&counter); got != 1 {
@@ -510,4 +763,76 @@ func TestReport(t *testing.T) {
 	if end[len(end)-1:len(end)] == "-" {
 		t.Errorf("TestReport: row report ends in -")
 	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(duration):
+		t.Errorf("TestReport: report did not return after cancel")
+	}
+}
+
+func TestReportClientUsage(t *testing.T) {
+	var b bytes.Buffer
+	var m sync.Mutex
+	printFn = func(s string, args ...interface{}) (n int, err error) {
+		m.Lock()
+		defer m.Unlock()
+		return fmt.Fprintf(&b, s, args...)
+	}
+	br := &BenchmarkRunner{reportClientUsage: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		br.report(ctx, 50*time.Millisecond)
+		close(done)
+	}()
+	time.Sleep(80 * time.Millisecond)
+	cancel()
+	<-done
+
+	m.Lock()
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	m.Unlock()
+	if len(lines) != 2 {
+		t.Fatalf("incorrect number of lines: got %d want %d\n%s", len(lines), 2, strings.Join(lines, "\n"))
+	}
+	header := strings.Split(lines[0], ",")
+	if !strings.HasSuffix(lines[0], ","+resources.UsageHeader) {
+		t.Errorf("client usage missing from header: %s", lines[0])
+	}
+	if got := len(strings.Split(lines[1], ",")); got != len(header) {
+		t.Errorf("incorrect number of columns: got %d want %d", got, len(header))
+	}
+}
+
+func TestSampleServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tsbs-server-usage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	br := &BenchmarkRunner{server: resources.ServerConfig{
+		PID:    os.Getpid(),
+		File:   filepath.Join(dir, "usage.csv"),
+		Period: 10 * time.Millisecond,
+	}}
+	if _, err := resources.NewServerSampler(br.server); err != nil {
+		t.Skipf("cannot sample own process on this platform: %v", err)
+	}
+	stop := br.sampleServer()
+	time.Sleep(35 * time.Millisecond)
+	stop()
+
+	data, err := ioutil.ReadFile(br.server.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if lines[0] != resources.ServerUsageHeader {
+		t.Errorf("incorrect header: got %s want %s", lines[0], resources.ServerUsageHeader)
+	}
+	if len(lines) < 2 {
+		t.Errorf("no samples written")
+	}
 }
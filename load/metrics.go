@@ -0,0 +1,57 @@
+package load
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/timescale/tsbs/internal/metrics"
+)
+
+// loadMetrics are the metrics updated by workers and exported with
+// --metrics-addr. A nil *loadMetrics ignores all updates.
+type loadMetrics struct {
+	batches       *metrics.Counter
+	batchDuration *metrics.HistogramVec
+}
+
+// newLoadMetrics returns a Registry with the metrics of l's load, including
+// the loadMetrics that workers have to update
+func newLoadMetrics(l *BenchmarkRunner) (*metrics.Registry, *loadMetrics) {
+	r := metrics.NewRegistry()
+	r.NewCounterFunc("tsbs_load_metrics_total", "Number of metrics loaded.", func() float64 {
+		return float64(atomic.LoadUint64(&l.metricCnt))
+	})
+	r.NewCounterFunc("tsbs_load_rows_total", "Number of rows loaded.", func() float64 {
+		return float64(atomic.LoadUint64(&l.rowCnt))
+	})
+	r.NewGaugeFunc("tsbs_load_workers", "Number of running workers.", func() float64 {
+		return float64(atomic.LoadInt64(&l.activeWorkers))
+	})
+	m := &loadMetrics{
+		batches:       r.NewCounter("tsbs_load_batches_total", "Number of batches loaded."),
+		batchDuration: r.NewHistogramVec("tsbs_load_batch_duration_seconds", "Time to process a batch.", "", metrics.DefaultLatencyBuckets),
+	}
+	return r, m
+}
+
+// observeBatch records that a worker processed a batch in took
+func (m *loadMetrics) observeBatch(took time.Duration) {
+	if m == nil {
+		return
+	}
+	m.batches.Inc()
+	m.batchDuration.Observe("", took.Seconds())
+}
+
+// serveMetrics starts serving the metrics of the load on --metrics-addr and
+// returns a function to stop serving them
+func (l *BenchmarkRunner) serveMetrics() func() {
+	r, m := newLoadMetrics(l)
+	stop, err := metrics.Serve(l.metricsAddr, r)
+	if err != nil {
+		fatal("cannot serve metrics on %s: %v", l.metricsAddr, err)
+		return func() {}
+	}
+	l.metrics = m
+	return func() { stop() }
+}
@@ -0,0 +1,38 @@
+package load
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadMetrics(t *testing.T) {
+	// a nil loadMetrics (i.e., --metrics-addr not set) ignores updates
+	var nilMetrics *loadMetrics
+	nilMetrics.observeBatch(time.Second)
+
+	br := &BenchmarkRunner{metricCnt: 10, rowCnt: 2, activeWorkers: 3}
+	r, m := newLoadMetrics(br)
+	m.observeBatch(20 * time.Millisecond)
+	m.observeBatch(2 * time.Second)
+
+	var b bytes.Buffer
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"tsbs_load_metrics_total 10\n",
+		"tsbs_load_rows_total 2\n",
+		"tsbs_load_workers 3\n",
+		"tsbs_load_batches_total 2\n",
+		"tsbs_load_batch_duration_seconds_bucket{le=\"0.025\"} 1\n",
+		"tsbs_load_batch_duration_seconds_bucket{le=\"+Inf\"} 2\n",
+		"tsbs_load_batch_duration_seconds_count 2\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in metrics:\n%s", want, got)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package load
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// rampStep holds what was loaded while a fixed number of workers were running
+type rampStep struct {
+	workers uint
+	took    time.Duration
+	metrics uint64
+	rows    uint64
+}
+
+// rampRecorder splits a load into rampSteps, one per number of workers
+type rampRecorder struct {
+	steps []rampStep
+
+	// current (unfinished) step
+	workers   uint
+	stepStart time.Time
+	metrics   uint64 // metrics loaded before stepStart
+	rows      uint64 // rows loaded before stepStart
+}
+
+// newRampRecorder returns a rampRecorder for a load started at start with
+// workers workers
+func newRampRecorder(start time.Time, workers uint) *rampRecorder {
+	return &rampRecorder{workers: workers, stepStart: start}
+}
+
+// next finishes the current step at now, when metrics and rows have been
+// loaded in total, and starts a new step with workers workers
+func (r *rampRecorder) next(now time.Time, workers uint, metrics, rows uint64) {
+	r.steps = append(r.steps, rampStep{
+		workers: r.workers,
+		took:    now.Sub(r.stepStart),
+		metrics: metrics - r.metrics,
+		rows:    rows - r.rows,
+	})
+	r.workers = workers
+	r.stepStart = now
+	r.metrics = metrics
+	r.rows = rows
+}
+
+// ramp starts --ramp-step more workers every --ramp-interval until --workers
+// workers are running or ctx is done. New workers are started by calling
+// startWorker with their worker number.
+func (l *BenchmarkRunner) ramp(ctx context.Context, r *rampRecorder, startWorker func(workerNum int)) {
+	ticker := time.NewTicker(l.rampInterval)
+	defer ticker.Stop()
+
+	workers := r.workers
+	for workers < l.workers {
+		var now time.Time
+		select {
+		case <-ctx.Done():
+			return
+		case now = <-ticker.C:
+		}
+
+		n := workers + l.rampStep
+		if n > l.workers {
+			n = l.workers
+		}
+		r.next(now, n, atomic.LoadUint64(&l.metricCnt), atomic.LoadUint64(&l.rowCnt))
+		printFn("ramp: increasing workers from %d to %d\n", workers, n)
+		for ; workers < n; workers++ {
+			startWorker(int(workers))
+		}
+	}
+}
+
+// rampSummary prints the throughput for each number of workers of a ramped load
+func (l *BenchmarkRunner) rampSummary(steps []rampStep) {
+	printFn("\nConcurrency vs throughput:\n")
+	printFn("workers,took (sec),metrics,metric/s,rows,row/s\n")
+	for _, s := range steps {
+		metricRate := float64(s.metrics) / s.took.Seconds()
+		if l.rowCnt > 0 {
+			rowRate := float64(s.rows) / s.took.Seconds()
+			printFn("%d,%0.3f,%d,%0.2f,%d,%0.2f\n", s.workers, s.took.Seconds(), s.metrics, metricRate, s.rows, rowRate)
+		} else {
+			printFn("%d,%0.3f,%d,%0.2f,-,-\n", s.workers, s.took.Seconds(), s.metrics, metricRate)
+		}
+	}
+}
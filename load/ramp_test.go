@@ -0,0 +1,118 @@
+package load
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRampRecorderNext(t *testing.T) {
+	start := time.Unix(0, 0)
+	r := newRampRecorder(start, 1)
+	r.next(start.Add(2*time.Second), 3, 10, 4)
+	r.next(start.Add(3*time.Second), 5, 25, 4)
+
+	want := []rampStep{
+		{workers: 1, took: 2 * time.Second, metrics: 10, rows: 4},
+		{workers: 3, took: time.Second, metrics: 15, rows: 0},
+	}
+	if !reflect.DeepEqual(r.steps, want) {
+		t.Errorf("incorrect steps: got %v want %v", r.steps, want)
+	}
+	if r.workers != 5 {
+		t.Errorf("incorrect workers for current step: got %d want %d", r.workers, 5)
+	}
+}
+
+func TestRamp(t *testing.T) {
+	oldPrintFn := printFn
+	defer func() {
+		printFn = oldPrintFn
+	}()
+	printFn = func(s string, args ...interface{}) (n int, err error) { return 0, nil }
+	br := &BenchmarkRunner{workers: 6, rampStep: 2, rampInterval: time.Millisecond}
+	r := newRampRecorder(time.Now(), 1)
+	started := []int{}
+	done := make(chan struct{})
+	go func() {
+		br.ramp(context.Background(), r, func(workerNum int) {
+			started = append(started, workerNum)
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("ramp did not stop after starting all workers")
+	}
+
+	if want := []int{1, 2, 3, 4, 5}; !reflect.DeepEqual(started, want) {
+		t.Errorf("incorrect workers started: got %v want %v", started, want)
+	}
+	gotWorkers := []uint{}
+	for _, s := range r.steps {
+		gotWorkers = append(gotWorkers, s.workers)
+	}
+	if want := []uint{1, 3, 5}; !reflect.DeepEqual(gotWorkers, want) {
+		t.Errorf("incorrect step workers: got %v want %v", gotWorkers, want)
+	}
+	if r.workers != 6 {
+		t.Errorf("incorrect workers for last step: got %d want %d", r.workers, 6)
+	}
+}
+
+func TestRampCancelled(t *testing.T) {
+	br := &BenchmarkRunner{workers: 4, rampStep: 1, rampInterval: time.Hour}
+	r := newRampRecorder(time.Now(), 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	br.ramp(ctx, r, func(workerNum int) {
+		t.Errorf("worker %d started after cancel", workerNum)
+	})
+	if len(r.steps) != 0 {
+		t.Errorf("steps recorded after cancel: got %d", len(r.steps))
+	}
+}
+
+func TestRampSummary(t *testing.T) {
+	steps := []rampStep{
+		{workers: 1, took: 2 * time.Second, metrics: 10, rows: 4},
+		{workers: 2, took: 500 * time.Millisecond, metrics: 10, rows: 2},
+	}
+	cases := []struct {
+		desc string
+		rows uint64
+		want string
+	}{
+		{
+			desc: "no rows",
+			rows: 0,
+			want: "\nConcurrency vs throughput:\nworkers,took (sec),metrics,metric/s,rows,row/s\n" +
+				"1,2.000,10,5.00,-,-\n2,0.500,10,20.00,-,-\n",
+		},
+		{
+			desc: "with rows",
+			rows: 6,
+			want: "\nConcurrency vs throughput:\nworkers,took (sec),metrics,metric/s,rows,row/s\n" +
+				"1,2.000,10,5.00,4,2.00\n2,0.500,10,20.00,2,4.00\n",
+		},
+	}
+	oldPrintFn := printFn
+	defer func() {
+		printFn = oldPrintFn
+	}()
+	for _, c := range cases {
+		var b bytes.Buffer
+		printFn = func(s string, args ...interface{}) (n int, err error) {
+			return fmt.Fprintf(&b, s, args...)
+		}
+		br := &BenchmarkRunner{rowCnt: c.rows}
+		br.rampSummary(steps)
+		if got := b.String(); got != c.want {
+			t.Errorf("%s: incorrect summary\ngot %s\nwant %s", c.desc, got, c.want)
+		}
+	}
+}
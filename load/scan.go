@@ -2,6 +2,7 @@ package load
 
 import (
 	"bufio"
+	"context"
 	"reflect"
 )
 
@@ -87,7 +88,9 @@ type PointDecoder interface {
 // Data is decoded by PointDecoder decoder and then placed into appropriate batches, using the supplied PointIndexer,
 // which are then dispatched to workers (duplexChannel chosen by PointIndexer). Scan does flow control to make sure workers are not left idle for too long
 // and also that the scanning process  does not starve them of CPU.
-func scanWithIndexer(channels []*duplexChannel, batchSize uint, limit uint64, br *bufio.Reader, decoder PointDecoder, factory BatchFactory, indexer PointIndexer) uint64 {
+// If ctx is done, reading stops early; items already read are still sent and acknowledged.
+// If cp is not nil, it is kept up to date with the batch each item is placed into.
+func scanWithIndexer(ctx context.Context, channels []*duplexChannel, batchSize uint, limit uint64, br *bufio.Reader, decoder PointDecoder, factory BatchFactory, indexer PointIndexer, cp *checkpointTracker) uint64 {
 	var itemsRead uint64
 	numChannels := len(channels)
 
@@ -134,6 +137,8 @@ func scanWithIndexer(channels []*duplexChannel, batchSize uint, limit uint64, br
 	// so we don't go over a limit (olimit), in order to slow down the scanner so it doesn't starve the workers
 	ocnt := 0
 	olimit := numChannels * cap(channels[0].toWorker) * 3
+	done := ctx.Done()
+readLoop:
 	for {
 
 		// Check whether incoming items limit reached.
@@ -142,6 +147,13 @@ func scanWithIndexer(channels []*duplexChannel, batchSize uint, limit uint64, br
 			break
 		}
 
+		// Check whether we were asked to stop early (e.g., on SIGINT).
+		select {
+		case <-done:
+			break readLoop
+		default:
+		}
+
 		caseLimit := len(cases)
 		if ocnt >= olimit {
 			// We have too many outstanding batches, wait until one finishes (i.e. no default)
@@ -165,7 +177,13 @@ func scanWithIndexer(channels []*duplexChannel, batchSize uint, limit uint64, br
 
 		// Append new item to batch
 		idx := indexer.GetIndex(item)
+		if cp != nil && fillingBatches[idx].Len() == 0 {
+			cp.startBatch(fillingBatches[idx], itemsRead-1)
+		}
 		fillingBatches[idx].Append(item)
+		if cp != nil {
+			cp.setRead(itemsRead)
+		}
 
 		if fillingBatches[idx].Len() >= int(batchSize) {
 			// Batch is full (contains at least batchSize items) - ready to be sent to worker,
@@ -202,3 +220,17 @@ func scanWithIndexer(channels []*duplexChannel, batchSize uint, limit uint64, br
 
 	return itemsRead
 }
+
+// skipItems decodes and discards up to n items from br, e.g. those already
+// loaded by a previous run that is being resumed. It returns the number of
+// items actually skipped, which is less than n only if the input ran out.
+func skipItems(n uint64, br *bufio.Reader, decoder PointDecoder) uint64 {
+	skipped := uint64(0)
+	for skipped < n {
+		if decoder.Decode(br) == nil {
+			break
+		}
+		skipped++
+	}
+	return skipped
+}
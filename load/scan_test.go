@@ -3,6 +3,7 @@ package load
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"io"
 	"testing"
 )
@@ -253,13 +254,81 @@ func TestScanWithIndexer(t *testing.T) {
 						t.Errorf("%s: did not panic when should", c.desc)
 					}
 				}()
-				scanWithIndexer(channels, c.batchSize, c.limit, br, decoder, &testFactory{}, indexer)
+				scanWithIndexer(context.Background(), channels, c.batchSize, c.limit, br, decoder, &testFactory{}, indexer, nil)
 			}()
 			continue
 		} else {
 			go _boringWorker(channels[0])
-			read := scanWithIndexer(channels, c.batchSize, c.limit, br, decoder, &testFactory{}, indexer)
+			read := scanWithIndexer(context.Background(), channels, c.batchSize, c.limit, br, decoder, &testFactory{}, indexer, nil)
 			_checkScan(t, c.desc, decoder.called, read, c.wantCalls)
 		}
 	}
 }
+
+func TestScanWithIndexerCancelled(t *testing.T) {
+	data := []byte{0x00, 0x01, 0x02}
+	br := bufio.NewReader(bytes.NewReader(data))
+	channels := []*duplexChannel{newDuplexChannel(1)}
+	decoder := &testDecoder{0}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	go _boringWorker(channels[0])
+	read := scanWithIndexer(ctx, channels, 1, 0, br, decoder, &testFactory{}, &ConstantIndexer{}, nil)
+	_checkScan(t, "scan w/ cancelled context", decoder.called, read, 0)
+}
+
+func TestScanWithIndexerCheckpoint(t *testing.T) {
+	data := []byte{0x00, 0x01, 0x02, 0x03, 0x04}
+	br := bufio.NewReader(bytes.NewReader(data))
+	channels := []*duplexChannel{newDuplexChannel(1)}
+	decoder := &testDecoder{0}
+	cp := newCheckpointTracker(10)
+
+	go func() {
+		for b := range channels[0].toWorker {
+			cp.done(cp.claim(b))
+			channels[0].sendToScanner()
+		}
+	}()
+	read := scanWithIndexer(context.Background(), channels, 2, 0, br, decoder, &testFactory{}, &ConstantIndexer{}, cp)
+	channels[0].close()
+	_checkScan(t, "scan w/ checkpoint", decoder.called, read, uint64(len(data)))
+	if got := cp.offset(); got != 10+uint64(len(data)) {
+		t.Errorf("incorrect checkpoint offset: got %d want %d", got, 10+len(data))
+	}
+}
+
+func TestSkipItems(t *testing.T) {
+	cases := []struct {
+		desc string
+		n    uint64
+		want uint64
+	}{
+		{
+			desc: "skip none",
+			n:    0,
+			want: 0,
+		},
+		{
+			desc: "skip some",
+			n:    2,
+			want: 2,
+		},
+		{
+			desc: "skip more than available",
+			n:    5,
+			want: 3,
+		},
+	}
+	for _, c := range cases {
+		br := bufio.NewReader(bytes.NewReader([]byte{0x00, 0x01, 0x02}))
+		decoder := &testDecoder{0}
+		if got := skipItems(c.n, br, decoder); got != c.want {
+			t.Errorf("%s: incorrect skipped count: got %d want %d", c.desc, got, c.want)
+		}
+		if decoder.called != c.want {
+			t.Errorf("%s: decoder not called enough: got %d want %d", c.desc, decoder.called, c.want)
+		}
+	}
+}
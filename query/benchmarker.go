@@ -2,13 +2,18 @@ package query
 
 import (
 	"bufio"
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"runtime/pprof"
 	"sync"
+	"syscall"
 	"time"
+
+	"github.com/timescale/tsbs/internal/resources"
+	"github.com/timescale/tsbs/internal/utils"
 )
 
 const (
@@ -17,6 +22,10 @@ const (
 	labelWarmQueries = "warm queries"
 
 	defaultReadSize = 4 << 20 // 4 MB
+
+	errServerNoFileMsg = "sampling the server's resource usage requires --server-usage-file to be set"
+
+	interruptedMsg = "interrupted: stopped reading queries and cancelled outstanding ones\n"
 )
 
 // BenchmarkRunner contains the common components for running a query benchmarking
@@ -31,11 +40,23 @@ type BenchmarkRunner struct {
 	debug          int
 	fileName       string
 
+	sweepWorkers      string
+	sweepStepDuration time.Duration
+	sweepResultsFile  string
+
+	metricsAddr string
+	server      resources.ServerConfig
+
+	plansDir      string
+	plansPerLabel uint64
+
 	// non-flag fields
 	br      *bufio.Reader
 	sp      statProcessor
 	scanner *scanner
 	ch      chan Query
+	metrics *queryMetrics
+	plans   *planRecorder
 }
 
 // NewBenchmarkRunner creates a new instance of BenchmarkRunner which is
@@ -51,12 +72,27 @@ func NewBenchmarkRunner() *BenchmarkRunner {
 	flag.Uint64Var(&spArgs.burnIn, "burn-in", 0, "Number of queries to ignore before collecting statistics.")
 	flag.Uint64Var(&runner.limit, "max-queries", 0, "Limit the number of queries to send, 0 = no limit")
 	flag.Uint64Var(&spArgs.printInterval, "print-interval", 100, "Print timing stats to stderr after this many queries (0 to disable)")
+	flag.DurationVar(&spArgs.reportingPeriod, "reporting-period", 10*time.Second, "Period to print query throughput to stderr (0 to disable)")
+	flag.BoolVar(&spArgs.reportClientUsage, "report-client-usage", false, "Whether to add the CPU, memory, GC and goroutine usage of this client to throughput reports and the summary")
+	flag.StringVar(&spArgs.latencyStats, "latency-stats", latencyStatsExact, "How to keep latencies for medians and percentiles: exact (all of them; memory grows with the number of queries) or reservoir (a fixed size random sample per query type)")
+	flag.Uint64Var(&spArgs.reservoirSize, "latency-reservoir-size", 10000, "Number of latencies sampled per query type with --latency-stats=reservoir")
 	flag.StringVar(&runner.memProfile, "memprofile", "", "Write a memory profile to this file.")
 	flag.UintVar(&runner.workers, "workers", 1, "Number of concurrent requests to make.")
 	flag.BoolVar(&spArgs.prewarmQueries, "prewarm-queries", false, "Run each query twice in a row so the warm query is guaranteed to be a cache hit")
 	flag.BoolVar(&runner.printResponses, "print-responses", false, "Pretty print response bodies for correctness checking (default false).")
 	flag.IntVar(&runner.debug, "debug", 0, "Whether to print debug messages.")
 	flag.StringVar(&runner.fileName, "file", "", "File name to read queries from")
+	flag.StringVar(&runner.metricsAddr, "metrics-addr", "", "Address to serve live query metrics on in Prometheus text format at /metrics, e.g. :9091 (empty = disabled)")
+	flag.IntVar(&runner.server.PID, "server-pid", 0, "PID of a database server process on this host to sample the resource usage of into --server-usage-file")
+	flag.StringVar(&runner.server.Pattern, "server-process", "", "Regular expression matching the command lines of the database server processes on this host to sample the resource usage of into --server-usage-file")
+	flag.StringVar(&runner.server.Cgroup, "server-cgroup", "", "Cgroup (absolute or relative to /sys/fs/cgroup) of the database server processes on this host to sample the resource usage of into --server-usage-file")
+	flag.StringVar(&runner.server.File, "server-usage-file", "", "File to write the CPU, memory, disk IO and open files of the database server to, as CSV")
+	flag.DurationVar(&runner.server.Period, "server-usage-period", time.Second, "Period to sample the resource usage of the database server")
+	flag.StringVar(&runner.sweepWorkers, "sweep-workers", "", "Comma-separated numbers of workers to run all queries with in turn, e.g. 1,2,4,8,16 (overrides --workers; requires --file)")
+	flag.DurationVar(&runner.sweepStepDuration, "sweep-step-duration", 0, "Maximum time to run queries for with each number of workers in --sweep-workers (0 = no limit)")
+	flag.StringVar(&runner.plansDir, "plans-dir", "", "Directory to write the database's plans of sample queries to, in a file per query type (empty = disabled). Plans are captured by the workers, and EXPLAIN ANALYZE runs the query again, so this lowers the overall throughput")
+	flag.Uint64Var(&runner.plansPerLabel, "plans-per-label", 1, "Number of queries of each query type to write the plans of with --plans-dir")
+	flag.StringVar(&runner.sweepResultsFile, "sweep-results-file", "", "File to write the throughput and latencies of each --sweep-workers step to, as JSON")
 
 	runner.sp = newStatProcessor(spArgs)
 	return runner
@@ -90,8 +126,9 @@ type Processor interface {
 	// Init initializes at global state for the Processor, possibly based on its worker number / ID
 	Init(workerNum int)
 
-	// ProcessQuery handles a given query and reports its stats
-	ProcessQuery(q Query, isWarm bool) ([]*Stat, error)
+	// ProcessQuery handles a given query and reports its stats. The query
+	// should be cancelled once ctx is done.
+	ProcessQuery(ctx context.Context, q Query, isWarm bool) ([]*Stat, error)
 }
 
 // GetBufferedReader returns the buffered Reader that should be used by the loader
@@ -115,8 +152,26 @@ func (b *BenchmarkRunner) GetBufferedReader() *bufio.Reader {
 // Run does the bulk of the benchmark execution.
 // It launches a gorountine to track stats, creates workers to process queries,
 // read in the input, execute the queries, and then does cleanup.
+// Receiving SIGINT or SIGTERM stops the benchmark early, but the stats
+// collected so far are still printed.
 func (b *BenchmarkRunner) Run(queryPool *sync.Pool, processorCreateFn ProcessorCreate) {
-	if b.workers == 0 {
+	ctx, stop := utils.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	b.RunContext(ctx, queryPool, processorCreateFn)
+}
+
+// RunContext is like Run, but stops reading queries once ctx is done. ctx is
+// also passed to the Processor, so queries in flight are cancelled; those are
+// left out of the stats.
+func (b *BenchmarkRunner) RunContext(ctx context.Context, queryPool *sync.Pool, processorCreateFn ProcessorCreate) {
+	var sweepWorkers []uint
+	if len(b.sweepWorkers) > 0 {
+		var err error
+		sweepWorkers, err = parseSweepWorkers(b.sweepWorkers)
+		if err != nil {
+			panic(fmt.Sprintf("invalid --sweep-workers: %v", err))
+		}
+	} else if b.workers == 0 {
 		panic("must have at least one worker")
 	}
 
@@ -124,23 +179,103 @@ func (b *BenchmarkRunner) Run(queryPool *sync.Pool, processorCreateFn ProcessorC
 	if spArgs.burnIn > b.limit {
 		panic("burn-in is larger than limit")
 	}
-	b.ch = make(chan Query, b.workers)
+	switch spArgs.latencyStats {
+	case latencyStatsExact, "":
+	case latencyStatsReservoir:
+		if spArgs.reservoirSize == 0 {
+			panic("latency reservoir size must be greater than 0")
+		}
+	default:
+		panic(fmt.Sprintf("unknown latency stats %q: must be %s or %s", spArgs.latencyStats, latencyStatsExact, latencyStatsReservoir))
+	}
+
+	if len(b.metricsAddr) > 0 {
+		stopMetrics := b.serveMetrics()
+		defer stopMetrics()
+	}
+	if b.server.Enabled() {
+		stopServerSampling := b.sampleServer()
+		defer stopServerSampling()
+	}
+	if len(b.plansDir) > 0 {
+		var err error
+		b.plans, err = newPlanRecorder(b.plansDir, b.plansPerLabel)
+		if err != nil {
+			panic(fmt.Sprintf("cannot create plans directory: %v", err))
+		}
+	}
+
+	if len(sweepWorkers) > 0 {
+		b.sweep(ctx, sweepWorkers, queryPool, processorCreateFn)
+	} else {
+		b.runWorkers(ctx, ctx, b.workers, queryPool, processorCreateFn)
+	}
+
+	// (Optional) create a memory profile:
+	if len(b.memProfile) > 0 {
+		f, err := os.Create(b.memProfile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pprof.WriteHeapProfile(f)
+		f.Close()
+	}
+}
+
+// sampleServer starts sampling the resource usage of the database server
+// into --server-usage-file and returns a function to stop sampling
+func (b *BenchmarkRunner) sampleServer() func() {
+	if len(b.server.File) == 0 {
+		panic(errServerNoFileMsg)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done, err := resources.SampleServer(ctx, b.server)
+	if err != nil {
+		cancel()
+		panic(fmt.Sprintf("cannot sample server resource usage: %v", err))
+	}
+	return func() {
+		cancel()
+		if err := <-done; err != nil {
+			log.Fatalf("cannot sample server resource usage: %v", err)
+		}
+	}
+}
+
+// runWorkers runs the queries from the input with the given number of workers
+// until the input is exhausted or scanCtx is done, and returns the wall clock
+// time. Queries are run with ctx, so they are only cancelled once it is done.
+func (b *BenchmarkRunner) runWorkers(ctx, scanCtx context.Context, workers uint, queryPool *sync.Pool, processorCreateFn ProcessorCreate) time.Duration {
+	var clientUsage *resources.ClientSampler
+	if b.sp.getArgs().reportClientUsage {
+		var err error
+		clientUsage, err = resources.NewClientSampler()
+		if err != nil {
+			log.Fatalf("cannot sample client resource usage: %v", err)
+		}
+	}
+
+	b.ch = make(chan Query, workers)
 
 	// Launch the stats processor:
-	go b.sp.process(b.workers)
+	b.sp.process(workers)
 
 	// Launch query processors
 	var wg sync.WaitGroup
-	for i := 0; i < int(b.workers); i++ {
+	for i := 0; i < int(workers); i++ {
 		wg.Add(1)
-		go b.processorHandler(&wg, queryPool, processorCreateFn(), i)
+		go b.processorHandler(ctx, &wg, queryPool, processorCreateFn(), i)
 	}
 
 	// Read in jobs, closing the job channel when done:
 	// Wall clock start time
 	wallStart := time.Now()
-	b.scanner.setReader(b.GetBufferedReader()).scan(queryPool, b.ch)
+	b.scanner.setReader(b.GetBufferedReader()).scan(scanCtx, queryPool, b.ch)
 	close(b.ch)
+	// (a sweep step running out of time is not an interruption)
+	if ctx.Err() != nil {
+		fmt.Fprint(os.Stderr, interruptedMsg)
+	}
 
 	// Block for workers to finish sending requests, closing the stats channel when done:
 	wg.Wait()
@@ -153,25 +288,32 @@ func (b *BenchmarkRunner) Run(queryPool *sync.Pool, processorCreateFn ProcessorC
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	// (Optional) create a memory profile:
-	if len(b.memProfile) > 0 {
-		f, err := os.Create(b.memProfile)
+	if clientUsage != nil {
+		u, err := clientUsage.Sample()
 		if err != nil {
-			log.Fatal(err)
+			log.Fatalf("cannot sample client resource usage: %v", err)
 		}
-		pprof.WriteHeapProfile(f)
-		f.Close()
+		fmt.Printf("client resource usage: %s\n", u)
 	}
+	return wallTook
 }
 
-func (b *BenchmarkRunner) processorHandler(wg *sync.WaitGroup, queryPool *sync.Pool, processor Processor, workerNum int) {
+func (b *BenchmarkRunner) processorHandler(ctx context.Context, wg *sync.WaitGroup, queryPool *sync.Pool, processor Processor, workerNum int) {
+	explainer, ok := processor.(PlanExplainer)
+	if b.plans != nil && !ok {
+		panic(errPlansNotSupportedMsg)
+	}
 	processor.Init(workerNum)
 	for query := range b.ch {
-		stats, err := processor.ProcessQuery(query, false)
-		if err != nil {
+		stats, err := processor.ProcessQuery(ctx, query, false)
+		if err != nil && ctx.Err() != nil {
+			// the query was cancelled by the interruption, so it has no stats
+			queryPool.Put(query)
+			continue
+		} else if err != nil {
 			panic(err)
 		}
+		b.metrics.observe(stats)
 		b.sp.send(stats)
 
 		// If PrewarmQueries is set, we run the query as 'cold' first (see above),
@@ -180,12 +322,25 @@ func (b *BenchmarkRunner) processorHandler(wg *sync.WaitGroup, queryPool *sync.P
 		spArgs := b.sp.getArgs()
 		if spArgs.prewarmQueries {
 			// Warm run
-			stats, err = processor.ProcessQuery(query, true)
-			if err != nil {
+			stats, err = processor.ProcessQuery(ctx, query, true)
+			if err != nil && ctx.Err() != nil {
+				queryPool.Put(query)
+				continue
+			} else if err != nil {
 				panic(err)
 			}
+			b.metrics.observe(stats)
 			b.sp.sendWarm(stats)
 		}
+
+		// The plan is captured after the measured runs, so it neither adds to
+		// their latencies nor warms caches for the warm run. It still takes up
+		// time of the worker, lowering the overall throughput.
+		if b.plans != nil {
+			if err := b.plans.record(explainer, query); err != nil {
+				panic(err)
+			}
+		}
 		queryPool.Put(query)
 	}
 	wg.Done()
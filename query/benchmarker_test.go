@@ -1,6 +1,7 @@
 package query
 
 import (
+	"context"
 	"io/ioutil"
 	"os"
 	"strings"
@@ -18,7 +19,7 @@ func (p *testProcessor) Init(workerNum int) {
 	p.count = 0
 }
 
-func (p *testProcessor) ProcessQuery(_ Query, _ bool) ([]*Stat, error) {
+func (p *testProcessor) ProcessQuery(_ context.Context, _ Query, _ bool) ([]*Stat, error) {
 	p.count++
 	return nil, nil
 }
@@ -36,8 +37,8 @@ func TestProcessorHandler(t *testing.T) {
 	var wg sync.WaitGroup
 	qPool := &testQueryPool
 	wg.Add(2)
-	go b.processorHandler(&wg, qPool, p1, 0)
-	go b.processorHandler(&wg, qPool, p2, 5)
+	go b.processorHandler(context.Background(), &wg, qPool, p1, 0)
+	go b.processorHandler(context.Background(), &wg, qPool, p2, 5)
 	for i := 0; i < qLimit; i++ {
 		q := qPool.Get().(*testQuery)
 		b.ch <- q
@@ -74,8 +75,8 @@ func TestProcessorHandlerPreWarm(t *testing.T) {
 	var wg sync.WaitGroup
 	qPool := &testQueryPool
 	wg.Add(2)
-	go b.processorHandler(&wg, qPool, p1, 0)
-	go b.processorHandler(&wg, qPool, p2, 5)
+	go b.processorHandler(context.Background(), &wg, qPool, p1, 0)
+	go b.processorHandler(context.Background(), &wg, qPool, p2, 5)
 	for i := 0; i < qLimit; i++ {
 		q := qPool.Get().(*testQuery)
 		b.ch <- q
@@ -93,6 +94,51 @@ func TestProcessorHandlerPreWarm(t *testing.T) {
 		t.Errorf("total queries wrong: want %d got %d", 2*qLimit, p1.count+p2.count)
 	}
 }
+
+// cancelProcessor fails its queries once the context is done, as a driver
+// does for a query in flight
+type cancelProcessor struct {
+	testProcessor
+}
+
+func (p *cancelProcessor) ProcessQuery(ctx context.Context, q Query, isWarm bool) ([]*Stat, error) {
+	p.count++
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return []*Stat{GetStat().Init(q.HumanLabelName(), 1.0)}, nil
+}
+
+func TestProcessorHandlerCancelled(t *testing.T) {
+	qLimit := 5
+	sent := 0
+	b := &BenchmarkRunner{}
+	b.sp = &mockStatProcessor{
+		args:   &statProcessorArgs{prewarmQueries: true},
+		onSend: func(_ []*Stat) { sent++ },
+	}
+	b.ch = make(chan Query, qLimit)
+	for i := 0; i < qLimit; i++ {
+		b.ch <- testQueryPool.Get().(*testQuery)
+	}
+	close(b.ch)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p := &cancelProcessor{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	// would panic if the cancelled queries were treated as failures
+	b.processorHandler(ctx, &wg, &testQueryPool, p, 0)
+
+	if p.count != qLimit {
+		t.Errorf("incorrect number of queries run: got %d want %d", p.count, qLimit)
+	}
+	if sent != 0 {
+		t.Errorf("stats of cancelled queries were sent %d times", sent)
+	}
+}
+
 func TestBenchmarkRunnerGetBufferedReaderPanicOnMissingFile(t *testing.T) {
 	dumbFileName := "some-random-file-that-should-not-exist"
 	_, err := os.Stat(dumbFileName)
@@ -304,6 +350,9 @@ func (m *mockStatProcessor) CloseAndWait() {
 	m.closed = true
 	m.wg.Done()
 }
+func (m *mockStatProcessor) statGroups() map[string]*statGroup {
+	return nil
+}
 
 type mockProcessor struct {
 	processRes []*Stat
@@ -312,6 +361,6 @@ type mockProcessor struct {
 }
 
 func (mp *mockProcessor) Init(workerNum int) { mp.initCalled = true }
-func (mp *mockProcessor) ProcessQuery(q Query, isWarm bool) ([]*Stat, error) {
+func (mp *mockProcessor) ProcessQuery(_ context.Context, q Query, isWarm bool) ([]*Stat, error) {
 	return mp.processRes, mp.processErr
 }
@@ -13,6 +13,7 @@ type ClickHouse struct {
 
 	Table    []byte // e.g. "cpu"
 	SqlQuery []byte
+	Params   []interface{} // values bound to the placeholders of SqlQuery, if any
 	id       uint64
 }
 
@@ -45,7 +46,11 @@ func (ch *ClickHouse) SetID(n uint64) {
 
 // String produces a debug-ready description of a Query.
 func (ch *ClickHouse) String() string {
-	return fmt.Sprintf("HumanLabel: %s, HumanDescription: %s, Table: %s, Query: %s", ch.HumanLabel, ch.HumanDescription, ch.Table, ch.SqlQuery)
+	s := fmt.Sprintf("HumanLabel: %s, HumanDescription: %s, Table: %s, Query: %s", ch.HumanLabel, ch.HumanDescription, ch.Table, ch.SqlQuery)
+	if len(ch.Params) > 0 {
+		s += fmt.Sprintf(", Params: %v", ch.Params)
+	}
+	return s
 }
 
 // HumanLabelName returns the human readable name of this Query
@@ -65,6 +70,7 @@ func (ch *ClickHouse) Release() {
 
 	ch.Table = ch.Table[:0]
 	ch.SqlQuery = ch.SqlQuery[:0]
+	ch.Params = nil
 
 	ClickHousePool.Put(ch)
 }
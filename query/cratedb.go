@@ -13,6 +13,7 @@ type CrateDB struct {
 
 	Table    []byte // e.g. "cpu"
 	SqlQuery []byte
+	Params   []interface{} // values bound to the placeholders of SqlQuery, if any
 	id       uint64
 }
 
@@ -41,8 +42,12 @@ func (q *CrateDB) SetID(n uint64) {
 
 // String produces a debug-ready description of a Query.
 func (q *CrateDB) String() string {
-	return fmt.Sprintf("HumanLabel: %s, HumanDescription: %s, Table: %s, Query: %s",
+	s := fmt.Sprintf("HumanLabel: %s, HumanDescription: %s, Table: %s, Query: %s",
 		q.HumanLabel, q.HumanDescription, q.Table, q.SqlQuery)
+	if len(q.Params) > 0 {
+		s += fmt.Sprintf(", Params: %v", q.Params)
+	}
+	return s
 }
 
 func (q *CrateDB) HumanLabelName() []byte {
@@ -61,6 +66,7 @@ func (q *CrateDB) Release() {
 
 	q.Table = q.Table[:0]
 	q.SqlQuery = q.SqlQuery[:0]
+	q.Params = nil
 
 	CrateDBPool.Put(q)
 }
@@ -11,13 +11,17 @@ func TestNewCrateDB(t *testing.T) {
 		if got := len(tq.SqlQuery); got != 0 {
 			t.Errorf("new query has non-0 sql query: got %d", got)
 		}
+		if got := len(tq.Params); got != 0 {
+			t.Errorf("new query has non-0 params: got %d", got)
+		}
 	}
 	tq := NewCrateDB()
 	check(tq)
 	tq.HumanLabel = []byte("foo")
 	tq.HumanDescription = []byte("bar")
 	tq.Table = []byte("table")
-	tq.SqlQuery = []byte("SELECT * FROM *")
+	tq.SqlQuery = []byte("SELECT * FROM * WHERE a = $1")
+	tq.Params = []interface{}{"b"}
 	tq.SetID(1)
 	if got := string(tq.HumanLabelName()); got != "foo" {
 		t.Errorf("incorrect label name: got %s", got)
@@ -0,0 +1,51 @@
+package query
+
+import (
+	"fmt"
+
+	"github.com/timescale/tsbs/internal/metrics"
+)
+
+// queryMetrics are the metrics updated by query workers and exported with
+// --metrics-addr. A nil *queryMetrics ignores all updates. Failed queries are
+// not counted, since a failure stops the benchmark.
+type queryMetrics struct {
+	queries *metrics.Counter
+	latency *metrics.HistogramVec
+}
+
+// newQueryMetrics returns a Registry with the query metrics, as well as the
+// queryMetrics to update them with
+func newQueryMetrics() (*metrics.Registry, *queryMetrics) {
+	r := metrics.NewRegistry()
+	m := &queryMetrics{
+		queries: r.NewCounter("tsbs_query_queries_total", "Number of queries completed."),
+		latency: r.NewHistogramVec("tsbs_query_duration_seconds", "Query latency by query type.", "query", metrics.DefaultLatencyBuckets),
+	}
+	return r, m
+}
+
+// observe records a completed query with the given stats
+func (m *queryMetrics) observe(stats []*Stat) {
+	if m == nil {
+		return
+	}
+	m.queries.Inc()
+	for _, s := range stats {
+		if !s.isPartial {
+			m.latency.Observe(string(s.label), s.value/1e3)
+		}
+	}
+}
+
+// serveMetrics starts serving the query metrics on --metrics-addr and returns
+// a function to stop serving them
+func (b *BenchmarkRunner) serveMetrics() func() {
+	r, m := newQueryMetrics()
+	stop, err := metrics.Serve(b.metricsAddr, r)
+	if err != nil {
+		panic(fmt.Sprintf("cannot serve metrics on %s: %v", b.metricsAddr, err))
+	}
+	b.metrics = m
+	return func() { stop() }
+}
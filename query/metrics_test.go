@@ -0,0 +1,37 @@
+package query
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestQueryMetrics(t *testing.T) {
+	// a nil queryMetrics (i.e., --metrics-addr not set) ignores updates
+	var nilMetrics *queryMetrics
+	nilMetrics.observe([]*Stat{GetStat().Init([]byte("foo"), 1)})
+
+	r, m := newQueryMetrics()
+	m.observe([]*Stat{GetStat().Init([]byte("foo"), 20), GetPartialStat().Init([]byte("foo part"), 10)})
+	m.observe([]*Stat{GetStat().Init([]byte("foo"), 2000)})
+	m.observe(nil)
+
+	var b bytes.Buffer
+	if err := r.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+	got := b.String()
+	for _, want := range []string{
+		"tsbs_query_queries_total 3\n",
+		"tsbs_query_duration_seconds_bucket{query=\"foo\",le=\"0.025\"} 1\n",
+		"tsbs_query_duration_seconds_bucket{query=\"foo\",le=\"+Inf\"} 2\n",
+		"tsbs_query_duration_seconds_count{query=\"foo\"} 2\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in metrics:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "foo part") {
+		t.Errorf("partial stat included in metrics:\n%s", got)
+	}
+}
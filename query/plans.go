@@ -0,0 +1,102 @@
+package query
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	planFileExt = ".plan"
+
+	errPlansNotSupportedMsg = "--plans-dir is not supported by this query runner"
+)
+
+// PlanExplainer is a Processor that can capture the database's plan of a
+// query, e.g. the output of EXPLAIN ANALYZE. Query runners implementing it
+// support --plans-dir.
+type PlanExplainer interface {
+	// ExplainQuery returns the plan of q as text. It is called outside of
+	// the measured query executions, so it may run q again, but it still
+	// takes up time of the worker.
+	ExplainQuery(q Query) (string, error)
+}
+
+var nonFileNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// planFileName returns the name of the file holding the plans of label
+func planFileName(label string) string {
+	name := nonFileNameChars.ReplaceAllString(strings.ToLower(label), "-")
+	return strings.Trim(name, "-") + planFileExt
+}
+
+// planRecorder writes the plans of up to perLabel queries of each label to a
+// file per label in dir
+type planRecorder struct {
+	dir      string
+	perLabel uint64
+
+	mu      sync.Mutex
+	counts  map[string]uint64 // plans reserved per label
+	written map[string]bool   // files created, since labels may share one
+}
+
+// newPlanRecorder returns a planRecorder writing to dir, which is created if
+// it does not exist
+func newPlanRecorder(dir string, perLabel uint64) (*planRecorder, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &planRecorder{
+		dir:      dir,
+		perLabel: perLabel,
+		counts:   make(map[string]uint64),
+		written:  make(map[string]bool),
+	}, nil
+}
+
+// want returns whether the plan of another query of label should be recorded,
+// reserving its place if so
+func (r *planRecorder) want(label string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.counts[label] >= r.perLabel {
+		return false
+	}
+	r.counts[label]++
+	return true
+}
+
+// record explains q with e and writes the plan to the file of its label. The
+// file is truncated by the first plan written to it by r.
+func (r *planRecorder) record(e PlanExplainer, q Query) error {
+	label := string(q.HumanLabelName())
+	if !r.want(label) {
+		return nil
+	}
+	plan, err := e.ExplainQuery(q)
+	if err != nil {
+		return fmt.Errorf("cannot explain query %s: %v", q.HumanDescriptionName(), err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := planFileName(label)
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if !r.written[name] {
+		flags |= os.O_TRUNC
+		r.written[name] = true
+	}
+	f, err := os.OpenFile(filepath.Join(r.dir, name), flags, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%s\n\n%s\n-----\n\n", q, strings.TrimSpace(plan))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+package query
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testExplainProcessor struct {
+	testProcessor
+	explained  int
+	runsBefore []int // queries run before each explanation
+}
+
+func (p *testExplainProcessor) ExplainQuery(q Query) (string, error) {
+	p.explained++
+	p.runsBefore = append(p.runsBefore, p.count)
+	return fmt.Sprintf("plan %d of %s\n", p.explained, q.HumanLabelName()), nil
+}
+
+func TestPlanFileName(t *testing.T) {
+	cases := []struct {
+		label string
+		want  string
+	}{
+		{label: "foo", want: "foo.plan"},
+		{label: "TimescaleDB 1 cpu metric(s), random    1 hosts, random 1h0m0s by 1m", want: "timescaledb-1-cpu-metric-s-random-1-hosts-random-1h0m0s-by-1m.plan"},
+		{label: "Mongo/../max", want: "mongo-max.plan"},
+	}
+	for _, c := range cases {
+		if got := planFileName(c.label); got != c.want {
+			t.Errorf("incorrect file name for %q: got %s want %s", c.label, got, c.want)
+		}
+	}
+}
+
+func TestProcessorHandlerPlans(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tsbs-plans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a stale plan from a previous run is replaced
+	stale := filepath.Join(dir, planFileName("foo"))
+	if err := ioutil.WriteFile(stale, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	b := &BenchmarkRunner{}
+	b.scanner = newScanner(&b.limit)
+	b.sp = newStatProcessor(&statProcessorArgs{limit: &b.limit})
+	b.plans, err = newPlanRecorder(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.ch = make(chan Query, 2)
+
+	p := &testExplainProcessor{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go b.processorHandler(context.Background(), &wg, &testQueryPool, p, 0)
+	for i := 0; i < 5; i++ {
+		for _, label := range []string{"foo", "bar"} {
+			b.ch <- &testQuery{HumanLabel: []byte(label)}
+		}
+	}
+	close(b.ch)
+	wg.Wait()
+
+	if p.count != 10 {
+		t.Errorf("incorrect number of queries run: got %d want %d", p.count, 10)
+	}
+	if p.explained != 4 {
+		t.Errorf("incorrect number of queries explained: got %d want %d", p.explained, 4)
+	}
+	for _, label := range []string{"foo", "bar"} {
+		data, err := ioutil.ReadFile(filepath.Join(dir, planFileName(label)))
+		if err != nil {
+			t.Fatalf("could not read plans of %s: %v", label, err)
+		}
+		plans := strings.Count(string(data), "\n-----\n")
+		if plans != 2 {
+			t.Errorf("incorrect number of plans of %s: got %d want %d", label, plans, 2)
+		}
+		if strings.Contains(string(data), "stale") {
+			t.Errorf("stale plan of %s not replaced", label)
+		}
+	}
+}
+
+func TestProcessorHandlerPlansNotSupported(t *testing.T) {
+	b := &BenchmarkRunner{plans: &planRecorder{}}
+	defer func() {
+		if r := recover(); r != errPlansNotSupportedMsg {
+			t.Errorf("incorrect panic: got %v want %s", r, errPlansNotSupportedMsg)
+		}
+	}()
+	var wg sync.WaitGroup
+	b.processorHandler(context.Background(), &wg, &testQueryPool, &testProcessor{}, 0)
+	t.Errorf("did not panic for a processor that cannot explain queries")
+}
+
+func TestProcessorHandlerPlansAfterWarmRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tsbs-plans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	b := &BenchmarkRunner{}
+	b.scanner = newScanner(&b.limit)
+	b.sp = newStatProcessor(&statProcessorArgs{limit: &b.limit, prewarmQueries: true})
+	b.plans, err = newPlanRecorder(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.ch = make(chan Query, 1)
+	b.ch <- &testQuery{HumanLabel: []byte("foo")}
+	close(b.ch)
+
+	p := &testExplainProcessor{}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	b.processorHandler(context.Background(), &wg, &testQueryPool, p, 0)
+
+	// both the cold and the warm run come before the plan
+	if want := []int{2}; !reflect.DeepEqual(p.runsBefore, want) {
+		t.Errorf("incorrect runs before the plan: got %v want %v", p.runsBefore, want)
+	}
+}
+
+func TestPlanRecorderSharedFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tsbs-plans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := newPlanRecorder(dir, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// both labels map to the file foo-bar.plan
+	p := &testExplainProcessor{}
+	for _, label := range []string{"foo bar", "foo/bar"} {
+		if err := r.record(p, &testQuery{HumanLabel: []byte(label)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "foo-bar.plan"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"plan 1 of foo bar", "plan 2 of foo/bar"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("missing %q in plans:\n%s", want, data)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package query
 
 import (
+	"context"
 	"encoding/gob"
 	"io"
 	"log"
@@ -25,9 +26,11 @@ func (s *scanner) setReader(r io.Reader) *scanner {
 	return s
 }
 
-// scan reads encoded Queries and places them into a channel
-func (s *scanner) scan(pool *sync.Pool, c chan Query) {
+// scan reads encoded Queries and places them into a channel until the input
+// is exhausted, the limit is reached or ctx is done
+func (s *scanner) scan(ctx context.Context, pool *sync.Pool, c chan Query) {
 	decoder := gob.NewDecoder(s.r)
+	done := ctx.Done()
 
 	n := uint64(0)
 	for {
@@ -36,6 +39,13 @@ func (s *scanner) scan(pool *sync.Pool, c chan Query) {
 			break
 		}
 
+		select {
+		case <-done:
+			// asked to stop early (e.g., on SIGINT), time to quit
+			return
+		default:
+		}
+
 		q := pool.Get().(Query)
 		err := decoder.Decode(q)
 		if err == io.EOF {
@@ -47,9 +57,14 @@ func (s *scanner) scan(pool *sync.Pool, c chan Query) {
 			log.Fatal(err)
 		}
 
-		// We have a query, send it to the runner
+		// We have a query, send it to the runner unless asked to stop
 		q.SetID(n)
-		c <- q
+		select {
+		case c <- q:
+		case <-done:
+			pool.Put(q)
+			return
+		}
 
 		// Queries counter
 		n++
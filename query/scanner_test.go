@@ -3,6 +3,7 @@ package query
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/gob"
 	"fmt"
 	"sync"
@@ -66,7 +67,7 @@ func runScan(t *testing.T, b *bytes.Buffer, limit, numQueries uint64, pool *sync
 		wg.Done()
 	}()
 	input := bufio.NewReaderSize(bytes.NewReader(b.Bytes()), 1<<20)
-	scanner.setReader(input).scan(pool, queryChan)
+	scanner.setReader(input).scan(context.Background(), pool, queryChan)
 	close(queryChan)
 	wg.Wait()
 	if got != numQueries {
@@ -113,6 +114,32 @@ func TestScannerLimit(t *testing.T) {
 	}
 }
 
+func TestScannerCancelled(t *testing.T) {
+	totalQueries := uint64(7)
+	var b bytes.Buffer
+	err := encodeQueries(&b, totalQueries, func(i uint64) Query {
+		return &testQuery{
+			HumanLabel:       []byte("testlabel"),
+			HumanDescription: []byte("testDesc"),
+		}
+	})
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	// buffered enough to hold all queries, so a scanner ignoring ctx would not block
+	queryChan := make(chan Query, totalQueries)
+	limit := uint64(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	input := bufio.NewReaderSize(bytes.NewReader(b.Bytes()), 1<<20)
+	newScanner(&limit).setReader(input).scan(ctx, &testQueryPool, queryChan)
+	close(queryChan)
+	if got := len(queryChan); got != 0 {
+		t.Errorf("incorrect num of queries scanned: got: %v want: %v", got, 0)
+	}
+}
+
 func TestScanTimescaleDB(t *testing.T) {
 	labelFmt := "tslabel%d"
 	descFmt := "tsdesc%d"
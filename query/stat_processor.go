@@ -2,9 +2,18 @@ package query
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"sync"
+	"time"
+
+	"github.com/timescale/tsbs/internal/resources"
+)
+
+const (
+	latencyStatsExact     = "exact"
+	latencyStatsReservoir = "reservoir"
 )
 
 // statProcessor is used to collect, analyze, and print query execution statistics.
@@ -14,6 +23,10 @@ type statProcessor interface {
 	sendWarm(stats []*Stat)
 	process(workers uint)
 	CloseAndWait()
+
+	// statGroups returns the statistics of the last run, per label. It must
+	// only be called after CloseAndWait.
+	statGroups() map[string]*statGroup
 }
 
 type statProcessorArgs struct {
@@ -21,6 +34,12 @@ type statProcessorArgs struct {
 	limit          *uint64 // limit is the number of statistics to analyze before stopping
 	burnIn         uint64  // burnIn is the number of statistics to ignore before analyzing
 	printInterval  uint64  // printInterval is how often print intermediate stats (number of queries)
+
+	reportingPeriod time.Duration // reportingPeriod is how often to print query throughput (0 to disable)
+	latencyStats    string        // latencyStats is how latencies are kept for the median and percentiles: exact or reservoir
+	reservoirSize   uint64        // reservoirSize is the number of latencies sampled per label with reservoir latencyStats
+
+	reportClientUsage bool // reportClientUsage tells whether to add the client's resource usage to throughput reports
 }
 
 // statProcessor is used to collect, analyze, and print query execution statistics.
@@ -28,6 +47,8 @@ type defaultStatProcessor struct {
 	args *statProcessorArgs
 	wg   sync.WaitGroup
 	c    chan *Stat // c is the channel for Stats to be sent for processing
+
+	statMapping map[string]*statGroup // statMapping holds the stats of the last run per label
 }
 
 func newStatProcessor(args *statProcessorArgs) statProcessor {
@@ -37,6 +58,18 @@ func newStatProcessor(args *statProcessorArgs) statProcessor {
 	return &defaultStatProcessor{args: args}
 }
 
+// newStatGroup returns a StatGroup that keeps latencies as set by the args
+func (sp *defaultStatProcessor) newStatGroup() *statGroup {
+	switch sp.args.latencyStats {
+	case latencyStatsExact, "":
+		return newStatGroup(*sp.args.limit)
+	case latencyStatsReservoir:
+		return newSampledStatGroup(*sp.args.limit, sp.args.reservoirSize)
+	default:
+		panic(fmt.Sprintf("unknown latency stats %q: must be %s or %s", sp.args.latencyStats, latencyStatsExact, latencyStatsReservoir))
+	}
+}
+
 func (sp *defaultStatProcessor) getArgs() *statProcessorArgs {
 	return sp.args
 }
@@ -62,23 +95,65 @@ func (sp *defaultStatProcessor) sendWarm(stats []*Stat) {
 	sp.send(stats)
 }
 
-// process collects latency results, aggregating them into summary
-// statistics. Optionally, they are printed to stderr at regular intervals.
+// process starts collecting latency results in the background, so stats
+// can be sent as soon as it returns. Collection ends with CloseAndWait.
 func (sp *defaultStatProcessor) process(workers uint) {
 	sp.c = make(chan *Stat, workers)
 	sp.wg.Add(1)
+	go sp.collect(workers)
+}
+
+// collect collects latency results, aggregating them into summary
+// statistics. Optionally, they are printed to stderr at regular intervals.
+func (sp *defaultStatProcessor) collect(workers uint) {
 	const allQueriesLabel = labelAllQueries
 	statMapping := map[string]*statGroup{
-		allQueriesLabel: newStatGroup(*sp.args.limit),
+		allQueriesLabel: sp.newStatGroup(),
 	}
 	// Only needed when differentiating between cold & warm
 	if sp.args.prewarmQueries {
-		statMapping[labelColdQueries] = newStatGroup(*sp.args.limit)
-		statMapping[labelWarmQueries] = newStatGroup(*sp.args.limit)
+		statMapping[labelColdQueries] = sp.newStatGroup()
+		statMapping[labelWarmQueries] = sp.newStatGroup()
+	}
+	sp.statMapping = statMapping
+
+	// Throughput is reported over time for all queries, but the final
+	// figures only cover queries after burn-in
+	start := time.Now()
+	var tick <-chan time.Time
+	var reporter *qpsReporter
+	if sp.args.reportingPeriod > 0 {
+		ticker := time.NewTicker(sp.args.reportingPeriod)
+		defer ticker.Stop()
+		tick = ticker.C
+		var usage *resources.ClientSampler
+		if sp.args.reportClientUsage {
+			var err error
+			usage, err = resources.NewClientSampler()
+			if err != nil {
+				log.Fatalf("cannot sample client resource usage: %v", err)
+			}
+		}
+		reporter = newQPSReporter(os.Stderr, start, usage)
 	}
 
 	i := uint64(0)
-	for stat := range sp.c {
+statsLoop:
+	for {
+		var stat *Stat
+		select {
+		case s, ok := <-sp.c:
+			if !ok {
+				break statsLoop
+			}
+			stat = s
+		case now := <-tick:
+			if err := reporter.report(now, i); err != nil {
+				log.Fatal(err)
+			}
+			continue
+		}
+
 		if i < sp.args.burnIn {
 			i++
 			statPool.Put(stat)
@@ -88,9 +163,10 @@ func (sp *defaultStatProcessor) process(workers uint) {
 			if err != nil {
 				log.Fatal(err)
 			}
+			start = time.Now()
 		}
 		if _, ok := statMapping[string(stat.label)]; !ok {
-			statMapping[string(stat.label)] = newStatGroup(*sp.args.limit)
+			statMapping[string(stat.label)] = sp.newStatGroup()
 		}
 
 		statMapping[string(stat.label)].push(stat.value)
@@ -143,11 +219,61 @@ func (sp *defaultStatProcessor) process(workers uint) {
 	if err != nil {
 		log.Fatal(err)
 	}
+	err = writeQPS(os.Stdout, statMapping, time.Since(start))
+	if err != nil {
+		log.Fatal(err)
+	}
 	sp.wg.Done()
 }
 
+func (sp *defaultStatProcessor) statGroups() map[string]*statGroup {
+	return sp.statMapping
+}
+
 // CloseAndWait closes the stats channel and blocks until the StatProcessor has finished all the stats on its channel.
 func (sp *defaultStatProcessor) CloseAndWait() {
 	close(sp.c)
 	sp.wg.Wait()
 }
+
+// qpsReporter prints the number of completed queries per reporting period,
+// similar to the periodic reports of the load package
+type qpsReporter struct {
+	w         io.Writer
+	usage     *resources.ClientSampler // optional
+	start     time.Time
+	prevTime  time.Time
+	prevCount uint64
+}
+
+// newQPSReporter returns a qpsReporter writing to w for a run started at start.
+// If usage is not nil, the client's resource usage is reported as well.
+func newQPSReporter(w io.Writer, start time.Time, usage *resources.ClientSampler) *qpsReporter {
+	usageHeader := ""
+	if usage != nil {
+		usageHeader = "," + resources.UsageHeader
+	}
+	_, err := fmt.Fprintf(w, "time,per. queries/s,queries total,overall queries/s%s\n", usageHeader)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return &qpsReporter{w: w, usage: usage, start: start, prevTime: start}
+}
+
+// report prints the throughput up to now, when count queries have completed
+func (r *qpsReporter) report(now time.Time, count uint64) error {
+	rate := float64(count-r.prevCount) / now.Sub(r.prevTime).Seconds()
+	overallRate := float64(count) / now.Sub(r.start).Seconds()
+	usage := ""
+	if r.usage != nil {
+		u, err := r.usage.Sample()
+		if err != nil {
+			return err
+		}
+		usage = "," + u.CSV()
+	}
+	_, err := fmt.Fprintf(r.w, "%d,%0.2f,%d,%0.2f%s\n", now.Unix(), rate, count, overallRate, usage)
+	r.prevTime = now
+	r.prevCount = count
+	return err
+}
@@ -1,8 +1,12 @@
 package query
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/timescale/tsbs/internal/resources"
 )
 
 func TestStatProcessorSend(t *testing.T) {
@@ -77,3 +81,58 @@ func TestStatProcessorSendWarm(t *testing.T) {
 		t.Errorf("empty stat array changed channel length: got %d want %d", got, wantLen)
 	}
 }
+
+func TestQPSReporter(t *testing.T) {
+	var b bytes.Buffer
+	start := time.Unix(100, 0)
+	r := newQPSReporter(&b, start, nil)
+	if err := r.report(start.Add(2*time.Second), 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.report(start.Add(4*time.Second), 30); err != nil {
+		t.Fatal(err)
+	}
+	want := "time,per. queries/s,queries total,overall queries/s\n" +
+		"102,5.00,10,5.00\n" +
+		"104,10.00,30,7.50\n"
+	if got := b.String(); got != want {
+		t.Errorf("incorrect report\ngot %s\nwant %s", got, want)
+	}
+}
+
+func TestStatProcessorNewStatGroup(t *testing.T) {
+	limit := uint64(1000)
+	sp := &defaultStatProcessor{args: &statProcessorArgs{limit: &limit}}
+	if sg := sp.newStatGroup(); sg.reservoirSize != 0 || cap(sg.values) != 1000 {
+		t.Errorf("incorrect default stat group: reservoir %d capacity %d", sg.reservoirSize, cap(sg.values))
+	}
+
+	sp.args.latencyStats = latencyStatsReservoir
+	sp.args.reservoirSize = 10
+	if sg := sp.newStatGroup(); sg.reservoirSize != 10 || cap(sg.values) != 10 {
+		t.Errorf("incorrect reservoir stat group: reservoir %d capacity %d", sg.reservoirSize, cap(sg.values))
+	}
+}
+
+func TestQPSReporterClientUsage(t *testing.T) {
+	usage, err := resources.NewClientSampler()
+	if err != nil {
+		t.Skipf("cannot sample own process on this platform: %v", err)
+	}
+	var b bytes.Buffer
+	start := time.Now()
+	r := newQPSReporter(&b, start, usage)
+	if err := r.report(start.Add(time.Second), 10); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("incorrect number of lines: got %d want %d", len(lines), 2)
+	}
+	if !strings.HasSuffix(lines[0], ","+resources.UsageHeader) {
+		t.Errorf("client usage missing from header: %s", lines[0])
+	}
+	if got, want := len(strings.Split(lines[1], ",")), len(strings.Split(lines[0], ",")); got != want {
+		t.Errorf("incorrect number of columns: got %d want %d", got, want)
+	}
+}
@@ -4,10 +4,16 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"math/rand"
 	"sort"
 	"sync"
+	"time"
 )
 
+// reservoirSeed seeds the sampling of values by sampled StatGroups, so that
+// the same run gives the same results
+const reservoirSeed = 1
+
 // Stat represents one statistical measurement, typically used to store the
 // latency of a query (or part of query).
 type Stat struct {
@@ -69,26 +75,81 @@ type statGroup struct {
 	stdDev float64
 
 	count int64
+
+	// reservoirSize bounds the number of values kept (0 = keep all of them).
+	// Once reached, values holds a uniform random sample of all pushed values.
+	reservoirSize uint64
+	rand          *rand.Rand
 }
 
-// newStatGroup returns a new StatGroup with an initial size
+// newStatGroup returns a new StatGroup with an initial size that keeps every
+// pushed value, so its median and percentiles are exact
 func newStatGroup(size uint64) *statGroup {
 	return &statGroup{
-		values: make([]float64, size),
+		values: make([]float64, 0, size),
 		count:  0,
 	}
 }
 
+// newSampledStatGroup returns a new StatGroup with an initial size that keeps
+// at most reservoirSize values, sampled from all pushed values. Median and
+// percentiles are then estimated from the sample: with about 95% confidence,
+// the value reported for percentile p (as a fraction) lies between the true
+// percentiles p-e and p+e, where e = 2*sqrt(p*(1-p)/reservoirSize). E.g., with
+// 10000 values the reported median is between the true 49th and 51st
+// percentiles, and the reported 99th between the true 98.8th and 99.2th.
+// Min, max, mean, stddev, sum and count are always exact.
+func newSampledStatGroup(size, reservoirSize uint64) *statGroup {
+	if size > reservoirSize {
+		size = reservoirSize
+	}
+	sg := newStatGroup(size)
+	sg.reservoirSize = reservoirSize
+	sg.rand = rand.New(rand.NewSource(reservoirSeed))
+	return sg
+}
+
 // median returns the median value of the StatGroup
 func (s *statGroup) median() float64 {
-	sort.Float64s(s.values[:s.count])
-	if s.count == 0 {
+	sort.Float64s(s.values)
+	n := len(s.values)
+	if n == 0 {
 		return 0
-	} else if s.count%2 == 0 {
-		idx := s.count / 2
+	} else if n%2 == 0 {
+		idx := n / 2
 		return (s.values[idx] + s.values[idx-1]) / 2.0
 	} else {
-		return s.values[s.count/2]
+		return s.values[n/2]
+	}
+}
+
+// percentile returns the p-th percentile (0 < p <= 100) of the values in the
+// StatGroup, using the nearest-rank method
+func (s *statGroup) percentile(p float64) float64 {
+	n := len(s.values)
+	if n == 0 {
+		return 0
+	}
+	sort.Float64s(s.values)
+	rank := int(math.Ceil(p / 100 * float64(n)))
+	if rank < 1 {
+		rank = 1
+	} else if rank > n {
+		rank = n
+	}
+	return s.values[rank-1]
+}
+
+// keep stores n, the count-th pushed value, in values or, once the reservoir
+// is full, replaces a random kept value with it with probability
+// reservoirSize/count (Vitter's Algorithm R)
+func (s *statGroup) keep(n float64) {
+	if s.reservoirSize == 0 || uint64(len(s.values)) < s.reservoirSize {
+		s.values = append(s.values, n)
+		return
+	}
+	if j := s.rand.Int63n(s.count); uint64(j) < s.reservoirSize {
+		s.values[j] = n
 	}
 }
 
@@ -104,11 +165,7 @@ func (s *statGroup) push(n float64) {
 		s.m = n
 		s.s = 0.0
 		s.stdDev = 0.0
-		if len(s.values) > 0 {
-			s.values[0] = n
-		} else {
-			s.values = append(s.values, n)
-		}
+		s.keep(n)
 		return
 	}
 
@@ -124,13 +181,9 @@ func (s *statGroup) push(n float64) {
 	// constant-space mean update:
 	sum := s.mean*float64(s.count) + n
 	s.mean = sum / float64(s.count+1)
-	if int(s.count) == len(s.values) {
-		s.values = append(s.values, n)
-	} else {
-		s.values[s.count] = n
-	}
 
 	s.count++
+	s.keep(n)
 
 	oldM := s.m
 	s.m += (n - oldM) / float64(s.count)
@@ -179,3 +232,33 @@ func writeStatGroupMap(w io.Writer, statGroups map[string]*statGroup) error {
 	}
 	return nil
 }
+
+// writeQPS writes the throughput of each StatGroup over a run that took took,
+// ordered by the key that they are stored by
+func writeQPS(w io.Writer, statGroups map[string]*statGroup, took time.Duration) error {
+	maxKeyLength := 0
+	keys := make([]string, 0, len(statGroups))
+	for k := range statGroups {
+		if len(k) > maxKeyLength {
+			maxKeyLength = len(k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	_, err := fmt.Fprintf(w, "throughput over %0.3fsec:\n", took.Seconds())
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		qps := 0.0
+		if took > 0 {
+			qps = float64(statGroups[k].count) / took.Seconds()
+		}
+		_, err = fmt.Fprintf(w, "%-*s: %0.2f queries/sec\n", maxKeyLength, k, qps)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
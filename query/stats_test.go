@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
+	"math/rand"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetPartialStat(t *testing.T) {
@@ -148,6 +151,33 @@ func TestStatGroupMedian0InitialSize(t *testing.T) {
 	}
 }
 
+func TestStatGroupPercentile(t *testing.T) {
+	sg := newStatGroup(0)
+	if got := sg.percentile(50); got != 0 {
+		t.Errorf("got: %v want: %v for empty group\n", got, 0)
+	}
+	// push 100..1 so values are not already sorted
+	for i := 100; i > 0; i-- {
+		sg.push(float64(i))
+	}
+	cases := []struct {
+		p    float64
+		want float64
+	}{
+		{p: 0, want: 1},
+		{p: 1, want: 1},
+		{p: 50, want: 50},
+		{p: 90, want: 90},
+		{p: 99.5, want: 100},
+		{p: 100, want: 100},
+	}
+	for _, c := range cases {
+		if got := sg.percentile(c.p); got != c.want {
+			t.Errorf("p%v: got: %v want: %v\n", c.p, got, c.want)
+		}
+	}
+}
+
 func TestStatGroupPush(t *testing.T) {
 	cases := []struct {
 		desc      string
@@ -375,3 +405,81 @@ func TestWriteStatGroupMap(t *testing.T) {
 		}
 	}
 }
+
+func TestWriteQPS(t *testing.T) {
+	short := newStatGroup(0)
+	for i := 0; i < 10; i++ {
+		short.push(1.0)
+	}
+	long := newStatGroup(0)
+	for i := 0; i < 5; i++ {
+		long.push(1.0)
+	}
+	m := map[string]*statGroup{"b": short, "aaa": long}
+
+	var b bytes.Buffer
+	if err := writeQPS(&b, m, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	want := "throughput over 2.000sec:\naaa: 2.50 queries/sec\nb  : 5.00 queries/sec\n"
+	if got := b.String(); got != want {
+		t.Errorf("incorrect output\ngot %s\nwant %s", got, want)
+	}
+
+	b.Reset()
+	if err := writeQPS(&b, m, 0); err != nil {
+		t.Fatal(err)
+	}
+	want = "throughput over 0.000sec:\naaa: 0.00 queries/sec\nb  : 0.00 queries/sec\n"
+	if got := b.String(); got != want {
+		t.Errorf("incorrect output for zero duration\ngot %s\nwant %s", got, want)
+	}
+}
+
+func TestSampledStatGroup(t *testing.T) {
+	// fewer values than the reservoir: all of them are kept
+	sg := newSampledStatGroup(1000, 100)
+	if cap(sg.values) != 100 {
+		t.Errorf("incorrect initial capacity: got %d want %d", cap(sg.values), 100)
+	}
+	for i := 1; i <= 51; i++ {
+		sg.push(float64(i))
+	}
+	if got := sg.median(); got != 26 {
+		t.Errorf("incorrect median below reservoir size: got %v want %v", got, 26)
+	}
+
+	// many more values than the reservoir, pushed in a shuffled order
+	const n = 100000
+	const size = 10000
+	sg = newSampledStatGroup(0, size)
+	r := rand.New(rand.NewSource(42))
+	for _, i := range r.Perm(n) {
+		sg.push(float64(i + 1))
+	}
+	if len(sg.values) != size {
+		t.Errorf("incorrect number of kept values: got %d want %d", len(sg.values), size)
+	}
+	if sg.count != n || sg.min != 1 || sg.max != n || math.Abs(sg.mean-(n+1)/2.0) > 1e-6 {
+		t.Errorf("streaming stats not exact: count %d min %v max %v mean %v", sg.count, sg.min, sg.max, sg.mean)
+	}
+	// documented error: within 2*sqrt(p*(1-p)/size) of the true percentile
+	cases := []struct {
+		p    float64
+		got  float64
+		desc string
+	}{
+		{p: 50, got: sg.median(), desc: "median"},
+		{p: 50, got: sg.percentile(50), desc: "p50"},
+		{p: 90, got: sg.percentile(90), desc: "p90"},
+		{p: 99, got: sg.percentile(99), desc: "p99"},
+	}
+	for _, c := range cases {
+		frac := c.p / 100
+		e := 2 * math.Sqrt(frac*(1-frac)/size)
+		lo, hi := (frac-e)*n, (frac+e)*n
+		if c.got < lo || c.got > hi {
+			t.Errorf("%s out of documented error: got %v want between %v and %v", c.desc, c.got, lo, hi)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package query
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const errSweepNoFileMsg = "--sweep-workers requires --file, since the queries are read once per step"
+
+// sweepPercentiles are the latency percentiles reported for each sweep step
+var sweepPercentiles = []float64{50, 90, 95, 99}
+
+// sweepLatency summarizes the latencies of one label in a sweep step
+type sweepLatency struct {
+	Count       int64              `json:"count"`
+	Min         float64            `json:"min_ms"`
+	Mean        float64            `json:"mean_ms"`
+	Max         float64            `json:"max_ms"`
+	Percentiles map[string]float64 `json:"percentiles_ms"`
+}
+
+// sweepStep holds the results of running the queries with a fixed number of workers
+type sweepStep struct {
+	Workers   uint                     `json:"workers"`
+	WallClock float64                  `json:"wall_clock_sec"`
+	Queries   int64                    `json:"queries"`
+	QPS       float64                  `json:"queries_per_sec"`
+	Latencies map[string]*sweepLatency `json:"latencies"`
+}
+
+// newSweepStep summarizes the stats of a run with workers workers that took took
+func newSweepStep(workers uint, took time.Duration, statGroups map[string]*statGroup) *sweepStep {
+	step := &sweepStep{
+		Workers:   workers,
+		WallClock: took.Seconds(),
+		Latencies: make(map[string]*sweepLatency),
+	}
+	for label, sg := range statGroups {
+		l := &sweepLatency{
+			Count:       sg.count,
+			Min:         sg.min,
+			Mean:        sg.mean,
+			Max:         sg.max,
+			Percentiles: make(map[string]float64),
+		}
+		for _, p := range sweepPercentiles {
+			l.Percentiles[percentileKey(p)] = sg.percentile(p)
+		}
+		step.Latencies[label] = l
+	}
+	if all, ok := statGroups[labelAllQueries]; ok {
+		step.Queries = all.count
+		if took > 0 {
+			step.QPS = float64(all.count) / took.Seconds()
+		}
+	}
+	return step
+}
+
+// percentileKey returns the name of percentile p in results, e.g. p99
+func percentileKey(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+// parseSweepWorkers parses a comma-separated list of worker counts, e.g. 1,2,4,8
+func parseSweepWorkers(s string) ([]uint, error) {
+	var ret []uint
+	for _, part := range strings.Split(s, ",") {
+		w, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number of workers %q: %v", part, err)
+		}
+		if w == 0 {
+			return nil, fmt.Errorf("number of workers must be greater than 0")
+		}
+		ret = append(ret, uint(w))
+	}
+	return ret, nil
+}
+
+// sweep runs the queries in --file once for each number of workers in
+// --sweep-workers, optionally limiting each step to --sweep-step-duration,
+// and prints how throughput and latency change with the number of workers.
+func (b *BenchmarkRunner) sweep(ctx context.Context, workers []uint, queryPool *sync.Pool, processorCreateFn ProcessorCreate) {
+	if len(b.fileName) == 0 {
+		panic(errSweepNoFileMsg)
+	}
+
+	steps := make([]*sweepStep, 0, len(workers))
+	for _, w := range workers {
+		if ctx.Err() != nil {
+			break
+		}
+		file, err := os.Open(b.fileName)
+		if err != nil {
+			panic(fmt.Sprintf("cannot open file for read %s: %v", b.fileName, err))
+		}
+		b.br = bufio.NewReaderSize(file, defaultReadSize)
+
+		stepCtx, cancel := ctx, context.CancelFunc(func() {})
+		if b.sweepStepDuration > 0 {
+			stepCtx, cancel = context.WithTimeout(ctx, b.sweepStepDuration)
+		}
+		took := b.runWorkers(ctx, stepCtx, w, queryPool, processorCreateFn)
+		cancel()
+		file.Close()
+
+		steps = append(steps, newSweepStep(w, took, b.sp.statGroups()))
+	}
+
+	if err := writeSweepSummary(os.Stdout, steps); err != nil {
+		panic(err)
+	}
+	if len(b.sweepResultsFile) > 0 {
+		if err := writeSweepResults(b.sweepResultsFile, steps); err != nil {
+			panic(fmt.Sprintf("cannot write sweep results to %s: %v", b.sweepResultsFile, err))
+		}
+	}
+}
+
+// writeSweepSummary writes a table of throughput and latency of all queries
+// per number of workers
+func writeSweepSummary(w io.Writer, steps []*sweepStep) error {
+	header := "workers,queries,wall clock (sec),queries/sec"
+	for _, p := range sweepPercentiles {
+		header += fmt.Sprintf(",%s (ms)", percentileKey(p))
+	}
+	if _, err := fmt.Fprintf(w, "\nConcurrency sweep:\n%s\n", header); err != nil {
+		return err
+	}
+	for _, s := range steps {
+		line := fmt.Sprintf("%d,%d,%0.3f,%0.2f", s.Workers, s.Queries, s.WallClock, s.QPS)
+		all := s.Latencies[labelAllQueries]
+		for _, p := range sweepPercentiles {
+			v := 0.0
+			if all != nil {
+				v = all.Percentiles[percentileKey(p)]
+			}
+			line += fmt.Sprintf(",%0.2f", v)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSweepResults writes the results of all steps as a JSON document
+func writeSweepResults(fileName string, steps []*sweepStep) error {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(struct {
+		Steps []*sweepStep `json:"steps"`
+	}{steps}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,171 @@
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseSweepWorkers(t *testing.T) {
+	cases := []struct {
+		in      string
+		want    []uint
+		wantErr bool
+	}{
+		{in: "1", want: []uint{1}},
+		{in: "1,2,4,8,16", want: []uint{1, 2, 4, 8, 16}},
+		{in: "1, 3", want: []uint{1, 3}},
+		{in: "1,0", wantErr: true},
+		{in: "1,a", wantErr: true},
+		{in: "1,-2", wantErr: true},
+		{in: "1,,2", wantErr: true},
+	}
+	for _, c := range cases {
+		got, err := parseSweepWorkers(c.in)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("%s: expected error", c.in)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.in, err)
+		} else if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v want %v", c.in, got, c.want)
+		}
+	}
+}
+
+func TestNewSweepStep(t *testing.T) {
+	all := newStatGroup(0)
+	for i := 1; i <= 100; i++ {
+		all.push(float64(i))
+	}
+	step := newSweepStep(4, 2*time.Second, map[string]*statGroup{labelAllQueries: all})
+	if step.Workers != 4 || step.WallClock != 2 || step.Queries != 100 || step.QPS != 50 {
+		t.Errorf("incorrect step: %+v", step)
+	}
+	l := step.Latencies[labelAllQueries]
+	if l == nil {
+		t.Fatalf("missing latencies for %s", labelAllQueries)
+	}
+	if l.Count != 100 || l.Min != 1 || l.Max != 100 || l.Mean != 50.5 {
+		t.Errorf("incorrect latency summary: %+v", l)
+	}
+	want := map[string]float64{"p50": 50, "p90": 90, "p95": 95, "p99": 99}
+	if !reflect.DeepEqual(l.Percentiles, want) {
+		t.Errorf("incorrect percentiles: got %v want %v", l.Percentiles, want)
+	}
+}
+
+func TestWriteSweepSummary(t *testing.T) {
+	steps := []*sweepStep{
+		{
+			Workers:   1,
+			WallClock: 2,
+			Queries:   10,
+			QPS:       5,
+			Latencies: map[string]*sweepLatency{
+				labelAllQueries: {Percentiles: map[string]float64{"p50": 1, "p90": 2, "p95": 3, "p99": 4}},
+			},
+		},
+		{Workers: 2, WallClock: 1, Queries: 10, QPS: 10},
+	}
+	var b bytes.Buffer
+	if err := writeSweepSummary(&b, steps); err != nil {
+		t.Fatal(err)
+	}
+	want := "\nConcurrency sweep:\nworkers,queries,wall clock (sec),queries/sec,p50 (ms),p90 (ms),p95 (ms),p99 (ms)\n" +
+		"1,10,2.000,5.00,1.00,2.00,3.00,4.00\n" +
+		"2,10,1.000,10.00,0.00,0.00,0.00,0.00\n"
+	if got := b.String(); got != want {
+		t.Errorf("incorrect summary\ngot %s\nwant %s", got, want)
+	}
+}
+
+type statTestProcessor struct{}
+
+func (p *statTestProcessor) Init(_ int) {}
+func (p *statTestProcessor) ProcessQuery(_ context.Context, q Query, _ bool) ([]*Stat, error) {
+	return []*Stat{GetStat().Init(q.HumanLabelName(), 1.0)}, nil
+}
+
+func TestBenchmarkRunnerSweep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sweep")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	numQueries := uint64(20)
+	var buf bytes.Buffer
+	err = encodeQueries(&buf, numQueries, func(i uint64) Query {
+		q := testQueryPool.Get().(*testQuery)
+		q.HumanLabel = []byte("label")
+		return q
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	queryFile := filepath.Join(dir, "queries")
+	if err := ioutil.WriteFile(queryFile, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	limit := uint64(0)
+	b := &BenchmarkRunner{
+		fileName:         queryFile,
+		sweepWorkers:     "1,3",
+		sweepResultsFile: filepath.Join(dir, "results.json"),
+		scanner:          newScanner(&limit),
+		sp:               newStatProcessor(&statProcessorArgs{limit: &limit}),
+	}
+	b.RunContext(context.Background(), &testQueryPool, func() Processor { return &statTestProcessor{} })
+
+	data, err := ioutil.ReadFile(b.sweepResultsFile)
+	if err != nil {
+		t.Fatalf("could not read results: %v", err)
+	}
+	var results struct {
+		Steps []*sweepStep `json:"steps"`
+	}
+	if err := json.Unmarshal(data, &results); err != nil {
+		t.Fatalf("could not parse results: %v", err)
+	}
+	if len(results.Steps) != 2 {
+		t.Fatalf("incorrect number of steps: got %d want %d", len(results.Steps), 2)
+	}
+	for i, w := range []uint{1, 3} {
+		s := results.Steps[i]
+		if s.Workers != w {
+			t.Errorf("step %d: incorrect workers: got %d want %d", i, s.Workers, w)
+		}
+		if s.Queries != int64(numQueries) {
+			t.Errorf("step %d: incorrect queries: got %d want %d", i, s.Queries, numQueries)
+		}
+		if l := s.Latencies["label"]; l == nil || l.Count != int64(numQueries) {
+			t.Errorf("step %d: incorrect latencies for label: %+v", i, l)
+		}
+	}
+}
+
+func TestBenchmarkRunnerSweepNoFile(t *testing.T) {
+	limit := uint64(0)
+	b := &BenchmarkRunner{
+		sweepWorkers: "1,2",
+		sp:           newStatProcessor(&statProcessorArgs{limit: &limit}),
+	}
+	defer func() {
+		if r := recover(); r != errSweepNoFileMsg {
+			t.Errorf("wrong panic: got %v want %s", r, errSweepNoFileMsg)
+		}
+	}()
+	b.RunContext(context.Background(), nil, nil)
+	t.Errorf("the code did not panic")
+}
@@ -13,6 +13,7 @@ type TimescaleDB struct {
 
 	Hypertable []byte // e.g. "cpu"
 	SqlQuery   []byte
+	Params     []interface{} // values bound to the placeholders of SqlQuery, if any
 	id         uint64
 }
 
@@ -45,7 +46,11 @@ func (q *TimescaleDB) SetID(n uint64) {
 
 // String produces a debug-ready description of a Query.
 func (q *TimescaleDB) String() string {
-	return fmt.Sprintf("HumanLabel: %s, HumanDescription: %s, Hypertable: %s, Query: %s", q.HumanLabel, q.HumanDescription, q.Hypertable, q.SqlQuery)
+	s := fmt.Sprintf("HumanLabel: %s, HumanDescription: %s, Hypertable: %s, Query: %s", q.HumanLabel, q.HumanDescription, q.Hypertable, q.SqlQuery)
+	if len(q.Params) > 0 {
+		s += fmt.Sprintf(", Params: %v", q.Params)
+	}
+	return s
 }
 
 // HumanLabelName returns the human readable name of this Query
@@ -66,6 +71,7 @@ func (q *TimescaleDB) Release() {
 
 	q.Hypertable = q.Hypertable[:0]
 	q.SqlQuery = q.SqlQuery[:0]
+	q.Params = nil
 
 	TimescaleDBPool.Put(q)
 }
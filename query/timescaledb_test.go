@@ -1,6 +1,11 @@
 package query
 
-import "testing"
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+)
 
 func TestNewTimescaleDB(t *testing.T) {
 	check := func(tq *TimescaleDB) {
@@ -11,13 +16,17 @@ func TestNewTimescaleDB(t *testing.T) {
 		if got := len(tq.SqlQuery); got != 0 {
 			t.Errorf("new query has non-0 sql query: got %d", got)
 		}
+		if got := len(tq.Params); got != 0 {
+			t.Errorf("new query has non-0 params: got %d", got)
+		}
 	}
 	tq := NewTimescaleDB()
 	check(tq)
 	tq.HumanLabel = []byte("foo")
 	tq.HumanDescription = []byte("bar")
 	tq.Hypertable = []byte("table")
-	tq.SqlQuery = []byte("SELECT * FROM *")
+	tq.SqlQuery = []byte("SELECT * FROM * WHERE a = $1")
+	tq.Params = []interface{}{"b"}
 	tq.SetID(1)
 	if got := string(tq.HumanLabelName()); got != "foo" {
 		t.Errorf("incorrect label name: got %s", got)
@@ -40,3 +49,27 @@ func TestTimescaleDBSetAndGetID(t *testing.T) {
 		q.Release()
 	}
 }
+
+func TestTimescaleDBParamsEncoding(t *testing.T) {
+	q := NewTimescaleDB()
+	defer q.Release()
+	q.SqlQuery = []byte("SELECT * FROM cpu WHERE hostname = $1 AND time >= $2")
+	q.Params = []interface{}{"host_1", int64(1451606400000)}
+
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(q); err != nil {
+		t.Fatalf("could not encode: %v", err)
+	}
+	got := &TimescaleDB{}
+	if err := gob.NewDecoder(&b).Decode(got); err != nil {
+		t.Fatalf("could not decode: %v", err)
+	}
+	if !reflect.DeepEqual(got.Params, q.Params) {
+		t.Errorf("incorrect params: got %v want %v", got.Params, q.Params)
+	}
+
+	want := "HumanLabel: , HumanDescription: , Hypertable: , Query: SELECT * FROM cpu WHERE hostname = $1 AND time >= $2, Params: [host_1 1451606400000]"
+	if got := q.String(); got != want {
+		t.Errorf("incorrect string: got %s want %s", got, want)
+	}
+}
@@ -17,6 +17,7 @@ PARTITIONS=${PARTITIONS:-1}
 HASH_WORKERS=${HASH_WORKERS:-false}
 TIME_PARTITION_INDEX=${TIME_PARTITION_INDEX:-false}
 PERF_OUTPUT=${PERF_OUTPUT:-}
+SERVER_PROCESS=${SERVER_PROCESS:-^postgres}
 JSON_TAGS=${JSON_TAGS:-false}
 IN_TABLE_PARTITION_TAG=${IN_TABLE_PARTITION_TAG:-true}
 USE_HYPERTABLE=${USE_HYPERTABLE:-true}
@@ -26,6 +27,12 @@ FORCE_TEXT_FORMAT=${FORCE_TEXT_FORMAT:-false}
 EXE_DIR=${EXE_DIR:-$(dirname $0)}
 source ${EXE_DIR}/load_common.sh
 
+# Sample the resource usage of the local server processes into PERF_OUTPUT, if set
+SERVER_USAGE_ARGS=()
+if [[ -n "$PERF_OUTPUT" ]]; then
+    SERVER_USAGE_ARGS=(--server-usage-file="${PERF_OUTPUT}" --server-process="${SERVER_PROCESS}")
+fi
+
 while ! pg_isready -h ${DATABASE_HOST}; do
     echo "Waiting for timescaledb"
     sleep 1
@@ -46,7 +53,7 @@ cat ${DATA_FILE} | gunzip | $EXE_FILE_NAME \
                                 --time-partition-index=${TIME_PARTITION_INDEX} \
                                 --partitions=${PARTITIONS} \
                                 --chunk-time=${CHUNK_TIME} \
-                                --write-profile=${PERF_OUTPUT} \
+                                "${SERVER_USAGE_ARGS[@]}" \
                                 --field-index-count=1 \
                                 --do-create-db=${DO_CREATE_DB} \
                                 --force-text-format=${FORCE_TEXT_FORMAT}